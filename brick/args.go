@@ -0,0 +1,110 @@
+package brick
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// validateBrickArg checks the value of an extra argument of the brick
+// process. Flags without a value are given an empty one.
+type validateBrickArg func(value string) error
+
+func noValue(value string) error {
+	if value != "" {
+		return fmt.Errorf("takes no value")
+	}
+	return nil
+}
+
+func oneOf(values ...string) validateBrickArg {
+	return func(value string) error {
+		for _, v := range values {
+			if value == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(values, ", "))
+	}
+}
+
+func positiveInt(value string) error {
+	if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+		return fmt.Errorf("must be a positive integer")
+	}
+	return nil
+}
+
+// allowedXlatorOptions are the translator options bricks can be started with,
+// by translator type. They only tune the performance and diagnostics of the
+// brick. Options on authentication, SSL, the brick directory and the ones
+// glusterd2 sets itself aren't listed.
+var allowedXlatorOptions = map[string][]string{
+	"posix": {"health-check-interval", "health-check-timeout",
+		"batch-fsync-mode", "batch-fsync-delay-usec"},
+	"io-threads": {"thread-count", "high-prio-threads", "normal-prio-threads",
+		"low-prio-threads", "least-prio-threads", "idle-time"},
+	"server":   {"event-threads", "outstanding-rpc-limit"},
+	"upcall":   {"cache-invalidation-timeout"},
+	"io-stats": {"latency-measurement", "count-fop-hits"},
+	"locks":    {"trace"},
+}
+
+// xlatorOption checks an --xlator-option of the form <xlator>.<key>=<value>,
+// where <xlator> is the name of a translator of the brick graph, like
+// <volume>-io-threads, or *-io-threads for all the bricks of the process.
+func xlatorOption(value string) error {
+	kv := strings.SplitN(value, "=", 2)
+	i := strings.LastIndex(kv[0], ".")
+	if len(kv) != 2 || kv[1] == "" || i <= 0 {
+		return fmt.Errorf("must be <xlator>.<option>=<value>")
+	}
+	name, key := kv[0][:i], kv[0][i+1:]
+	for xlator, keys := range allowedXlatorOptions {
+		if !strings.HasSuffix(name, "-"+xlator) {
+			continue
+		}
+		for _, k := range keys {
+			if key == k {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("option %s is not allowed", kv[0])
+}
+
+// allowedBrickArgs are the extra arguments a brick process can be started
+// with. Arguments which change how the process runs, like --debug or
+// --no-daemon, or where it logs and listens, aren't allowed.
+var allowedBrickArgs = map[string]validateBrickArg{
+	"--log-level":          oneOf("TRACE", "DEBUG", "INFO", "WARNING", "ERROR", "CRITICAL", "NONE"),
+	"--log-buf-size":       positiveInt,
+	"--log-flush-timeout":  positiveInt,
+	"--mem-accounting":     noValue,
+	"--global-timer-wheel": noValue,
+	"--event-history":      oneOf("on", "off"),
+	"--xlator-option":      xlatorOption,
+}
+
+// ValidateExtraArgs checks that the extra arguments of the brick processes of
+// a volume are allowed. Arguments are given as --flag or --flag=value.
+func ValidateExtraArgs(args []string) error {
+	for _, arg := range args {
+		if strings.ContainsAny(arg, " \t\n") {
+			return fmt.Errorf("invalid brick argument %q", arg)
+		}
+		kv := strings.SplitN(arg, "=", 2)
+		validate, ok := allowedBrickArgs[kv[0]]
+		if !ok {
+			return fmt.Errorf("brick argument %s is not allowed", kv[0])
+		}
+		var value string
+		if len(kv) == 2 {
+			value = kv[1]
+		}
+		if err := validate(value); err != nil {
+			return fmt.Errorf("invalid brick argument %s: %s", arg, err.Error())
+		}
+	}
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/gluster/glusterd2/daemon"
 	"github.com/gluster/glusterd2/gdctx"
 	"github.com/gluster/glusterd2/pmap"
 	"github.com/gluster/glusterd2/utils"
@@ -31,6 +32,8 @@ type Glusterfsd struct {
 
 	// For internal use
 	brickinfo Brickinfo
+	limits    daemon.Limits
+	extraArgs []string
 }
 
 // Name returns human-friendly name of the brick process. This is used for logging.
@@ -48,8 +51,6 @@ func (b *Glusterfsd) Args() string {
 
 	brickPathWithoutSlashes := strings.Trim(strings.Replace(b.brickinfo.Path, "/", "-", -1), "-")
 
-	logFile := path.Join(config.GetString("logdir"), "glusterfs", "bricks", fmt.Sprintf("%s.log", brickPathWithoutSlashes))
-
 	brickPort := strconv.Itoa(pmap.AssignPort(0, b.brickinfo.Path))
 
 	volFileID := b.brickinfo.VolumeName + "." + gdctx.MyUUID.String() + "." + brickPathWithoutSlashes
@@ -67,9 +68,12 @@ func (b *Glusterfsd) Args() string {
 	buffer.WriteString(fmt.Sprintf(" -S %s", b.SocketFile()))
 	buffer.WriteString(fmt.Sprintf(" --brick-name %s", b.brickinfo.Path))
 	buffer.WriteString(fmt.Sprintf(" --brick-port %s", brickPort))
-	buffer.WriteString(fmt.Sprintf(" -l %s", logFile))
+	buffer.WriteString(fmt.Sprintf(" -l %s", b.LogFile()))
 	buffer.WriteString(fmt.Sprintf(" --xlator-option *-posix.glusterd-uuid=%s", gdctx.MyUUID))
 	buffer.WriteString(fmt.Sprintf(" --xlator-option %s-server.transport.socket.listen-port=%s", b.brickinfo.VolumeName, brickPort))
+	for _, arg := range b.extraArgs {
+		buffer.WriteString(" " + arg)
+	}
 
 	b.args = buffer.String()
 	return b.args
@@ -100,6 +104,12 @@ func (b *Glusterfsd) SocketFile() string {
 	return b.socketfilepath
 }
 
+// LogFile returns path to the log file of the brick process
+func (b *Glusterfsd) LogFile() string {
+	brickPathWithoutSlashes := strings.Trim(strings.Replace(b.brickinfo.Path, "/", "-", -1), "-")
+	return path.Join(config.GetString("logdir"), "glusterfs", "bricks", fmt.Sprintf("%s.log", brickPathWithoutSlashes))
+}
+
 // PidFile returns path to the pid file of the brick process
 func (b *Glusterfsd) PidFile() string {
 
@@ -130,3 +140,31 @@ func NewGlusterfsd(binfo Brickinfo) (*Glusterfsd, error) {
 func (b *Glusterfsd) ID() string {
 	return b.brickinfo.Path
 }
+
+// Limits returns the resource limits of the brick process
+func (b *Glusterfsd) Limits() daemon.Limits {
+	return b.limits
+}
+
+// SetLimits sets the resource limits to be applied to the brick process when
+// it is started
+func (b *Glusterfsd) SetLimits(l daemon.Limits) {
+	b.limits = l
+}
+
+// SetExtraArgs sets the extra arguments, validated by ValidateExtraArgs, the
+// brick process is started with
+func (b *Glusterfsd) SetExtraArgs(args []string) {
+	b.extraArgs = args
+}
+
+// DefaultLimits returns the resource limits configured for the brick
+// processes of this node. They apply to the bricks of volumes which don't
+// have limits of their own.
+func DefaultLimits() daemon.Limits {
+	return daemon.Limits{
+		MemoryMB:   uint64(config.GetInt("brick-memory-limit")),
+		CPUPercent: uint64(config.GetInt("brick-cpu-limit")),
+		OpenFiles:  uint64(config.GetInt("brick-open-files-limit")),
+	}
+}
@@ -0,0 +1,25 @@
+package brick
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"strings"
+)
+
+// BrickID returns the stable identifier of a brick, used to address bricks
+// in the REST API. It is derived as follows, so that clients can compute it
+// from the host and path of a brick as listed in the volume info:
+//
+//	hex(sha256(lowercase(host) + ":" + path.Clean(path))[:16])
+//
+// The result is 32 lowercase hex characters, and is safe to use in URLs.
+func BrickID(host, brickPath string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(host) + ":" + path.Clean(brickPath)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ID returns the BrickID of the brick
+func (b *Brickinfo) ID() string {
+	return BrickID(b.Hostname, b.Path)
+}
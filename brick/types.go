@@ -1,6 +1,8 @@
 package brick
 
 import (
+	"github.com/gluster/glusterd2/daemon"
+
 	"github.com/pborman/uuid"
 )
 
@@ -11,14 +13,39 @@ type Brickinfo struct {
 	Path       string
 	VolumeName string
 	VolumeID   uuid.UUID
+	// DeviceID is the ID of the device the brick was on when it was
+	// created, 0 if it wasn't recorded
+	DeviceID int
+}
+
+// InodeUsage is the inode usage of the filesystem of a brick
+type InodeUsage struct {
+	Total uint64
+	Free  uint64
+	Used  uint64
 }
 
 // Brickstatus represents real-time status of the brick and contains dynamic
 // information about the brick
 type Brickstatus struct {
+	// ID is the BrickID of the brick
+	ID     string
 	BInfo  Brickinfo
 	Online bool
 	Pid    int
 	Port   int
+	// StorageAddress is the address clients reach the brick on
+	StorageAddress string
+	// Limits are the resource limits applied to the brick process
+	Limits daemon.Limits
+	// ExtraArgs are the extra arguments the brick process is started with
+	ExtraArgs []string `json:",omitempty"`
+	// ReadOnlyFS is true if the filesystem of the brick is read-only, as
+	// happens after I/O errors. Such bricks are left stopped by a volume
+	// start with the start-degraded read-only policy.
+	ReadOnlyFS bool
+	// Inodes is the inode usage of the filesystem of the brick, missing if
+	// the filesystem doesn't report it
+	Inodes *InodeUsage `json:",omitempty"`
 	// TODO: Add other fields like filesystem type, statvfs output etc.
 }
@@ -0,0 +1,70 @@
+// Package authcommands implements the commands issuing REST API tokens and
+// managing the roles of the users they are issued to
+package authcommands
+
+import (
+	"net/http"
+
+	"github.com/gluster/glusterd2/middleware"
+	"github.com/gluster/glusterd2/servers/rest/route"
+)
+
+// authTokenRateLimit limits the token requests, stricter than the other
+// routes, so that the secret can't be guessed quickly
+var authTokenRateLimit = middleware.RateLimit{Rate: 1, Burst: 5}
+
+// Command is a holding struct used to implement the GlusterD Command interface
+type Command struct {
+}
+
+// Routes returns command routes. Required for the Command interface.
+func (c *Command) Routes() route.Routes {
+	return route.Routes{
+		route.Route{
+			Name:        "AuthToken",
+			Method:      "POST",
+			Pattern:     "/auth/token",
+			Version:     1,
+			HandlerFunc: authTokenHandler,
+			Middleware: []func(http.Handler) http.Handler{
+				middleware.RouteRateLimit("AuthToken", authTokenRateLimit),
+			},
+			RequestType:    TokenReq{},
+			ResponseType:   TokenResp{},
+			ResponseStatus: http.StatusCreated,
+		},
+		route.Route{
+			Name:         "UserRoles",
+			Method:       "GET",
+			Pattern:      "/auth/roles",
+			Version:      1,
+			HandlerFunc:  userRolesHandler,
+			ResponseType: map[string]string{},
+			Role:         route.RoleAdmin,
+		},
+		route.Route{
+			Name:         "SetUserRole",
+			Method:       "PUT",
+			Pattern:      "/auth/roles/{user}",
+			Version:      1,
+			HandlerFunc:  userRoleSetHandler,
+			RequestType:  UserRoleReq{},
+			ResponseType: map[string]string{},
+			Role:         route.RoleAdmin,
+		},
+		route.Route{
+			Name:           "DeleteUserRole",
+			Method:         "DELETE",
+			Pattern:        "/auth/roles/{user}",
+			Version:        1,
+			HandlerFunc:    userRoleDeleteHandler,
+			ResponseStatus: http.StatusNoContent,
+			Role:           route.RoleAdmin,
+		},
+	}
+}
+
+// RegisterStepFuncs implements a required function for the Command interface
+func (c *Command) RegisterStepFuncs() {
+	return
+}
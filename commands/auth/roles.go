@@ -0,0 +1,81 @@
+package authcommands
+
+import (
+	goerrors "errors"
+	"net/http"
+
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/middleware"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/utils"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+var errUserHasNoRole = goerrors.New("user has no role")
+
+// UserRoleReq gives a user a role, one of admin, operator or viewer
+type UserRoleReq struct {
+	Role string `json:"role"`
+}
+
+func userRolesHandler(w http.ResponseWriter, r *http.Request) {
+	roles, err := middleware.GetUserRoles()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	restutils.SendHTTPResponse(w, http.StatusOK, roles)
+}
+
+func userRoleSetHandler(w http.ResponseWriter, r *http.Request) {
+	user := mux.Vars(r)["user"]
+	_, logger := restutils.GetReqIDandLogger(r)
+
+	var req UserRoleReq
+	if err := utils.GetJSONFromRequest(r, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, errors.ErrJSONParsingFailed.Error())
+		return
+	}
+	if err := middleware.ValidateRole(req.Role); err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	roles, err := middleware.UpdateUserRoles(func(roles map[string]string) error {
+		roles[user] = req.Role
+		return nil
+	})
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logger.WithFields(log.Fields{"user": user, "role": req.Role}).Info("user role changed")
+	restutils.SendHTTPResponse(w, http.StatusOK, roles)
+}
+
+func userRoleDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	user := mux.Vars(r)["user"]
+	_, logger := restutils.GetReqIDandLogger(r)
+
+	_, err := middleware.UpdateUserRoles(func(roles map[string]string) error {
+		if _, ok := roles[user]; !ok {
+			return errUserHasNoRole
+		}
+		delete(roles, user)
+		return nil
+	})
+	if err == errUserHasNoRole {
+		restutils.SendHTTPError(w, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Tokens of the user are refused from now on
+	logger.WithField("user", user).Info("user role removed")
+	restutils.SendHTTPResponse(w, http.StatusNoContent, nil)
+}
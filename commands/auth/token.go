@@ -0,0 +1,60 @@
+package authcommands
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/middleware"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/utils"
+)
+
+// TokenReq requests a token for the secret of the cluster, found in the
+// auth-secret file of localstatedir on every node. The token is for User,
+// who has the role given to the user, or is an admin token if no user is
+// given. TTL is a duration like 30m, capped at rest-auth-token-max-ttl, which
+// is also the default.
+type TokenReq struct {
+	Secret string `json:"secret"`
+	User   string `json:"user,omitempty"`
+	TTL    string `json:"ttl,omitempty"`
+}
+
+// TokenResp is a token to be sent as an Authorization: Bearer header
+type TokenResp struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires-at"`
+}
+
+func authTokenHandler(w http.ResponseWriter, r *http.Request) {
+	_, logger := restutils.GetReqIDandLogger(r)
+
+	var req TokenReq
+	if err := utils.GetJSONFromRequest(r, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, errors.ErrJSONParsingFailed.Error())
+		return
+	}
+
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+			restutils.SendHTTPError(w, http.StatusBadRequest, "invalid ttl specified")
+			return
+		}
+	}
+
+	token, expires, err := middleware.IssueToken(req.Secret, req.User, ttl)
+	switch {
+	case err == middleware.ErrInvalidAuthSecret:
+		logger.WithField("remote", r.RemoteAddr).Warn("token requested with an invalid secret")
+		restutils.SendHTTPError(w, http.StatusUnauthorized, err.Error())
+		return
+	case err != nil:
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	restutils.SendHTTPResponse(w, http.StatusCreated, TokenResp{token, expires})
+}
@@ -0,0 +1,317 @@
+package clustercommands
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/store"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/volume"
+
+	"github.com/pborman/uuid"
+)
+
+const (
+	nodeMetadataTxnKey = "nodemetadata"
+	lastCheckKey       = store.GlusterPrefix + "cluster-check"
+)
+
+// The kinds of discrepancies between the metadata of a node and the store
+const (
+	// DiscrepancyDiffers is reported for metadata whose fields differ
+	DiscrepancyDiffers = "differs"
+	// DiscrepancyMissing is reported for metadata the node doesn't have
+	DiscrepancyMissing = "missing"
+	// DiscrepancyUnexpected is reported for metadata the node has, but which
+	// isn't in the store
+	DiscrepancyUnexpected = "unexpected"
+)
+
+// nodeMetadata is the view of a node of the cluster metadata, as served by
+// its cache. ID is the ID the node identifies itself with. Only the digests
+// of the top-level fields of every volume and peer are sent, to keep the
+// result small however big the metadata is.
+type nodeMetadata struct {
+	ID      uuid.UUID                    `json:"id"`
+	Volumes map[string]map[string]string `json:"volumes"`
+	Peers   map[string]map[string]string `json:"peers"`
+}
+
+// Discrepancy is a volume or peer whose metadata on a node differs from the
+// store. Fields are the top-level fields which differ.
+type Discrepancy struct {
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	Status string   `json:"status"`
+	Fields []string `json:"fields,omitempty"`
+}
+
+// NodeCheckResult are the discrepancies found on a node. Error is set if
+// the node couldn't be checked.
+type NodeCheckResult struct {
+	Node          uuid.UUID     `json:"node"`
+	Name          string        `json:"name"`
+	Consistent    bool          `json:"consistent"`
+	Error         string        `json:"error,omitempty"`
+	Discrepancies []Discrepancy `json:"discrepancies,omitempty"`
+}
+
+// ClusterCheckResp is the result of a consistency check of the cluster.
+// Nodes which couldn't be reached aren't checked, and are listed in
+// Unreachable.
+type ClusterCheckResp struct {
+	CheckedAt   time.Time         `json:"checked-at"`
+	Consistent  bool              `json:"consistent"`
+	Nodes       []NodeCheckResult `json:"nodes"`
+	Unreachable []string          `json:"unreachable,omitempty"`
+}
+
+// digestFields returns the digests of the top-level fields of every JSON
+// object. The fields are re-encoded, so that the digests don't depend on
+// how the objects were formatted.
+func digestFields(objs map[string]json.RawMessage) (map[string]map[string]string, error) {
+	digests := make(map[string]map[string]string, len(objs))
+	for name, obj := range objs {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(obj, &fields); err != nil {
+			return nil, err
+		}
+		digests[name] = make(map[string]string, len(fields))
+		for k, v := range fields {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			sum := sha256.Sum256(b)
+			digests[name][k] = hex.EncodeToString(sum[:8])
+		}
+	}
+	return digests, nil
+}
+
+func getNodeMetadata(c transaction.TxnCtx) error {
+	md := nodeMetadata{ID: gdctx.MyUUID}
+
+	volumes, err := volume.GetVolumesMetadata(false)
+	if err != nil {
+		return err
+	}
+	if md.Volumes, err = digestFields(volumes); err != nil {
+		return err
+	}
+	peers, err := peer.GetPeersMetadata(false)
+	if err != nil {
+		return err
+	}
+	if md.Peers, err = digestFields(peers); err != nil {
+		return err
+	}
+
+	c.SetNodeResult(gdctx.MyUUID, nodeMetadataTxnKey, md)
+	return nil
+}
+
+// diffFields returns the top-level fields whose digests differ
+func diffFields(a, b map[string]string) []string {
+	var fields []string
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || v != bv {
+			fields = append(fields, k)
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// compareMetadata returns the discrepancies of the node metadata against the
+// metadata in the store, of the given kind
+func compareMetadata(kind string, node, stored map[string]map[string]string) []Discrepancy {
+	var ds []Discrepancy
+	for name, s := range stored {
+		n, ok := node[name]
+		if !ok {
+			ds = append(ds, Discrepancy{Kind: kind, Name: name, Status: DiscrepancyMissing})
+			continue
+		}
+		if fields := diffFields(s, n); len(fields) > 0 {
+			ds = append(ds, Discrepancy{Kind: kind, Name: name, Status: DiscrepancyDiffers, Fields: fields})
+		}
+	}
+	for name := range node {
+		if _, ok := stored[name]; !ok {
+			ds = append(ds, Discrepancy{Kind: kind, Name: name, Status: DiscrepancyUnexpected})
+		}
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i].Name < ds[j].Name })
+	return ds
+}
+
+// getNodesMetadata gets the metadata of every node, in a transaction of its
+// own, so that a node failing doesn't prevent the others from being checked.
+// The errors are returned by node.
+func getNodesMetadata(nodes []uuid.UUID) (map[string]nodeMetadata, map[string]error) {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		mds  = make(map[string]nodeMetadata, len(nodes))
+		errs = make(map[string]error)
+	)
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node uuid.UUID) {
+			defer wg.Done()
+
+			// Only the caches are read, so no lock is taken. Every
+			// transaction needs an ID of its own, as they run concurrently.
+			txn := transaction.NewTxn(uuid.NewRandom().String())
+			defer txn.Cleanup()
+			txn.Nodes = []uuid.UUID{node}
+			txn.Steps = []*transaction.Step{
+				{
+					DoFunc: "cluster-check.GetMetadata",
+					Nodes:  txn.Nodes,
+				},
+			}
+
+			var md nodeMetadata
+			rtxn, err := txn.Do()
+			if err == nil {
+				err = rtxn.GetNodeResult(node, nodeMetadataTxnKey, &md)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[node.String()] = err
+			} else {
+				mds[node.String()] = md
+			}
+		}(node)
+	}
+	wg.Wait()
+	return mds, errs
+}
+
+// clusterCheckHandler compares the view every reachable node has of the
+// volume and peer metadata, which is served from its cache, against the
+// store. The result is kept, to be fetched later from any node.
+func clusterCheckHandler(w http.ResponseWriter, r *http.Request) {
+	_, logger := restutils.GetReqIDandLogger(r)
+
+	peers, err := peer.GetPeers()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp := ClusterCheckResp{
+		CheckedAt:  time.Now(),
+		Consistent: true,
+		Nodes:      []NodeCheckResult{},
+	}
+	var nodes []uuid.UUID
+	names := make(map[string]string)
+	for _, p := range peers {
+		names[p.ID.String()] = p.Name
+		if peer.IsOnline(p.ID) {
+			nodes = append(nodes, p.ID)
+		} else {
+			resp.Unreachable = append(resp.Unreachable, p.Ref())
+		}
+	}
+
+	mds, errs := getNodesMetadata(nodes)
+
+	// The store is read after the nodes, so that changes made in between
+	// show up as discrepancies rather than being missed
+	var volumes, storedPeers map[string]map[string]string
+	rawVolumes, err := volume.GetVolumesMetadata(true)
+	if err == nil {
+		volumes, err = digestFields(rawVolumes)
+	}
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rawPeers, err := peer.GetPeersMetadata(true)
+	if err == nil {
+		storedPeers, err = digestFields(rawPeers)
+	}
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	for _, node := range nodes {
+		result := NodeCheckResult{Node: node, Name: names[node.String()]}
+		if err := errs[node.String()]; err != nil {
+			logger.WithError(err).WithField("node", node.String()).Error("failed to get the metadata of the node")
+			result.Error = err.Error()
+			resp.Consistent = false
+			resp.Nodes = append(resp.Nodes, result)
+			continue
+		}
+
+		md := mds[node.String()]
+		if !uuid.Equal(md.ID, node) {
+			result.Discrepancies = append(result.Discrepancies, Discrepancy{
+				Kind:   "peer",
+				Name:   node.String(),
+				Status: DiscrepancyDiffers,
+				Fields: []string{"id"},
+			})
+		}
+		result.Discrepancies = append(result.Discrepancies, compareMetadata("volume", md.Volumes, volumes)...)
+		result.Discrepancies = append(result.Discrepancies, compareMetadata("peer", md.Peers, storedPeers)...)
+		result.Consistent = len(result.Discrepancies) == 0
+		if !result.Consistent {
+			resp.Consistent = false
+			logger.WithField("node", node.String()).WithField(
+				"discrepancies", len(result.Discrepancies)).Warn("node metadata differs from the store")
+		}
+		resp.Nodes = append(resp.Nodes, result)
+	}
+
+	if b, err := json.Marshal(resp); err != nil {
+		logger.WithError(err).Error("failed to marshal the cluster check result")
+	} else if _, err := store.Store.Put(context.TODO(), lastCheckKey, string(b)); err != nil {
+		logger.WithError(err).Error("failed to store the cluster check result")
+	}
+
+	restutils.SendHTTPResponse(w, http.StatusOK, resp)
+}
+
+// clusterCheckResultHandler returns the result of the last consistency check
+// of the cluster
+func clusterCheckResultHandler(w http.ResponseWriter, r *http.Request) {
+	resp, err := store.Store.Get(context.TODO(), lastCheckKey)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if resp.Count != 1 {
+		restutils.SendHTTPError(w, http.StatusNotFound, "the cluster hasn't been checked yet")
+		return
+	}
+
+	var result ClusterCheckResp
+	if err := json.Unmarshal(resp.Kvs[0].Value, &result); err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	restutils.SendHTTPResponse(w, http.StatusOK, result)
+}
@@ -0,0 +1,47 @@
+// Package clustercommands implements the commands checking the cluster as a
+// whole
+package clustercommands
+
+import (
+	"github.com/gluster/glusterd2/servers/rest/route"
+	"github.com/gluster/glusterd2/transaction"
+)
+
+// Command is a holding struct used to implement the GlusterD Command interface
+type Command struct {
+}
+
+// Routes returns command routes. Required for the Command interface.
+func (c *Command) Routes() route.Routes {
+	return route.Routes{
+		route.Route{
+			Name:         "ClusterCheck",
+			Method:       "POST",
+			Pattern:      "/cluster/check",
+			Version:      1,
+			HandlerFunc:  clusterCheckHandler,
+			ResponseType: ClusterCheckResp{},
+		},
+		route.Route{
+			Name:         "ClusterCheckResult",
+			Method:       "GET",
+			Pattern:      "/cluster/check",
+			Version:      1,
+			HandlerFunc:  clusterCheckResultHandler,
+			ResponseType: ClusterCheckResp{},
+		},
+		route.Route{
+			Name:         "OpVersionFeasibility",
+			Method:       "GET",
+			Pattern:      "/cluster/opversion/feasibility",
+			Version:      1,
+			HandlerFunc:  opVersionFeasibilityHandler,
+			ResponseType: FeasibilityResp{},
+		},
+	}
+}
+
+// RegisterStepFuncs implements a required function for the Command interface
+func (c *Command) RegisterStepFuncs() {
+	transaction.RegisterStepFunc(getNodeMetadata, "cluster-check.GetMetadata")
+}
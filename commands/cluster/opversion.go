@@ -0,0 +1,38 @@
+package clustercommands
+
+import (
+	"net/http"
+
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/version"
+)
+
+// FeasibilityResp is whether a version-gated feature can be used, with the
+// online peers which don't support it
+type FeasibilityResp struct {
+	Feature string `json:"feature"`
+	*peer.OpVersionCheck
+}
+
+// opVersionFeasibilityHandler checks whether every online peer supports the
+// op-version the given feature requires
+func opVersionFeasibilityHandler(w http.ResponseWriter, r *http.Request) {
+	feature := r.URL.Query().Get("feature")
+	if feature == "" {
+		restutils.SendHTTPError(w, http.StatusBadRequest, "feature not specified")
+		return
+	}
+	required, ok := version.FeatureOpVersion(feature)
+	if !ok {
+		restutils.SendHTTPError(w, http.StatusNotFound, "unknown version-gated feature "+feature)
+		return
+	}
+
+	check, err := peer.CheckOpVersion(required)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	restutils.SendHTTPResponse(w, http.StatusOK, FeasibilityResp{Feature: feature, OpVersionCheck: check})
+}
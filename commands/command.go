@@ -2,7 +2,15 @@
 package commands
 
 import (
+	"github.com/gluster/glusterd2/commands/auth"
+	"github.com/gluster/glusterd2/commands/cluster"
+	"github.com/gluster/glusterd2/commands/diagnostics"
+	"github.com/gluster/glusterd2/commands/health"
+	"github.com/gluster/glusterd2/commands/logging"
+	"github.com/gluster/glusterd2/commands/metrics"
+	"github.com/gluster/glusterd2/commands/operations"
 	"github.com/gluster/glusterd2/commands/peers"
+	"github.com/gluster/glusterd2/commands/transactions"
 	"github.com/gluster/glusterd2/commands/version"
 	"github.com/gluster/glusterd2/commands/volumes"
 	"github.com/gluster/glusterd2/servers/rest/route"
@@ -19,6 +27,14 @@ type Command interface {
 // Commands is a list of commands available
 var Commands = []Command{
 	&versioncommands.Command{},
+	&healthcommands.Command{},
 	&volumecommands.Command{},
 	&peercommands.Command{},
+	&metricscommands.Command{},
+	&diagnosticscommands.Command{},
+	&loggingcommands.Command{},
+	&transactionscommands.Command{},
+	&operationscommands.Command{},
+	&clustercommands.Command{},
+	&authcommands.Command{},
 }
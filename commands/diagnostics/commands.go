@@ -0,0 +1,39 @@
+// Package diagnosticscommands implements the diagnostics commands
+package diagnosticscommands
+
+import (
+	"github.com/gluster/glusterd2/servers/rest/route"
+	"github.com/gluster/glusterd2/transaction"
+)
+
+// Command is a holding struct used to implement the GlusterD Command interface
+type Command struct {
+}
+
+// Routes returns command routes. Required for the Command interface.
+func (c *Command) Routes() route.Routes {
+	return route.Routes{
+		route.Route{
+			Name:         "SelfTest",
+			Method:       "POST",
+			Pattern:      "/diagnostics/self-test",
+			Version:      1,
+			HandlerFunc:  selfTestHandler,
+			ResponseType: SelfTestReport{},
+		},
+		route.Route{
+			Name:         "SupportBundle",
+			Method:       "POST",
+			Pattern:      "/support-bundle",
+			Version:      1,
+			HandlerFunc:  supportBundleHandler,
+			RequestType:  SupportBundleReq{},
+			ResponseType: []byte{},
+		},
+	}
+}
+
+// RegisterStepFuncs implements a required function for the Command interface
+func (c *Command) RegisterStepFuncs() {
+	transaction.RegisterStepFunc(collectNodeBundle, "support-bundle.Collect")
+}
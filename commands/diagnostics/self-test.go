@@ -0,0 +1,155 @@
+package diagnosticscommands
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gluster/glusterd2/gdctx"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/store"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	"github.com/pborman/uuid"
+)
+
+const (
+	// The results of a check
+	checkPassed  = "passed"
+	checkFailed  = "failed"
+	checkSkipped = "skipped"
+
+	storeCheckTimeout = 5 * time.Second
+)
+
+var (
+	// unsupportedFilesystems are the filesystem types bricks can't be
+	// placed on
+	unsupportedFilesystems = map[string]bool{
+		"tmpfs":     true,
+		"nfs":       true,
+		"overlayfs": true,
+		"fuse":      true,
+	}
+)
+
+// SelfTestCheck is the result of a check run by the self-test. Action tells
+// how to fix a check which failed.
+type SelfTestCheck struct {
+	Name    string `json:"name"`
+	Result  string `json:"result"`
+	Message string `json:"message,omitempty"`
+	Action  string `json:"action,omitempty"`
+}
+
+// SelfTestReport is the report of the self-test of a node. Passed is false if
+// any check failed.
+type SelfTestReport struct {
+	NodeID uuid.UUID       `json:"node-id"`
+	Passed bool            `json:"passed"`
+	Checks []SelfTestCheck `json:"checks"`
+}
+
+func passed(name string, message string) SelfTestCheck {
+	return SelfTestCheck{Name: name, Result: checkPassed, Message: message}
+}
+
+func failed(name string, err error, action string) SelfTestCheck {
+	return SelfTestCheck{Name: name, Result: checkFailed, Message: err.Error(), Action: action}
+}
+
+func checkStore() SelfTestCheck {
+	const name = "store"
+	ctx, cancel := context.WithTimeout(context.Background(), storeCheckTimeout)
+	defer cancel()
+	if _, err := store.Store.Get(ctx, store.GlusterPrefix); err != nil {
+		return failed(name, err, "check that the etcd endpoints are reachable from this node and that etcd is running")
+	}
+	return passed(name, fmt.Sprintf("store reachable at %v", store.Store.Endpoints()))
+}
+
+func checkLocalIP() SelfTestCheck {
+	const name = "local-ip"
+	ip, err := utils.GetLocalIP()
+	if err != nil {
+		return failed(name, err, "configure a non-loopback address on this node, or set addressfamily to a family the node has an address in")
+	}
+	return passed(name, "local address is "+ip)
+}
+
+// checkXattrSupport validates extended attribute support on a temporary
+// directory under the brick root, which is removed afterwards
+func checkXattrSupport(root string) SelfTestCheck {
+	const name = "xattr-support"
+	tmp, err := ioutil.TempDir(root, ".gd2-self-test-")
+	if err != nil {
+		return failed(name, err, "check that "+root+" exists and is writable by glusterd2")
+	}
+	defer os.RemoveAll(tmp)
+
+	// The brick root could itself be in use, which doesn't matter here
+	if err := utils.ValidateXattrSupport(tmp, gdctx.HostName, uuid.NewRandom(), true); err != nil {
+		return failed(name, err, "place bricks on a filesystem supporting trusted extended attributes, such as XFS, and run glusterd2 as root")
+	}
+	return passed(name, "extended attributes are supported under "+root)
+}
+
+func checkFilesystemType(root string) SelfTestCheck {
+	const name = "filesystem-type"
+	if _, err := os.Stat(root); err != nil {
+		return failed(name, err, "check that "+root+" exists")
+	}
+	fstype, err := utils.GetFSType(root)
+	if err != nil {
+		return failed(name, err, "check that "+root+" exists")
+	}
+	if !utils.IsKnownFSType(fstype) {
+		return passed(name, fmt.Sprintf("%s is on an unknown filesystem type %s, XFS is recommended", root, fstype))
+	}
+	if unsupportedFilesystems[fstype] {
+		return failed(name, fmt.Errorf("%s is on %s, which bricks can't be placed on", root, fstype),
+			"place bricks on a local filesystem, preferably XFS")
+	}
+	return passed(name, root+" is on "+fstype)
+}
+
+// selfTest runs the local health checks of the node. The brick root checks
+// are skipped if no default brick root is set.
+func selfTest() *SelfTestReport {
+	report := &SelfTestReport{
+		NodeID: gdctx.MyUUID,
+		Passed: true,
+		Checks: []SelfTestCheck{checkStore(), checkLocalIP()},
+	}
+
+	if root, err := volume.GetDefaultBrickRoot(); err == nil && root.Root != "" {
+		report.Checks = append(report.Checks, checkXattrSupport(root.Root), checkFilesystemType(root.Root))
+	} else {
+		for _, name := range []string{"xattr-support", "filesystem-type"} {
+			report.Checks = append(report.Checks, SelfTestCheck{
+				Name:    name,
+				Result:  checkSkipped,
+				Message: "no default brick root set",
+				Action:  "set the cluster default brick root to check the filesystem bricks are placed on",
+			})
+		}
+	}
+
+	for _, c := range report.Checks {
+		if c.Result == checkFailed {
+			report.Passed = false
+		}
+	}
+	return report
+}
+
+// selfTestHandler runs the self-test of this node. The checks don't modify
+// the node, and the report is sent with a 200 response whether they pass or
+// not.
+func selfTestHandler(w http.ResponseWriter, r *http.Request) {
+	restutils.SendHTTPResponse(w, http.StatusOK, selfTest())
+}
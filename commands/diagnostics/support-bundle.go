@@ -0,0 +1,377 @@
+package diagnosticscommands
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	gderrors "github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	"github.com/pborman/uuid"
+	config "github.com/spf13/viper"
+)
+
+const (
+	nodeBundleTxnKey = "supportbundle"
+
+	// supportBundleLogBytes is how much of the end of the log file of a
+	// node is included, as the bundle of every node passes through the
+	// store
+	supportBundleLogBytes = 512 * 1024
+
+	redacted = "<redacted>"
+)
+
+// bundleComponents are the components a support bundle can include, by name,
+// along with the function collecting them on a node into a file of the bundle
+var bundleComponents = map[string]struct {
+	file    string
+	collect func() ([]byte, error)
+}{
+	"config":    {"config.json", collectConfig},
+	"logs":      {"glusterd2.log", collectLogs},
+	"volumes":   {"volumes.json", collectVolumes},
+	"peers":     {"peers.json", collectPeers},
+	"self-test": {"self-test.json", collectSelfTest},
+}
+
+// SupportBundleReq selects the components included in a support bundle, all
+// of them if none are given
+type SupportBundleReq struct {
+	Components []string `json:"components,omitempty"`
+}
+
+// SupportBundleNode is a node whose components are included in a support
+// bundle, under the directory Dir. Errors has the components which couldn't
+// be collected on the node, with the reason.
+type SupportBundleNode struct {
+	ID     uuid.UUID         `json:"id"`
+	Name   string            `json:"name"`
+	Dir    string            `json:"dir,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// SupportBundleManifest describes the contents of a support bundle. Nodes
+// which were offline, or couldn't be reached, are listed in Unreachable.
+type SupportBundleManifest struct {
+	CreatedAt   time.Time           `json:"created-at"`
+	Components  []string            `json:"components"`
+	Nodes       []SupportBundleNode `json:"nodes"`
+	Unreachable []SupportBundleNode `json:"unreachable,omitempty"`
+}
+
+// nodeBundle is the part of a support bundle collected on a node, as file
+// contents by file name
+type nodeBundle struct {
+	Files  map[string][]byte
+	Errors map[string]string
+}
+
+func marshalIndent(v interface{}) ([]byte, error) {
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// isSecretSetting returns true if the setting is named like one having a
+// secret, like a token or a password. Webhook URLs are secrets as a whole, as
+// they often have a token in their path.
+func isSecretSetting(name string) bool {
+	for _, word := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	}) {
+		switch word {
+		case "token", "secret", "password", "passwd", "key", "apikey", "credentials", "webhook":
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL removes the password and the query of a URL, either of which
+// could have a secret
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return s
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = redacted
+	}
+	return u.String()
+}
+
+// redactSettings returns the settings with the values of the secret ones,
+// and the secrets in URLs, redacted
+func redactSettings(settings map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(settings))
+	for name, value := range settings {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			out[name] = redactSettings(v)
+		case string:
+			if isSecretSetting(name) && v != "" {
+				out[name] = redacted
+			} else {
+				out[name] = redactURL(v)
+			}
+		default:
+			if isSecretSetting(name) {
+				out[name] = redacted
+			} else {
+				out[name] = v
+			}
+		}
+	}
+	return out
+}
+
+func collectConfig() ([]byte, error) {
+	return marshalIndent(redactSettings(config.AllSettings()))
+}
+
+// collectLogs returns the end of the log file, from the first complete line
+func collectLogs() ([]byte, error) {
+	logfile := config.GetString("logfile")
+	switch strings.ToLower(logfile) {
+	case "stderr", "stdout", "-":
+		return nil, fmt.Errorf("glusterd2 logs to %s, not to a file", logfile)
+	}
+
+	f, err := os.Open(path.Join(config.GetString("logdir"), logfile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := fi.Size() - supportBundleLogBytes
+	if offset < 0 {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if i := strings.IndexByte(string(b), '\n'); i >= 0 {
+			b = b[i+1:]
+		}
+	}
+	return b, nil
+}
+
+// collectVolumes returns the volumes as this node sees them, without the
+// credentials of their clients
+func collectVolumes() ([]byte, error) {
+	volumes, err := volume.GetVolumes()
+	if err != nil {
+		return nil, err
+	}
+	for i := range volumes {
+		volumes[i].Auth = volume.VolAuth{Username: redacted, Password: redacted}
+	}
+	return marshalIndent(volumes)
+}
+
+func collectPeers() ([]byte, error) {
+	peers, err := peer.GetPeers()
+	if err != nil {
+		return nil, err
+	}
+	return marshalIndent(peers)
+}
+
+func collectSelfTest() ([]byte, error) {
+	return marshalIndent(selfTest())
+}
+
+// collectNodeBundle collects the requested components of the support bundle
+// on this node. A component which can't be collected is recorded as an error
+// rather than failing the others.
+func collectNodeBundle(c transaction.TxnCtx) error {
+	var components []string
+	if err := c.Get("components", &components); err != nil {
+		return err
+	}
+
+	bundle := nodeBundle{
+		Files:  make(map[string][]byte),
+		Errors: make(map[string]string),
+	}
+	for _, name := range components {
+		comp := bundleComponents[name]
+		b, err := comp.collect()
+		if err != nil {
+			c.Logger().WithError(err).WithField("component", name).Warn("failed to collect support bundle component")
+			bundle.Errors[name] = err.Error()
+			continue
+		}
+		bundle.Files[comp.file] = b
+	}
+
+	c.SetNodeResult(gdctx.MyUUID, nodeBundleTxnKey, bundle)
+	return nil
+}
+
+// getNodeBundle collects the support bundle of a single node, so that a node
+// failing doesn't fail the others
+func getNodeBundle(reqID string, node uuid.UUID, components []string) (*nodeBundle, error) {
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = []uuid.UUID{node}
+	txn.Steps = []*transaction.Step{
+		{
+			DoFunc: "support-bundle.Collect",
+			Nodes:  txn.Nodes,
+		},
+	}
+	if err := txn.Ctx.Set("components", components); err != nil {
+		return nil, err
+	}
+
+	rtxn, err := txn.Do()
+	if err != nil {
+		return nil, err
+	}
+	var bundle nodeBundle
+	if err := rtxn.GetNodeResult(node, nodeBundleTxnKey, &bundle); err != nil {
+		return nil, err
+	}
+	return &bundle, nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, b []byte, modTime time.Time) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(b)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(b)
+	return err
+}
+
+// supportBundleHandler collects the configuration, logs, volume and peer
+// metadata and self-test report of every online node, and sends them back
+// as a tar.gz archive with a directory per node and a manifest. Offline
+// nodes are listed in the manifest.
+func supportBundleHandler(w http.ResponseWriter, r *http.Request) {
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	var req SupportBundleReq
+	if r.ContentLength != 0 {
+		if err := utils.GetJSONFromRequest(r, &req); err != nil {
+			restutils.SendHTTPError(w, http.StatusUnprocessableEntity, gderrors.ErrJSONParsingFailed.Error())
+			return
+		}
+	}
+	components := req.Components
+	if len(components) == 0 {
+		for name := range bundleComponents {
+			components = append(components, name)
+		}
+	}
+	for _, name := range components {
+		if _, ok := bundleComponents[name]; !ok {
+			restutils.SendHTTPError(w, http.StatusBadRequest, "unknown support bundle component "+name)
+			return
+		}
+	}
+	sort.Strings(components)
+
+	peers, err := peer.GetPeers()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	manifest := SupportBundleManifest{
+		CreatedAt:  time.Now(),
+		Components: components,
+		Nodes:      []SupportBundleNode{},
+	}
+	bundles := make(map[string]*nodeBundle)
+	for _, p := range peers {
+		node := SupportBundleNode{ID: p.ID, Name: p.Name}
+		if !peer.IsOnline(p.ID) {
+			manifest.Unreachable = append(manifest.Unreachable, node)
+			continue
+		}
+		bundle, err := getNodeBundle(reqID, p.ID, components)
+		if err != nil {
+			logger.WithError(err).WithField("peer", p.ID).Warn("failed to collect support bundle of node")
+			node.Errors = map[string]string{"node": err.Error()}
+			manifest.Unreachable = append(manifest.Unreachable, node)
+			continue
+		}
+		node.Dir = p.Ref()
+		if len(bundle.Errors) > 0 {
+			node.Errors = bundle.Errors
+		}
+		manifest.Nodes = append(manifest.Nodes, node)
+		bundles[node.Dir] = bundle
+	}
+
+	manifestJSON, err := marshalIndent(manifest)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Nothing can fail now but writing to the client, so the archive is
+	// streamed
+	name := fmt.Sprintf("support-bundle-%s", manifest.CreatedAt.UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".tar.gz"))
+	w.WriteHeader(http.StatusOK)
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	err = writeTarFile(tw, path.Join(name, "manifest.json"), manifestJSON, manifest.CreatedAt)
+	for _, node := range manifest.Nodes {
+		for file, b := range bundles[node.Dir].Files {
+			if err != nil {
+				break
+			}
+			err = writeTarFile(tw, path.Join(name, node.Dir, file), b, manifest.CreatedAt)
+		}
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gw.Close()
+	}
+	if err != nil {
+		logger.WithError(err).Error("failed to send support bundle")
+		return
+	}
+	logger.WithField("nodes", len(manifest.Nodes)).Info("sent support bundle")
+}
@@ -0,0 +1,29 @@
+// Package healthcommands implements the health command
+package healthcommands
+
+import (
+	"github.com/gluster/glusterd2/middleware"
+	"github.com/gluster/glusterd2/servers/rest/route"
+)
+
+// Command is a holding struct used to implement the GlusterD Command interface
+type Command struct {
+}
+
+// Routes returns command routes. Required for the Command interface.
+func (c *Command) Routes() route.Routes {
+	return route.Routes{
+		route.Route{
+			Name:         "GetHealth",
+			Method:       "GET",
+			Pattern:      middleware.HealthPath,
+			HandlerFunc:  getHealthHandler,
+			ResponseType: HealthResponse{},
+		},
+	}
+}
+
+// RegisterStepFuncs implements a required function for the Command interface
+func (c *Command) RegisterStepFuncs() {
+	return
+}
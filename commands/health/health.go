@@ -0,0 +1,24 @@
+// Package healthcommands implements the health ReST end point
+package healthcommands
+
+import (
+	"net/http"
+
+	"github.com/gluster/glusterd2/gdctx"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+)
+
+// HealthResponse is the response of the /healthz end point, which liveness
+// probes request. It is served as long as the REST server of the node is up,
+// and isn't rate limited.
+type HealthResponse struct {
+	Status string `json:"status"`
+	PeerID string `json:"peer-id"`
+}
+
+func getHealthHandler(w http.ResponseWriter, r *http.Request) {
+	restutils.SendHTTPResponse(w, http.StatusOK, HealthResponse{
+		Status: "ok",
+		PeerID: gdctx.MyUUID.String(),
+	})
+}
@@ -0,0 +1,29 @@
+// Package loggingcommands implements the logging command
+package loggingcommands
+
+import (
+	"github.com/gluster/glusterd2/servers/rest/route"
+)
+
+// Command is a holding struct used to implement the GlusterD Command interface
+type Command struct {
+}
+
+// Routes returns command routes. Required for the Command interface.
+func (c *Command) Routes() route.Routes {
+	return route.Routes{
+		route.Route{
+			Name:         "GetLogging",
+			Method:       "GET",
+			Pattern:      "/logging",
+			Version:      1,
+			HandlerFunc:  getLoggingHandler,
+			ResponseType: LoggingResponse{},
+		},
+	}
+}
+
+// RegisterStepFuncs implements a required function for the Command interface
+func (c *Command) RegisterStepFuncs() {
+	return
+}
@@ -0,0 +1,46 @@
+package loggingcommands
+
+import (
+	"net/http"
+
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/utils"
+
+	log "github.com/Sirupsen/logrus"
+	config "github.com/spf13/viper"
+)
+
+// LogRotation is the rotation of the log files of glusterd2 and the bricks.
+// MaxSize is in bytes, and 0 if log files aren't rotated.
+type LogRotation struct {
+	MaxSize    int64  `json:"max-size"`
+	MaxAge     string `json:"max-age,omitempty"`
+	MaxBackups int    `json:"max-backups,omitempty"`
+}
+
+// LoggingResponse is the logging configuration of glusterd2
+type LoggingResponse struct {
+	Level    string      `json:"level"`
+	Format   string      `json:"format"`
+	LogDir   string      `json:"logdir"`
+	LogFile  string      `json:"logfile"`
+	Rotation LogRotation `json:"rotation"`
+}
+
+func getLoggingHandler(w http.ResponseWriter, r *http.Request) {
+	rotation := utils.LogRotateConfig()
+	resp := LoggingResponse{
+		Level:   log.GetLevel().String(),
+		Format:  config.GetString("logformat"),
+		LogDir:  config.GetString("logdir"),
+		LogFile: config.GetString("logfile"),
+		Rotation: LogRotation{
+			MaxSize:    rotation.MaxSize,
+			MaxBackups: rotation.MaxBackups,
+		},
+	}
+	if rotation.MaxAge > 0 {
+		resp.Rotation.MaxAge = rotation.MaxAge.String()
+	}
+	restutils.SendHTTPResponse(w, http.StatusOK, resp)
+}
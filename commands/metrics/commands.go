@@ -0,0 +1,30 @@
+// Package metricscommands implements the metrics command
+package metricscommands
+
+import (
+	"github.com/gluster/glusterd2/servers/rest/route"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Command is a holding struct used to implement the GlusterD Command interface
+type Command struct {
+}
+
+// Routes returns command routes. Required for the Command interface.
+func (c *Command) Routes() route.Routes {
+	return route.Routes{
+		route.Route{
+			Name:         "GetMetrics",
+			Method:       "GET",
+			Pattern:      "/metrics",
+			HandlerFunc:  promhttp.Handler().ServeHTTP,
+			ResponseType: "",
+		},
+	}
+}
+
+// RegisterStepFuncs implements a required function for the Command interface
+func (c *Command) RegisterStepFuncs() {
+	return
+}
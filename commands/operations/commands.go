@@ -0,0 +1,31 @@
+// Package operationscommands implements the commands polling the operations
+// started by asynchronous requests
+package operationscommands
+
+import (
+	"github.com/gluster/glusterd2/operations"
+	"github.com/gluster/glusterd2/servers/rest/route"
+)
+
+// Command is a holding struct used to implement the GlusterD Command interface
+type Command struct {
+}
+
+// Routes returns command routes. Required for the Command interface.
+func (c *Command) Routes() route.Routes {
+	return route.Routes{
+		route.Route{
+			Name:         "GetOperation",
+			Method:       "GET",
+			Pattern:      "/operations/{id}",
+			Version:      1,
+			HandlerFunc:  getOperationHandler,
+			ResponseType: operations.Operation{},
+		},
+	}
+}
+
+// RegisterStepFuncs implements a required function for the Command interface
+func (c *Command) RegisterStepFuncs() {
+	return
+}
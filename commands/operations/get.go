@@ -0,0 +1,26 @@
+package operationscommands
+
+import (
+	"net/http"
+
+	"github.com/gluster/glusterd2/operations"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// getOperationHandler returns the status of an operation, the progress of its
+// transactions on each node, and its result once it has completed. Operations
+// whose result has expired aren't found.
+func getOperationHandler(w http.ResponseWriter, r *http.Request) {
+	op, err := operations.Get(mux.Vars(r)["id"])
+	if err == operations.ErrOperationNotFound {
+		restutils.SendHTTPError(w, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	restutils.SendHTTPResponse(w, http.StatusOK, op)
+}
@@ -1,6 +1,8 @@
 package peercommands
 
 import (
+	"context"
+	goerrors "errors"
 	"fmt"
 	"net/http"
 
@@ -11,6 +13,7 @@ import (
 	"github.com/gluster/glusterd2/utils"
 
 	log "github.com/Sirupsen/logrus"
+	config "github.com/spf13/viper"
 )
 
 type peerAddReq struct {
@@ -36,34 +39,30 @@ func addPeerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// A peer can have multiple addresses. For now, we use only the first
-	// address present in the req.Addresses list.
-	remotePeerAddress, err := utils.FormRemotePeerAddress(req.Addresses[0])
-	if err != nil {
-		log.WithError(err).WithField("address", req.Addresses[0]).Error("failed to parse peer address")
-		restutils.SendHTTPError(w, http.StatusBadRequest, "failed to parse remote address")
+	// The peer may also be known by another hostname or IP of its host
+	if err := peer.CheckPeerHostConflict(req.Addresses); err != nil {
+		if _, ok := err.(*peer.PeerHostConflict); ok {
+			restutils.SendHTTPError(w, http.StatusConflict, err.Error())
+		} else {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		}
 		return
 	}
 
-	// TODO: Try all addresses till the first one connects
-	client, err := getPeerServiceClient(remotePeerAddress)
+	newconfig := &StoreConfig{store.Store.Endpoints()}
+	log.WithField("endpoints", newconfig.Endpoints).Debug("asking new peer to join cluster with given endpoints")
+
+	// A peer can have multiple addresses, on different networks. The
+	// addresses are tried in order till the peer is reached on one.
+	ctx, cancel := probeContext()
+	defer cancel()
+	rsp, remotePeerAddress, err := joinPeer(ctx, req.Addresses, newconfig)
 	if err != nil {
 		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	defer client.conn.Close()
 	logger := log.WithField("peer", remotePeerAddress)
-
-	newconfig := &StoreConfig{store.Store.Endpoints()}
-	logger.WithField("endpoints", newconfig.Endpoints).Debug("asking new peer to join cluster with given endpoints")
-
-	// Ask the peer to join the cluster
-	rsp, err := client.JoinCluster(newconfig)
-	if err != nil {
-		log.WithError(err).Error("sending Join request failed")
-		restutils.SendHTTPError(w, http.StatusInternalServerError, "failed to send join cluster request")
-		return
-	} else if Error(rsp.Err) != ErrNone {
+	if Error(rsp.Err) != ErrNone {
 		err = Error(rsp.Err)
 		logger.WithError(err).Error("join request failed")
 		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
@@ -78,9 +77,42 @@ func addPeerHandler(w http.ResponseWriter, r *http.Request) {
 		// XXX: Don't know the correct error to send here
 		restutils.SendHTTPError(w, http.StatusInternalServerError, "new peer was added, but could not find peer in store. Try again later.")
 	} else {
+		w.Header().Set("Location", "/v1/peers/"+newpeer.Ref())
 		restutils.SendHTTPResponse(w, http.StatusCreated, newpeer)
 	}
 
 	// Save updated store endpoints for restarts
 	store.Store.UpdateEndpoints()
 }
+
+// probeContext returns a context bounding the time taken by a peer to join
+// the cluster to the configured probe timeout
+func probeContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), config.GetDuration("peer-probe-timeout"))
+}
+
+// joinPeer asks the peer to join the cluster on the first of its addresses
+// it can be reached on. It returns the response of the peer and the address
+// it was reached on.
+func joinPeer(ctx context.Context, addresses []string, conf *StoreConfig) (*JoinRsp, string, error) {
+	for _, address := range addresses {
+		remotePeerAddress, e := utils.FormRemotePeerAddress(address)
+		if e != nil {
+			log.WithError(e).WithField("address", address).Error("failed to parse peer address")
+			continue
+		}
+
+		client, e := getPeerServiceClient(remotePeerAddress)
+		if e != nil {
+			continue
+		}
+		rsp, e := client.JoinCluster(ctx, conf)
+		client.conn.Close()
+		if e != nil {
+			log.WithError(e).WithField("peer", remotePeerAddress).Error("sending Join request failed")
+			continue
+		}
+		return rsp, remotePeerAddress, nil
+	}
+	return nil, "", goerrors.New("failed to send join cluster request")
+}
@@ -0,0 +1,177 @@
+package peercommands
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/store"
+	"github.com/gluster/glusterd2/utils"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/pborman/uuid"
+)
+
+const (
+	// bulkProbeWorkers is the maximum number of peers probed at once
+	bulkProbeWorkers = 8
+
+	// The outcomes of probing an address
+	probeAdded   = "added"
+	probeMember  = "already-member"
+	probeInvalid = "invalid"
+	// probeConflict is an address of the host of an existing peer, under
+	// another name
+	probeConflict = "conflict"
+	probeFailed   = "failed"
+)
+
+type peerBulkAddReq struct {
+	Addresses []string
+}
+
+// PeerProbeResult is the outcome of probing a single address of a bulk probe
+type PeerProbeResult struct {
+	Address string    `json:"address"`
+	Result  string    `json:"result"`
+	PeerID  uuid.UUID `json:"peer-id,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// probePeer asks the peer at the address to join the cluster, unless it is a
+// member already
+func probePeer(address string, conf *StoreConfig) PeerProbeResult {
+	result := PeerProbeResult{Address: address}
+
+	if p, _ := peer.GetPeerByAddrs([]string{address}); p != nil {
+		result.Result = probeMember
+		result.PeerID = p.ID
+		return result
+	}
+	if err := peer.CheckPeerHostConflict([]string{address}); err != nil {
+		result.Result = probeFailed
+		if c, ok := err.(*peer.PeerHostConflict); ok {
+			result.Result = probeConflict
+			result.PeerID = c.Peer.ID
+		}
+		result.Error = err.Error()
+		return result
+	}
+
+	ctx, cancel := probeContext()
+	defer cancel()
+	rsp, _, err := joinPeer(ctx, []string{address}, conf)
+	if err == nil && Error(rsp.Err) != ErrNone {
+		err = Error(rsp.Err)
+	}
+	if err != nil {
+		result.Result = probeFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Result = probeAdded
+	result.PeerID = uuid.Parse(rsp.PeerID)
+	return result
+}
+
+// bulkProbe probes the addresses concurrently, at most bulkProbeWorkers at a
+// time. The results are in the order of the addresses.
+func bulkProbe(addresses []string, conf *StoreConfig) []PeerProbeResult {
+	results := make([]PeerProbeResult, len(addresses))
+	indices := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < bulkProbeWorkers && w < len(addresses); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				results[i] = probePeer(addresses[i], conf)
+			}
+		}()
+	}
+	for i := range addresses {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	return results
+}
+
+// sameHostAddress returns the address of the list which is the same host as
+// the given address, if any
+func sameHostAddress(address string, addresses []string) string {
+	for _, a := range addresses {
+		if utils.IsPeerHostSame(address, a) {
+			return a
+		}
+	}
+	return ""
+}
+
+// bulkAddPeersHandler adds a peer for each of the given addresses. Invalid
+// addresses and peers which fail to join are reported along with the peers
+// added, and don't undo the peers which did join.
+func bulkAddPeersHandler(w http.ResponseWriter, r *http.Request) {
+	var req peerBulkAddReq
+	if e := utils.GetJSONFromRequest(r, &req); e != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, e.Error())
+		return
+	}
+
+	if len(req.Addresses) < 1 {
+		restutils.SendHTTPError(w, http.StatusBadRequest, errors.ErrNoHostnamesPresent.Error())
+		return
+	}
+	log.WithField("addresses", req.Addresses).Debug("received request to add peers in bulk")
+
+	var (
+		results = make([]PeerProbeResult, len(req.Addresses))
+		valid   []string
+		index   []int
+	)
+	for i, address := range req.Addresses {
+		results[i].Address = address
+		if err := utils.ValidatePeerAddress(address); err != nil {
+			results[i].Result = probeInvalid
+			results[i].Error = err.Error()
+			continue
+		}
+		if dup := sameHostAddress(address, valid); dup != "" {
+			results[i].Result = probeInvalid
+			if dup == address {
+				results[i].Error = "address repeated in request"
+			} else {
+				results[i].Error = fmt.Sprintf("address is the same host as %s in request", dup)
+			}
+			continue
+		}
+		valid = append(valid, address)
+		index = append(index, i)
+	}
+
+	newconfig := &StoreConfig{store.Store.Endpoints()}
+	added := 0
+	for i, result := range bulkProbe(valid, newconfig) {
+		results[index[i]] = result
+		if result.Result == probeAdded {
+			added++
+		}
+	}
+	log.WithFields(log.Fields{
+		"added":     added,
+		"addresses": len(req.Addresses),
+	}).Info("bulk peer add done")
+
+	if added > 0 {
+		// Save updated store endpoints for restarts
+		store.Store.UpdateEndpoints()
+	}
+
+	restutils.SendHTTPResponse(w, http.StatusOK, results)
+}
@@ -2,6 +2,9 @@
 package peercommands
 
 import (
+	"net/http"
+
+	"github.com/gluster/glusterd2/peer"
 	"github.com/gluster/glusterd2/servers/rest/route"
 )
 
@@ -13,18 +16,21 @@ type Command struct {
 func (c *Command) Routes() route.Routes {
 	return route.Routes{
 		route.Route{
-			Name:        "GetPeer",
-			Method:      "GET",
-			Pattern:     "/peers/{peerid}",
-			Version:     1,
-			HandlerFunc: getPeerHandler,
+			Name:         "GetPeer",
+			Method:       "GET",
+			Pattern:      "/peers/{peerid}",
+			Version:      1,
+			HandlerFunc:  getPeerHandler,
+			ResponseType: peer.Peer{},
 		},
 		route.Route{
-			Name:        "GetPeers",
-			Method:      "GET",
-			Pattern:     "/peers",
-			Version:     1,
-			HandlerFunc: getPeersHandler,
+			Name:         "GetPeers",
+			Method:       "GET",
+			Pattern:      "/peers",
+			Version:      1,
+			HandlerFunc:  getPeersHandler,
+			Paginated:    true,
+			ResponseType: []peerListEntry{},
 		},
 		route.Route{
 			Name:        "EtcdHealthPeer",
@@ -41,18 +47,40 @@ func (c *Command) Routes() route.Routes {
 			HandlerFunc: peerEtcdStatusHandler,
 		},
 		route.Route{
-			Name:        "DeletePeer",
-			Method:      "DELETE",
-			Pattern:     "/peers/{peerid}",
-			Version:     1,
-			HandlerFunc: deletePeerHandler,
+			Name:           "DeletePeer",
+			Method:         "DELETE",
+			Pattern:        "/peers/{peerid}",
+			Version:        1,
+			HandlerFunc:    deletePeerHandler,
+			ResponseStatus: http.StatusNoContent,
 		},
 		route.Route{
-			Name:        "AddPeer",
-			Method:      "POST",
-			Pattern:     "/peers",
-			Version:     1,
-			HandlerFunc: addPeerHandler,
+			Name:           "AddPeer",
+			Method:         "POST",
+			Pattern:        "/peers",
+			Version:        1,
+			HandlerFunc:    addPeerHandler,
+			RequestType:    peerAddReq{},
+			ResponseType:   peer.Peer{},
+			ResponseStatus: http.StatusCreated,
+		},
+		route.Route{
+			Name:         "AddPeersBulk",
+			Method:       "POST",
+			Pattern:      "/peers/bulk",
+			Version:      1,
+			HandlerFunc:  bulkAddPeersHandler,
+			RequestType:  peerBulkAddReq{},
+			ResponseType: []PeerProbeResult{},
+		},
+		route.Route{
+			Name:         "SetPeerLabels",
+			Method:       "PUT",
+			Pattern:      "/peers/{peerid}/labels",
+			Version:      1,
+			HandlerFunc:  setPeerLabelsHandler,
+			RequestType:  map[string]string{},
+			ResponseType: peer.Peer{},
 		},
 	}
 }
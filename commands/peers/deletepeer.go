@@ -2,19 +2,23 @@ package peercommands
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/gluster/glusterd2/errors"
 	"github.com/gluster/glusterd2/gdctx"
 	"github.com/gluster/glusterd2/peer"
 	restutils "github.com/gluster/glusterd2/servers/rest/utils"
 	"github.com/gluster/glusterd2/store"
 	"github.com/gluster/glusterd2/utils"
-	"github.com/gluster/glusterd2/volume"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
-	"github.com/pborman/uuid"
 )
 
+// deletePeerHandler removes a peer from the cluster. With `dryRun=true` the
+// peer is not removed, and the impact of removing it on the volumes is
+// returned instead.
 func deletePeerHandler(w http.ResponseWriter, r *http.Request) {
 	peerReq := mux.Vars(r)
 
@@ -24,6 +28,15 @@ func deletePeerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var dryRun bool
+	if v := r.URL.Query().Get("dryRun"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			restutils.SendHTTPError(w, http.StatusBadRequest, "invalid value for dryRun")
+			return
+		}
+	}
+
 	// Deleting a peer from the cluster happens as follows,
 	// 	- Check if the peer is a member of the cluster
 	// 	- Check if the peer can be removed
@@ -34,16 +47,20 @@ func deletePeerHandler(w http.ResponseWriter, r *http.Request) {
 	logger.Debug("received delete peer request")
 
 	// Check whether the member exists
-	p, err := peer.GetPeerF(id)
-	if err != nil {
-		logger.WithError(err).Error("failed to get peer")
-		restutils.SendHTTPError(w, http.StatusInternalServerError, "could not validate delete request")
-		return
-	} else if p == nil {
+	p, err := peer.ResolvePeer(id)
+	if err == errors.ErrPeerNotFound {
 		logger.Debug("request denied, received request to remove unknown peer")
 		restutils.SendHTTPError(w, http.StatusNotFound, "peer not found in cluster")
 		return
+	} else if _, ok := err.(*peer.AmbiguousPeer); ok {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		logger.WithError(err).Error("failed to get peer")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, "could not validate delete request")
+		return
 	}
+	id = p.ID.String()
 
 	// You cannot remove yourself
 	if id == gdctx.MyUUID.String() {
@@ -53,13 +70,19 @@ func deletePeerHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if any volumes exist with bricks on this peer
-	if exists, err := bricksExist(id); err != nil {
+	impact, err := analyzeDetachImpact(p.ID)
+	if err != nil {
 		logger.WithError(err).Error("failed to check if bricks exist on peer")
 		restutils.SendHTTPError(w, http.StatusInternalServerError, "could not validate delete request")
 		return
-	} else if exists {
-		logger.Debug("request denied, peer has bricks")
-		restutils.SendHTTPError(w, http.StatusForbidden, "cannot delete peer, peer has bricks")
+	}
+	if dryRun {
+		restutils.SendHTTPResponse(w, http.StatusOK, impact)
+		return
+	}
+	if !impact.Allowed {
+		logger.WithField("volumes", impact.Messages).Debug("request denied, peer has bricks")
+		restutils.SendHTTPError(w, http.StatusForbidden, "cannot delete peer, peer has bricks: "+strings.Join(impact.Messages, ", "))
 		return
 	}
 
@@ -105,23 +128,3 @@ func deletePeerHandler(w http.ResponseWriter, r *http.Request) {
 	// Save updated store endpoints for restarts
 	store.Store.UpdateEndpoints()
 }
-
-// bricksExist checks if the given peer has any bricks on it
-// TODO: Move this to a more appropriate place
-func bricksExist(id string) (bool, error) {
-	pid := uuid.Parse(id)
-
-	vols, err := volume.GetVolumes()
-	if err != nil {
-		return true, err
-	}
-
-	for _, v := range vols {
-		for _, b := range v.Bricks {
-			if uuid.Equal(pid, b.NodeID) {
-				return true, nil
-			}
-		}
-	}
-	return false, nil
-}
@@ -0,0 +1,104 @@
+package peercommands
+
+import (
+	"github.com/gluster/glusterd2/peer"
+	"github.com/gluster/glusterd2/volume"
+
+	"github.com/pborman/uuid"
+)
+
+const (
+	// impactDegraded is the impact on a volume having a replica set which
+	// loses some but not all of its bricks
+	impactDegraded = "loses-redundancy"
+	// impactOffline is the impact on a volume having a replica set, or a
+	// distribute brick, which loses all of its bricks
+	impactOffline = "offline"
+)
+
+// volumeImpact is the impact of detaching a peer on a volume having bricks on
+// it
+type volumeImpact struct {
+	Name   string          `json:"name"`
+	Status volume.VolState `json:"status"`
+	Bricks []string        `json:"bricks"`
+	Impact string          `json:"impact"`
+}
+
+// detachImpact is the impact of detaching a peer. A peer can be detached only
+// if no volume has bricks on it.
+type detachImpact struct {
+	PeerID   uuid.UUID      `json:"peer-id"`
+	Allowed  bool           `json:"allowed"`
+	Volumes  []volumeImpact `json:"volumes,omitempty"`
+	Messages []string       `json:"messages,omitempty"`
+}
+
+// analyzeVolumeImpact returns the impact on the volume of losing the bricks
+// on the peer, or nil if the volume has no bricks on it. Bricks on peers
+// which are already offline count as lost.
+func analyzeVolumeImpact(v *volume.Volinfo, id uuid.UUID) *volumeImpact {
+	var bricks []string
+	for _, b := range v.Bricks {
+		if uuid.Equal(b.NodeID, id) {
+			bricks = append(bricks, b.Hostname+":"+b.Path)
+		}
+	}
+	if len(bricks) == 0 {
+		return nil
+	}
+
+	setSize := v.ReplicaCount
+	if setSize < 1 {
+		setSize = 1
+	}
+
+	// Every brick lost degrades its replica set at least
+	impact := impactDegraded
+	for i := 0; i < len(v.Bricks); i += setSize {
+		left := 0
+		for j := i; j < i+setSize && j < len(v.Bricks); j++ {
+			b := v.Bricks[j]
+			if !uuid.Equal(b.NodeID, id) && peer.IsOnline(b.NodeID) {
+				left++
+			}
+		}
+		if left == 0 {
+			impact = impactOffline
+			break
+		}
+	}
+
+	return &volumeImpact{
+		Name:   v.Name,
+		Status: v.Status,
+		Bricks: bricks,
+		Impact: impact,
+	}
+}
+
+// analyzeDetachImpact returns the impact of detaching the peer on the volumes
+// of the cluster. Both the real detach and its dry-run go through this, so
+// that the dry-run reports exactly what the detach would refuse.
+func analyzeDetachImpact(id uuid.UUID) (*detachImpact, error) {
+	vols, err := volume.GetVolumes()
+	if err != nil {
+		return nil, err
+	}
+
+	impact := &detachImpact{PeerID: id, Allowed: true}
+	for i := range vols {
+		vi := analyzeVolumeImpact(&vols[i], id)
+		if vi == nil {
+			continue
+		}
+		impact.Volumes = append(impact.Volumes, *vi)
+		impact.Allowed = false
+		if vi.Impact == impactOffline {
+			impact.Messages = append(impact.Messages, "volume "+vi.Name+" would go offline")
+		} else {
+			impact.Messages = append(impact.Messages, "volume "+vi.Name+" would lose redundancy")
+		}
+	}
+	return impact, nil
+}
@@ -18,9 +18,19 @@ func getPeerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if peer, err := peer.GetPeerF(id); err != nil {
-		restutils.SendHTTPError(w, http.StatusNotFound, err.Error())
+	fields, err := restutils.GetFields(r, peer.Peer{})
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if p, err := peer.ResolvePeer(id); err != nil {
+		status := http.StatusNotFound
+		if _, ok := err.(*peer.AmbiguousPeer); ok {
+			status = http.StatusBadRequest
+		}
+		restutils.SendHTTPError(w, status, err.Error())
 	} else {
-		restutils.SendHTTPResponse(w, http.StatusOK, peer)
+		restutils.SendHTTPResponse(w, http.StatusOK, restutils.Project(p, fields))
 	}
 }
@@ -2,15 +2,118 @@ package peercommands
 
 import (
 	"net/http"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/gluster/glusterd2/peer"
 	restutils "github.com/gluster/glusterd2/servers/rest/utils"
 )
 
+// peerListEntry is a peer in the peer list, along with its liveness
+type peerListEntry struct {
+	peer.Peer
+	Online   bool       `json:"online"`
+	LastSeen *time.Time `json:"last-seen,omitempty"`
+}
+
+// getPeersHandler returns the list of peers, ordered by peer ID. The list can
+// be filtered to only the online peers with `online=true`, and paginated with
+// `limit` and either `offset` or `continue`, the token sent in the X-Continue
+// header of the previous page. The total number of peers matching the filter
+// is sent in the X-Total-Count header. The fields of the peers sent can be
+// selected with `fields`.
 func getPeersHandler(w http.ResponseWriter, r *http.Request) {
-	if peers, err := peer.GetPeersF(); err != nil {
+	pagination, err := restutils.GetPagination(r)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	fields, err := restutils.GetFields(r, peerListEntry{})
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var onlineOnly bool
+	if v := r.URL.Query().Get("online"); v != "" {
+		if onlineOnly, err = strconv.ParseBool(v); err != nil {
+			restutils.SendHTTPError(w, http.StatusBadRequest, "invalid value for online")
+			return
+		}
+	}
+
+	// Pages of the unfiltered list are read from the store alone, instead
+	// of all the peers
+	if pagination.Paginated() && !onlineOnly {
+		getPeersPage(w, pagination, fields)
+		return
+	}
+
+	peers, err := peer.GetPeersF()
+	if err != nil {
 		restutils.SendHTTPError(w, http.StatusNotFound, err.Error())
-	} else {
-		restutils.SendHTTPResponse(w, http.StatusOK, peers)
+		return
+	}
+
+	entries := make([]peerListEntry, 0, len(peers))
+	for _, p := range peers {
+		e := newPeerListEntry(p)
+		if onlineOnly && !e.Online {
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	// A stable ordering keeps pages from overlapping or skipping peers
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID.String() < entries[j].ID.String()
+	})
+	total := len(entries)
+	if pagination.After != "" {
+		i := sort.Search(len(entries), func(i int) bool {
+			return entries[i].ID.String() > pagination.After
+		})
+		entries = entries[i:]
+	}
+
+	start, end := pagination.Bounds(len(entries))
+	if end > start {
+		pagination.SetContinue(w, entries[end-1].ID.String(), end < len(entries))
+	}
+	w.Header().Set(restutils.TotalCountHeader, strconv.Itoa(total))
+	restutils.SendHTTPResponse(w, http.StatusOK, restutils.Project(entries[start:end], fields))
+}
+
+// getPeersPage sends a page of the list of all peers
+func getPeersPage(w http.ResponseWriter, pagination *restutils.Pagination, fields []string) {
+	total, err := peer.CountPeers()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	peers, more, err := peer.GetPeersPage(pagination.After, pagination.Offset, pagination.Limit)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	entries := make([]peerListEntry, 0, len(peers))
+	for _, p := range peers {
+		entries = append(entries, newPeerListEntry(p))
+	}
+	if len(entries) > 0 {
+		pagination.SetContinue(w, entries[len(entries)-1].ID.String(), more)
+	}
+	w.Header().Set(restutils.TotalCountHeader, strconv.Itoa(total))
+	restutils.SendHTTPResponse(w, http.StatusOK, restutils.Project(entries, fields))
+}
+
+func newPeerListEntry(p peer.Peer) peerListEntry {
+	e := peerListEntry{Peer: p, Online: peer.IsOnline(p.ID)}
+	if t := peer.LastSeen(p.ID); !t.IsZero() {
+		e.LastSeen = &t
 	}
+	return e
 }
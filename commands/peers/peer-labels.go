@@ -0,0 +1,48 @@
+package peercommands
+
+import (
+	"net/http"
+
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// setPeerLabelsHandler replaces the labels of a peer, which brick placement
+// constraints of volume create and expand refer to
+func setPeerLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	_, logger := restutils.GetReqIDandLogger(r)
+
+	p, err := peer.ResolvePeer(mux.Vars(r)["peerid"])
+	if err != nil {
+		if _, ok := err.(*peer.AmbiguousPeer); ok {
+			restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		restutils.SendHTTPError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	var labels map[string]string
+	if err := utils.GetJSONFromRequest(r, &labels); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, errors.ErrJSONParsingFailed.Error())
+		return
+	}
+	if err := peer.ValidateLabels(labels); err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	p.Labels = labels
+	if err := peer.AddOrUpdatePeer(p); err != nil {
+		logger.WithError(err).WithField("peer", p.ID).Error("failed to store peer labels")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logger.WithField("peer", p.ID).WithField("labels", labels).Info("peer labels changed")
+	restutils.SendHTTPResponse(w, http.StatusOK, p)
+}
@@ -33,12 +33,12 @@ func getPeerServiceClient(address string) (*peerSvcClnt, error) {
 }
 
 // JoinCluster asks the remote peer to join the current cluster by reconfiguring the store with the given config
-func (pc *peerSvcClnt) JoinCluster(conf *StoreConfig) (*JoinRsp, error) {
+func (pc *peerSvcClnt) JoinCluster(ctx context.Context, conf *StoreConfig) (*JoinRsp, error) {
 	args := &JoinReq{
 		gdctx.MyUUID.String(),
 		conf,
 	}
-	rsp, err := pc.client.Join(context.TODO(), args)
+	rsp, err := pc.client.Join(ctx, args)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"rpc":    "PeerService.Join",
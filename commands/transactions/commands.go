@@ -0,0 +1,40 @@
+// Package transactionscommands implements the commands tuning the
+// transactions of a node
+package transactionscommands
+
+import (
+	"github.com/gluster/glusterd2/servers/rest/route"
+)
+
+// Command is a holding struct used to implement the GlusterD Command interface
+type Command struct {
+}
+
+// Routes returns command routes. Required for the Command interface.
+func (c *Command) Routes() route.Routes {
+	return route.Routes{
+		route.Route{
+			Name:         "GetTxnLimits",
+			Method:       "GET",
+			Pattern:      "/transactions/limits",
+			Version:      1,
+			HandlerFunc:  getTxnLimitsHandler,
+			ResponseType: TxnLimits{},
+		},
+		route.Route{
+			Name:         "SetTxnLimits",
+			Method:       "PUT",
+			Pattern:      "/transactions/limits",
+			Version:      1,
+			HandlerFunc:  setTxnLimitsHandler,
+			RequestType:  TxnLimitsReq{},
+			ResponseType: TxnLimits{},
+			Role:         route.RoleAdmin,
+		},
+	}
+}
+
+// RegisterStepFuncs implements a required function for the Command interface
+func (c *Command) RegisterStepFuncs() {
+	return
+}
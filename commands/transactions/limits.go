@@ -0,0 +1,85 @@
+package transactionscommands
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/middleware"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/utils"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// TxnLimits are the limits on the mutating requests served at once by a node.
+// A MaxConcurrent of 0 doesn't limit requests.
+type TxnLimits struct {
+	MaxConcurrent int    `json:"max-concurrent"`
+	MaxQueued     int    `json:"max-queued"`
+	QueueTimeout  string `json:"queue-timeout"`
+}
+
+// TxnLimitsReq changes the limits of a node. Limits which aren't given are
+// left as they are.
+type TxnLimitsReq struct {
+	MaxConcurrent *int   `json:"max-concurrent,omitempty"`
+	MaxQueued     *int   `json:"max-queued,omitempty"`
+	QueueTimeout  string `json:"queue-timeout,omitempty"`
+}
+
+func txnLimitsResp(l middleware.TxnLimits) TxnLimits {
+	return TxnLimits{
+		MaxConcurrent: l.MaxConcurrent,
+		MaxQueued:     l.MaxQueued,
+		QueueTimeout:  l.QueueTimeout.String(),
+	}
+}
+
+func getTxnLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	restutils.SendHTTPResponse(w, http.StatusOK, txnLimitsResp(middleware.GetTxnLimits()))
+}
+
+// setTxnLimitsHandler changes the limits of this node till it is restarted
+func setTxnLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	_, logger := restutils.GetReqIDandLogger(r)
+
+	var req TxnLimitsReq
+	if err := utils.GetJSONFromRequest(r, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, errors.ErrJSONParsingFailed.Error())
+		return
+	}
+
+	limits := middleware.GetTxnLimits()
+	if req.MaxConcurrent != nil {
+		if *req.MaxConcurrent < 0 {
+			restutils.SendHTTPError(w, http.StatusBadRequest, "invalid max-concurrent specified")
+			return
+		}
+		limits.MaxConcurrent = *req.MaxConcurrent
+	}
+	if req.MaxQueued != nil {
+		if *req.MaxQueued < 0 {
+			restutils.SendHTTPError(w, http.StatusBadRequest, "invalid max-queued specified")
+			return
+		}
+		limits.MaxQueued = *req.MaxQueued
+	}
+	if req.QueueTimeout != "" {
+		timeout, err := time.ParseDuration(req.QueueTimeout)
+		if err != nil || timeout <= 0 {
+			restutils.SendHTTPError(w, http.StatusBadRequest, "invalid queue-timeout specified")
+			return
+		}
+		limits.QueueTimeout = timeout
+	}
+
+	middleware.SetTxnLimits(limits)
+	logger.WithFields(log.Fields{
+		"max-concurrent": limits.MaxConcurrent,
+		"max-queued":     limits.MaxQueued,
+		"queue-timeout":  limits.QueueTimeout,
+	}).Info("transaction limits changed")
+
+	restutils.SendHTTPResponse(w, http.StatusOK, txnLimitsResp(limits))
+}
@@ -13,10 +13,11 @@ type Command struct {
 func (c *Command) Routes() route.Routes {
 	return route.Routes{
 		route.Route{
-			Name:        "GetVersion",
-			Method:      "GET",
-			Pattern:     "/version",
-			HandlerFunc: getVersionHandler,
+			Name:         "GetVersion",
+			Method:       "GET",
+			Pattern:      "/version",
+			HandlerFunc:  getVersionHandler,
+			ResponseType: VersionResponse{},
 		},
 	}
 }
@@ -13,12 +13,16 @@ import (
 type VersionResponse struct {
 	GlusterdVersion string
 	APIVersion      int
+	// APIVersions are all the API versions served, which clients can ask
+	// for with the X-Gluster-API-Version header
+	APIVersions []int
 }
 
 func getVersionHandler(w http.ResponseWriter, r *http.Request) {
 	v := VersionResponse{
 		GlusterdVersion: version.GlusterdVersion,
 		APIVersion:      version.APIVersion,
+		APIVersions:     version.APIVersions(),
 	}
 	restutils.SendHTTPResponse(w, http.StatusOK, v)
 }
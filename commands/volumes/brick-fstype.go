@@ -0,0 +1,132 @@
+package volumecommands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/pborman/uuid"
+	config "github.com/spf13/viper"
+)
+
+const brickFSTypesTxnKey = "brickfstypes"
+
+// BrickFSType is the type of the filesystem a brick is on
+type BrickFSType struct {
+	Brick  string `json:"brick"`
+	FSType string `json:"fstype"`
+}
+
+// MixedFSTypesError is returned when the bricks of a volume are on different
+// types of filesystems, with the type of every brick
+type MixedFSTypesError struct {
+	Bricks []BrickFSType
+}
+
+func (e *MixedFSTypesError) Error() string {
+	var bricks []string
+	for _, b := range e.Bricks {
+		bricks = append(bricks, b.Brick+" ("+b.FSType+")")
+	}
+	return "bricks of the volume are on different filesystem types: " + strings.Join(bricks, ", ")
+}
+
+// getBrickFSTypes finds the filesystem types of the bricks on this node.
+// Bricks yet to be created are on the filesystem of their parent.
+func getBrickFSTypes(c transaction.TxnCtx) error {
+	var bricks []brick.Brickinfo
+	if err := c.Get("bricks", &bricks); err != nil {
+		return err
+	}
+
+	fstypes := make(map[string]string)
+	for _, b := range bricks {
+		if !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+			continue
+		}
+		fstype, err := utils.GetFSType(b.Path)
+		if err != nil {
+			return err
+		}
+		fstypes[b.Hostname+":"+b.Path] = fstype
+	}
+
+	c.SetNodeResult(gdctx.MyUUID, brickFSTypesTxnKey, fstypes)
+	return nil
+}
+
+func registerBrickFSTypeStepFuncs() {
+	transaction.RegisterStepFunc(getBrickFSTypes, "brick-fstype.Get")
+}
+
+// checkBrickFSTypes checks that the bricks, which are those of a volume, are
+// all on the same type of filesystem. Bricks on nodes which are offline are
+// left out. Mixed types are only logged, unless brick-fstype-strict is set,
+// as existing volumes may have them.
+func checkBrickFSTypes(reqID string, bricks []brick.Brickinfo) error {
+	strict := config.GetBool("brick-fstype-strict")
+	logger := log.WithField("reqid", reqID)
+
+	var nodes []uuid.UUID
+	seen := make(map[string]bool)
+	for _, b := range bricks {
+		if seen[b.NodeID.String()] || !peer.IsOnline(b.NodeID) {
+			continue
+		}
+		seen[b.NodeID.String()] = true
+		nodes = append(nodes, b.NodeID)
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = nodes
+	txn.Steps = []*transaction.Step{
+		{
+			DoFunc: "brick-fstype.Get",
+			Nodes:  txn.Nodes,
+		},
+	}
+	if err := txn.Ctx.Set("bricks", bricks); err != nil {
+		return err
+	}
+
+	rtxn, err := txn.Do()
+	if err != nil {
+		if strict {
+			return fmt.Errorf("failed to find the filesystem types of the bricks: %s", err.Error())
+		}
+		logger.WithError(err).Warn("failed to find the filesystem types of the bricks")
+		return nil
+	}
+
+	var result []BrickFSType
+	types := make(map[string]bool)
+	for _, node := range nodes {
+		var fstypes map[string]string
+		if err := rtxn.GetNodeResult(node, brickFSTypesTxnKey, &fstypes); err != nil {
+			return err
+		}
+		for b, fstype := range fstypes {
+			result = append(result, BrickFSType{Brick: b, FSType: fstype})
+			types[fstype] = true
+		}
+	}
+	if len(types) < 2 {
+		return nil
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Brick < result[j].Brick })
+	mixed := &MixedFSTypesError{Bricks: result}
+	if strict {
+		return mixed
+	}
+	logger.WithField("bricks", result).Warn(mixed.Error())
+	return nil
+}
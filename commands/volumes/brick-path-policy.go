@@ -0,0 +1,45 @@
+package volumecommands
+
+import (
+	"net/http"
+
+	"github.com/gluster/glusterd2/errors"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+)
+
+// brickPathPolicyHandler returns the cluster brick path policy
+func brickPathPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	policy, err := volume.GetBrickPathPolicy()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	restutils.SendHTTPResponse(w, http.StatusOK, policy)
+}
+
+// brickPathPolicySetHandler replaces the cluster brick path policy, which the
+// bricks of volumes created or expanded later must follow
+func brickPathPolicySetHandler(w http.ResponseWriter, r *http.Request) {
+	_, logger := restutils.GetReqIDandLogger(r)
+
+	var policy volume.BrickPathPolicy
+	if err := utils.GetJSONFromRequest(r, &policy); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, errors.ErrJSONParsingFailed.Error())
+		return
+	}
+	if err := policy.Validate(); err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, "invalid brick path policy: "+err.Error())
+		return
+	}
+
+	if err := volume.SetBrickPathPolicy(&policy); err != nil {
+		logger.WithError(err).Error("failed to store the brick path policy")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logger.WithField("policy", policy).Info("brick path policy changed")
+	restutils.SendHTTPResponse(w, http.StatusOK, &policy)
+}
@@ -0,0 +1,141 @@
+package volumecommands
+
+import (
+	"net/http"
+	"path/filepath"
+
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+const (
+	brickValidateTxnKey string = "brickvalidate"
+
+	// Checks a brick path can fail
+	brickCheckPath  = "path"
+	brickCheckXattr = "xattr"
+)
+
+// BrickValidateReq is a request to validate a brick path on a node
+type BrickValidateReq struct {
+	Path  string `json:"path"`
+	Force bool   `json:"force,omitempty"`
+}
+
+// BrickValidationError tells why a brick path failed validation. Check is
+// the check that failed, one of path or xattr.
+type BrickValidationError struct {
+	NodeID uuid.UUID `json:"node-id"`
+	Path   string    `json:"path"`
+	Check  string    `json:"check"`
+	Reason string    `json:"reason"`
+}
+
+// BrickValidateResp is the response for a brick path which passed validation
+type BrickValidateResp struct {
+	NodeID uuid.UUID `json:"node-id"`
+	Path   string    `json:"path"`
+	Valid  bool      `json:"valid"`
+}
+
+// validateBrickPath validates the brick path without modifying it. A failed
+// validation is a result rather than a step failure, so that failures of the
+// step can be told apart as the node being unreachable.
+func validateBrickPath(c transaction.TxnCtx) error {
+	var req BrickValidateReq
+	if err := c.Get("req", &req); err != nil {
+		return err
+	}
+
+	var result *BrickValidationError
+	opts := utils.BrickValidationOpts{Force: req.Force, ReadOnly: true}
+	if err := utils.ValidateBrickPathStatsWithOpts(req.Path, gdctx.HostName, opts); err != nil {
+		result = &BrickValidationError{Check: brickCheckPath, Reason: err.Error()}
+	} else if err := utils.ValidateXattrSupportWithOpts(req.Path, gdctx.HostName, nil, opts); err != nil {
+		result = &BrickValidationError{Check: brickCheckXattr, Reason: err.Error()}
+	}
+	if result != nil {
+		result.NodeID = gdctx.MyUUID
+		result.Path = req.Path
+	}
+
+	c.SetNodeResult(gdctx.MyUUID, brickValidateTxnKey, result)
+	return nil
+}
+
+func registerBrickValidateStepFuncs() {
+	transaction.RegisterStepFunc(validateBrickPath, "brick-validate.Validate")
+}
+
+func brickValidateHandler(w http.ResponseWriter, r *http.Request) {
+	peerID := mux.Vars(r)["peerid"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	var req BrickValidateReq
+	if err := utils.GetJSONFromRequest(r, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, errors.ErrJSONParsingFailed.Error())
+		return
+	}
+	if req.Path == "" || !filepath.IsAbs(req.Path) {
+		restutils.SendHTTPError(w, http.StatusBadRequest, "brick path must be an absolute path")
+		return
+	}
+	req.Path = filepath.Clean(req.Path)
+
+	p, err := peer.ResolvePeer(peerID)
+	if err != nil {
+		if _, ok := err.(*peer.AmbiguousPeer); ok {
+			restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrPeerNotFound.Error())
+		return
+	}
+	node := p.ID
+	if !peer.IsOnline(node) {
+		restutils.SendHTTPError(w, http.StatusServiceUnavailable, "node is unreachable")
+		return
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = []uuid.UUID{node}
+	txn.Steps = []*transaction.Step{
+		{
+			DoFunc: "brick-validate.Validate",
+			Nodes:  txn.Nodes,
+		},
+	}
+	txn.Ctx.Set("req", &req)
+
+	rtxn, err := txn.Do()
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"error": err.Error(),
+			"peer":  peerID,
+			"brick": req.Path,
+		}).Error("brickValidateHandler: failed to validate brick on node")
+		restutils.SendHTTPError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	var result *BrickValidationError
+	if err := rtxn.GetNodeResult(node, brickValidateTxnKey, &result); err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if result != nil {
+		restutils.SendHTTPResponse(w, http.StatusUnprocessableEntity, result)
+		return
+	}
+
+	restutils.SendHTTPResponse(w, http.StatusOK, BrickValidateResp{NodeID: node, Path: req.Path, Valid: true})
+}
@@ -0,0 +1,124 @@
+package volumecommands
+
+import (
+	"net/http"
+
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+const (
+	brickXattrsTxnKey string = "brickxattrs"
+)
+
+func getBrickXattrs(c transaction.TxnCtx) error {
+	var brickPath string
+	if err := c.Get("brickpath", &brickPath); err != nil {
+		return err
+	}
+
+	xattrs, err := utils.GetGlusterXattrs(brickPath)
+	if err != nil {
+		c.Logger().WithError(err).WithField(
+			"brick", brickPath).Debug("getBrickXattrs: failed to read brick xattrs")
+		return err
+	}
+
+	c.SetNodeResult(gdctx.MyUUID, brickXattrsTxnKey, xattrs)
+	return nil
+}
+
+func registerBrickXattrsStepFuncs() {
+	transaction.RegisterStepFunc(getBrickXattrs, "brick-xattrs.Get")
+}
+
+// isBrickOnNode returns true if the given path is a brick of some volume
+// hosted on the given node
+func isBrickOnNode(nodeID uuid.UUID, brickPath string) (bool, error) {
+	volumes, err := volume.GetVolumes()
+	if err != nil {
+		return false, err
+	}
+
+	for _, v := range volumes {
+		for _, b := range v.Bricks {
+			if uuid.Equal(b.NodeID, nodeID) && b.Path == brickPath {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func brickXattrsHandler(w http.ResponseWriter, r *http.Request) {
+	peerID := mux.Vars(r)["peerid"]
+	brickPath := r.URL.Query().Get("brick")
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	if brickPath == "" {
+		restutils.SendHTTPError(w, http.StatusBadRequest, "brick path not specified")
+		return
+	}
+
+	p, err := peer.ResolvePeer(peerID)
+	if err != nil {
+		if _, ok := err.(*peer.AmbiguousPeer); ok {
+			restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrPeerNotFound.Error())
+		return
+	}
+	node := p.ID
+
+	// Only bricks known to gluster are looked up, so that this can't be
+	// used to inspect arbitrary paths on the node.
+	found, err := isBrickOnNode(node, brickPath)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !found {
+		restutils.SendHTTPError(w, http.StatusNotFound, "brick not found on the node")
+		return
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = []uuid.UUID{node}
+	txn.Steps = []*transaction.Step{
+		{
+			DoFunc: "brick-xattrs.Get",
+			Nodes:  txn.Nodes,
+		},
+	}
+	txn.Ctx.Set("brickpath", brickPath)
+
+	rtxn, err := txn.Do()
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"error": err.Error(),
+			"peer":  peerID,
+			"brick": brickPath,
+		}).Error("brickXattrsHandler: failed to get brick xattrs")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	xattrs := make(map[string]string)
+	if err := rtxn.GetNodeResult(node, brickXattrsTxnKey, &xattrs); err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	restutils.SendHTTPResponse(w, http.StatusOK, xattrs)
+}
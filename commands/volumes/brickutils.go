@@ -1,22 +1,34 @@
 package volumecommands
 
 import (
+	"fmt"
+	"net"
 	"os/exec"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/gluster/glusterd2/brick"
 	"github.com/gluster/glusterd2/daemon"
+	"github.com/gluster/glusterd2/gdctx"
 	"github.com/gluster/glusterd2/peer"
+	"github.com/gluster/glusterd2/pmap"
+	"github.com/gluster/glusterd2/transaction"
 	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
 
 	"github.com/pborman/uuid"
+	config "github.com/spf13/viper"
 )
 
 // BrickStartMaxRetries represents maximum no. of attempts that will be made
 // to start brick processes in case of port clashes.
 const BrickStartMaxRetries = 3
 
+// brickReadyPollInterval is the interval between checks of a brick being
+// started for it serving
+const brickReadyPollInterval = 250 * time.Millisecond
+
 // Until https://review.gluster.org/#/c/16200/ gets into a release.
 // And this is fully safe too as no other well-known errno exists after 132
 const anotherEADDRINUSE = syscall.Errno(0x9E) // 158
@@ -38,12 +50,20 @@ func errorContainsErrno(err error, errno syscall.Errno) bool {
 
 // These functions are used in vol-create, vol-expand and vol-shrink (TBD)
 
-func startBrick(b brick.Brickinfo) error {
+func startBrick(b brick.Brickinfo, limits daemon.Limits, extraArgs []string) error {
+
+	// Fail before the brick process is spawned rather than leaving it
+	// running unbound
+	if err := limits.CheckCPUSet(); err != nil {
+		return err
+	}
 
 	brickDaemon, err := brick.NewGlusterfsd(b)
 	if err != nil {
 		return err
 	}
+	brickDaemon.SetLimits(limits)
+	brickDaemon.SetExtraArgs(extraArgs)
 
 	for i := 0; i < BrickStartMaxRetries; i++ {
 		err = daemon.Start(brickDaemon, true)
@@ -59,8 +79,57 @@ func startBrick(b brick.Brickinfo) error {
 			break
 		}
 	}
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return waitForBrick(b, brickDaemon)
+}
+
+// brickNotReady returns why the brick process isn't serving yet, or an empty
+// string if it is. A brick is serving once it has signed in to the port
+// mapper and accepts connections on its port.
+func brickNotReady(b brick.Brickinfo, brickDaemon *brick.Glusterfsd) (reason string, exited bool) {
+	pid, err := daemon.ReadPidFromFile(brickDaemon.PidFile())
+	if err != nil {
+		return "brick process has no pid file", false
+	}
+	if _, err := daemon.GetProcess(pid); err != nil {
+		return fmt.Sprintf("brick process %d exited", pid), true
+	}
+
+	port := pmap.RegistrySearch(b.Path, pmap.GfPmapPortBrickserver)
+	if port <= 0 {
+		return "brick hasn't signed in to the port mapper", false
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(b.Hostname, strconv.Itoa(port)), brickReadyPollInterval)
+	if err != nil {
+		return fmt.Sprintf("brick isn't accepting connections on port %d", port), false
+	}
+	conn.Close()
+	return "", false
+}
+
+// waitForBrick waits till the brick process just spawned is serving, for at
+// most brick-start-timeout. A process which spawned but failed to initialize
+// fails the start, instead of the brick being reported started.
+func waitForBrick(b brick.Brickinfo, brickDaemon *brick.Glusterfsd) error {
+	timeout := config.GetDuration("brick-start-timeout")
+	if timeout <= 0 {
+		return nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		reason, exited := brickNotReady(b, brickDaemon)
+		if reason == "" {
+			return nil
+		}
+		if exited || time.Now().After(deadline) {
+			return fmt.Errorf("brick %s:%s did not come up within %s: %s", b.Hostname, b.Path, timeout, reason)
+		}
+		time.Sleep(brickReadyPollInterval)
+	}
 }
 
 func stopBrick(b brick.Brickinfo) error {
@@ -118,3 +187,60 @@ func nodesFromBricks(bricks []string) ([]uuid.UUID, error) {
 
 	return nodes, nil
 }
+
+// appendNodes appends the nodes not in the list already
+func appendNodes(nodes []uuid.UUID, more []uuid.UUID) []uuid.UUID {
+	for _, m := range more {
+		present := false
+		for _, n := range nodes {
+			if uuid.Equal(m, n) {
+				present = true
+				break
+			}
+		}
+		if !present {
+			nodes = append(nodes, m)
+		}
+	}
+	return nodes
+}
+
+// recordBrickDevices records the devices of the new bricks on this node in the
+// transaction, to later find bricks whose device changed behind our back. It
+// is done when the bricks are checked, on the nodes of the bricks.
+func recordBrickDevices(c transaction.TxnCtx, bricks []brick.Brickinfo) error {
+	devices := make(map[string]int)
+	for _, b := range bricks {
+		if !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+			continue
+		}
+		id, err := volume.BrickDeviceID(b)
+		if err != nil {
+			return err
+		}
+		devices[b.ID()] = id
+	}
+	c.SetNodeResult(gdctx.MyUUID, newBrickDevicesTxnKey, devices)
+	return nil
+}
+
+// setBrickDevices sets the devices recorded by recordBrickDevices on the
+// nodes of the new bricks, before the bricks are stored
+func setBrickDevices(c transaction.TxnCtx, bricks []brick.Brickinfo) error {
+	var nodes []uuid.UUID
+	for _, b := range bricks {
+		nodes = appendNodes(nodes, []uuid.UUID{b.NodeID})
+	}
+	for _, node := range nodes {
+		var devices map[string]int
+		if err := c.GetNodeResult(node, newBrickDevicesTxnKey, &devices); err != nil {
+			return err
+		}
+		for i := range bricks {
+			if id, ok := devices[bricks[i].ID()]; ok {
+				bricks[i].DeviceID = id
+			}
+		}
+	}
+	return nil
+}
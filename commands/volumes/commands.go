@@ -2,7 +2,13 @@
 package volumecommands
 
 import (
+	"net/http"
+
+	"github.com/gluster/glusterd2/pkg/api"
 	"github.com/gluster/glusterd2/servers/rest/route"
+	"github.com/gluster/glusterd2/volume"
+
+	"github.com/pborman/uuid"
 )
 
 // Command is a holding struct used to implement the GlusterD Command interface
@@ -13,25 +19,325 @@ type Command struct {
 func (c *Command) Routes() route.Routes {
 	return route.Routes{
 		route.Route{
-			Name:        "VolumeCreate",
-			Method:      "POST",
-			Pattern:     "/volumes",
-			Version:     1,
-			HandlerFunc: volumeCreateHandler},
+			Name:           "VolumeCreate",
+			Method:         "POST",
+			Pattern:        "/volumes",
+			Version:        1,
+			HandlerFunc:    volumeCreateHandler,
+			RequestType:    VolCreateRequest{},
+			ResponseType:   volume.Volinfo{},
+			ResponseStatus: http.StatusCreated},
 		route.Route{
-			Name:        "VolumeExpand",
-			Method:      "POST",
-			Pattern:     "/volumes/{volname}/expand",
-			Version:     1,
-			HandlerFunc: volumeExpandHandler},
+			Name:         "VolumeExpand",
+			Method:       "POST",
+			Pattern:      "/volumes/{volname}/expand",
+			Version:      1,
+			HandlerFunc:  volumeExpandHandler,
+			RequestType:  VolExpandReq{},
+			ResponseType: volume.Volinfo{}},
 		// TODO: Implmement volume reset as
 		// DELETE /volumes/{volname}/options
 		route.Route{
-			Name:        "VolumeOptions",
-			Method:      "POST",
-			Pattern:     "/volumes/{volname}/options",
+			Name:         "VolumeVolfile",
+			Method:       "GET",
+			Pattern:      "/volumes/{volname}/volfile",
+			Version:      1,
+			HandlerFunc:  volumeVolfileHandler,
+			ResponseType: ""},
+		route.Route{
+			Name:         "BrickVolfile",
+			Method:       "GET",
+			Pattern:      "/volumes/{volname}/bricks/{brickid}/volfile",
+			Version:      1,
+			HandlerFunc:  brickVolfileHandler,
+			ResponseType: ""},
+		route.Route{
+			Name:           "VolumeBatchCreate",
+			Method:         "POST",
+			Pattern:        "/volumes/batch",
+			Version:        1,
+			HandlerFunc:    volumeBatchCreateHandler,
+			RequestType:    []VolCreateRequest{},
+			ResponseType:   VolBatchResp{},
+			ResponseStatus: http.StatusCreated},
+		route.Route{
+			Name:         "VolumeNFSExport",
+			Method:       "POST",
+			Pattern:      "/volumes/{volname}/nfs/export",
+			Version:      1,
+			HandlerFunc:  volumeNFSExportHandler,
+			RequestType:  VolNFSExportReq{},
+			ResponseType: volume.NFSExport{}},
+		route.Route{
+			Name:        "VolumeNFSUnexport",
+			Method:      "DELETE",
+			Pattern:     "/volumes/{volname}/nfs/export",
+			Version:     1,
+			HandlerFunc: volumeNFSUnexportHandler},
+		route.Route{
+			Name:         "VolumeNFSExportStatus",
+			Method:       "GET",
+			Pattern:      "/volumes/{volname}/nfs/export",
+			Version:      1,
+			HandlerFunc:  volumeNFSStatusHandler,
+			ResponseType: VolNFSExportStatus{}},
+		route.Route{
+			Name:         "VolumeSMBShare",
+			Method:       "POST",
+			Pattern:      "/volumes/{volname}/smb/share",
+			Version:      1,
+			HandlerFunc:  volumeSMBShareHandler,
+			RequestType:  VolSMBShareReq{},
+			ResponseType: volume.SMBShare{}},
+		route.Route{
+			Name:        "VolumeSMBUnshare",
+			Method:      "DELETE",
+			Pattern:     "/volumes/{volname}/smb/share",
 			Version:     1,
-			HandlerFunc: volumeOptionsHandler},
+			HandlerFunc: volumeSMBUnshareHandler},
+		route.Route{
+			Name:         "VolumeSMBShareStatus",
+			Method:       "GET",
+			Pattern:      "/volumes/{volname}/smb/share",
+			Version:      1,
+			HandlerFunc:  volumeSMBStatusHandler,
+			ResponseType: VolSMBShareStatus{}},
+		route.Route{
+			Name:         "VolumeUtilization",
+			Method:       "GET",
+			Pattern:      "/volumes/{volname}/utilization",
+			Version:      1,
+			HandlerFunc:  volumeUtilizationHandler,
+			ResponseType: VolUtilizationResp{}},
+		route.Route{
+			Name:         "VolumeSplitBrain",
+			Method:       "GET",
+			Pattern:      "/volumes/{volname}/split-brain",
+			Version:      1,
+			HandlerFunc:  volumeSplitBrainHandler,
+			ResponseType: VolSplitBrainResp{}},
+		route.Route{
+			Name:         "VolumeSplitBrainResolve",
+			Method:       "POST",
+			Pattern:      "/volumes/{volname}/split-brain/resolve",
+			Version:      1,
+			HandlerFunc:  volumeSplitBrainResolveHandler,
+			RequestType:  VolSplitBrainResolveReq{},
+			ResponseType: VolSplitBrainResolveResp{}},
+		route.Route{
+			Name:         "VolumeConsistencyCheck",
+			Method:       "GET",
+			Pattern:      "/volumes/{volname}/consistency-check",
+			Version:      1,
+			HandlerFunc:  volumeConsistencyCheckHandler,
+			ResponseType: VolConsistencyCheckResp{}},
+		route.Route{
+			Name:         "VolumeGFID",
+			Method:       "GET",
+			Pattern:      "/volumes/{volname}/gfid/{gfid}",
+			Version:      1,
+			HandlerFunc:  volumeGFIDHandler,
+			ResponseType: VolGFIDResp{}},
+		route.Route{
+			Name:         "VolumeClients",
+			Method:       "GET",
+			Pattern:      "/volumes/{volname}/clients",
+			Version:      1,
+			HandlerFunc:  volumeClientsHandler,
+			ResponseType: VolClientsResp{}},
+		route.Route{
+			Name:         "VolumeClientDisconnect",
+			Method:       "POST",
+			Pattern:      "/volumes/{volname}/clients/{clientid}/disconnect",
+			Version:      1,
+			HandlerFunc:  volumeClientDisconnectHandler,
+			ResponseType: VolClientDisconnectResp{}},
+		route.Route{
+			Name:         "VolumeStats",
+			Method:       "GET",
+			Pattern:      "/volumes/{volname}/stats",
+			Version:      1,
+			HandlerFunc:  volumeStatsHandler,
+			ResponseType: VolStatsResp{}},
+		route.Route{
+			Name:         "VolumeStatsReset",
+			Method:       "POST",
+			Pattern:      "/volumes/{volname}/stats/reset",
+			Version:      1,
+			HandlerFunc:  volumeStatsResetHandler,
+			ResponseType: VolStatsResp{}},
+		route.Route{
+			Name:         "VolumeSubdirExports",
+			Method:       "GET",
+			Pattern:      "/volumes/{volname}/subdir-exports",
+			Version:      1,
+			HandlerFunc:  volumeSubdirExportsHandler,
+			ResponseType: []volume.SubdirExport{}},
+		route.Route{
+			Name:           "VolumeSubdirExportAdd",
+			Method:         "POST",
+			Pattern:        "/volumes/{volname}/subdir-exports",
+			Version:        1,
+			HandlerFunc:    volumeSubdirExportAddHandler,
+			RequestType:    volume.SubdirExport{},
+			ResponseType:   []volume.SubdirExport{},
+			ResponseStatus: http.StatusCreated},
+		route.Route{
+			Name:         "VolumeSSLCA",
+			Method:       "GET",
+			Pattern:      "/volumes/{volname}/ssl/ca",
+			Version:      1,
+			HandlerFunc:  volumeSSLCAHandler,
+			ResponseType: ""},
+		route.Route{
+			Name:         "VolumeSSLAllowedCNs",
+			Method:       "PUT",
+			Pattern:      "/volumes/{volname}/ssl/allowed-cns",
+			Version:      1,
+			HandlerFunc:  volumeAllowedCNsHandler,
+			RequestType:  VolAllowedCNsReq{},
+			ResponseType: volume.VolEncryption{}},
+		route.Route{
+			Name:         "VolumeBarrier",
+			Method:       "POST",
+			Pattern:      "/volumes/{volname}/barrier",
+			Version:      1,
+			HandlerFunc:  volumeBarrierHandler,
+			RequestType:  VolBarrierReq{},
+			ResponseType: volume.VolBarrier{}},
+		route.Route{
+			Name:         "VolumeReadOnly",
+			Method:       "POST",
+			Pattern:      "/volumes/{volname}/readonly",
+			Version:      1,
+			HandlerFunc:  volumeReadOnlyHandler,
+			RequestType:  VolReadOnlyReq{},
+			ResponseType: volume.Volinfo{}},
+		route.Route{
+			Name:         "VolumeHeal",
+			Method:       "POST",
+			Pattern:      "/volumes/{volname}/heal",
+			Version:      1,
+			HandlerFunc:  volumeHealHandler,
+			RequestType:  VolHealReq{},
+			ResponseType: volume.Volinfo{}},
+		route.Route{
+			Name:         "VolumeBrickArgs",
+			Method:       "PUT",
+			Pattern:      "/volumes/{volname}/brick-args",
+			Version:      1,
+			HandlerFunc:  volumeBrickArgsHandler,
+			RequestType:  VolBrickArgsReq{},
+			ResponseType: VolBrickArgsReq{}},
+		route.Route{
+			Name:         "VolumeForceRemove",
+			Method:       "POST",
+			Pattern:      "/volumes/{volname}/force-remove",
+			Version:      1,
+			HandlerFunc:  volumeForceRemoveHandler,
+			RequestType:  VolForceRemoveReq{},
+			ResponseType: VolForceRemoveResp{}},
+		route.Route{
+			Name:         "VolumePlan",
+			Method:       "POST",
+			Pattern:      "/volumes/plan",
+			Version:      1,
+			HandlerFunc:  volumePlanHandler,
+			RequestType:  VolPlanReq{},
+			ResponseType: VolPlanResp{}},
+		route.Route{
+			Name:         "VolumeValidate",
+			Method:       "POST",
+			Pattern:      "/volumes/validate",
+			Version:      1,
+			HandlerFunc:  volumeValidateHandler,
+			RequestType:  VolCreateRequest{},
+			ResponseType: VolValidateResp{}},
+		route.Route{
+			Name:         "DefaultVolumeOptions",
+			Method:       "GET",
+			Pattern:      "/cluster/default-volume-options",
+			Version:      1,
+			HandlerFunc:  defaultOptionsHandler,
+			ResponseType: map[string]string{}},
+		route.Route{
+			Name:         "SetDefaultVolumeOptions",
+			Method:       "PUT",
+			Pattern:      "/cluster/default-volume-options",
+			Version:      1,
+			HandlerFunc:  defaultOptionsSetHandler,
+			RequestType:  api.VolOptionReq{},
+			ResponseType: map[string]string{}},
+		route.Route{
+			Name:         "BrickPathPolicy",
+			Method:       "GET",
+			Pattern:      "/cluster/brick-path-policy",
+			Version:      1,
+			HandlerFunc:  brickPathPolicyHandler,
+			ResponseType: volume.BrickPathPolicy{}},
+		route.Route{
+			Name:         "SetBrickPathPolicy",
+			Method:       "PUT",
+			Pattern:      "/cluster/brick-path-policy",
+			Version:      1,
+			HandlerFunc:  brickPathPolicySetHandler,
+			RequestType:  volume.BrickPathPolicy{},
+			ResponseType: volume.BrickPathPolicy{}},
+		route.Route{
+			Name:         "DefaultBrickRoot",
+			Method:       "GET",
+			Pattern:      "/cluster/default-brick-root",
+			Version:      1,
+			HandlerFunc:  defaultBrickRootHandler,
+			ResponseType: volume.DefaultBrickRoot{}},
+		route.Route{
+			Name:         "SetDefaultBrickRoot",
+			Method:       "PUT",
+			Pattern:      "/cluster/default-brick-root",
+			Version:      1,
+			HandlerFunc:  defaultBrickRootSetHandler,
+			RequestType:  volume.DefaultBrickRoot{},
+			ResponseType: volume.DefaultBrickRoot{}},
+		route.Route{
+			Name:           "VolumeClone",
+			Method:         "POST",
+			Pattern:        "/volumes/{volname}/clone",
+			Version:        1,
+			HandlerFunc:    volumeCloneHandler,
+			RequestType:    VolCloneReq{},
+			ResponseType:   volume.Volinfo{},
+			ResponseStatus: http.StatusCreated},
+		route.Route{
+			Name:         "VolumeReplica",
+			Method:       "POST",
+			Pattern:      "/volumes/{volname}/replica",
+			Version:      1,
+			HandlerFunc:  volumeReplicaHandler,
+			RequestType:  VolReplicaReq{},
+			ResponseType: volume.Volinfo{}},
+		route.Route{
+			Name:         "VolumeOptions",
+			Method:       "POST",
+			Pattern:      "/volumes/{volname}/options",
+			Version:      1,
+			HandlerFunc:  volumeOptionsHandler,
+			RequestType:  api.VolOptionReq{},
+			ResponseType: map[string]string{}},
+		route.Route{
+			Name:         "VolumeTuneAdvisor",
+			Method:       "POST",
+			Pattern:      "/volumes/{volname}/tune-advisor",
+			Version:      1,
+			HandlerFunc:  volumeTuneAdvisorHandler,
+			RequestType:  VolTuneAdvisorReq{},
+			ResponseType: VolTuneAdvice{}},
+		route.Route{
+			Name:         "VolumeMigrateOptions",
+			Method:       "POST",
+			Pattern:      "/volumes/{volname}/migrate-options",
+			Version:      1,
+			HandlerFunc:  volumeMigrateOptionsHandler,
+			ResponseType: VolOptionsMigration{}},
 		route.Route{
 			Name:        "VolumeDelete",
 			Method:      "DELETE",
@@ -39,35 +345,71 @@ func (c *Command) Routes() route.Routes {
 			Version:     1,
 			HandlerFunc: volumeDeleteHandler},
 		route.Route{
-			Name:        "VolumeInfo",
-			Method:      "GET",
-			Pattern:     "/volumes/{volname}",
-			Version:     1,
-			HandlerFunc: volumeInfoHandler},
+			Name:         "VolumeInfo",
+			Method:       "GET",
+			Pattern:      "/volumes/{volname}",
+			Version:      1,
+			HandlerFunc:  volumeInfoHandler,
+			ResponseType: volume.Volinfo{}},
 		route.Route{
-			Name:        "VolumeStatus",
-			Method:      "GET",
-			Pattern:     "/volumes/{volname}/status",
-			Version:     1,
-			HandlerFunc: volumeStatusHandler},
+			Name:         "VolumeStatus",
+			Method:       "GET",
+			Pattern:      "/volumes/{volname}/status",
+			Version:      1,
+			HandlerFunc:  volumeStatusHandler,
+			ResponseType: volume.VolStatus{}},
 		route.Route{
-			Name:        "VolumeList",
-			Method:      "GET",
-			Pattern:     "/volumes",
-			Version:     1,
-			HandlerFunc: volumeListHandler},
+			Name:         "VolumeList",
+			Method:       "GET",
+			Pattern:      "/volumes",
+			Version:      1,
+			HandlerFunc:  volumeListHandler,
+			ResponseType: map[string]uuid.UUID{},
+			Paginated:    true},
 		route.Route{
-			Name:        "VolumeStart",
-			Method:      "POST",
-			Pattern:     "/volumes/{volname}/start",
-			Version:     1,
-			HandlerFunc: volumeStartHandler},
+			Name:         "VolumeStart",
+			Method:       "POST",
+			Pattern:      "/volumes/{volname}/start",
+			Version:      1,
+			HandlerFunc:  volumeStartHandler,
+			ResponseType: volume.Volinfo{}},
 		route.Route{
-			Name:        "VolumeStop",
-			Method:      "POST",
-			Pattern:     "/volumes/{volname}/stop",
-			Version:     1,
-			HandlerFunc: volumeStopHandler},
+			Name:         "VolumeStop",
+			Method:       "POST",
+			Pattern:      "/volumes/{volname}/stop",
+			Version:      1,
+			HandlerFunc:  volumeStopHandler,
+			ResponseType: volume.Volinfo{}},
+		route.Route{
+			Name:         "NodeReplace",
+			Method:       "POST",
+			Pattern:      "/nodes/{oldpeerid}/replace",
+			Version:      1,
+			HandlerFunc:  nodeReplaceHandler,
+			RequestType:  NodeReplaceReq{},
+			ResponseType: []brickReplacement{}},
+		route.Route{
+			Name:         "NodeBricks",
+			Method:       "GET",
+			Pattern:      "/nodes/{peerid}/bricks",
+			Version:      1,
+			HandlerFunc:  nodeBricksHandler,
+			ResponseType: []NodeBrick{}},
+		route.Route{
+			Name:         "BrickXattrs",
+			Method:       "GET",
+			Pattern:      "/nodes/{peerid}/bricks/xattrs",
+			Version:      1,
+			HandlerFunc:  brickXattrsHandler,
+			ResponseType: map[string]string{}},
+		route.Route{
+			Name:         "BrickValidate",
+			Method:       "POST",
+			Pattern:      "/nodes/{peerid}/bricks/validate",
+			Version:      1,
+			HandlerFunc:  brickValidateHandler,
+			RequestType:  BrickValidateReq{},
+			ResponseType: BrickValidateResp{}},
 	}
 }
 
@@ -80,4 +422,24 @@ func (c *Command) RegisterStepFuncs() {
 	registerVolStatusStepFuncs()
 	registerVolExpandStepFuncs()
 	registerVolOptionStepFuncs()
+	registerVolReplicaStepFuncs()
+	registerVolPlanStepFuncs()
+	registerVolValidateStepFuncs()
+	registerVolVolfileStepFuncs()
+	registerVolBatchStepFuncs()
+	registerVolNFSStepFuncs()
+	registerVolSMBStepFuncs()
+	registerNodeReplaceStepFuncs()
+	registerBrickXattrsStepFuncs()
+	registerBrickValidateStepFuncs()
+	registerVolBarrierStepFuncs()
+	registerVolReadOnlyStepFuncs()
+	registerNodeBricksStepFuncs()
+	registerVolClientsStepFuncs()
+	registerBrickFSTypeStepFuncs()
+	registerVolForceRemoveStepFuncs()
+	registerVolSplitBrainStepFuncs()
+	registerVolConsistencyCheckStepFuncs()
+	registerVolGFIDStepFuncs()
+	registerVolStatsStepFuncs()
 }
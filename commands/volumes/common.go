@@ -1,10 +1,13 @@
 package volumecommands
 
 import (
+	"net/http"
 	"os"
 	"strings"
 
 	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
 	"github.com/gluster/glusterd2/servers/sunrpc"
 	"github.com/gluster/glusterd2/transaction"
 	"github.com/gluster/glusterd2/utils"
@@ -23,16 +26,36 @@ func (e invalidOptionError) Error() string {
 	return e.option
 }
 
+// glusterdOptions are the volume options used by glusterd itself rather than
+// by the xlators, along with their validators
+var glusterdOptions = map[string]func(string) error{
+	volume.UtilizationThresholdsOption: func(v string) error {
+		_, err := volume.ParseThresholds(v)
+		return err
+	},
+	volume.InodeUtilizationThresholdsOption: func(v string) error {
+		_, err := volume.ParseThresholds(v)
+		return err
+	},
+}
+
 func areOptionNamesValid(optsFromReq map[string]string) error {
 
 	var xlOptFound bool
-	for o := range optsFromReq {
+	for o, v := range optsFromReq {
 
 		tmp := strings.Split(strings.TrimSpace(o), ".")
 		if !(len(tmp) == 2 || len(tmp) == 3) {
 			return invalidOptionError{option: o}
 		}
 
+		if validate, ok := glusterdOptions[strings.TrimSpace(o)]; ok {
+			if validate(v) != nil {
+				return invalidOptionError{option: o}
+			}
+			continue
+		}
+
 		_, xlatorType, xlatorOption := volume.SplitVolumeOptionName(o)
 
 		options, ok := xlator.AllOptions[xlatorType]
@@ -124,3 +147,39 @@ func storeVolume(c transaction.TxnCtx) error {
 
 	return nil
 }
+
+// checkFeatureSupported sends an error naming the peers which don't support
+// the version-gated feature, and returns false, if some online peer doesn't
+func checkFeatureSupported(w http.ResponseWriter, feature string) bool {
+	err := peer.CheckFeature(feature)
+	switch err.(type) {
+	case nil:
+		return true
+	case *peer.FeatureNotSupported:
+		restutils.SendHTTPError(w, http.StatusConflict, err.Error())
+	default:
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+	}
+	return false
+}
+
+// reachableVolNodes returns the nodes of the volume which are alive, and the
+// bricks on the other nodes. The commands which only read the bricks of a
+// volume on those nodes, like its statistics or split-brain files, don't
+// modify any state, so their transactions take no lock.
+func reachableVolNodes(vol *volume.Volinfo) ([]uuid.UUID, []string) {
+	var nodes []uuid.UUID
+	var unreachable []string
+	for _, node := range vol.Nodes() {
+		if peer.IsOnline(node) {
+			nodes = append(nodes, node)
+			continue
+		}
+		for _, b := range vol.Bricks {
+			if uuid.Equal(b.NodeID, node) {
+				unreachable = append(unreachable, b.ID())
+			}
+		}
+	}
+	return nodes, unreachable
+}
@@ -0,0 +1,50 @@
+package volumecommands
+
+import (
+	"net/http"
+	"path/filepath"
+
+	"github.com/gluster/glusterd2/errors"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+)
+
+// defaultBrickRootHandler returns the cluster default brick root
+func defaultBrickRootHandler(w http.ResponseWriter, r *http.Request) {
+	root, err := volume.GetDefaultBrickRoot()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	restutils.SendHTTPResponse(w, http.StatusOK, root)
+}
+
+// defaultBrickRootSetHandler replaces the cluster default brick root, which
+// the bricks of volumes created later with only a list of nodes are placed
+// under. An empty root unsets it.
+func defaultBrickRootSetHandler(w http.ResponseWriter, r *http.Request) {
+	_, logger := restutils.GetReqIDandLogger(r)
+
+	var root volume.DefaultBrickRoot
+	if err := utils.GetJSONFromRequest(r, &root); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, errors.ErrJSONParsingFailed.Error())
+		return
+	}
+	if err := root.Validate(); err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if root.Root != "" {
+		root.Root = filepath.Clean(root.Root)
+	}
+
+	if err := volume.SetDefaultBrickRoot(&root); err != nil {
+		logger.WithError(err).Error("failed to store the default brick root")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logger.WithField("root", root.Root).Info("default brick root changed")
+	restutils.SendHTTPResponse(w, http.StatusOK, &root)
+}
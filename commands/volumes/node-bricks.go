@@ -0,0 +1,206 @@
+package volumecommands
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/daemon"
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	"github.com/gluster/glusterd2/pkg/api"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+const (
+	brickOnlineTxnKey string = "brickonline"
+)
+
+// The statuses of a brick on a node
+const (
+	NodeBrickOnline      = "online"
+	NodeBrickOffline     = "offline"
+	NodeBrickStopped     = "stopped"
+	NodeBrickUnreachable = "unreachable"
+)
+
+// NodeBrickCapacity is the last sampled space and inode utilization of a
+// brick
+type NodeBrickCapacity struct {
+	Total         api.Uint64 `json:"total"`
+	Used          api.Uint64 `json:"used"`
+	Percent       int        `json:"percent"`
+	InodesTotal   api.Uint64 `json:"inodes-total,omitempty"`
+	InodesUsed    api.Uint64 `json:"inodes-used,omitempty"`
+	InodesPercent int        `json:"inodes-percent,omitempty"`
+	Sampled       time.Time  `json:"sampled"`
+}
+
+// NodeBrick is a brick on a node. Status is offline for bricks of started
+// volumes whose process isn't running, stopped for bricks of volumes which
+// aren't started, and unreachable if the node is down. Capacity is missing
+// for bricks whose utilization hasn't been sampled.
+type NodeBrick struct {
+	ID       string             `json:"id"`
+	Volume   string             `json:"volume"`
+	VolumeID uuid.UUID          `json:"volume-id"`
+	Path     string             `json:"path"`
+	Status   string             `json:"status"`
+	Capacity *NodeBrickCapacity `json:"capacity,omitempty"`
+}
+
+func checkBricksOnline(c transaction.TxnCtx) error {
+	var bricks []brick.Brickinfo
+	if err := c.Get("bricks", &bricks); err != nil {
+		return err
+	}
+
+	online := make(map[string]bool)
+	for _, b := range bricks {
+		brickDaemon, err := brick.NewGlusterfsd(b)
+		if err != nil {
+			return err
+		}
+		pid, err := daemon.ReadPidFromFile(brickDaemon.PidFile())
+		if err == nil {
+			_, err = daemon.GetProcess(pid)
+		}
+		online[b.ID()] = err == nil
+	}
+
+	c.SetNodeResult(gdctx.MyUUID, brickOnlineTxnKey, online)
+	return nil
+}
+
+func registerNodeBricksStepFuncs() {
+	transaction.RegisterStepFunc(checkBricksOnline, "node-bricks.CheckOnline")
+}
+
+// nodeBricksHandler lists the bricks on a node, from the brick index, with
+// their volume, status and capacity
+func nodeBricksHandler(w http.ResponseWriter, r *http.Request) {
+	peerID := mux.Vars(r)["peerid"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	p, err := peer.ResolvePeer(peerID)
+	if err != nil {
+		if _, ok := err.(*peer.AmbiguousPeer); ok {
+			restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrPeerNotFound.Error())
+		return
+	}
+	node := p.ID
+
+	entries, err := volume.GetNodeBricks(node)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp := make([]NodeBrick, 0, len(entries))
+	volumes := make(map[string]*volume.Volinfo)
+	capacities := make(map[string]*NodeBrickCapacity)
+	var started []brick.Brickinfo
+	for _, e := range entries {
+		vol, ok := volumes[e.Volume]
+		if !ok {
+			if vol, err = volume.GetVolume(e.Volume); err != nil {
+				restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			volumes[e.Volume] = vol
+
+			utilizations, err := volume.GetBrickUtilizations(vol)
+			if err != nil {
+				restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			for _, u := range utilizations {
+				capacities[u.BrickID] = &NodeBrickCapacity{
+					Total:         u.Total,
+					Used:          u.Used,
+					Percent:       u.Percent,
+					InodesTotal:   u.InodesTotal,
+					InodesUsed:    u.InodesUsed,
+					InodesPercent: u.InodesPercent,
+					Sampled:       u.Sampled,
+				}
+			}
+		}
+
+		status := NodeBrickStopped
+		if vol.Status == volume.VolStarted {
+			status = NodeBrickUnreachable
+			for _, b := range vol.Bricks {
+				if b.ID() == e.BrickID {
+					started = append(started, b)
+				}
+			}
+		}
+		resp = append(resp, NodeBrick{
+			ID:       e.BrickID,
+			Volume:   e.Volume,
+			VolumeID: e.VolumeID,
+			Path:     e.Path,
+			Status:   status,
+			Capacity: capacities[e.BrickID],
+		})
+	}
+
+	// Whether the bricks of started volumes are running is only known to
+	// the node, which is asked if it is up
+	if len(started) > 0 && peer.IsOnline(node) {
+		txn := transaction.NewTxn(reqID)
+		defer txn.Cleanup()
+		txn.Nodes = []uuid.UUID{node}
+		txn.Steps = []*transaction.Step{
+			{
+				DoFunc: "node-bricks.CheckOnline",
+				Nodes:  txn.Nodes,
+			},
+		}
+		txn.Ctx.Set("bricks", started)
+
+		rtxn, err := txn.Do()
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"error": err.Error(),
+				"peer":  peerID,
+			}).Error("nodeBricksHandler: failed to check bricks")
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		online := make(map[string]bool)
+		if err := rtxn.GetNodeResult(node, brickOnlineTxnKey, &online); err != nil {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for i := range resp {
+			if up, ok := online[resp[i].ID]; ok {
+				resp[i].Status = NodeBrickOffline
+				if up {
+					resp[i].Status = NodeBrickOnline
+				}
+			}
+		}
+	}
+
+	sort.Slice(resp, func(i, j int) bool {
+		if resp[i].Volume != resp[j].Volume {
+			return resp[i].Volume < resp[j].Volume
+		}
+		return resp[i].Path < resp[j].Path
+	})
+	restutils.SendHTTPResponse(w, http.StatusOK, resp)
+}
@@ -0,0 +1,416 @@
+package volumecommands
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/servers/sunrpc"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volgen"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+// NodeReplaceReq represents a request to replace all the bricks hosted on a
+// node with new bricks. Bricks maps the path of every brick on the node being
+// replaced to the new brick in <host>:<brick-path> format. With Recover, new
+// bricks which already belong to their volume, like the bricks of a node
+// reinstalled with its disks kept, are taken as they are.
+type NodeReplaceReq struct {
+	Bricks  map[string]string `json:"bricks"`
+	Recover bool              `json:"recover,omitempty"`
+}
+
+// brickReplacement pairs a brick being replaced with its replacement
+type brickReplacement struct {
+	Volume   string
+	OldBrick brick.Brickinfo
+	NewBrick brick.Brickinfo
+}
+
+func newBricksFromReplacements(replacements []brickReplacement) []brick.Brickinfo {
+	var bricks []brick.Brickinfo
+	for _, r := range replacements {
+		bricks = append(bricks, r.NewBrick)
+	}
+	return bricks
+}
+
+func checkBricksOnNodeReplace(c transaction.TxnCtx) error {
+
+	var replacements []brickReplacement
+	if err := c.Get("replacements", &replacements); err != nil {
+		return err
+	}
+	var recoverBricks bool
+	if err := c.Get("recover", &recoverBricks); err != nil {
+		return err
+	}
+
+	for _, r := range replacements {
+		opts := utils.BrickValidationOpts{Recover: recoverBricks}
+		if _, err := volume.ValidateBrickEntriesWithOpts([]brick.Brickinfo{r.NewBrick}, r.NewBrick.VolumeID, opts); err != nil {
+			c.Logger().WithError(err).WithField(
+				"brick", r.NewBrick.Path).Debug("checkBricksOnNodeReplace: failed to validate brick")
+			return err
+		}
+	}
+
+	return recordBrickDevices(c, newBricksFromReplacements(replacements))
+}
+
+func undoCheckBricksOnNodeReplace(c transaction.TxnCtx) error {
+
+	var replacements []brickReplacement
+	if err := c.Get("replacements", &replacements); err != nil {
+		return err
+	}
+
+	_ = volume.RemoveBrickPaths(newBricksFromReplacements(replacements))
+	return nil
+}
+
+func startBricksOnNodeReplace(c transaction.TxnCtx) error {
+
+	var replacements []brickReplacement
+	if err := c.Get("replacements", &replacements); err != nil {
+		return err
+	}
+
+	for _, r := range replacements {
+		if !uuid.Equal(r.NewBrick.NodeID, gdctx.MyUUID) {
+			continue
+		}
+
+		volinfo, err := volume.GetVolume(r.Volume)
+		if err != nil {
+			return err
+		}
+
+		if err := volgen.GenerateBrickVolfile(volinfo, &r.NewBrick); err != nil {
+			c.Logger().WithError(err).WithField(
+				"brick", r.NewBrick.Path).Debug("GenerateBrickVolfile: failed to create brick volfile")
+			return err
+		}
+
+		if volinfo.Status != volume.VolStarted {
+			continue
+		}
+
+		c.Logger().WithFields(log.Fields{
+			"volume": r.Volume,
+			"brick":  r.NewBrick.Hostname + ":" + r.NewBrick.Path,
+		}).Info("Starting replacement brick")
+
+		if err := startBrick(r.NewBrick, volinfo.EffectiveBrickLimits(), volinfo.BrickArgs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func undoStartBricksOnNodeReplace(c transaction.TxnCtx) error {
+
+	var replacements []brickReplacement
+	if err := c.Get("replacements", &replacements); err != nil {
+		return err
+	}
+
+	for _, r := range replacements {
+		if !uuid.Equal(r.NewBrick.NodeID, gdctx.MyUUID) {
+			continue
+		}
+
+		b := r.NewBrick
+		c.Logger().WithFields(log.Fields{
+			"volume": b.VolumeName,
+			"brick":  b.Hostname + ":" + b.Path,
+		}).Info("node replace failed, stopping replacement brick")
+
+		if err := stopBrick(b); err != nil {
+			// can't know here which of the new bricks started
+			c.Logger().WithFields(log.Fields{
+				"error":  err,
+				"volume": b.VolumeName,
+				"brick":  b.Hostname + ":" + b.Path,
+			}).Debug("stopping brick failed")
+		}
+
+		if err := volgen.DeleteBrickVolfile(&b); err != nil {
+			c.Logger().WithFields(log.Fields{
+				"error":  err,
+				"volume": b.VolumeName,
+				"brick":  b.Hostname + ":" + b.Path,
+			}).Debug("failed to remove brick volfile")
+		}
+	}
+
+	return nil
+}
+
+func updateVolinfoOnNodeReplace(c transaction.TxnCtx) error {
+
+	var replacements []brickReplacement
+	if err := c.Get("replacements", &replacements); err != nil {
+		return err
+	}
+
+	newBricks := newBricksFromReplacements(replacements)
+	if err := setBrickDevices(c, newBricks); err != nil {
+		return err
+	}
+
+	volinfos := make(map[string]*volume.Volinfo)
+	for i, r := range replacements {
+		r.NewBrick = newBricks[i]
+		volinfo, ok := volinfos[r.Volume]
+		if !ok {
+			v, err := volume.GetVolume(r.Volume)
+			if err != nil {
+				return err
+			}
+			volinfo = v
+			volinfos[r.Volume] = volinfo
+		}
+
+		for i, b := range volinfo.Bricks {
+			if uuid.Equal(b.NodeID, r.OldBrick.NodeID) && b.Path == r.OldBrick.Path {
+				volinfo.Bricks[i] = r.NewBrick
+			}
+		}
+	}
+
+	var updated []*volume.Volinfo
+	for _, volinfo := range volinfos {
+		if err := volgen.GenerateClientVolfile(volinfo); err != nil {
+			c.Logger().WithError(err).WithField(
+				"volume", volinfo.Name).Debug("updateVolinfoOnNodeReplace: failed to create client volfile")
+			return err
+		}
+		updated = append(updated, volinfo)
+	}
+
+	// The volumes are stored together, so that a failure doesn't leave some
+	// of them using the new bricks and the others the old ones
+	if err := volume.AddOrUpdateVolumes(updated); err != nil {
+		c.Logger().WithError(err).Debug("updateVolinfoOnNodeReplace: failed to store volume info")
+		return err
+	}
+
+	return nil
+}
+
+func notifyClientsOnNodeReplace(c transaction.TxnCtx) error {
+	// Clients fetch the new volfile only if the volume is running, and a
+	// single notification covers all the affected volumes.
+	sunrpc.FetchSpecNotify(c)
+	return nil
+}
+
+func registerNodeReplaceStepFuncs() {
+	var sfs = []struct {
+		name string
+		sf   transaction.StepFunc
+	}{
+		{"node-replace.CheckBricks", checkBricksOnNodeReplace},
+		{"node-replace.UndoCheckBricks", undoCheckBricksOnNodeReplace},
+		{"node-replace.StartBricks", startBricksOnNodeReplace},
+		{"node-replace.UndoStartBricks", undoStartBricksOnNodeReplace},
+		{"node-replace.UpdateVolinfo", updateVolinfoOnNodeReplace}, // only on initiator node
+		{"node-replace.NotifyClients", notifyClientsOnNodeReplace},
+	}
+	for _, sf := range sfs {
+		transaction.RegisterStepFunc(sf.sf, sf.name)
+	}
+}
+
+// prepareBrickReplacements matches every brick hosted on the node being
+// replaced against the requested replacements
+func prepareBrickReplacements(oldNode uuid.UUID, req *NodeReplaceReq) ([]brickReplacement, error) {
+
+	volumes, err := volume.GetVolumes()
+	if err != nil {
+		return nil, err
+	}
+
+	var replacements []brickReplacement
+	matched := make(map[string]bool)
+	used := make(map[string]string)
+	for _, v := range volumes {
+		for _, b := range v.Bricks {
+			if !uuid.Equal(b.NodeID, oldNode) {
+				continue
+			}
+
+			newBrick, ok := req.Bricks[b.Path]
+			if !ok {
+				return nil, fmt.Errorf("no replacement given for brick %s of volume %s", b.Path, v.Name)
+			}
+			matched[b.Path] = true
+
+			newBricks, err := volume.NewBrickEntriesFunc([]string{newBrick}, v.Name, v.ID)
+			if err != nil {
+				return nil, err
+			}
+			if uuid.Equal(newBricks[0].NodeID, oldNode) {
+				return nil, fmt.Errorf("replacement brick %s is on the node being replaced", newBrick)
+			}
+			key := newBricks[0].NodeID.String() + ":" + newBricks[0].Path
+			if other, ok := used[key]; ok {
+				return nil, fmt.Errorf("replacement brick %s is given for both bricks %s and %s", newBrick, other, b.Path)
+			}
+			used[key] = b.Path
+
+			replacements = append(replacements, brickReplacement{
+				Volume:   v.Name,
+				OldBrick: b,
+				NewBrick: newBricks[0],
+			})
+		}
+	}
+
+	for path := range req.Bricks {
+		if !matched[path] {
+			return nil, fmt.Errorf("brick %s not found on the node being replaced", path)
+		}
+	}
+
+	return replacements, nil
+}
+
+func nodeReplaceHandler(w http.ResponseWriter, r *http.Request) {
+
+	reqID, logger := restutils.GetReqIDandLogger(r)
+	oldPeerID := mux.Vars(r)["oldpeerid"]
+
+	oldPeer, err := peer.ResolvePeer(oldPeerID)
+	if err != nil {
+		if _, ok := err.(*peer.AmbiguousPeer); ok {
+			restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrPeerNotFound.Error())
+		return
+	}
+	oldNode := oldPeer.ID
+
+	if uuid.Equal(oldNode, gdctx.MyUUID) {
+		restutils.SendHTTPError(w, http.StatusBadRequest, "replacing self is disallowed")
+		return
+	}
+
+	var req NodeReplaceReq
+	if err := utils.GetJSONFromRequest(r, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, errors.ErrJSONParsingFailed.Error())
+		return
+	}
+
+	if len(req.Bricks) == 0 {
+		restutils.SendHTTPError(w, http.StatusBadRequest, errors.ErrEmptyBrickList.Error())
+		return
+	}
+
+	replacements, err := prepareBrickReplacements(oldNode, &req)
+	if err != nil {
+		logger.WithError(err).Error("failed to prepare brick replacements")
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var newBricks []string
+	for _, rep := range replacements {
+		newBricks = append(newBricks, rep.NewBrick.NodeID.String()+":"+rep.NewBrick.Path)
+	}
+	nodes, err := nodesFromBricks(newBricks)
+	if err != nil {
+		logger.WithError(err).Error("could not prepare node list")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = nodes
+
+	// Lock all the affected volumes for the entire duration of the
+	// transaction, so that the per-brick replacements are done together.
+	var locks, unlocks []*transaction.Step
+	locked := make(map[string]bool)
+	for _, rep := range replacements {
+		if locked[rep.Volume] {
+			continue
+		}
+		lock, unlock, err := transaction.CreateLockSteps(rep.Volume)
+		if err != nil {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		locks = append(locks, lock)
+		unlocks = append(unlocks, unlock)
+		locked[rep.Volume] = true
+	}
+
+	txn.Steps = append(txn.Steps, locks...)
+	txn.Steps = append(txn.Steps,
+		&transaction.Step{
+			DoFunc:   "node-replace.CheckBricks",
+			UndoFunc: "node-replace.UndoCheckBricks",
+			Nodes:    txn.Nodes,
+		},
+		&transaction.Step{
+			DoFunc:   "node-replace.StartBricks",
+			UndoFunc: "node-replace.UndoStartBricks",
+			Nodes:    txn.Nodes,
+		},
+		&transaction.Step{
+			DoFunc: "node-replace.UpdateVolinfo",
+			Nodes:  []uuid.UUID{gdctx.MyUUID},
+		},
+		&transaction.Step{
+			DoFunc: "node-replace.NotifyClients",
+			Nodes:  txn.Nodes,
+		},
+	)
+	txn.Steps = append(txn.Steps, unlocks...)
+
+	if err := txn.Ctx.Set("replacements", replacements); err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := txn.Ctx.Set("recover", req.Recover); err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if _, err = txn.Do(); err != nil {
+		logger.WithError(err).WithField("peer", oldPeerID).Error("node replace transaction failed")
+		if err == transaction.ErrLockTimeout {
+			restutils.SendHTTPError(w, http.StatusConflict, err.Error())
+		} else {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	// The replaced node no longer hosts any bricks and is expected to be
+	// dead, so it is removed from the cluster membership directly.
+	if err := peer.DeletePeer(oldNode.String()); err != nil {
+		logger.WithError(err).WithField("peer", oldPeerID).Error("failed to remove replaced peer from the store")
+		restutils.SendHTTPError(w, http.StatusInternalServerError,
+			fmt.Sprintf("bricks were replaced, but failed to remove peer %s: %s", oldPeerID, err.Error()))
+		return
+	}
+
+	logger.WithField("peer", oldPeerID).Info("node replaced")
+	restutils.SendHTTPResponse(w, http.StatusOK, replacements)
+}
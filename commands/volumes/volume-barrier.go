@@ -0,0 +1,178 @@
+package volumecommands
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	gderrors "github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+	config "github.com/spf13/viper"
+)
+
+const (
+	defaultBarrierTimeout = 120
+)
+
+// VolBarrierReq is a request to enable or disable the I/O barrier of a
+// volume. Timeout is the number of seconds after which the barrier is
+// released even if it isn't disabled, the barrier-timeout setting if not
+// given.
+type VolBarrierReq struct {
+	Enable  bool `json:"enable"`
+	Timeout int  `json:"timeout,omitempty"`
+}
+
+func registerVolBarrierStepFuncs() {
+	var sfs = []struct {
+		name string
+		sf   transaction.StepFunc
+	}{
+		{"vol-barrier.RegenerateVolfiles", generateBrickVolfiles},
+		{"vol-barrier.NotifyVolfileChange", notifyVolfileChange},
+		{"vol-barrier.Store", storeVolume},
+	}
+	for _, sf := range sfs {
+		transaction.RegisterStepFunc(sf.sf, sf.name)
+	}
+}
+
+// barrierTimeout returns the barrier timeout for the request, in seconds
+func barrierTimeout(req *VolBarrierReq) (int, error) {
+	max := config.GetInt("barrier-timeout")
+	if max <= 0 {
+		max = defaultBarrierTimeout
+	}
+	if req.Timeout == 0 {
+		return max, nil
+	}
+	if req.Timeout < 0 || req.Timeout > max {
+		return 0, fmt.Errorf("barrier timeout must be between 1 and %d seconds", max)
+	}
+	return req.Timeout, nil
+}
+
+// setVolumeBarrier sets the barrier of the volume on all its bricks. The
+// brick volfiles are regenerated with the barrier, and the bricks are
+// notified to fetch them.
+func setVolumeBarrier(reqID string, volname string, barrier volume.VolBarrier) (int, error) {
+	lock, unlock, err := transaction.CreateLockSteps(volname)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	volinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		return http.StatusNotFound, gderrors.ErrVolNotFound
+	}
+	if barrier.Enabled && volinfo.Status != volume.VolStarted {
+		return http.StatusBadRequest, errors.New("volume must be started to enable the barrier")
+	}
+
+	allNodes, err := peer.GetPeerIDs()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = volinfo.Nodes()
+	txn.Steps = []*transaction.Step{
+		lock,
+		{
+			DoFunc: "vol-barrier.RegenerateVolfiles",
+			Nodes:  txn.Nodes,
+		},
+		{
+			// Bricks fetch their volfiles like the clients do
+			DoFunc: "vol-barrier.NotifyVolfileChange",
+			Nodes:  allNodes,
+		},
+		{
+			DoFunc: "vol-barrier.Store",
+			Nodes:  []uuid.UUID{gdctx.MyUUID},
+		},
+		unlock,
+	}
+
+	volinfo.Barrier = barrier
+	if err := txn.Ctx.Set("volinfo", volinfo); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if _, err := txn.Do(); err != nil {
+		if err == transaction.ErrLockTimeout {
+			return http.StatusConflict, err
+		}
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// releaseExpiredBarrier disables the barrier of the volume once it expires.
+// The bricks release the barrier by themselves, so this only keeps the
+// volfiles and the store in line with them. A barrier enabled again
+// meanwhile is left alone.
+func releaseExpiredBarrier(volname string, expires time.Time) {
+	time.AfterFunc(expires.Sub(time.Now()), func() {
+		volinfo, err := volume.GetVolume(volname)
+		if err != nil || !volinfo.Barrier.Enabled || !volinfo.Barrier.Expires.Equal(expires) {
+			return
+		}
+
+		reqID := uuid.NewRandom().String()
+		if _, err := setVolumeBarrier(reqID, volname, volume.VolBarrier{}); err != nil {
+			log.WithError(err).WithField("volume", volname).Error("failed to disable expired barrier")
+		}
+	})
+}
+
+func volumeBarrierHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	var req VolBarrierReq
+	if err := utils.GetJSONFromRequest(r, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, gderrors.ErrJSONParsingFailed.Error())
+		return
+	}
+
+	var barrier volume.VolBarrier
+	if req.Enable {
+		timeout, err := barrierTimeout(&req)
+		if err != nil {
+			restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		barrier = volume.VolBarrier{
+			Enabled: true,
+			Timeout: timeout,
+			Expires: time.Now().Add(time.Duration(timeout) * time.Second),
+		}
+	}
+
+	status, err := setVolumeBarrier(reqID, volname, barrier)
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"error":  err.Error(),
+			"volume": volname,
+		}).Error("failed to set volume barrier")
+		restutils.SendHTTPError(w, status, err.Error())
+		return
+	}
+
+	if barrier.Enabled {
+		releaseExpiredBarrier(volname, barrier.Expires)
+	}
+	restutils.SendHTTPResponse(w, http.StatusOK, barrier)
+}
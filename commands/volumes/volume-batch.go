@@ -0,0 +1,425 @@
+package volumecommands
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+
+	gderrors "github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	"github.com/pborman/uuid"
+	config "github.com/spf13/viper"
+)
+
+const (
+	// Outcomes of a volume in a batch
+	batchVolCreated    = "created"
+	batchVolFailed     = "failed"
+	batchVolSkipped    = "skipped"
+	batchVolRolledBack = "rolled-back"
+)
+
+// VolBatchResult is the outcome of creating one volume of a batch
+type VolBatchResult struct {
+	Name   string          `json:"name"`
+	Status string          `json:"status"`
+	Error  string          `json:"error,omitempty"`
+	Volume *volume.Volinfo `json:"volume,omitempty"`
+}
+
+// VolBatchResp is the response to a batch volume create request. Results are
+// in the order of the requested volumes.
+type VolBatchResp struct {
+	Results []VolBatchResult `json:"results"`
+}
+
+// batchEntryCtx is the transaction context seen by the volume create step
+// functions for one volume of a batch. The keys of every volume are suffixed
+// with its index in the batch.
+type batchEntryCtx struct {
+	transaction.TxnCtx
+	suffix string
+}
+
+func newBatchEntryCtx(c transaction.TxnCtx, i int) *batchEntryCtx {
+	return &batchEntryCtx{c, fmt.Sprintf(".%d", i)}
+}
+
+func (c *batchEntryCtx) Set(key string, value interface{}) error {
+	return c.TxnCtx.Set(key+c.suffix, value)
+}
+
+func (c *batchEntryCtx) Get(key string, value interface{}) error {
+	return c.TxnCtx.Get(key+c.suffix, value)
+}
+
+func (c *batchEntryCtx) SetNodeResult(nodeID uuid.UUID, key string, value interface{}) error {
+	return c.TxnCtx.SetNodeResult(nodeID, key+c.suffix, value)
+}
+
+func (c *batchEntryCtx) GetNodeResult(nodeID uuid.UUID, key string, value interface{}) error {
+	return c.TxnCtx.GetNodeResult(nodeID, key+c.suffix, value)
+}
+
+func (c *batchEntryCtx) Delete(key string) error {
+	return c.TxnCtx.Delete(key + c.suffix)
+}
+
+// forEachBatchEntry runs the step function for every volume of the batch,
+// stopping at the first failure
+func forEachBatchEntry(c transaction.TxnCtx, sf transaction.StepFunc) error {
+
+	var count int
+	if err := c.Get("batch-count", &count); err != nil {
+		return err
+	}
+
+	for i := 0; i < count; i++ {
+		ec := newBatchEntryCtx(c, i)
+		if err := sf(ec); err != nil {
+			var volinfo volume.Volinfo
+			if e := ec.Get("volinfo", &volinfo); e == nil {
+				return fmt.Errorf("volume %s: %s", volinfo.Name, err.Error())
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func validateVolumeBatch(c transaction.TxnCtx) error {
+	return forEachBatchEntry(c, validateVolumeCreate)
+}
+
+func generateBatchBrickVolfiles(c transaction.TxnCtx) error {
+	return forEachBatchEntry(c, generateBrickVolfiles)
+}
+
+// removeVolumeIDXattrs undoes the marking of the bricks done while
+// validating the volume
+func removeVolumeIDXattrs(c transaction.TxnCtx) error {
+
+	var volinfo volume.Volinfo
+	if err := c.Get("volinfo", &volinfo); err != nil {
+		return err
+	}
+
+	for _, b := range volinfo.Bricks {
+		if !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+			continue
+		}
+		// Empty brick paths have already been removed by the rollback,
+		// and others may have been prepared only partly
+		if err := utils.RemoveBrickXattrs(b.Path, volinfo.ID, true); err != nil {
+			c.Logger().WithError(err).WithField(
+				"brick", b.Path).Debug("removeVolumeIDXattrs: failed to clean up brick")
+		}
+	}
+	return nil
+}
+
+// undoValidateVolumeBatch is run for all the volumes, as the volumes
+// validated before a failure are not known
+func undoValidateVolumeBatch(c transaction.TxnCtx) error {
+
+	var count int
+	if err := c.Get("batch-count", &count); err != nil {
+		return err
+	}
+
+	for i := 0; i < count; i++ {
+		removeVolumeIDXattrs(newBatchEntryCtx(c, i))
+	}
+	return nil
+}
+
+func rollBackVolumeBatch(c transaction.TxnCtx) error {
+
+	var count int
+	if err := c.Get("batch-count", &count); err != nil {
+		return err
+	}
+
+	for i := 0; i < count; i++ {
+		rollBackVolumeCreate(newBatchEntryCtx(c, i))
+	}
+	return nil
+}
+
+// storeVolumeBatch stores all the volumes of the batch. The store step is not
+// undone, so the volumes stored before a failure are deleted here.
+func storeVolumeBatch(c transaction.TxnCtx) error {
+
+	var count int
+	if err := c.Get("batch-count", &count); err != nil {
+		return err
+	}
+
+	var stored []string
+	for i := 0; i < count; i++ {
+		ec := newBatchEntryCtx(c, i)
+		if err := storeNewVolume(ec); err != nil {
+			for _, name := range stored {
+				if e := volume.DeleteVolume(name); e != nil {
+					c.Logger().WithError(e).WithField(
+						"volume", name).Error("storeVolumeBatch: failed to delete stored volume")
+				}
+			}
+			return err
+		}
+
+		var volinfo volume.Volinfo
+		if err := ec.Get("volinfo", &volinfo); err != nil {
+			return err
+		}
+		stored = append(stored, volinfo.Name)
+	}
+	return nil
+}
+
+func registerVolBatchStepFuncs() {
+	var sfs = []struct {
+		name string
+		sf   transaction.StepFunc
+	}{
+		{"vol-batch.Stage", validateVolumeBatch},
+		{"vol-batch.UndoStage", undoValidateVolumeBatch},
+		{"vol-batch.Commit", generateBatchBrickVolfiles},
+		{"vol-batch.Store", storeVolumeBatch},
+		{"vol-batch.Rollback", rollBackVolumeBatch},
+	}
+	for _, sf := range sfs {
+		transaction.RegisterStepFunc(sf.sf, sf.name)
+	}
+}
+
+// prepareVolumeBatch checks the requests and creates the volinfos of the
+// volumes, with the cluster default and recommended options. A volume which can't be created
+// is marked failed in the results.
+func prepareVolumeBatch(reqs []VolCreateRequest, results []VolBatchResult, defaults map[string]string, opVersion int) ([]*volume.Volinfo, bool) {
+
+	volinfos := make([]*volume.Volinfo, len(reqs))
+	names := make(map[string]bool)
+	bricks := make(map[string]string)
+	ok := true
+
+	for i := range reqs {
+		req := &reqs[i]
+		results[i] = VolBatchResult{Name: req.Name, Status: batchVolSkipped}
+
+		fail := func(err error) {
+			results[i].Status = batchVolFailed
+			results[i].Error = err.Error()
+			ok = false
+		}
+
+		if _, err := checkVolCreateRequest(req); err != nil {
+			fail(err)
+			continue
+		}
+		if names[req.Name] {
+			fail(errors.New("volume given more than once in the batch"))
+			continue
+		}
+		names[req.Name] = true
+
+		if volume.ExistsFunc(req.Name) {
+			fail(gderrors.ErrVolExists)
+			continue
+		}
+		if err := areOptionNamesValid(req.Options); err != nil {
+			fail(fmt.Errorf("invalid volume option specified: %s", err.Error()))
+			continue
+		}
+
+		v, err := createVolinfo(req)
+		if err != nil {
+			fail(err)
+			continue
+		}
+		applyCreateOptions(v, defaults, opVersion)
+		if v.ThinArbiter != nil {
+			if err := checkThinArbiterReachable(v.ThinArbiter); err != nil {
+				fail(err)
+				continue
+			}
+		}
+
+		// Bricks of existing volumes are checked while staging, but not
+		// those of the other volumes of the batch as they aren't stored yet.
+		// Paths are cleaned so that /b1 and /b1/ are the same brick.
+		for _, b := range v.Bricks {
+			key := b.NodeID.String() + ":" + filepath.Clean(b.Path)
+			if other, found := bricks[key]; found {
+				err = fmt.Errorf("brick %s:%s is also used by volume %s", b.Hostname, b.Path, other)
+				break
+			}
+			bricks[key] = v.Name
+		}
+		if err != nil {
+			fail(err)
+			continue
+		}
+
+		volinfos[i] = v
+	}
+
+	return volinfos, ok
+}
+
+func volumeBatchCreateHandler(w http.ResponseWriter, r *http.Request) {
+
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	var reqs []VolCreateRequest
+	if err := utils.GetJSONFromRequest(r, &reqs); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, gderrors.ErrJSONParsingFailed.Error())
+		return
+	}
+	if len(reqs) == 0 {
+		restutils.SendHTTPError(w, http.StatusBadRequest, "no volumes given")
+		return
+	}
+
+	defaults, err := volume.GetDefaultOptions()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	opVersion, err := peer.ClusterOpVersion()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	results := make([]VolBatchResult, len(reqs))
+	volinfos, ok := prepareVolumeBatch(reqs, results, defaults, opVersion)
+	if ok {
+		for i, v := range volinfos {
+			if _, err := checkPlacement(logger, reqs[i].Placement, v.Bricks, v.ReplicaCount, 0); err != nil {
+				results[i].Status = batchVolFailed
+				results[i].Error = err.Error()
+				ok = false
+				continue
+			}
+			if err := checkBrickFSTypes(reqID, v.Bricks); err != nil {
+				results[i].Status = batchVolFailed
+				results[i].Error = err.Error()
+				ok = false
+			}
+		}
+	}
+	if !ok {
+		restutils.SendHTTPResponse(w, http.StatusBadRequest, VolBatchResp{results})
+		return
+	}
+
+	var allBricks []string
+	for _, req := range reqs {
+		allBricks = append(allBricks, req.Bricks...)
+	}
+	if max := config.GetInt("max-bricks"); max > 0 && len(allBricks) > max {
+		msg := fmt.Sprintf("batch has %d bricks, more than the maximum of %d", len(allBricks), max)
+		restutils.SendHTTPError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	nodes, err := nodesFromBricks(allBricks)
+	if err != nil {
+		logger.WithError(err).Error("could not prepare node list")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = nodes
+
+	// Volume names are locked in sorted order, so that overlapping batches
+	// can't deadlock
+	var names []string
+	for _, req := range reqs {
+		names = append(names, req.Name)
+	}
+	sort.Strings(names)
+
+	var lockSteps, unlockSteps []*transaction.Step
+	for _, name := range names {
+		lock, unlock, err := transaction.CreateLockSteps(name)
+		if err != nil {
+			logger.WithError(err).Error("failed to create lock steps")
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		lockSteps = append(lockSteps, lock)
+		unlockSteps = append([]*transaction.Step{unlock}, unlockSteps...)
+	}
+
+	txn.Steps = append(lockSteps,
+		&transaction.Step{
+			DoFunc:   "vol-batch.Stage",
+			UndoFunc: "vol-batch.UndoStage",
+			Nodes:    txn.Nodes,
+		},
+		&transaction.Step{
+			DoFunc:   "vol-batch.Commit",
+			UndoFunc: "vol-batch.Rollback",
+			Nodes:    txn.Nodes,
+		},
+		&transaction.Step{
+			DoFunc: "vol-batch.Store",
+			Nodes:  []uuid.UUID{gdctx.MyUUID},
+		},
+	)
+	txn.Steps = append(txn.Steps, unlockSteps...)
+
+	txn.Ctx.Set("batch-count", len(reqs))
+	for i := range reqs {
+		ec := newBatchEntryCtx(txn.Ctx, i)
+		if err := ec.Set("req", &reqs[i]); err != nil {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err := ec.Set("volinfo", volinfos[i]); err != nil {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	c, err := txn.Do()
+	if err != nil {
+		logger.WithError(err).Error("volume batch create transaction failed")
+		for i := range results {
+			results[i].Status = batchVolRolledBack
+			results[i].Error = err.Error()
+		}
+		status := http.StatusInternalServerError
+		if err == transaction.ErrLockTimeout {
+			status = http.StatusConflict
+		}
+		restutils.SendHTTPResponse(w, status, VolBatchResp{results})
+		return
+	}
+
+	for i := range results {
+		var v volume.Volinfo
+		if err := newBatchEntryCtx(c, i).Get("volinfo", &v); err != nil {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, "failed to get volinfo")
+			return
+		}
+		results[i].Status = batchVolCreated
+		results[i].Volume = &v
+	}
+
+	c.Logger().WithField("volumes", names).Info("new volumes created")
+	restutils.SendHTTPResponse(w, http.StatusCreated, VolBatchResp{results})
+}
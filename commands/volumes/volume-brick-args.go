@@ -0,0 +1,90 @@
+package volumecommands
+
+import (
+	"net/http"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+// VolBrickArgsReq sets the extra arguments of the brick processes of a
+// volume. An empty list removes them.
+type VolBrickArgsReq struct {
+	Args []string `json:"args"`
+}
+
+// volumeBrickArgsHandler sets the extra arguments of the brick processes of
+// the volume. They apply to brick processes started from then on, so running
+// bricks get them once the volume is restarted.
+func volumeBrickArgsHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	var req VolBrickArgsReq
+	if err := utils.GetJSONFromRequest(r, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, errors.ErrJSONParsingFailed.Error())
+		return
+	}
+	if err := brick.ValidateExtraArgs(req.Args); err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !checkFeatureSupported(w, "brick-args") {
+		return
+	}
+
+	lock, unlock, err := transaction.CreateLockSteps(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = []uuid.UUID{gdctx.MyUUID}
+	txn.Steps = []*transaction.Step{
+		lock,
+		{
+			DoFunc: "vol-option.UpdateVolinfo",
+			Nodes:  txn.Nodes,
+		},
+		unlock,
+	}
+
+	vol.BrickArgs = req.Args
+	if err := txn.Ctx.Set("volinfo", vol); err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if _, err := txn.Do(); err != nil {
+		logger.WithError(err).WithField("volume", volname).Error("failed to set brick arguments")
+		if err == transaction.ErrLockTimeout {
+			restutils.SendHTTPError(w, http.StatusConflict, err.Error())
+		} else {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	logger.WithFields(log.Fields{
+		"volume": volname,
+		"args":   vol.BrickArgs,
+	}).Info("brick arguments of volume changed")
+	restutils.SendHTTPResponse(w, http.StatusOK, VolBrickArgsReq{Args: vol.BrickArgs})
+}
@@ -0,0 +1,334 @@
+package volumecommands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/pmap"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+const (
+	volClientsTxnKey    string = "volclients"
+	volDisconnectTxnKey string = "voldisconnect"
+)
+
+// VolClient is a connection of a mount of the volume to its bricks. Bricks
+// only know the address and port connections come from, so every mount, and
+// every connection of a mount, is a client of its own. Bricks are the IDs of
+// the bricks it is connected to.
+type VolClient struct {
+	ID      string   `json:"id"`
+	Address string   `json:"address"`
+	Port    int      `json:"port"`
+	Bricks  []string `json:"bricks"`
+}
+
+// VolClientsResp are the clients connected to the bricks of a volume. Bricks
+// on nodes which couldn't be reached aren't looked at, and are listed in
+// UnreachableBricks.
+type VolClientsResp struct {
+	Clients           []VolClient `json:"clients"`
+	UnreachableBricks []string    `json:"unreachable-bricks,omitempty"`
+}
+
+// VolClientDisconnectResp is the result of disconnecting a client from the
+// bricks of a volume
+type VolClientDisconnectResp struct {
+	ID      string   `json:"id"`
+	Address string   `json:"address"`
+	Port    int      `json:"port"`
+	Bricks  []string `json:"bricks"`
+}
+
+// clientID returns the stable identifier of a client of a volume, derived
+// from its address and port the same way as brick IDs:
+//
+//	hex(sha256(address:port)[:16])
+func clientID(conn utils.Connection) string {
+	sum := sha256.Sum256([]byte(net.JoinHostPort(conn.RemoteIP, strconv.Itoa(conn.RemotePort))))
+	return hex.EncodeToString(sum[:16])
+}
+
+// addVolClients adds the connections to a brick to the clients, by client ID
+func addVolClients(clients map[string]*VolClient, brickID string, conns []utils.Connection) {
+	for _, conn := range conns {
+		id := clientID(conn)
+		cl, ok := clients[id]
+		if !ok {
+			cl = &VolClient{ID: id, Address: conn.RemoteIP, Port: conn.RemotePort}
+			clients[id] = cl
+		}
+		if !utils.StringInSlice(brickID, cl.Bricks) {
+			cl.Bricks = append(cl.Bricks, brickID)
+		}
+	}
+}
+
+// localBrickPorts returns the ports of the running bricks of the volume on
+// this node, by BrickID
+func localBrickPorts(vol *volume.Volinfo) map[string]int {
+	ports := make(map[string]int)
+	for _, b := range vol.Bricks {
+		if !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+			continue
+		}
+		if port := pmap.RegistrySearch(b.Path, pmap.GfPmapPortBrickserver); port > 0 {
+			ports[b.ID()] = port
+		}
+	}
+	return ports
+}
+
+func listVolClients(c transaction.TxnCtx) error {
+	var volname string
+	if err := c.Get("volname", &volname); err != nil {
+		return err
+	}
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		return err
+	}
+
+	clients := make(map[string]*VolClient)
+	for brickID, port := range localBrickPorts(vol) {
+		conns, err := utils.ListConnections(port)
+		if err != nil {
+			c.Logger().WithError(err).WithField(
+				"port", port).Error("listVolClients: failed to list connections")
+			return err
+		}
+		addVolClients(clients, brickID, conns)
+	}
+
+	c.SetNodeResult(gdctx.MyUUID, volClientsTxnKey, clients)
+	return nil
+}
+
+func disconnectVolClient(c transaction.TxnCtx) error {
+	var volname string
+	if err := c.Get("volname", &volname); err != nil {
+		return err
+	}
+	var client utils.Connection
+	if err := c.Get("client", &client); err != nil {
+		return err
+	}
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		return err
+	}
+
+	var bricks []string
+	for brickID, port := range localBrickPorts(vol) {
+		conns, err := utils.ListConnections(port)
+		if err != nil {
+			return err
+		}
+		killed := false
+		for _, conn := range conns {
+			if conn != client {
+				continue
+			}
+			if err := utils.KillConnection(port, conn); err != nil {
+				c.Logger().WithError(err).WithFields(log.Fields{
+					"brick":  brickID,
+					"client": client.RemoteIP,
+					"port":   client.RemotePort,
+				}).Error("disconnectVolClient: failed to disconnect client")
+				return err
+			}
+			killed = true
+		}
+		if killed {
+			bricks = append(bricks, brickID)
+		}
+	}
+
+	c.SetNodeResult(gdctx.MyUUID, volDisconnectTxnKey, bricks)
+	return nil
+}
+
+func registerVolClientsStepFuncs() {
+	transaction.RegisterStepFunc(listVolClients, "vol-clients.List")
+	transaction.RegisterStepFunc(disconnectVolClient, "vol-clients.Disconnect")
+}
+
+// findVolClients returns the clients connected to the bricks of the volume on
+// the nodes which are alive, sorted by address and port
+func findVolClients(reqID string, vol *volume.Volinfo) (*VolClientsResp, error) {
+	resp := &VolClientsResp{Clients: []VolClient{}}
+	var nodes []uuid.UUID
+	nodes, resp.UnreachableBricks = reachableVolNodes(vol)
+	if len(nodes) == 0 {
+		return resp, nil
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = nodes
+	txn.Steps = []*transaction.Step{
+		{
+			DoFunc: "vol-clients.List",
+			Nodes:  txn.Nodes,
+		},
+	}
+	txn.Ctx.Set("volname", vol.Name)
+
+	rtxn, err := txn.Do()
+	if err != nil {
+		return nil, err
+	}
+
+	clients := make(map[string]*VolClient)
+	for _, node := range nodes {
+		var tmp map[string]*VolClient
+		if err := rtxn.GetNodeResult(node, volClientsTxnKey, &tmp); err != nil {
+			return nil, err
+		}
+		for id, c := range tmp {
+			if cl, ok := clients[id]; ok {
+				cl.Bricks = append(cl.Bricks, c.Bricks...)
+			} else {
+				clients[id] = c
+			}
+		}
+	}
+	for _, c := range clients {
+		sort.Strings(c.Bricks)
+		resp.Clients = append(resp.Clients, *c)
+	}
+	sort.Slice(resp.Clients, func(i, j int) bool {
+		if resp.Clients[i].Address != resp.Clients[j].Address {
+			return resp.Clients[i].Address < resp.Clients[j].Address
+		}
+		return resp.Clients[i].Port < resp.Clients[j].Port
+	})
+	return resp, nil
+}
+
+// volumeClientsHandler lists the clients connected to the bricks of the
+// volume
+func volumeClientsHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+	if vol.Status != volume.VolStarted {
+		restutils.SendHTTPError(w, http.StatusBadRequest, "volume must be started to list its clients")
+		return
+	}
+
+	resp, err := findVolClients(reqID, vol)
+	if err != nil {
+		logger.WithError(err).WithField("volume", volname).Error("failed to list volume clients")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	restutils.SendHTTPResponse(w, http.StatusOK, resp)
+}
+
+// volumeClientDisconnectHandler closes the connection of a client to the
+// bricks of the volume, leaving the other connections from its address alone.
+// Clients reconnect on their own, so a client which must be kept out also has
+// to be denied access, with a subdirectory export of / for instance.
+func volumeClientDisconnectHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	id := mux.Vars(r)["clientid"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+	if vol.Status != volume.VolStarted {
+		restutils.SendHTTPError(w, http.StatusBadRequest, "volume must be started to disconnect its clients")
+		return
+	}
+
+	clients, err := findVolClients(reqID, vol)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var client *VolClient
+	for i := range clients.Clients {
+		if clients.Clients[i].ID == id {
+			client = &clients.Clients[i]
+		}
+	}
+	if client == nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, "client not connected to the volume")
+		return
+	}
+
+	// Only the nodes the client is connected to are asked to disconnect it
+	var nodes []uuid.UUID
+	seen := make(map[string]bool)
+	for _, b := range vol.Bricks {
+		if utils.StringInSlice(b.ID(), client.Bricks) && !seen[b.NodeID.String()] {
+			seen[b.NodeID.String()] = true
+			nodes = append(nodes, b.NodeID)
+		}
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = nodes
+	txn.Steps = []*transaction.Step{
+		{
+			DoFunc: "vol-clients.Disconnect",
+			Nodes:  txn.Nodes,
+		},
+	}
+	txn.Ctx.Set("volname", volname)
+	txn.Ctx.Set("client", utils.Connection{RemoteIP: client.Address, RemotePort: client.Port})
+
+	rtxn, err := txn.Do()
+	if err != nil {
+		logger.WithError(err).WithFields(log.Fields{
+			"volume": volname,
+			"client": client.Address,
+			"port":   client.Port,
+		}).Error("failed to disconnect client")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp := VolClientDisconnectResp{ID: client.ID, Address: client.Address, Port: client.Port, Bricks: []string{}}
+	for _, node := range nodes {
+		var bricks []string
+		if err := rtxn.GetNodeResult(node, volDisconnectTxnKey, &bricks); err != nil {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		resp.Bricks = append(resp.Bricks, bricks...)
+	}
+	sort.Strings(resp.Bricks)
+
+	logger.WithFields(log.Fields{
+		"volume": volname,
+		"client": client.Address,
+		"port":   client.Port,
+		"bricks": len(resp.Bricks),
+	}).Info("disconnected client from volume")
+	restutils.SendHTTPResponse(w, http.StatusOK, resp)
+}
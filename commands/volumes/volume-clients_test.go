@@ -0,0 +1,28 @@
+package volumecommands
+
+import (
+	"testing"
+
+	"github.com/gluster/glusterd2/tests"
+	"github.com/gluster/glusterd2/utils"
+)
+
+// TestAddVolClients validates that two mounts from the same host are listed
+// as separate clients, each with the bricks it is connected to
+func TestAddVolClients(t *testing.T) {
+	mount1 := utils.Connection{RemoteIP: "10.0.0.1", RemotePort: 1023}
+	mount2 := utils.Connection{RemoteIP: "10.0.0.1", RemotePort: 1022}
+
+	clients := make(map[string]*VolClient)
+	addVolClients(clients, "brick1", []utils.Connection{mount1, mount2})
+	addVolClients(clients, "brick2", []utils.Connection{mount1})
+	tests.Assert(t, len(clients) == 2)
+
+	c1, c2 := clients[clientID(mount1)], clients[clientID(mount2)]
+	tests.Assert(t, c1 != nil && c2 != nil && c1.ID != c2.ID)
+	tests.Assert(t, c1.Address == "10.0.0.1" && c1.Port == 1023)
+	tests.Assert(t, len(c1.Bricks) == 2 && len(c2.Bricks) == 1)
+
+	// The ID is stable
+	tests.Assert(t, clientID(mount1) == clientID(utils.Connection{RemoteIP: "10.0.0.1", RemotePort: 1023}))
+}
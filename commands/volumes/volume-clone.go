@@ -0,0 +1,92 @@
+package volumecommands
+
+import (
+	"fmt"
+	"net/http"
+
+	gderrors "github.com/gluster/glusterd2/errors"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	"github.com/gorilla/mux"
+)
+
+// VolCloneReq represents a request to create a new volume with the same
+// geometry, options, brick settings, encryption and thin-arbiter as an
+// existing volume, but on different bricks. No data is copied from the source
+// volume.
+type VolCloneReq struct {
+	Name   string   `json:"name"`
+	Bricks []string `json:"bricks"`
+	Force  bool     `json:"force,omitempty"`
+}
+
+// newCloneCreateRequest returns the volume create request for a clone of the
+// given volume
+func newCloneCreateRequest(src *volume.Volinfo, req *VolCloneReq) (*VolCreateRequest, error) {
+
+	if req.Name == "" {
+		return nil, gderrors.ErrEmptyVolName
+	}
+	if len(req.Bricks) == 0 {
+		return nil, gderrors.ErrEmptyBrickList
+	}
+	if len(req.Bricks) != len(src.Bricks) {
+		return nil, fmt.Errorf("volume %s has %d bricks, same number of bricks are needed for the clone", src.Name, len(src.Bricks))
+	}
+
+	options := make(map[string]string)
+	for k, v := range src.Options {
+		options[k] = v
+	}
+
+	createReq := &VolCreateRequest{
+		Name:         req.Name,
+		Transport:    src.Transport,
+		ReplicaCount: src.ReplicaCount,
+		Bricks:       req.Bricks,
+		Force:        req.Force,
+		Options:      options,
+		BrickOrder:   src.BrickOrder,
+		BrickLimits:  src.BrickLimits,
+		BrickArgs:    append([]string(nil), src.BrickArgs...),
+		Encryption:   src.Encryption,
+	}
+	createReq.Encryption.AllowedCNs = append([]string(nil), src.Encryption.AllowedCNs...)
+	if src.ThinArbiter != nil {
+		createReq.ThinArbiter = src.ThinArbiter.Address() + ":" + src.ThinArbiter.Path
+	}
+	return createReq, nil
+}
+
+func volumeCloneHandler(w http.ResponseWriter, r *http.Request) {
+	_, logger := restutils.GetReqIDandLogger(r)
+	volname := mux.Vars(r)["volname"]
+
+	src, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, gderrors.ErrVolNotFound.Error())
+		return
+	}
+
+	var req VolCloneReq
+	if err := utils.GetJSONFromRequest(r, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, gderrors.ErrJSONParsingFailed.Error())
+		return
+	}
+
+	createReq, err := newCloneCreateRequest(src, &req)
+	if err != nil {
+		logger.WithError(err).WithField("volume", volname).Error("invalid volume clone request")
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if status, err := checkVolCreateRequest(createReq); err != nil {
+		logger.WithError(err).WithField("volume", volname).Error("invalid volume clone request")
+		restutils.SendHTTPError(w, status, err.Error())
+		return
+	}
+
+	createVolume(w, r, createReq)
+}
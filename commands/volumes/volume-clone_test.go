@@ -0,0 +1,37 @@
+package volumecommands
+
+import (
+	"testing"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/tests"
+	"github.com/gluster/glusterd2/volume"
+)
+
+// TestNewCloneCreateRequest validates that a clone has the geometry and the
+// settings of the source volume
+func TestNewCloneCreateRequest(t *testing.T) {
+	src := &volume.Volinfo{
+		Name:         "vol1",
+		Transport:    "tcp",
+		ReplicaCount: 2,
+		Bricks:       make([]brick.Brickinfo, 2),
+		Options:      map[string]string{"afr.eager-lock": "on"},
+		BrickOrder:   volume.BrickOrderHashed,
+		BrickArgs:    []string{"--brick-arg"},
+		Encryption:   volume.VolEncryption{IO: true, AllowedCNs: []string{"client1"}},
+		ThinArbiter:  &volume.ThinArbiter{Host: "10.0.0.9", Port: "24007", Path: "/ta"},
+	}
+
+	_, err := newCloneCreateRequest(src, &VolCloneReq{Name: "vol2", Bricks: []string{"10.0.0.1:/b1"}})
+	tests.Assert(t, err != nil)
+
+	req, err := newCloneCreateRequest(src, &VolCloneReq{Name: "vol2", Bricks: []string{"10.0.0.1:/b1", "10.0.0.2:/b1"}})
+	tests.Assert(t, err == nil)
+	tests.Assert(t, req.Name == "vol2" && req.ReplicaCount == 2 && req.BrickOrder == volume.BrickOrderHashed)
+	tests.Assert(t, len(req.BrickArgs) == 1 && req.Encryption.IO && len(req.Encryption.AllowedCNs) == 1)
+	tests.Assert(t, req.Options["afr.eager-lock"] == "on")
+
+	ta, err := volume.ParseThinArbiter(req.ThinArbiter)
+	tests.Assert(t, err == nil && *ta == *src.ThinArbiter)
+}
@@ -0,0 +1,159 @@
+package volumecommands
+
+import (
+	"net/http"
+
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+const (
+	brickDeviceCheckTxnKey string = "brickdevicecheck"
+)
+
+// BrickCheckFailure is a brick which couldn't be checked
+type BrickCheckFailure struct {
+	BrickID string `json:"brick-id"`
+	Error   string `json:"error"`
+}
+
+// brickDeviceCheck is the result of checking the devices of the bricks of a
+// node
+type brickDeviceCheck struct {
+	Changes   []volume.BrickDeviceChange
+	Failures  []BrickCheckFailure
+	Unchecked []string
+}
+
+// VolConsistencyCheckResp is the result of checking the bricks of a volume
+// against what was recorded of them. DeviceChanges are the bricks whose
+// device is not the one they were created on. Bricks created before devices
+// were recorded are listed in UncheckedBricks, and bricks on nodes which
+// couldn't be reached in UnreachableBricks.
+type VolConsistencyCheckResp struct {
+	Consistent        bool                       `json:"consistent"`
+	DeviceChanges     []volume.BrickDeviceChange `json:"device-changes"`
+	Failures          []BrickCheckFailure        `json:"failures,omitempty"`
+	UncheckedBricks   []string                   `json:"unchecked-bricks,omitempty"`
+	UnreachableBricks []string                   `json:"unreachable-bricks,omitempty"`
+}
+
+// checkLocalBrickDevices checks the devices of the bricks of the volume on
+// this node
+func checkLocalBrickDevices(vol *volume.Volinfo) *brickDeviceCheck {
+	result := &brickDeviceCheck{}
+	for _, b := range vol.Bricks {
+		if !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+			continue
+		}
+		if b.DeviceID == 0 {
+			result.Unchecked = append(result.Unchecked, b.ID())
+			continue
+		}
+		change, err := volume.CheckBrickDevice(b)
+		if err != nil {
+			result.Failures = append(result.Failures, BrickCheckFailure{b.ID(), err.Error()})
+		} else if change != nil {
+			result.Changes = append(result.Changes, *change)
+		}
+	}
+	return result
+}
+
+func checkBrickDevices(c transaction.TxnCtx) error {
+	var volname string
+	if err := c.Get("volname", &volname); err != nil {
+		return err
+	}
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		return err
+	}
+
+	result := checkLocalBrickDevices(vol)
+	for _, change := range result.Changes {
+		c.Logger().WithFields(log.Fields{
+			"brick":    change.Path,
+			"recorded": change.Recorded,
+			"current":  change.Current,
+		}).Warn("checkBrickDevices: device of brick changed")
+	}
+
+	c.SetNodeResult(gdctx.MyUUID, brickDeviceCheckTxnKey, result)
+	return nil
+}
+
+func registerVolConsistencyCheckStepFuncs() {
+	transaction.RegisterStepFunc(checkBrickDevices, "vol-consistency-check.CheckBrickDevices")
+}
+
+// checkVolumeConsistency checks the bricks of the volume on the nodes which
+// are alive
+func checkVolumeConsistency(reqID string, vol *volume.Volinfo) (*VolConsistencyCheckResp, error) {
+	resp := &VolConsistencyCheckResp{DeviceChanges: []volume.BrickDeviceChange{}}
+
+	var nodes []uuid.UUID
+	nodes, resp.UnreachableBricks = reachableVolNodes(vol)
+
+	if len(nodes) > 0 {
+		txn := transaction.NewTxn(reqID)
+		defer txn.Cleanup()
+		txn.Nodes = nodes
+		txn.Steps = []*transaction.Step{
+			{
+				DoFunc: "vol-consistency-check.CheckBrickDevices",
+				Nodes:  txn.Nodes,
+			},
+		}
+		txn.Ctx.Set("volname", vol.Name)
+
+		rtxn, err := txn.Do()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, node := range nodes {
+			var result brickDeviceCheck
+			if err := rtxn.GetNodeResult(node, brickDeviceCheckTxnKey, &result); err != nil {
+				return nil, err
+			}
+			resp.DeviceChanges = append(resp.DeviceChanges, result.Changes...)
+			resp.Failures = append(resp.Failures, result.Failures...)
+			resp.UncheckedBricks = append(resp.UncheckedBricks, result.Unchecked...)
+		}
+	}
+
+	resp.Consistent = len(resp.DeviceChanges) == 0 && len(resp.Failures) == 0
+	return resp, nil
+}
+
+func volumeConsistencyCheckHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+
+	resp, err := checkVolumeConsistency(reqID, vol)
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"error":  err.Error(),
+			"volume": volname,
+		}).Error("failed to check consistency of bricks")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	restutils.SendHTTPResponse(w, http.StatusOK, resp)
+}
@@ -3,15 +3,36 @@ package volumecommands
 import (
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/daemon"
 	gderrors "github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
 	restutils "github.com/gluster/glusterd2/servers/rest/utils"
 	"github.com/gluster/glusterd2/transaction"
 	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/version"
 	"github.com/gluster/glusterd2/volume"
 
 	"github.com/pborman/uuid"
+	config "github.com/spf13/viper"
+)
+
+const (
+	// defaultReplicaCount is used for replicated volumes if neither the
+	// request nor the configuration gives a replica count
+	defaultReplicaCount = 3
+
+	newBrickDevicesTxnKey string = "newbrickdevices"
+
+	thinArbiterDialTimeout = 5 * time.Second
 )
 
 // VolCreateRequest defines the parameters for creating a volume in the volume-create command
@@ -24,6 +45,98 @@ type VolCreateRequest struct {
 	Options      map[string]string `json:"options,omitempty"`
 	// Bricks list is ordered (like in glusterd1) and decides which bricks
 	// form replica sets.
+
+	// Type can be one of distribute, replicate or distribute-replicate.
+	// It is optional, and needed only to create a replicated volume with
+	// the default replica count.
+	Type string `json:"type,omitempty"`
+
+	// BrickOrder is the ordering of the bricks, one of as-given (the
+	// default) or hashed. See volume.BrickOrderHashed for the trade-offs.
+	BrickOrder string `json:"brick-order,omitempty"`
+
+	// Placement constrains the nodes the bricks can be on, by the labels
+	// of the nodes
+	Placement *volume.PlacementConstraints `json:"placement,omitempty"`
+
+	// BrickLimits are the resource limits of the brick processes
+	BrickLimits daemon.Limits `json:"brick-limits,omitempty"`
+	// BrickArgs are extra arguments of the brick processes
+	BrickArgs []string `json:"brick-args,omitempty"`
+	// StartHeal can be set to false to create a replicated volume with the
+	// self-heal daemon not healing it, till it is enabled later
+	StartHeal *bool `json:"start-heal,omitempty"`
+
+	// Encryption enables on-wire encryption, which requires the SSL
+	// certificates to be present on all the brick nodes
+	Encryption volume.VolEncryption `json:"encryption,omitempty"`
+
+	// BrickTemplate can be given instead of Bricks, to have the brick list
+	// generated by expanding the template against the Nodes list. See
+	// expandBrickTemplate for the template format. If neither is given,
+	// the bricks are placed under the cluster default brick root.
+	BrickTemplate string   `json:"brick-template,omitempty"`
+	Nodes         []string `json:"nodes,omitempty"`
+	BricksPerNode int      `json:"bricks-per-node,omitempty"`
+
+	// ThinArbiter is the thin-arbiter of a replica 2 volume, given as
+	// <host>[:<port>]:<path>. The thin-arbiter node needn't be a peer.
+	ThinArbiter string `json:"thin-arbiter,omitempty"`
+}
+
+// expandBrickTemplate generates a brick list from a brick template such as
+// "{node}:/data/{volume}/brick{index}". For every node, bricksPerNode bricks
+// are generated with {node} replaced by the node, {volume} by the volume name
+// and {index} by the index of the brick on the node, starting from 1. The
+// bricks are ordered index-wise, so that consecutive bricks, which form
+// replica sets, are on different nodes.
+func expandBrickTemplate(template string, volname string, nodes []string, bricksPerNode int) ([]string, error) {
+	if len(nodes) == 0 {
+		return nil, errors.New("node list is empty, nodes are needed to expand the brick template")
+	}
+	if bricksPerNode <= 0 {
+		bricksPerNode = 1
+	}
+
+	var bricks []string
+	seen := make(map[string]bool)
+	for i := 1; i <= bricksPerNode; i++ {
+		for _, node := range nodes {
+			b := strings.NewReplacer(
+				"{node}", node,
+				"{volume}", volname,
+				"{index}", strconv.Itoa(i),
+			).Replace(template)
+
+			_, path, err := utils.ParseHostAndBrickPath(b)
+			if err != nil {
+				return nil, err
+			}
+			if !filepath.IsAbs(path) {
+				return nil, fmt.Errorf("brick path %s generated from template is not absolute", b)
+			}
+
+			b = strings.TrimSuffix(b, path) + filepath.Clean(path)
+			if seen[b] {
+				return nil, fmt.Errorf("brick template generates duplicate brick %s", b)
+			}
+			seen[b] = true
+			bricks = append(bricks, b)
+		}
+	}
+
+	return bricks, nil
+}
+
+// defaultBrickTemplate returns the brick template placing bricks under the
+// cluster default brick root, as {root}/{volume}/brick{index}. It is empty if
+// no default brick root is set.
+func defaultBrickTemplate() (string, error) {
+	root, err := volume.GetDefaultBrickRootFunc()
+	if err != nil || root.Root == "" {
+		return "", err
+	}
+	return "{node}:" + filepath.Join(root.Root, "{volume}", "brick{index}"), nil
 }
 
 func unmarshalVolCreateRequest(msg *VolCreateRequest, r *http.Request) (int, error) {
@@ -31,14 +144,121 @@ func unmarshalVolCreateRequest(msg *VolCreateRequest, r *http.Request) (int, err
 		return 422, gderrors.ErrJSONParsingFailed
 	}
 
+	return checkVolCreateRequest(msg)
+}
+
+// checkVolCreateRequest checks the request for missing parameters, and
+// expands the brick template if one is given
+func checkVolCreateRequest(msg *VolCreateRequest) (int, error) {
+	if errs := volCreateRequestErrors(msg); len(errs) > 0 {
+		return http.StatusBadRequest, errs[0].err
+	}
+	return 0, nil
+}
+
+// requestFieldError is an error with the field of the request it is about,
+// named as in the JSON of the request
+type requestFieldError struct {
+	field string
+	err   error
+}
+
+// volCreateRequestErrors runs all the checks of checkVolCreateRequest and
+// returns every error found, in the order the checks are run in
+func volCreateRequestErrors(msg *VolCreateRequest) []requestFieldError {
+	var errs []requestFieldError
+	add := func(field string, err error) {
+		errs = append(errs, requestFieldError{field, err})
+	}
+
 	if msg.Name == "" {
-		return http.StatusBadRequest, gderrors.ErrEmptyVolName
+		add("name", gderrors.ErrEmptyVolName)
+	}
+	// Bricks given explicitly take precedence over the default brick root
+	if msg.BrickTemplate == "" && len(msg.Bricks) == 0 && len(msg.Nodes) > 0 {
+		template, err := defaultBrickTemplate()
+		switch {
+		case err != nil:
+			add("nodes", err)
+		case template == "":
+			add("nodes", errors.New("nodes can be given without bricks or a brick template only if a default brick root is set"))
+		default:
+			msg.BrickTemplate = template
+		}
+	}
+	if msg.BrickTemplate != "" {
+		if len(msg.Bricks) > 0 {
+			add("brick-template", errors.New("only one of bricks or brick template can be given"))
+		} else if bricks, err := expandBrickTemplate(msg.BrickTemplate, msg.Name, msg.Nodes, msg.BricksPerNode); err != nil {
+			add("brick-template", err)
+		} else {
+			msg.Bricks = bricks
+		}
 	}
 	if len(msg.Bricks) <= 0 {
-		return http.StatusBadRequest, gderrors.ErrEmptyBrickList
+		add("bricks", gderrors.ErrEmptyBrickList)
 	}
-	return 0, nil
+	if err := msg.BrickLimits.Validate(); err != nil {
+		add("brick-limits", err)
+	}
+	if err := brick.ValidateExtraArgs(msg.BrickArgs); err != nil {
+		add("brick-args", err)
+	}
+	if err := volume.ValidateBrickOrder(msg.BrickOrder); err != nil {
+		add("brick-order", err)
+	}
+	if count, err := replicaCountForRequest(msg); err != nil {
+		add("type", err)
+	} else if msg.StartHeal != nil && !*msg.StartHeal && count < 2 {
+		add("start-heal", errSelfHealNotReplicated)
+	}
+	if len(msg.Encryption.AllowedCNs) > 0 && !msg.Encryption.IO {
+		add("encryption", errors.New("allowed client common names can be given only with I/O encryption"))
+	}
+	if err := volume.ValidateAllowedCNs(msg.Encryption.AllowedCNs); err != nil {
+		add("encryption", err)
+	}
+	if max := config.GetInt("max-bricks"); max > 0 && len(msg.Bricks) > max {
+		add("bricks", fmt.Errorf("volume has %d bricks, more than the maximum of %d", len(msg.Bricks), max))
+	}
+	return errs
+}
+
+// replicaCountForRequest returns the replica count of the volume to be
+// created. If the request asks for a replicated volume without giving the
+// replica count, the configured default replica count is used.
+func replicaCountForRequest(req *VolCreateRequest) (int, error) {
+	switch req.Type {
+	case "", "distribute":
+		if req.Type != "" && req.ReplicaCount > 1 {
+			return 0, errors.New("replica count can not be given for a distribute volume")
+		}
+		if req.ReplicaCount == 0 {
+			return 1, nil
+		}
+	case "replicate", "distribute-replicate":
+		if req.ReplicaCount == 0 {
+			count := config.GetInt("default-replica-count")
+			if count < 2 {
+				count = defaultReplicaCount
+			}
+			return count, nil
+		}
+		if req.ReplicaCount < 2 {
+			return 0, fmt.Errorf("replica count of a %s volume must be at least 2", req.Type)
+		}
+	default:
+		return 0, fmt.Errorf("invalid volume type %s", req.Type)
+	}
+	return req.ReplicaCount, nil
+}
 
+// applyCreateOptions sets the cluster default options on the new volume, then
+// the defaults recommended at the cluster op-version for the options still
+// unset. The op-version is recorded so the options can be migrated later.
+func applyCreateOptions(v *volume.Volinfo, defaults map[string]string, opVersion int) {
+	v.ApplyDefaultOptions(defaults)
+	v.ApplyRecommendedOptions(version.OptionDefaults(opVersion), opVersion)
 }
 
 func createVolinfo(req *VolCreateRequest) (*volume.Volinfo, error) {
@@ -60,10 +280,9 @@ func createVolinfo(req *VolCreateRequest) (*volume.Volinfo, error) {
 		v.Transport = "tcp"
 	}
 
-	if req.ReplicaCount == 0 {
-		v.ReplicaCount = 1
-	} else {
-		v.ReplicaCount = req.ReplicaCount
+	v.ReplicaCount, err = replicaCountForRequest(req)
+	if err != nil {
+		return nil, err
 	}
 
 	if (len(req.Bricks) % v.ReplicaCount) != 0 {
@@ -71,28 +290,42 @@ func createVolinfo(req *VolCreateRequest) (*volume.Volinfo, error) {
 	}
 
 	v.DistCount = len(req.Bricks) / v.ReplicaCount
-
-	switch len(req.Bricks) {
-	case 1:
-		fallthrough
-	case v.DistCount:
-		v.Type = volume.Distribute
-	case v.ReplicaCount:
-		v.Type = volume.Replicate
-	default:
-		v.Type = volume.DistReplicate
-	}
+	v.Type = volume.InferVolumeType(len(req.Bricks), v.ReplicaCount)
 
 	v.Bricks, err = volume.NewBrickEntriesFunc(req.Bricks, v.Name, v.ID)
 	if err != nil {
 		return nil, err
 	}
 
+	v.BrickOrder = req.BrickOrder
+	if v.BrickOrder == "" {
+		v.BrickOrder = volume.BrickOrderAsGiven
+	}
+	v.Bricks = volume.OrderBricks(v.Bricks, v.ReplicaCount, v.BrickOrder)
+
+	if req.ThinArbiter != "" {
+		if v.ThinArbiter, err = volume.ParseThinArbiter(req.ThinArbiter); err != nil {
+			return nil, err
+		}
+		if err = volume.ValidateThinArbiter(v.ThinArbiter, v.ReplicaCount, v.Bricks); err != nil {
+			return nil, err
+		}
+	}
+
 	v.Auth = volume.VolAuth{
 		Username: uuid.NewRandom().String(),
 		Password: uuid.NewRandom().String(),
 	}
 
+	v.BrickLimits = req.BrickLimits
+	v.BrickArgs = req.BrickArgs
+	v.SelfHealDisabled = req.StartHeal != nil && !*req.StartHeal
+	v.Encryption = req.Encryption
+	if v.Encryption.IO {
+		v.Options["client.ssl"] = "on"
+		v.Options["server.ssl"] = "on"
+		v.SetAllowedCNs(req.Encryption.AllowedCNs)
+	}
 	v.Status = volume.VolStopped
 
 	return v, nil
@@ -112,6 +345,12 @@ func validateVolumeCreate(c transaction.TxnCtx) error {
 		return err
 	}
 
+	if volinfo.Encryption.IO {
+		if err := utils.CheckSSLCerts(); err != nil {
+			return fmt.Errorf("node %s (%s): %s", gdctx.HostName, gdctx.MyUUID, err.Error())
+		}
+	}
+
 	// FIXME: Return values of this function are inconsistent and unused
 	if _, err = volume.ValidateBrickEntriesFunc(volinfo.Bricks, volinfo.ID, req.Force); err != nil {
 		c.Logger().WithError(err).WithField(
@@ -119,7 +358,35 @@ func validateVolumeCreate(c transaction.TxnCtx) error {
 		return err
 	}
 
-	return nil
+	return recordBrickDevices(c, volinfo.Bricks)
+}
+
+// checkThinArbiterReachable checks that the thin-arbiter process accepts
+// connections
+func checkThinArbiterReachable(ta *volume.ThinArbiter) error {
+	conn, err := net.DialTimeout("tcp", ta.Address(), thinArbiterDialTimeout)
+	if err != nil {
+		return fmt.Errorf("thin-arbiter %s is not reachable: %s", ta.Address(), err.Error())
+	}
+	return conn.Close()
+}
+
+// storeNewVolume stores the volume with the devices of its bricks recorded
+// by each node in the stage
+func storeNewVolume(c transaction.TxnCtx) error {
+	var volinfo volume.Volinfo
+	if err := c.Get("volinfo", &volinfo); err != nil {
+		return err
+	}
+
+	if err := setBrickDevices(c, volinfo.Bricks); err != nil {
+		return err
+	}
+	if err := c.Set("volinfo", &volinfo); err != nil {
+		return err
+	}
+
+	return storeVolume(c)
 }
 
 func rollBackVolumeCreate(c transaction.TxnCtx) error {
@@ -140,7 +407,7 @@ func registerVolCreateStepFuncs() {
 	}{
 		{"vol-create.Stage", validateVolumeCreate},
 		{"vol-create.Commit", generateBrickVolfiles},
-		{"vol-create.Store", storeVolume},
+		{"vol-create.Store", storeNewVolume},
 		{"vol-create.Rollback", rollBackVolumeCreate},
 	}
 	for _, sf := range sfs {
@@ -150,7 +417,7 @@ func registerVolCreateStepFuncs() {
 
 func volumeCreateHandler(w http.ResponseWriter, r *http.Request) {
 	req := new(VolCreateRequest)
-	reqID, logger := restutils.GetReqIDandLogger(r)
+	_, logger := restutils.GetReqIDandLogger(r)
 
 	httpStatus, err := unmarshalVolCreateRequest(req, r)
 	if err != nil {
@@ -158,6 +425,17 @@ func volumeCreateHandler(w http.ResponseWriter, r *http.Request) {
 		restutils.SendHTTPError(w, httpStatus, err.Error())
 		return
 	}
+	if len(req.BrickArgs) > 0 && !checkFeatureSupported(w, "brick-args") {
+		return
+	}
+
+	createVolume(w, r, req)
+}
+
+// createVolume runs the volume create transaction for the given request and
+// sends the response
+func createVolume(w http.ResponseWriter, r *http.Request, req *VolCreateRequest) {
+	reqID, logger := restutils.GetReqIDandLogger(r)
 
 	if volume.ExistsFunc(req.Name) {
 		restutils.SendHTTPError(w, http.StatusInternalServerError, gderrors.ErrVolExists.Error())
@@ -178,6 +456,45 @@ func volumeCreateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	vol, err := createVolinfo(req)
+	if err != nil {
+		logger.WithError(err).Error("failed to create volinfo")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	defaults, err := volume.GetDefaultOptions()
+	if err != nil {
+		logger.WithError(err).Error("failed to get the default volume options")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	opVersion, err := peer.ClusterOpVersion()
+	if err != nil {
+		logger.WithError(err).Error("failed to get the cluster op-version")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	applyCreateOptions(vol, defaults, opVersion)
+
+	if vol.ThinArbiter != nil {
+		if err := checkThinArbiterReachable(vol.ThinArbiter); err != nil {
+			logger.WithError(err).WithField("thin-arbiter", vol.ThinArbiter.Address()).Error("thin-arbiter is unreachable")
+			restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if status, err := checkPlacement(logger, req.Placement, vol.Bricks, vol.ReplicaCount, 0); err != nil {
+		restutils.SendHTTPError(w, status, err.Error())
+		return
+	}
+
+	if err := checkBrickFSTypes(reqID, vol.Bricks); err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	txn, err := (&transaction.SimpleTxn{
 		Nodes:    nodes,
 		LockKey:  req.Name,
@@ -200,13 +517,6 @@ func volumeCreateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vol, err := createVolinfo(req)
-	if err != nil {
-		logger.WithError(err).Error("failed to create volinfo")
-		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
 	err = txn.Ctx.Set("volinfo", vol)
 	if err != nil {
 		logger.WithError(err).Error("failed to set volinfo in transaction context")
@@ -14,6 +14,7 @@ import (
 	"github.com/gluster/glusterd2/volume"
 
 	"github.com/pborman/uuid"
+	config "github.com/spf13/viper"
 
 	heketitests "github.com/heketi/tests"
 )
@@ -49,6 +50,30 @@ func TestUnmarshalVolCreateRequest(t *testing.T) {
 	_, e = unmarshalVolCreateRequest(msg, r)
 	tests.Assert(t, e == nil)
 
+	// Request with brick arguments, which must be allowed
+	msg = new(VolCreateRequest)
+	r, _ = http.NewRequest("POST", "/v1/volumes/", bytes.NewBuffer([]byte(`{"name" : "vol", "bricks":["127.0.0.1:/tmp/b1"], "brick-args":["--log-level=DEBUG", "--xlator-option=*-posix.batch-fsync-mode=none", "--xlator-option=vol-io-threads.thread-count=32"]}`)))
+	_, e = unmarshalVolCreateRequest(msg, r)
+	tests.Assert(t, e == nil && len(msg.BrickArgs) == 3)
+
+	for _, args := range []string{`["--no-daemon"]`, `["--log-level=LOUD"]`, `["--xlator-option=*-server.transport.socket.listen-port=1"]`, `["--mem-accounting --no-daemon"]`,
+		`["--xlator-option=vol-server.auth.addr./b1.allow=*"]`, `["--xlator-option=*-server.ssl=off"]`, `["--xlator-option=*-posix.directory=/etc"]`, `["--xlator-option=*-posix.glusterd-uuid=x"]`} {
+		msg = new(VolCreateRequest)
+		r, _ = http.NewRequest("POST", "/v1/volumes/", bytes.NewBuffer([]byte(`{"name" : "vol", "bricks":["127.0.0.1:/tmp/b1"], "brick-args":`+args+`}`)))
+		_, e = unmarshalVolCreateRequest(msg, r)
+		tests.Assert(t, e != nil)
+	}
+
+	// Self-heal can be left disabled only for replicated volumes
+	msg = new(VolCreateRequest)
+	r, _ = http.NewRequest("POST", "/v1/volumes/", bytes.NewBuffer([]byte(`{"name" : "vol", "bricks":["127.0.0.1:/tmp/b1"], "start-heal":false}`)))
+	_, e = unmarshalVolCreateRequest(msg, r)
+	tests.Assert(t, e == errSelfHealNotReplicated)
+
+	msg = new(VolCreateRequest)
+	r, _ = http.NewRequest("POST", "/v1/volumes/", bytes.NewBuffer([]byte(`{"name" : "vol", "replica": 2, "bricks":["127.0.0.1:/tmp/b1", "127.0.0.1:/tmp/b2"], "start-heal":false}`)))
+	_, e = unmarshalVolCreateRequest(msg, r)
+	tests.Assert(t, e == nil && msg.StartHeal != nil && !*msg.StartHeal)
 }
 
 // TestCreateVolinfo validates createVolinfo()
@@ -99,3 +124,112 @@ func TestValidateVolumeCreate(t *testing.T) {
 	e = validateVolumeCreate(c)
 	tests.Assert(t, e == errBad)
 }
+
+// TestExpandBrickTemplate validates expandBrickTemplate()
+func TestExpandBrickTemplate(t *testing.T) {
+	bricks, e := expandBrickTemplate("{node}:/data/{volume}/brick{index}", "vol", []string{"n1", "n2"}, 2)
+	tests.Assert(t, e == nil)
+	tests.Assert(t, len(bricks) == 4)
+	tests.Assert(t, bricks[0] == "n1:/data/vol/brick1")
+	tests.Assert(t, bricks[1] == "n2:/data/vol/brick1")
+	tests.Assert(t, bricks[3] == "n2:/data/vol/brick2")
+
+	// Multiple bricks per node without {index} collide
+	_, e = expandBrickTemplate("{node}:/data/{volume}/brick", "vol", []string{"n1", "n2"}, 2)
+	tests.Assert(t, e != nil)
+
+	// Relative brick paths are rejected
+	_, e = expandBrickTemplate("{node}:data/brick", "vol", []string{"n1"}, 1)
+	tests.Assert(t, e != nil)
+
+	_, e = expandBrickTemplate("{node}:/data/brick", "vol", nil, 1)
+	tests.Assert(t, e != nil)
+}
+
+// TestReplicaCountForRequest validates replicaCountForRequest()
+func TestReplicaCountForRequest(t *testing.T) {
+	defer config.Set("default-replica-count", config.Get("default-replica-count"))
+	config.Set("default-replica-count", 2)
+
+	c, e := replicaCountForRequest(&VolCreateRequest{})
+	tests.Assert(t, e == nil && c == 1)
+
+	c, e = replicaCountForRequest(&VolCreateRequest{Type: "replicate"})
+	tests.Assert(t, e == nil && c == 2)
+
+	c, e = replicaCountForRequest(&VolCreateRequest{Type: "distribute-replicate", ReplicaCount: 3})
+	tests.Assert(t, e == nil && c == 3)
+
+	_, e = replicaCountForRequest(&VolCreateRequest{Type: "distribute", ReplicaCount: 2})
+	tests.Assert(t, e != nil)
+
+	_, e = replicaCountForRequest(&VolCreateRequest{Type: "stripe"})
+	tests.Assert(t, e != nil)
+
+	_, e = replicaCountForRequest(&VolCreateRequest{Type: "replicate", ReplicaCount: 1})
+	tests.Assert(t, e != nil)
+
+	// Invalid types are rejected with the other checks of the request
+	errs := volCreateRequestErrors(&VolCreateRequest{Name: "vol", Type: "distribute", ReplicaCount: 2, Bricks: []string{"n1:/b1", "n2:/b1"}})
+	tests.Assert(t, len(errs) == 1 && errs[0].field == "type")
+}
+
+// TestDefaultBrickRoot validates generation of brick paths under the default
+// brick root for requests giving only nodes
+func TestDefaultBrickRoot(t *testing.T) {
+	defer func(f func() (*volume.DefaultBrickRoot, error)) { volume.GetDefaultBrickRootFunc = f }(volume.GetDefaultBrickRootFunc)
+	root := new(volume.DefaultBrickRoot)
+	volume.GetDefaultBrickRootFunc = func() (*volume.DefaultBrickRoot, error) {
+		return root, nil
+	}
+
+	req := &VolCreateRequest{Name: "vol", Nodes: []string{"n1", "n2"}}
+	_, e := checkVolCreateRequest(req)
+	tests.Assert(t, e != nil)
+
+	root.Root = "/gluster/bricks/"
+	_, e = checkVolCreateRequest(req)
+	tests.Assert(t, e == nil)
+	tests.Assert(t, len(req.Bricks) == 2)
+	tests.Assert(t, req.Bricks[0] == "n1:/gluster/bricks/vol/brick1")
+
+	// Explicit bricks override the default brick root
+	req = &VolCreateRequest{Name: "vol", Nodes: []string{"n1"}, Bricks: []string{"n1:/data/b1"}}
+	_, e = checkVolCreateRequest(req)
+	tests.Assert(t, e == nil)
+	tests.Assert(t, len(req.Bricks) == 1 && req.Bricks[0] == "n1:/data/b1")
+}
+
+// TestVolCreateRequestErrors validates that all the errors of a request are
+// found, with the fields they are about
+func TestVolCreateRequestErrors(t *testing.T) {
+	errs := volCreateRequestErrors(&VolCreateRequest{BrickOrder: "random"})
+	tests.Assert(t, len(errs) == 3)
+	tests.Assert(t, errs[0].field == "name" && errs[0].err == gderrors.ErrEmptyVolName)
+	tests.Assert(t, errs[1].field == "bricks" && errs[1].err == gderrors.ErrEmptyBrickList)
+	tests.Assert(t, errs[2].field == "brick-order")
+
+	// The first error is the one volume create fails with
+	_, e := checkVolCreateRequest(&VolCreateRequest{BrickOrder: "random"})
+	tests.Assert(t, e == gderrors.ErrEmptyVolName)
+
+	errs = volCreateRequestErrors(&VolCreateRequest{Name: "vol", Bricks: []string{"n1:/b1"}})
+	tests.Assert(t, len(errs) == 0)
+}
+
+// TestPlanReplicaSets validates the arrangement of bricks into replica sets
+// spread across fault domains
+func TestPlanReplicaSets(t *testing.T) {
+	bricks := []string{"n1:/b1", "n1:/b2", "n2:/b1", "n2:/b2"}
+	domains := map[string]string{"n1:/b1": "n1", "n1:/b2": "n1", "n2:/b1": "n2", "n2:/b2": "n2"}
+
+	plan, e := planReplicaSets(bricks, domains, 2)
+	tests.Assert(t, e == nil && len(plan) == 4)
+	for i := 0; i < len(plan); i += 2 {
+		tests.Assert(t, domains[plan[i]] != domains[plan[i+1]])
+	}
+
+	// Fewer domains than the replica count
+	_, e = planReplicaSets([]string{"n1:/b1", "n1:/b2", "n2:/b1"}, domains, 3)
+	tests.Assert(t, e == errTooFewFaultDomains)
+}
@@ -0,0 +1,53 @@
+package volumecommands
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/pkg/api"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+)
+
+// defaultOptionsHandler returns the cluster default options of new volumes
+func defaultOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	opts, err := volume.GetDefaultOptions()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	restutils.SendHTTPResponse(w, http.StatusOK, opts)
+}
+
+// defaultOptionsSetHandler replaces the cluster default options, which are
+// set on every new volume unless given explicitly at create. The options are
+// validated now, so that a bad default doesn't fail every later create.
+func defaultOptionsSetHandler(w http.ResponseWriter, r *http.Request) {
+	_, logger := restutils.GetReqIDandLogger(r)
+
+	var req api.VolOptionReq
+	if err := utils.GetJSONFromRequest(r, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, errors.ErrJSONParsingFailed.Error())
+		return
+	}
+	if req.Options == nil {
+		req.Options = make(map[string]string)
+	}
+
+	if err := areOptionNamesValid(req.Options); err != nil {
+		logger.WithField("option", err.Error()).Error("invalid default option specified")
+		restutils.SendHTTPError(w, http.StatusBadRequest, fmt.Sprintf("invalid option specified: %s", err.Error()))
+		return
+	}
+
+	if err := volume.SetDefaultOptions(req.Options); err != nil {
+		logger.WithError(err).Error("failed to store the default volume options")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logger.WithField("options", req.Options).Info("default volume options changed")
+	restutils.SendHTTPResponse(w, http.StatusOK, req.Options)
+}
@@ -53,6 +53,15 @@ func deleteVolume(c transaction.TxnCtx) error {
 		return err
 	}
 
+	volinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		return err
+	}
+	if err := volume.DeleteIOStatsBaseline(volinfo.ID); err != nil {
+		c.Logger().WithError(err).WithField(
+			"volume", volname).Debug("deleteVolume: failed to delete I/O statistics baseline")
+	}
+
 	return volume.DeleteVolume(volname)
 }
 
@@ -93,20 +102,28 @@ func volumeDeleteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	txn.Nodes = vol.Nodes()
-	txn.Steps = []*transaction.Step{
-		lock,
-		{
+
+	// Volumes are unexported when stopped, but those stopped before may
+	// still be exported
+	unshare, unshareNodes := unshareSteps(vol)
+	txn.Nodes = appendNodes(txn.Nodes, unshareNodes)
+
+	txn.Steps = []*transaction.Step{lock}
+	txn.Steps = append(txn.Steps, unshare...)
+	txn.Steps = append(txn.Steps,
+		&transaction.Step{
 			DoFunc: "vol-delete.Commit",
-			Nodes:  txn.Nodes,
+			Nodes:  vol.Nodes(),
 		},
-		{
+		&transaction.Step{
 			DoFunc: "vol-delete.Store",
 			Nodes:  []uuid.UUID{gdctx.MyUUID},
 		},
 		unlock,
-	}
+	)
 
 	txn.Ctx.Set("volname", volname)
+	txn.Ctx.Set("volinfo", vol)
 	if _, err = txn.Do(); err != nil {
 		logger.WithError(err).WithField(
 			"volume", volname).Error("failed to delete the volume")
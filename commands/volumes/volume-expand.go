@@ -1,6 +1,7 @@
 package volumecommands
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/gluster/glusterd2/brick"
@@ -21,6 +22,15 @@ import (
 type VolExpandReq struct {
 	ReplicaCount int      `json:"replica,omitempty"`
 	Bricks       []string `json:"bricks"`
+	// BrickOrder overrides the brick ordering of the volume for this and
+	// later expands
+	BrickOrder string `json:"brick-order,omitempty"`
+	// Placement constrains the nodes the new bricks can be on, by the
+	// labels of the nodes
+	Placement *volume.PlacementConstraints `json:"placement,omitempty"`
+	// Force allows bricks of a replica set on the same node, as long as
+	// they are on different devices
+	Force bool `json:"force,omitempty"`
 	// TODO: Add other fields like disperse count when we support
 	// that volume type
 }
@@ -37,7 +47,29 @@ func checkBricksOnExpand(c transaction.TxnCtx) error {
 		return err
 	}
 
-	return nil
+	var force bool
+	if err := c.Get("force", &force); err != nil {
+		return err
+	}
+	if err := recordBrickDevices(c, newBricks); err != nil {
+		return err
+	}
+	if !force {
+		return nil
+	}
+
+	// Bricks of a replica set on the same node must at least be on
+	// different devices, which is known only once their paths exist
+	var volinfo volume.Volinfo
+	if err := c.Get("oldvolinfo", &volinfo); err != nil {
+		return err
+	}
+	var newReplicaCount int
+	if err := c.Get("newreplicacount", &newReplicaCount); err != nil {
+		return err
+	}
+	bricks := append(volinfo.Bricks, newBricks...)
+	return volume.ValidateNewReplicaSetDevices(bricks, newReplicaCount, len(volinfo.Bricks))
 }
 
 func startBricksOnExpand(c transaction.TxnCtx) error {
@@ -80,7 +112,7 @@ func startBricksOnExpand(c transaction.TxnCtx) error {
 			"brick":  b.Hostname + ":" + b.Path,
 		}).Info("Starting brick")
 
-		if err := startBrick(b); err != nil {
+		if err := startBrick(b, volinfo.EffectiveBrickLimits(), volinfo.BrickArgs); err != nil {
 			return err
 		}
 	}
@@ -146,8 +178,26 @@ func updateVolinfoOnExpand(c transaction.TxnCtx) error {
 		return err
 	}
 
+	var brickOrder string
+	if err := c.Get("brickorder", &brickOrder); err != nil {
+		return err
+	}
+
+	if err := setBrickDevices(c, newBricks); err != nil {
+		return err
+	}
+
+	// Changing the replica count regroups the replica sets, so the
+	// bricks are kept in the order given then
+	appendOnly := newReplicaCount != volinfo.ReplicaCount
+
 	volinfo.ReplicaCount = newReplicaCount
-	volinfo.Bricks = append(volinfo.Bricks, newBricks...)
+	volinfo.BrickOrder = brickOrder
+	if appendOnly {
+		volinfo.Bricks = append(volinfo.Bricks, newBricks...)
+	} else {
+		volinfo.Bricks = volume.ExpandBricks(volinfo.Bricks, newBricks, volinfo.ReplicaCount, volinfo.BrickOrder)
+	}
 	volinfo.DistCount = len(volinfo.Bricks) / volinfo.ReplicaCount
 
 	switch len(volinfo.Bricks) {
@@ -201,6 +251,14 @@ func volumeExpandHandler(w http.ResponseWriter, r *http.Request) {
 		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, errors.ErrJSONParsingFailed.Error())
 		return
 	}
+	if err := volume.ValidateBrickOrder(req.BrickOrder); err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	brickOrder := volinfo.BrickOrder
+	if req.BrickOrder != "" {
+		brickOrder = req.BrickOrder
+	}
 
 	newBrickCount := len(req.Bricks) + len(volinfo.Bricks)
 
@@ -216,6 +274,14 @@ func volumeExpandHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Without a change of the replica count, the bricks are added as whole
+	// replica sets
+	if newReplicaCount == volinfo.ReplicaCount && len(req.Bricks)%newReplicaCount != 0 {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity,
+			fmt.Sprintf("number of bricks added must be a multiple of the replica count %d", newReplicaCount))
+		return
+	}
+
 	if volinfo.Type == volume.Replicate && req.ReplicaCount != 0 {
 		if req.ReplicaCount < volinfo.ReplicaCount {
 			restutils.SendHTTPError(w, http.StatusUnprocessableEntity, "Invalid number of bricks")
@@ -226,6 +292,32 @@ func volumeExpandHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	newBricks, err := volume.NewBrickEntriesFunc(req.Bricks, volinfo.Name, volinfo.ID)
+	if err != nil {
+		logger.WithError(err).Error("failed to create new brick entries")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if !req.Force {
+		bricks := append(volinfo.Bricks, newBricks...)
+		if err := volume.ValidateNewReplicaSets(bricks, newReplicaCount, len(volinfo.Bricks)); err != nil {
+			restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if status, err := checkPlacement(logger, req.Placement, append(volinfo.Bricks, newBricks...),
+		newReplicaCount, len(volinfo.Bricks)); err != nil {
+		restutils.SendHTTPError(w, status, err.Error())
+		return
+	}
+
+	if err := checkBrickFSTypes(reqID, append(volinfo.Bricks, newBricks...)); err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	lock, unlock, err := transaction.CreateLockSteps(volinfo.Name)
 	if err != nil {
 		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
@@ -265,19 +357,22 @@ func volumeExpandHandler(w http.ResponseWriter, r *http.Request) {
 		unlock,
 	}
 
-	newBricks, err := volume.NewBrickEntriesFunc(req.Bricks, volinfo.Name, volinfo.ID)
-	if err != nil {
-		logger.WithError(err).Error("failed to create new brick entries")
+	if err := txn.Ctx.Set("newbricks", newBricks); err != nil {
 		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	if err := txn.Ctx.Set("newbricks", newBricks); err != nil {
+	if err := txn.Ctx.Set("newreplicacount", newReplicaCount); err != nil {
 		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	if err := txn.Ctx.Set("newreplicacount", newReplicaCount); err != nil {
+	if err := txn.Ctx.Set("force", req.Force); err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := txn.Ctx.Set("brickorder", brickOrder); err != nil {
 		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -0,0 +1,172 @@
+package volumecommands
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gluster/glusterd2/brick"
+	gderrors "github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/events"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volgen"
+	"github.com/gluster/glusterd2/volume"
+
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+const (
+	forceRemoveTxnKey string = "forceremoveerrors"
+)
+
+// VolForceRemoveReq is a request to forcibly remove a volume. Confirm must
+// be the name of the volume, so that the removal isn't triggered by accident.
+type VolForceRemoveReq struct {
+	Confirm string `json:"confirm"`
+}
+
+// ForceRemoveNodeResult is the result of cleaning up the bricks of a volume
+// being forcibly removed on a node. Errors are the cleanups which failed.
+type ForceRemoveNodeResult struct {
+	NodeID    uuid.UUID `json:"node-id"`
+	Reachable bool      `json:"reachable"`
+	Errors    []string  `json:"errors,omitempty"`
+}
+
+// VolForceRemoveResp is the result of forcibly removing a volume
+type VolForceRemoveResp struct {
+	Volume string                  `json:"volume"`
+	Nodes  []ForceRemoveNodeResult `json:"nodes,omitempty"`
+}
+
+// cleanupBricks stops the bricks of the volume on this node, and removes
+// their volfiles and volume ID xattrs. It carries on past failures, which
+// are returned as the result of the node rather than failing the step.
+func cleanupBricks(c transaction.TxnCtx) error {
+
+	var bricks []brick.Brickinfo
+	if err := c.Get("bricks", &bricks); err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, b := range bricks {
+		if !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+			continue
+		}
+
+		name := b.Hostname + ":" + b.Path
+		// The brick might never have been started
+		if err := stopBrick(b); err != nil {
+			c.Logger().WithError(err).WithField("brick", name).Debug("cleanupBricks: failed to stop brick")
+		}
+		if err := volgen.DeleteBrickVolfile(&b); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, name+": failed to delete brick volfile: "+err.Error())
+		}
+		if err := utils.RemoveBrickXattrs(b.Path, b.VolumeID, true); err != nil {
+			errs = append(errs, name+": "+err.Error())
+		}
+	}
+
+	c.SetNodeResult(gdctx.MyUUID, forceRemoveTxnKey, errs)
+	return nil
+}
+
+func registerVolForceRemoveStepFuncs() {
+	transaction.RegisterStepFunc(cleanupBricks, "vol-force-remove.CleanupBricks")
+}
+
+// cleanupBricksOnNode runs the brick cleanup on a single node, so that a
+// node failing doesn't stop the cleanup on the others
+func cleanupBricksOnNode(reqID string, node uuid.UUID, bricks []brick.Brickinfo) []string {
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = []uuid.UUID{node}
+	txn.Steps = []*transaction.Step{
+		{
+			DoFunc: "vol-force-remove.CleanupBricks",
+			Nodes:  txn.Nodes,
+		},
+	}
+	txn.Ctx.Set("bricks", bricks)
+
+	rtxn, err := txn.Do()
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var errs []string
+	if err := rtxn.GetNodeResult(node, forceRemoveTxnKey, &errs); err != nil {
+		return []string{err.Error()}
+	}
+	return errs
+}
+
+// volumeForceRemoveHandler removes a volume left half created or deleted,
+// which the regular delete can't remove. The volume is removed from the store
+// whatever its state, and its bricks are cleaned up on a best-effort basis on
+// the nodes which are reachable. No volume lock is taken, as a failed
+// operation could have left it held.
+func volumeForceRemoveHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	var req VolForceRemoveReq
+	if err := utils.GetJSONFromRequest(r, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, gderrors.ErrJSONParsingFailed.Error())
+		return
+	}
+	if req.Confirm != volname {
+		restutils.SendHTTPError(w, http.StatusBadRequest, "confirm must be set to the name of the volume to force remove it")
+		return
+	}
+
+	if !volume.ExistsFunc(volname) {
+		restutils.SendHTTPError(w, http.StatusNotFound, gderrors.ErrVolNotFound.Error())
+		return
+	}
+
+	resp := VolForceRemoveResp{Volume: volname}
+	volinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		// An entry which can't be read is purged without any cleanup
+		logger.WithError(err).WithField("volume", volname).Warn("force removing unreadable volume")
+		volinfo = &volume.Volinfo{Name: volname}
+	}
+
+	var unreachable []string
+	for _, node := range volinfo.Nodes() {
+		result := ForceRemoveNodeResult{NodeID: node, Reachable: peer.IsOnline(node)}
+		if result.Reachable {
+			result.Errors = cleanupBricksOnNode(reqID, node, volinfo.Bricks)
+		} else {
+			unreachable = append(unreachable, node.String())
+		}
+		resp.Nodes = append(resp.Nodes, result)
+	}
+
+	if err := volgen.DeleteClientVolfile(volinfo); err != nil {
+		logger.WithError(err).WithField("volume", volname).Warn("failed to delete client volfile")
+	}
+	if err := volume.DeleteIOStatsBaseline(volinfo.ID); err != nil {
+		logger.WithError(err).WithField("volume", volname).Warn("failed to delete I/O statistics baseline")
+	}
+	if err := volume.DeleteVolume(volname); err != nil {
+		logger.WithError(err).WithField("volume", volname).Error("failed to remove volume from store")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	events.Broadcast(events.New("volume-force-removed", map[string]string{
+		"volume.name":       volname,
+		"volume.id":         volinfo.ID.String(),
+		"nodes.unreachable": strings.Join(unreachable, ","),
+		"request.id":        reqID,
+	}))
+
+	restutils.SendHTTPResponse(w, http.StatusOK, resp)
+}
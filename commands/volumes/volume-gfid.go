@@ -0,0 +1,134 @@
+package volumecommands
+
+import (
+	"net/http"
+
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+const (
+	gfidCopiesTxnKey string = "gfidcopies"
+)
+
+// VolGFIDResp is a file of a volume found by its gfid. Bricks on nodes which
+// couldn't be reached are listed in UnreachableBricks, as copies of the file
+// on them can't be found.
+type VolGFIDResp struct {
+	volume.GFIDLookup
+	UnreachableBricks []string `json:"unreachable-bricks,omitempty"`
+}
+
+func lookupGFID(c transaction.TxnCtx) error {
+	var volname, gfid string
+	if err := c.Get("volname", &volname); err != nil {
+		return err
+	}
+	if err := c.Get("gfid", &gfid); err != nil {
+		return err
+	}
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		return err
+	}
+
+	copies := make(map[string]volume.GFIDCopy)
+	for _, b := range vol.Bricks {
+		if !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+			continue
+		}
+		gfidCopy, err := volume.LookupGFID(b, gfid)
+		if err != nil {
+			c.Logger().WithError(err).WithField(
+				"brick", b.Path).Error("lookupGFID: failed to look up gfid on brick")
+			return err
+		}
+		if gfidCopy != nil {
+			copies[b.ID()] = *gfidCopy
+		}
+	}
+
+	c.SetNodeResult(gdctx.MyUUID, gfidCopiesTxnKey, copies)
+	return nil
+}
+
+func registerVolGFIDStepFuncs() {
+	transaction.RegisterStepFunc(lookupGFID, "vol-gfid.Lookup")
+}
+
+func volumeGFIDHandler(w http.ResponseWriter, r *http.Request) {
+	p := mux.Vars(r)
+	volname := p["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+
+	gfid, err := volume.NormalizeGFID(p["gfid"])
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var resp VolGFIDResp
+	var nodes []uuid.UUID
+	nodes, resp.UnreachableBricks = reachableVolNodes(vol)
+	if len(nodes) == 0 {
+		restutils.SendHTTPError(w, http.StatusServiceUnavailable, "no node of the volume is reachable")
+		return
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = nodes
+	txn.Steps = []*transaction.Step{
+		{
+			DoFunc: "vol-gfid.Lookup",
+			Nodes:  txn.Nodes,
+		},
+	}
+	txn.Ctx.Set("volname", volname)
+	txn.Ctx.Set("gfid", gfid)
+
+	rtxn, err := txn.Do()
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"error":  err.Error(),
+			"volume": volname,
+			"gfid":   gfid,
+		}).Error("failed to look up gfid on bricks")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	copies := make(map[string]volume.GFIDCopy)
+	for _, node := range nodes {
+		var tmp map[string]volume.GFIDCopy
+		if err := rtxn.GetNodeResult(node, gfidCopiesTxnKey, &tmp); err != nil {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for id, c := range tmp {
+			copies[id] = c
+		}
+	}
+
+	lookup := volume.CompareGFIDCopies(vol, gfid, copies, resp.UnreachableBricks)
+	if lookup == nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, "gfid not found on any brick of the volume")
+		return
+	}
+	resp.GFIDLookup = *lookup
+	restutils.SendHTTPResponse(w, http.StatusOK, resp)
+}
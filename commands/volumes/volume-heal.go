@@ -0,0 +1,108 @@
+package volumecommands
+
+import (
+	goerrors "errors"
+	"net/http"
+
+	gderrors "github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+var errSelfHealNotReplicated = goerrors.New("self-heal can be toggled only for replicated volumes")
+
+// VolHealReq is a request to have the self-heal daemon heal a volume, or stop
+// healing it
+type VolHealReq struct {
+	Enable bool `json:"enable"`
+}
+
+// volumeHealHandler enables or disables the healing of the volume by the
+// self-heal daemon, which picks the change up from the regenerated client
+// volfile
+func volumeHealHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	var req VolHealReq
+	if err := utils.GetJSONFromRequest(r, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, gderrors.ErrJSONParsingFailed.Error())
+		return
+	}
+
+	lock, unlock, err := transaction.CreateLockSteps(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	volinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, gderrors.ErrVolNotFound.Error())
+		return
+	}
+	if volinfo.ReplicaCount < 2 {
+		restutils.SendHTTPError(w, http.StatusBadRequest, errSelfHealNotReplicated.Error())
+		return
+	}
+	if volinfo.SelfHealDisabled == !req.Enable {
+		restutils.SendHTTPResponse(w, http.StatusOK, volinfo)
+		return
+	}
+
+	allNodes, err := peer.GetPeerIDs()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = allNodes
+	txn.Steps = []*transaction.Step{
+		lock,
+		{
+			// Stores the volume and regenerates its client volfile
+			DoFunc: "vol-option.UpdateVolinfo",
+			Nodes:  []uuid.UUID{gdctx.MyUUID},
+		},
+		{
+			DoFunc: "vol-option.NotifyVolfileChange",
+			Nodes:  allNodes,
+		},
+		unlock,
+	}
+
+	volinfo.SelfHealDisabled = !req.Enable
+	if err := txn.Ctx.Set("volinfo", volinfo); err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if _, err := txn.Do(); err != nil {
+		logger.WithFields(log.Fields{
+			"error":  err.Error(),
+			"volume": volname,
+		}).Error("failed to toggle self-heal of volume")
+		if err == transaction.ErrLockTimeout {
+			restutils.SendHTTPError(w, http.StatusConflict, err.Error())
+		} else {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	logger.WithFields(log.Fields{
+		"volume":    volname,
+		"self-heal": req.Enable,
+	}).Info("volume self-heal changed")
+	restutils.SendHTTPResponse(w, http.StatusOK, volinfo)
+}
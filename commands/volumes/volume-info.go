@@ -14,10 +14,16 @@ func volumeInfoHandler(w http.ResponseWriter, r *http.Request) {
 	p := mux.Vars(r)
 	volname := p["volname"]
 
+	fields, e := restutils.GetFields(r, volume.Volinfo{})
+	if e != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, e.Error())
+		return
+	}
+
 	vol, e := volume.GetVolume(volname)
 	if e != nil {
 		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
 	} else {
-		restutils.SendHTTPResponse(w, http.StatusOK, vol)
+		restutils.SendHTTPResponse(w, http.StatusOK, restutils.Project(vol, fields))
 	}
 }
@@ -2,17 +2,77 @@ package volumecommands
 
 import (
 	"net/http"
+	"sort"
+	"strconv"
 
 	restutils "github.com/gluster/glusterd2/servers/rest/utils"
 	"github.com/gluster/glusterd2/volume"
+
+	"github.com/pborman/uuid"
 )
 
+// volumeListHandler returns the names of the volumes with their IDs. The list
+// can be paginated, in the order of the volume names, with `limit` and either
+// `offset` or `continue`, the token sent in the X-Continue header of the
+// previous page. Pages are read from the store alone, and the total number of
+// volumes is sent in the X-Total-Count header. With `fields`, the volumes are
+// sent as a list of their volume info, with only the given fields.
 func volumeListHandler(w http.ResponseWriter, r *http.Request) {
 
-	volumes, e := volume.GetVolumesList()
-	if e != nil {
-		restutils.SendHTTPError(w, http.StatusNotFound, e.Error())
-	} else {
-		restutils.SendHTTPResponse(w, http.StatusOK, volumes)
+	pagination, err := restutils.GetPagination(r)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fields, err := restutils.GetFields(r, volume.Volinfo{})
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if fields != nil && !pagination.Paginated() {
+		volumes, e := volume.GetVolumes()
+		if e != nil {
+			restutils.SendHTTPError(w, http.StatusNotFound, e.Error())
+			return
+		}
+		sort.Slice(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
+		restutils.SendHTTPResponse(w, http.StatusOK, restutils.Project(volumes, fields))
+		return
+	}
+	if !pagination.Paginated() {
+		volumes, e := volume.GetVolumesList()
+		if e != nil {
+			restutils.SendHTTPError(w, http.StatusNotFound, e.Error())
+		} else {
+			restutils.SendHTTPResponse(w, http.StatusOK, volumes)
+		}
+		return
+	}
+
+	total, err := volume.CountVolumes()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	page, more, err := volume.GetVolumesPage(pagination.After, pagination.Offset, pagination.Limit)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if len(page) > 0 {
+		pagination.SetContinue(w, page[len(page)-1].Name, more)
+	}
+	w.Header().Set(restutils.TotalCountHeader, strconv.Itoa(total))
+	if fields != nil {
+		restutils.SendHTTPResponse(w, http.StatusOK, restutils.Project(page, fields))
+		return
+	}
+
+	volumes := make(map[string]uuid.UUID, len(page))
+	for _, v := range page {
+		volumes[v.Name] = v.ID
 	}
+	restutils.SendHTTPResponse(w, http.StatusOK, volumes)
 }
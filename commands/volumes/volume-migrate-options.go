@@ -0,0 +1,126 @@
+package volumecommands
+
+import (
+	"net/http"
+	"strconv"
+
+	gderrors "github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/version"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+// VolOptionsMigration is the result of migrating the options of a volume to
+// the recommended defaults of the cluster op-version. Changes are the options
+// changed, or which would be with a dry run. Skipped are the options set
+// explicitly on the volume or from the cluster defaults, which are left as
+// they are.
+type VolOptionsMigration struct {
+	Volume    string                `json:"volume"`
+	OpVersion int                   `json:"op-version"`
+	DryRun    bool                  `json:"dry-run"`
+	Changes   []volume.OptionChange `json:"changes"`
+	Skipped   []volume.OptionChange `json:"skipped"`
+}
+
+// volumeMigrateOptionsHandler sets the options of the volume to the defaults
+// recommended at the op-version of the cluster, leaving the options set
+// explicitly or from the cluster defaults alone. With `dryRun=true` the changes are only returned.
+func volumeMigrateOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	var dryRun bool
+	if v := r.URL.Query().Get("dryRun"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			restutils.SendHTTPError(w, http.StatusBadRequest, "invalid value for dryRun")
+			return
+		}
+	}
+
+	opVersion, err := peer.ClusterOpVersion()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	lock, unlock, err := transaction.CreateLockSteps(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	volinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, gderrors.ErrVolNotFound.Error())
+		return
+	}
+
+	resp := VolOptionsMigration{
+		Volume:    volname,
+		OpVersion: opVersion,
+		DryRun:    dryRun,
+	}
+	resp.Changes, resp.Skipped = volinfo.OptionChanges(version.OptionDefaults(opVersion))
+	if dryRun || len(resp.Changes) == 0 {
+		restutils.SendHTTPResponse(w, http.StatusOK, resp)
+		return
+	}
+
+	allNodes, err := peer.GetPeerIDs()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = volinfo.Nodes()
+	txn.Steps = []*transaction.Step{
+		lock,
+		{
+			DoFunc: "vol-option.UpdateVolinfo",
+			Nodes:  []uuid.UUID{gdctx.MyUUID},
+		},
+		{
+			DoFunc: "vol-option.RegenerateVolfiles",
+			Nodes:  allNodes,
+		},
+		{
+			DoFunc: "vol-option.NotifyVolfileChange",
+			Nodes:  allNodes,
+		},
+		unlock,
+	}
+
+	volinfo.ApplyOptionChanges(resp.Changes, opVersion)
+	if err := txn.Ctx.Set("volinfo", volinfo); err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if _, err := txn.Do(); err != nil {
+		logger.WithError(err).WithField("volume", volname).Error("failed to migrate volume options")
+		if err == transaction.ErrLockTimeout {
+			restutils.SendHTTPError(w, http.StatusConflict, err.Error())
+		} else {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	logger.WithFields(log.Fields{
+		"volume":     volname,
+		"op-version": opVersion,
+		"changes":    resp.Changes,
+	}).Info("volume options migrated")
+	restutils.SendHTTPResponse(w, http.StatusOK, resp)
+}
@@ -0,0 +1,508 @@
+package volumecommands
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/store"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+	config "github.com/spf13/viper"
+)
+
+const (
+	nfsExportStatusTxnKey string = "nfsexportstatus"
+
+	// Export IDs below this are left for exports not managed by glusterd2,
+	// like the pseudo root. Export IDs are 16-bit.
+	minNFSExportID = 2
+	maxNFSExportID = 65535
+
+	// nfsExportIDKey has the export ID last given to a volume
+	nfsExportIDKey = store.GlusterPrefix + "nfs-export-id"
+)
+
+var ganeshaExportTemplate = `EXPORT {
+	Export_Id = %d;
+	Path = "/%s";
+	Pseudo = "/%s";
+	Access_Type = RW;
+	Squash = No_root_squash;
+	Disable_ACL = true;
+	Protocols = "3", "4";
+	Transports = "UDP", "TCP";
+	SecType = "sys";
+	FSAL {
+		Name = GLUSTER;
+		Hostname = "localhost";
+		Volume = "%s";
+	}
+}
+`
+
+// VolNFSExportReq is a request to export a volume with NFS-Ganesha. Nodes are
+// the IDs of the nodes running NFS-Ganesha to export the volume on, all the
+// peers if not given.
+type VolNFSExportReq struct {
+	Nodes []string `json:"nodes,omitempty"`
+}
+
+// ShareNodeStatus is the status of the export or share of a volume on a node
+type ShareNodeStatus struct {
+	ID     uuid.UUID `json:"id"`
+	Active bool      `json:"active"`
+}
+
+// VolNFSExportStatus is the status of the NFS-Ganesha export of a volume
+type VolNFSExportStatus struct {
+	Exported bool              `json:"exported"`
+	ExportID int               `json:"export-id,omitempty"`
+	Nodes    []ShareNodeStatus `json:"nodes,omitempty"`
+}
+
+// ganeshaExportMgr calls a method of the export manager of NFS-Ganesha over
+// D-Bus, and returns the reply
+var ganeshaExportMgr = func(method string, args ...string) (string, error) {
+	cmdArgs := append([]string{"--system", "--print-reply", "--dest=org.ganesha.nfsd",
+		"/org/ganesha/nfsd/ExportMgr", "org.ganesha.nfsd.exportmgr." + method}, args...)
+	out, err := exec.Command("dbus-send", cmdArgs...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// ganeshaExportActive returns true if the running NFS-Ganesha has an export
+// with the given ID. The reply to ShowExports lists the ID of every export as
+// a uint16.
+func ganeshaExportActive(id int) (bool, error) {
+	out, err := ganeshaExportMgr("ShowExports")
+	if err != nil {
+		return false, err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "uint16" && fields[1] == strconv.Itoa(id) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func ganeshaExportFile(volname string) string {
+	return path.Join(config.GetString("ganesha-config-dir"), "exports", "export."+volname+".conf")
+}
+
+func exportVolumeNFS(c transaction.TxnCtx) error {
+
+	var volinfo volume.Volinfo
+	if err := c.Get("volinfo", &volinfo); err != nil {
+		return err
+	}
+
+	file := ganeshaExportFile(volinfo.Name)
+	if err := os.MkdirAll(path.Dir(file), os.ModeDir|os.ModePerm); err != nil {
+		return err
+	}
+	conf := fmt.Sprintf(ganeshaExportTemplate, volinfo.NFSExport.ExportID, volinfo.Name, volinfo.Name, volinfo.Name)
+	if err := ioutil.WriteFile(file, []byte(conf), 0644); err != nil {
+		return err
+	}
+
+	_, err := ganeshaExportMgr("AddExport", "string:"+file,
+		fmt.Sprintf("string:EXPORT(Export_Id=%d)", volinfo.NFSExport.ExportID))
+	if err != nil {
+		c.Logger().WithError(err).WithField(
+			"volume", volinfo.Name).Debug("exportVolumeNFS: failed to add NFS-Ganesha export")
+		os.Remove(file)
+		return err
+	}
+	return nil
+}
+
+func unexportVolumeNFS(c transaction.TxnCtx) error {
+
+	var volinfo volume.Volinfo
+	if err := c.Get("volinfo", &volinfo); err != nil {
+		return err
+	}
+
+	file := ganeshaExportFile(volinfo.Name)
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		// Not exported on this node, as when undoing a failed export
+		return nil
+	}
+
+	_, err := ganeshaExportMgr("RemoveExport", fmt.Sprintf("uint16:%d", volinfo.NFSExport.ExportID))
+	if err != nil {
+		c.Logger().WithError(err).WithField(
+			"volume", volinfo.Name).Debug("unexportVolumeNFS: failed to remove NFS-Ganesha export")
+		return err
+	}
+	return os.Remove(file)
+}
+
+func checkNFSExport(c transaction.TxnCtx) error {
+
+	var volinfo volume.Volinfo
+	if err := c.Get("volinfo", &volinfo); err != nil {
+		return err
+	}
+
+	// NFS-Ganesha not running means the export isn't active
+	active, err := ganeshaExportActive(volinfo.NFSExport.ExportID)
+	if err != nil {
+		c.Logger().WithError(err).WithField(
+			"volume", volinfo.Name).Debug("checkNFSExport: failed to list NFS-Ganesha exports")
+	}
+	c.SetNodeResult(gdctx.MyUUID, nfsExportStatusTxnKey, active)
+	return nil
+}
+
+// storeVolinfo stores the volinfo in the transaction context, with no other
+// changes to the volume
+func storeVolinfo(c transaction.TxnCtx) error {
+
+	var volinfo volume.Volinfo
+	if err := c.Get("newvolinfo", &volinfo); err != nil {
+		return err
+	}
+
+	if err := volume.AddOrUpdateVolumeFunc(&volinfo); err != nil {
+		c.Logger().WithError(err).WithField(
+			"volume", volinfo.Name).Debug("storeVolinfo: failed to store volume info")
+		return err
+	}
+	return nil
+}
+
+func registerVolNFSStepFuncs() {
+	var sfs = []struct {
+		name string
+		sf   transaction.StepFunc
+	}{
+		{"vol-nfs.Export", exportVolumeNFS},
+		{"vol-nfs.Unexport", unexportVolumeNFS},
+		{"vol-nfs.Status", checkNFSExport},
+		{"vol-nfs.Store", storeVolinfo},
+	}
+	for _, sf := range sfs {
+		transaction.RegisterStepFunc(sf.sf, sf.name)
+	}
+}
+
+// nextNFSExportID returns an export ID not used by any volume. The ID is
+// taken with a compare-and-swap on the last ID given, so that volumes
+// exported concurrently don't get the same ID.
+func nextNFSExportID() (int, error) {
+	for {
+		resp, err := store.Store.Get(context.TODO(), nfsExportIDKey)
+		if err != nil {
+			return 0, err
+		}
+		id := minNFSExportID
+		var rev int64
+		if resp.Count == 1 {
+			last, err := strconv.Atoi(string(resp.Kvs[0].Value))
+			if err != nil {
+				return 0, err
+			}
+			id, rev = last+1, resp.Kvs[0].ModRevision
+		}
+
+		// Volumes exported before the last ID was kept
+		volumes, err := volume.GetVolumes()
+		if err != nil {
+			return 0, err
+		}
+		for _, v := range volumes {
+			if v.NFSExport != nil && v.NFSExport.ExportID >= id {
+				id = v.NFSExport.ExportID + 1
+			}
+		}
+		if id > maxNFSExportID {
+			return 0, fmt.Errorf("no NFS export IDs left, the last is %d", maxNFSExportID)
+		}
+
+		txn, err := store.Store.Txn(context.TODO()).
+			If(clientv3.Compare(clientv3.ModRevision(nfsExportIDKey), "=", rev)).
+			Then(clientv3.OpPut(nfsExportIDKey, strconv.Itoa(id))).
+			Commit()
+		if err != nil {
+			return 0, err
+		}
+		if txn.Succeeded {
+			return id, nil
+		}
+	}
+}
+
+// unshareSteps returns the steps removing the NFS-Ganesha export and the SMB
+// share of the volume, along with the nodes they run on. Nodes which are
+// down are skipped, as they can't be reached.
+func unshareSteps(volinfo *volume.Volinfo) ([]*transaction.Step, []uuid.UUID) {
+	online := func(nodes []uuid.UUID) []uuid.UUID {
+		var alive []uuid.UUID
+		for _, node := range nodes {
+			if peer.IsOnline(node) {
+				alive = append(alive, node)
+			} else {
+				log.WithFields(log.Fields{
+					"volume": volinfo.Name,
+					"node":   node.String(),
+				}).Warn("node is down, its NFS-Ganesha export or SMB share of the volume is left behind")
+			}
+		}
+		return alive
+	}
+
+	var steps []*transaction.Step
+	var nodes []uuid.UUID
+	if volinfo.NFSExport != nil {
+		if alive := online(volinfo.NFSExport.Nodes); len(alive) > 0 {
+			steps = append(steps, &transaction.Step{
+				DoFunc:   "vol-nfs.Unexport",
+				UndoFunc: "vol-nfs.Export",
+				Nodes:    alive,
+			})
+			nodes = append(nodes, alive...)
+		}
+	}
+	if volinfo.SMBShare != nil {
+		if alive := online(volinfo.SMBShare.Nodes); len(alive) > 0 {
+			steps = append(steps, &transaction.Step{
+				DoFunc:   "vol-smb.Unshare",
+				UndoFunc: "vol-smb.Share",
+				Nodes:    alive,
+			})
+			nodes = append(nodes, alive...)
+		}
+	}
+	return steps, nodes
+}
+
+// nodesFromIDs parses the given node IDs, returning all the peers if none
+// are given
+func nodesFromIDs(ids []string) ([]uuid.UUID, error) {
+	var nodes []uuid.UUID
+	if len(ids) == 0 {
+		peers, err := peer.GetPeersF()
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range peers {
+			nodes = append(nodes, p.ID)
+		}
+		return nodes, nil
+	}
+
+	for _, id := range ids {
+		node := uuid.Parse(id)
+		if node == nil {
+			return nil, fmt.Errorf("invalid node ID %s", id)
+		}
+		if _, err := peer.GetPeerF(id); err != nil {
+			return nil, fmt.Errorf("node %s is not a peer", id)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// runVolumeShareTxn runs a transaction changing how a volume is shared. The
+// do step is run on the nodes, after which the new volinfo is stored.
+func runVolumeShareTxn(reqID string, volinfo, newvolinfo *volume.Volinfo, nodes []uuid.UUID, do, undo, storeStep string) (int, error) {
+
+	lock, unlock, err := transaction.CreateLockSteps(volinfo.Name)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = nodes
+	txn.Steps = []*transaction.Step{
+		lock,
+		{
+			DoFunc:   do,
+			UndoFunc: undo,
+			Nodes:    txn.Nodes,
+		},
+		{
+			DoFunc: storeStep,
+			Nodes:  []uuid.UUID{gdctx.MyUUID},
+		},
+		unlock,
+	}
+	txn.Ctx.Set("volinfo", volinfo)
+	txn.Ctx.Set("newvolinfo", newvolinfo)
+
+	if _, err := txn.Do(); err != nil {
+		if err == transaction.ErrLockTimeout {
+			return http.StatusConflict, err
+		}
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func volumeNFSExportHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	var req VolNFSExportReq
+	if r.ContentLength != 0 {
+		if err := utils.GetJSONFromRequest(r, &req); err != nil {
+			restutils.SendHTTPError(w, http.StatusUnprocessableEntity, errors.ErrJSONParsingFailed.Error())
+			return
+		}
+	}
+
+	volinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+	if volinfo.Status != volume.VolStarted {
+		restutils.SendHTTPError(w, http.StatusBadRequest, "volume must be started to be exported")
+		return
+	}
+	if volinfo.NFSExport != nil {
+		restutils.SendHTTPError(w, http.StatusConflict, "volume is already exported")
+		return
+	}
+
+	nodes, err := nodesFromIDs(req.Nodes)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := nextNFSExportID()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	newvolinfo := *volinfo
+	newvolinfo.NFSExport = &volume.NFSExport{ExportID: id, Nodes: nodes}
+
+	// The export steps work on the volinfo with the export set
+	status, err := runVolumeShareTxn(reqID, &newvolinfo, &newvolinfo, nodes,
+		"vol-nfs.Export", "vol-nfs.Unexport", "vol-nfs.Store")
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"error":  err.Error(),
+			"volume": volname,
+		}).Error("failed to export volume with NFS-Ganesha")
+		restutils.SendHTTPError(w, status, err.Error())
+		return
+	}
+
+	restutils.SendHTTPResponse(w, http.StatusOK, newvolinfo.NFSExport)
+}
+
+func volumeNFSUnexportHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	volinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+	if volinfo.NFSExport == nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, "volume is not exported")
+		return
+	}
+
+	newvolinfo := *volinfo
+	newvolinfo.NFSExport = nil
+
+	status, err := runVolumeShareTxn(reqID, volinfo, &newvolinfo, volinfo.NFSExport.Nodes,
+		"vol-nfs.Unexport", "vol-nfs.Export", "vol-nfs.Store")
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"error":  err.Error(),
+			"volume": volname,
+		}).Error("failed to remove NFS-Ganesha export of volume")
+		restutils.SendHTTPError(w, status, err.Error())
+		return
+	}
+
+	restutils.SendHTTPResponse(w, http.StatusOK, nil)
+}
+
+func volumeNFSStatusHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	volinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+	if volinfo.NFSExport == nil {
+		restutils.SendHTTPResponse(w, http.StatusOK, VolNFSExportStatus{})
+		return
+	}
+
+	resp := VolNFSExportStatus{Exported: true, ExportID: volinfo.NFSExport.ExportID}
+
+	// Nodes which are down are reported inactive without asking them
+	var alive []uuid.UUID
+	for _, node := range volinfo.NFSExport.Nodes {
+		if peer.IsOnline(node) {
+			alive = append(alive, node)
+		}
+	}
+
+	results := make(map[string]bool)
+	if len(alive) > 0 {
+		txn := transaction.NewTxn(reqID)
+		defer txn.Cleanup()
+		txn.Nodes = alive
+		txn.Steps = []*transaction.Step{
+			{
+				DoFunc: "vol-nfs.Status",
+				Nodes:  txn.Nodes,
+			},
+		}
+		txn.Ctx.Set("volinfo", volinfo)
+
+		rtxn, err := txn.Do()
+		if err != nil {
+			logger.WithError(err).WithField("volume", volname).Error("failed to get NFS-Ganesha export status")
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, node := range alive {
+			var active bool
+			if err := rtxn.GetNodeResult(node, nfsExportStatusTxnKey, &active); err == nil {
+				results[node.String()] = active
+			}
+		}
+	}
+
+	for _, node := range volinfo.NFSExport.Nodes {
+		resp.Nodes = append(resp.Nodes, ShareNodeStatus{ID: node, Active: results[node.String()]})
+	}
+	restutils.SendHTTPResponse(w, http.StatusOK, resp)
+}
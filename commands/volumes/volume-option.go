@@ -101,6 +101,7 @@ func volumeOptionsHandler(w http.ResponseWriter, r *http.Request) {
 		// will be stored in volinfo:
 		// {"afr.eager-lock":"on","gfproxy.afr.eager-lock":"on"}
 		volinfo.Options[k] = v
+		volinfo.ClearDefaultOption(k)
 	}
 
 	if err := txn.Ctx.Set("volinfo", volinfo); err != nil {
@@ -0,0 +1,36 @@
+package volumecommands
+
+import (
+	"net/http"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/peer"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// checkPlacement checks that the new bricks, those from index first on, meet
+// the placement constraints, if any. New bricks which aren't on nodes with
+// the preferred labels are only logged.
+func checkPlacement(logger log.FieldLogger, c *volume.PlacementConstraints, bricks []brick.Brickinfo, replicaCount, first int) (int, error) {
+	if c == nil {
+		return 0, nil
+	}
+
+	labels, err := peer.GetNodeLabels()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	unpreferred, err := c.Check(bricks, replicaCount, first, labels)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if len(unpreferred) > 0 {
+		logger.WithFields(log.Fields{
+			"bricks": unpreferred,
+			"prefer": c.Prefer,
+		}).Warn("bricks are not on nodes with the preferred labels")
+	}
+	return 0, nil
+}
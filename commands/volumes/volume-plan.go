@@ -0,0 +1,288 @@
+package volumecommands
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/gluster/glusterd2/brick"
+	gderrors "github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	"github.com/pborman/uuid"
+)
+
+const (
+	brickDevicesTxnKey string = "brickdevices"
+
+	// Fault domains that replica sets can be spread across
+	faultDomainNode   = "node"
+	faultDomainDevice = "device"
+	faultDomainNone   = "none"
+)
+
+// VolPlanReq represents a request for a recommended brick layout of a volume
+// with the given geometry, made from the candidate bricks
+type VolPlanReq struct {
+	ReplicaCount int                          `json:"replica,omitempty"`
+	Bricks       []string                     `json:"bricks"`
+	Force        bool                         `json:"force,omitempty"`
+	Placement    *volume.PlacementConstraints `json:"placement,omitempty"`
+}
+
+// VolPlanResp is the recommended brick layout. Bricks are ordered as they
+// should be given to volume create, and FaultDomain tells what replica sets
+// are spread across, which is the spread-by label of the placement
+// constraints if given. Unpreferred are the bricks not on nodes with the
+// preferred labels.
+type VolPlanResp struct {
+	Bricks      []string `json:"bricks"`
+	FaultDomain string   `json:"fault-domain"`
+	Unpreferred []string `json:"unpreferred,omitempty"`
+}
+
+// deviceOfPath returns the ID of the device containing the path, or the
+// nearest existing ancestor of the path if it doesn't exist yet
+func deviceOfPath(p string) (int, error) {
+	for ; ; p = filepath.Dir(p) {
+		fi, err := os.Stat(p)
+		if err == nil {
+			return utils.GetDeviceID(fi)
+		}
+		if !os.IsNotExist(err) || p == "/" {
+			return -1, err
+		}
+	}
+}
+
+func getBrickDevices(c transaction.TxnCtx) error {
+
+	var bricks []brick.Brickinfo
+	if err := c.Get("bricks", &bricks); err != nil {
+		return err
+	}
+
+	var force bool
+	if err := c.Get("force", &force); err != nil {
+		return err
+	}
+
+	devices := make(map[string]int)
+	for _, b := range bricks {
+		if !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+			continue
+		}
+		// Planning must not create the bricks
+		opts := utils.BrickValidationOpts{Force: force, ReadOnly: true}
+		if err := utils.ValidateBrickPathStatsWithOpts(b.Path, b.Hostname, opts); err != nil {
+			return fmt.Errorf("brick %s:%s: %s", b.Hostname, b.Path, err.Error())
+		}
+		dev, err := deviceOfPath(b.Path)
+		if err != nil {
+			c.Logger().WithError(err).WithField(
+				"brick", b.Path).Debug("getBrickDevices: failed to get device of brick")
+			return err
+		}
+		devices[b.Path] = dev
+	}
+
+	c.SetNodeResult(gdctx.MyUUID, brickDevicesTxnKey, devices)
+	return nil
+}
+
+func registerVolPlanStepFuncs() {
+	transaction.RegisterStepFunc(getBrickDevices, "vol-plan.BrickDevices")
+}
+
+// errTooFewFaultDomains is returned when the bricks are in fewer fault
+// domains than the replica count, which no arrangement can spread sets across
+var errTooFewFaultDomains = errors.New("fewer fault domains than the replica count")
+
+// planReplicaSets arranges the bricks into replica sets, such that no two
+// bricks of a set share a domain. domains gives the domain of every brick.
+// The domains with the most bricks left are picked first for every set,
+// which always succeeds if any valid arrangement exists.
+func planReplicaSets(bricks []string, domains map[string]string, replica int) ([]string, error) {
+
+	byDomain := make(map[string][]string)
+	var names []string
+	for _, b := range bricks {
+		d := domains[b]
+		if _, ok := byDomain[d]; !ok {
+			names = append(names, d)
+		}
+		byDomain[d] = append(byDomain[d], b)
+	}
+	if len(names) < replica {
+		return nil, errTooFewFaultDomains
+	}
+
+	var plan []string
+	for i := 0; i < len(bricks)/replica; i++ {
+		// Stable sort keeps the order deterministic among domains
+		// with the same number of bricks left
+		sort.SliceStable(names, func(a, b int) bool {
+			return len(byDomain[names[a]]) > len(byDomain[names[b]])
+		})
+		if len(byDomain[names[replica-1]]) == 0 {
+			return nil, errors.New("not enough fault domains")
+		}
+		for _, d := range names[:replica] {
+			plan = append(plan, byDomain[d][0])
+			byDomain[d] = byDomain[d][1:]
+		}
+	}
+	return plan, nil
+}
+
+func volumePlanHandler(w http.ResponseWriter, r *http.Request) {
+
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	var req VolPlanReq
+	if err := utils.GetJSONFromRequest(r, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, gderrors.ErrJSONParsingFailed.Error())
+		return
+	}
+
+	if len(req.Bricks) == 0 {
+		restutils.SendHTTPError(w, http.StatusBadRequest, gderrors.ErrEmptyBrickList.Error())
+		return
+	}
+	if req.ReplicaCount == 0 {
+		req.ReplicaCount = 1
+	}
+	if len(req.Bricks)%req.ReplicaCount != 0 {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, "Invalid number of bricks")
+		return
+	}
+
+	bricks, err := volume.NewBrickEntriesFunc(req.Bricks, "", nil)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var unpreferred []string
+	if req.Placement != nil {
+		labels, err := peer.GetNodeLabels()
+		if err != nil {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		// The replica sets are yet to be planned, so only the required
+		// labels are checked here
+		if unpreferred, err = req.Placement.Check(bricks, 1, 0, labels); err != nil {
+			restutils.SendHTTPError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+
+		if spreadBy := req.Placement.SpreadBy; spreadBy != "" && req.ReplicaCount > 1 {
+			domains := make(map[string]string)
+			for i, b := range bricks {
+				value, ok := labels[b.NodeID.String()][spreadBy]
+				if !ok {
+					restutils.SendHTTPError(w, http.StatusUnprocessableEntity, (&volume.PlacementError{
+						Constraint: "spread-by",
+						Bricks:     []string{req.Bricks[i]},
+						Reason:     fmt.Sprintf("node %s has no %s label", b.Hostname, spreadBy),
+					}).Error())
+					return
+				}
+				domains[req.Bricks[i]] = value
+			}
+			plan, err := planReplicaSets(req.Bricks, domains, req.ReplicaCount)
+			if err == errTooFewFaultDomains {
+				restutils.SendHTTPError(w, http.StatusBadRequest, fmt.Sprintf(
+					"bricks are on nodes with fewer than %d different values of the %s label", req.ReplicaCount, spreadBy))
+				return
+			}
+			if err != nil {
+				restutils.SendHTTPError(w, http.StatusUnprocessableEntity, fmt.Sprintf(
+					"no arrangement spreads the replica sets across %d different values of the %s label", req.ReplicaCount, spreadBy))
+				return
+			}
+			restutils.SendHTTPResponse(w, http.StatusOK, VolPlanResp{plan, spreadBy, unpreferred})
+			return
+		}
+	}
+
+	if req.ReplicaCount == 1 {
+		// Plain distribute, nothing to spread
+		restutils.SendHTTPResponse(w, http.StatusOK, VolPlanResp{req.Bricks, faultDomainNone, unpreferred})
+		return
+	}
+
+	nodes, err := nodesFromBricks(req.Bricks)
+	if err != nil {
+		logger.WithError(err).Error("could not prepare node list")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Read-only transaction to find the devices of the bricks on their nodes
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = nodes
+	txn.Steps = []*transaction.Step{
+		{
+			DoFunc: "vol-plan.BrickDevices",
+			Nodes:  txn.Nodes,
+		},
+	}
+	txn.Ctx.Set("bricks", bricks)
+	txn.Ctx.Set("force", req.Force)
+
+	rtxn, err := txn.Do()
+	if err != nil {
+		logger.WithError(err).Error("failed to get devices of bricks")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	nodeDomains := make(map[string]string)
+	deviceDomains := make(map[string]string)
+	for _, node := range nodes {
+		var devices map[string]int
+		if err := rtxn.GetNodeResult(node, brickDevicesTxnKey, &devices); err != nil {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for i, b := range bricks {
+			if !uuid.Equal(b.NodeID, node) {
+				continue
+			}
+			nodeDomains[req.Bricks[i]] = node.String()
+			deviceDomains[req.Bricks[i]] = fmt.Sprintf("%s/%d", node.String(), devices[b.Path])
+		}
+	}
+
+	if plan, err := planReplicaSets(req.Bricks, nodeDomains, req.ReplicaCount); err == nil {
+		restutils.SendHTTPResponse(w, http.StatusOK, VolPlanResp{plan, faultDomainNode, unpreferred})
+		return
+	}
+
+	// Replica sets can't be spread across nodes, spreading them across
+	// devices at least protects against disk failures
+	plan, err := planReplicaSets(req.Bricks, deviceDomains, req.ReplicaCount)
+	if err == nil {
+		restutils.SendHTTPResponse(w, http.StatusOK, VolPlanResp{plan, faultDomainDevice, unpreferred})
+		return
+	}
+	if err == errTooFewFaultDomains {
+		restutils.SendHTTPError(w, http.StatusBadRequest, fmt.Sprintf(
+			"bricks are on fewer than %d different devices, no replica set can be fault tolerant", req.ReplicaCount))
+		return
+	}
+
+	restutils.SendHTTPError(w, http.StatusUnprocessableEntity,
+		"no fault tolerant arrangement possible, replica sets would have multiple bricks on the same device")
+}
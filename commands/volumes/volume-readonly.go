@@ -0,0 +1,140 @@
+package volumecommands
+
+import (
+	"net/http"
+
+	gderrors "github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+// VolReadOnlyReq is a request to make a volume read-only, or writable again
+type VolReadOnlyReq struct {
+	Enable bool `json:"enable"`
+}
+
+// undoReadOnlyVolfiles regenerates the brick volfiles of the volume as they
+// were before the read-only change
+func undoReadOnlyVolfiles(c transaction.TxnCtx) error {
+	var volinfo volume.Volinfo
+	if err := c.Get("oldvolinfo", &volinfo); err != nil {
+		return err
+	}
+	if err := c.Set("volinfo", volinfo); err != nil {
+		return err
+	}
+	return generateBrickVolfiles(c)
+}
+
+func registerVolReadOnlyStepFuncs() {
+	var sfs = []struct {
+		name string
+		sf   transaction.StepFunc
+	}{
+		{"vol-readonly.RegenerateVolfiles", generateBrickVolfiles},
+		{"vol-readonly.UndoRegenerateVolfiles", undoReadOnlyVolfiles},
+		{"vol-readonly.NotifyVolfileChange", notifyVolfileChange},
+		{"vol-readonly.Store", storeVolume},
+	}
+	for _, sf := range sfs {
+		transaction.RegisterStepFunc(sf.sf, sf.name)
+	}
+}
+
+// volumeReadOnlyHandler makes the bricks of the volume reject writes, or
+// accept them again. The bricks fetch their regenerated volfiles at once, so
+// clients see the change without remounting.
+func volumeReadOnlyHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	var req VolReadOnlyReq
+	if err := utils.GetJSONFromRequest(r, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, gderrors.ErrJSONParsingFailed.Error())
+		return
+	}
+	if !checkFeatureSupported(w, "read-only") {
+		return
+	}
+
+	lock, unlock, err := transaction.CreateLockSteps(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	volinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, gderrors.ErrVolNotFound.Error())
+		return
+	}
+	if volinfo.ReadOnly == req.Enable {
+		restutils.SendHTTPResponse(w, http.StatusOK, volinfo)
+		return
+	}
+
+	allNodes, err := peer.GetPeerIDs()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = volinfo.Nodes()
+	txn.Steps = []*transaction.Step{
+		lock,
+		{
+			DoFunc:   "vol-readonly.RegenerateVolfiles",
+			UndoFunc: "vol-readonly.UndoRegenerateVolfiles",
+			Nodes:    txn.Nodes,
+		},
+		{
+			// Bricks fetch their volfiles like the clients do
+			DoFunc: "vol-readonly.NotifyVolfileChange",
+			Nodes:  allNodes,
+		},
+		{
+			DoFunc: "vol-readonly.Store",
+			Nodes:  []uuid.UUID{gdctx.MyUUID},
+		},
+		unlock,
+	}
+
+	if err := txn.Ctx.Set("oldvolinfo", volinfo); err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	volinfo.ReadOnly = req.Enable
+	if err := txn.Ctx.Set("volinfo", volinfo); err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if _, err := txn.Do(); err != nil {
+		logger.WithFields(log.Fields{
+			"error":  err.Error(),
+			"volume": volname,
+		}).Error("failed to set volume read-only")
+		if err == transaction.ErrLockTimeout {
+			restutils.SendHTTPError(w, http.StatusConflict, err.Error())
+		} else {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	logger.WithFields(log.Fields{
+		"volume":    volname,
+		"read-only": volinfo.ReadOnly,
+	}).Info("volume read-only changed")
+	restutils.SendHTTPResponse(w, http.StatusOK, volinfo)
+}
@@ -0,0 +1,321 @@
+package volumecommands
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+// VolReplicaReq represents a request to increase the replica count of a
+// volume. Bricks are the additional bricks needed for the new geometry,
+// listed in the order of the replica sets they get added to. Force allows
+// bricks of a replica set on the same node, as long as they are on different
+// devices, and skips the checks on the mount points of the bricks.
+type VolReplicaReq struct {
+	ReplicaCount int      `json:"replica"`
+	Bricks       []string `json:"bricks"`
+	Force        bool     `json:"force,omitempty"`
+}
+
+// addReplicaBricks returns the brick list of a volume whose replica count is
+// increased from oldReplica to newReplica. Bricks of a replica set are
+// contiguous in the brick list, so the new bricks are spread across the
+// existing replica sets instead of being appended at the end.
+func addReplicaBricks(bricks []brick.Brickinfo, newBricks []brick.Brickinfo, oldReplica int, newReplica int) []brick.Brickinfo {
+	var result []brick.Brickinfo
+	added := newReplica - oldReplica
+	for i := 0; i < len(bricks)/oldReplica; i++ {
+		result = append(result, bricks[i*oldReplica:(i+1)*oldReplica]...)
+		result = append(result, newBricks[i*added:(i+1)*added]...)
+	}
+	return result
+}
+
+func checkBricksOnReplicaChange(c transaction.TxnCtx) error {
+
+	var newBricks []brick.Brickinfo
+	if err := c.Get("newbricks", &newBricks); err != nil {
+		return err
+	}
+
+	var force bool
+	if err := c.Get("force", &force); err != nil {
+		return err
+	}
+
+	if _, err := volume.ValidateBrickEntriesFunc(newBricks, newBricks[0].VolumeID, force); err != nil {
+		return err
+	}
+	if err := recordBrickDevices(c, newBricks); err != nil {
+		return err
+	}
+	if !force {
+		return nil
+	}
+
+	// Bricks of a replica set on the same node must at least be on
+	// different devices, which is known only once their paths exist. All
+	// the replica sets get new bricks.
+	var volinfo volume.Volinfo
+	if err := c.Get("oldvolinfo", &volinfo); err != nil {
+		return err
+	}
+	var newReplicaCount int
+	if err := c.Get("newreplicacount", &newReplicaCount); err != nil {
+		return err
+	}
+	bricks := addReplicaBricks(volinfo.Bricks, newBricks, volinfo.ReplicaCount, newReplicaCount)
+	return volume.ValidateNewReplicaSetDevices(bricks, newReplicaCount, 0)
+}
+
+func updateVolinfoOnReplicaChange(c transaction.TxnCtx) error {
+
+	var newBricks []brick.Brickinfo
+	if err := c.Get("newbricks", &newBricks); err != nil {
+		return err
+	}
+
+	var volinfo volume.Volinfo
+	if err := c.Get("oldvolinfo", &volinfo); err != nil {
+		return err
+	}
+
+	var newReplicaCount int
+	if err := c.Get("newreplicacount", &newReplicaCount); err != nil {
+		return err
+	}
+
+	if err := setBrickDevices(c, newBricks); err != nil {
+		return err
+	}
+	volinfo.Bricks = addReplicaBricks(volinfo.Bricks, newBricks, volinfo.ReplicaCount, newReplicaCount)
+	volinfo.ReplicaCount = newReplicaCount
+
+	if volinfo.DistCount == 1 {
+		volinfo.Type = volume.Replicate
+	} else {
+		volinfo.Type = volume.DistReplicate
+	}
+
+	// update new volinfo in txn ctx
+	if err := c.Set("volinfo", volinfo); err != nil {
+		return err
+	}
+
+	// update new volinfo in etcd store and generate client volfile
+	if err := storeVolume(c); err != nil {
+		c.Logger().WithError(err).WithField(
+			"volume", volinfo.Name).Debug("storeVolume: failed to store volume info")
+		return err
+	}
+
+	return nil
+}
+
+// markHealOnReplicaChange has the existing bricks of this node blame the new
+// bricks of their replica set, so that the self-heal daemon heals the data of
+// the volume onto the new bricks rather than waiting for files to be
+// accessed
+func markHealOnReplicaChange(c transaction.TxnCtx) error {
+
+	var newBricks []brick.Brickinfo
+	if err := c.Get("newbricks", &newBricks); err != nil {
+		return err
+	}
+
+	var volinfo volume.Volinfo
+	if err := c.Get("volinfo", &volinfo); err != nil {
+		return err
+	}
+
+	isNew := make(map[string]bool)
+	for _, b := range newBricks {
+		isNew[b.ID()] = true
+	}
+
+	for set := 0; set < len(volinfo.Bricks)/volinfo.ReplicaCount; set++ {
+		first := set * volinfo.ReplicaCount
+		bricks := volinfo.Bricks[first : first+volinfo.ReplicaCount]
+
+		var indices []int
+		for i, b := range bricks {
+			if isNew[b.ID()] {
+				indices = append(indices, first+i)
+			}
+		}
+		for _, b := range bricks {
+			if isNew[b.ID()] || !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+				continue
+			}
+			if err := volume.MarkRootPendingHeal(volinfo.Name, b, indices); err != nil {
+				c.Logger().WithError(err).WithField(
+					"brick", b.Path).Error("markHealOnReplicaChange: failed to mark brick for heal")
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func registerVolReplicaStepFuncs() {
+	// Starting the new bricks and notifying the clients is no different
+	// from expanding a volume, so the vol-expand steps are reused for those.
+	transaction.RegisterStepFunc(checkBricksOnReplicaChange, "vol-replica.CheckBrick")
+	transaction.RegisterStepFunc(updateVolinfoOnReplicaChange, "vol-replica.UpdateVolinfo") // only on initiator node
+	transaction.RegisterStepFunc(markHealOnReplicaChange, "vol-replica.MarkHeal")
+}
+
+func validateReplicaChange(volinfo *volume.Volinfo, req *VolReplicaReq) error {
+
+	switch volinfo.Type {
+	case volume.Distribute, volume.Replicate, volume.DistReplicate:
+	default:
+		return fmt.Errorf("replica count of volume %s can not be changed", volinfo.Name)
+	}
+	if volinfo.ThinArbiter != nil {
+		return fmt.Errorf("replica count of volume %s can not be changed as it has a thin-arbiter", volinfo.Name)
+	}
+
+	if req.ReplicaCount <= volinfo.ReplicaCount {
+		return fmt.Errorf("replica count can only be increased, current replica count is %d", volinfo.ReplicaCount)
+	}
+
+	expected := volinfo.DistCount * (req.ReplicaCount - volinfo.ReplicaCount)
+	if len(req.Bricks) != expected {
+		return fmt.Errorf("invalid number of bricks, %d bricks are needed to change replica count to %d", expected, req.ReplicaCount)
+	}
+
+	return nil
+}
+
+func volumeReplicaHandler(w http.ResponseWriter, r *http.Request) {
+
+	reqID, logger := restutils.GetReqIDandLogger(r)
+	volname := mux.Vars(r)["volname"]
+
+	volinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+
+	var req VolReplicaReq
+	if err := utils.GetJSONFromRequest(r, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, errors.ErrJSONParsingFailed.Error())
+		return
+	}
+
+	if err := validateReplicaChange(volinfo, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	newBricks, err := volume.NewBrickEntriesFunc(req.Bricks, volinfo.Name, volinfo.ID)
+	if err != nil {
+		logger.WithError(err).Error("failed to create new brick entries")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if !req.Force {
+		bricks := addReplicaBricks(volinfo.Bricks, newBricks, volinfo.ReplicaCount, req.ReplicaCount)
+		if err := volume.ValidateNewReplicaSets(bricks, req.ReplicaCount, 0); err != nil {
+			restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	lock, unlock, err := transaction.CreateLockSteps(volinfo.Name)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+
+	nodes, err := nodesFromBricks(req.Bricks)
+	if err != nil {
+		logger.WithError(err).Error("could not prepare node list")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// The nodes of the existing bricks mark them for heal onto the new ones
+	volNodes := volinfo.Nodes()
+	txn.Nodes = appendNodes(nodes, volNodes)
+	txn.Steps = []*transaction.Step{
+		lock,
+		{
+			DoFunc: "vol-replica.CheckBrick",
+			Nodes:  nodes,
+		},
+		{
+			DoFunc:   "vol-expand.StartBrick",
+			Nodes:    nodes,
+			UndoFunc: "vol-expand.UndoStartBrick",
+		},
+		{
+			DoFunc: "vol-replica.UpdateVolinfo",
+			Nodes:  []uuid.UUID{gdctx.MyUUID},
+		},
+		{
+			DoFunc: "vol-replica.MarkHeal",
+			Nodes:  volNodes,
+		},
+		{
+			DoFunc: "vol-expand.NotifyClients",
+			Nodes:  txn.Nodes,
+		},
+		unlock,
+	}
+
+	if err := txn.Ctx.Set("newbricks", newBricks); err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := txn.Ctx.Set("force", req.Force); err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := txn.Ctx.Set("newreplicacount", req.ReplicaCount); err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := txn.Ctx.Set("oldvolinfo", volinfo); err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if _, err = txn.Do(); err != nil {
+		logger.WithError(err).Error("volume replica change transaction failed")
+		if err == transaction.ErrLockTimeout {
+			restutils.SendHTTPError(w, http.StatusConflict, err.Error())
+		} else {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	newvolinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	restutils.SendHTTPResponse(w, http.StatusOK, newvolinfo)
+}
@@ -0,0 +1,308 @@
+package volumecommands
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+
+	gderrors "github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+const (
+	smbShareStatusTxnKey string = "smbsharestatus"
+)
+
+// VolSMBShareReq is a request to share a volume over SMB with Samba. Nodes
+// are the IDs of the nodes running Samba to share the volume on, all the
+// peers if not given.
+type VolSMBShareReq struct {
+	Nodes      []string `json:"nodes,omitempty"`
+	GuestOK    bool     `json:"guest-ok,omitempty"`
+	ValidUsers []string `json:"valid-users,omitempty"`
+}
+
+// VolSMBShareStatus is the status of the SMB share of a volume
+type VolSMBShareStatus struct {
+	Shared bool              `json:"shared"`
+	Share  string            `json:"share,omitempty"`
+	Nodes  []ShareNodeStatus `json:"nodes,omitempty"`
+}
+
+// sambaNetConf runs a `net conf` command, which manages the shares kept in
+// the registry of Samba. Registry shares are picked up by Samba without a
+// reload.
+var sambaNetConf = func(args ...string) error {
+	out, err := exec.Command("net", append([]string{"conf"}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// sambaRunning returns true if the Samba server is running, as registry
+// shares are only served while it is
+var sambaRunning = func() bool {
+	return exec.Command("smbcontrol", "smbd", "ping").Run() == nil
+}
+
+// smbShareName returns the name of the SMB share of a volume
+func smbShareName(volname string) string {
+	return "gluster-" + volname
+}
+
+func validateSMBShareReq(req *VolSMBShareReq) error {
+	if req.GuestOK && len(req.ValidUsers) > 0 {
+		return errors.New("valid users can not be given for a share with guest access")
+	}
+	for _, u := range req.ValidUsers {
+		if u == "" || strings.ContainsAny(u, ", \t") {
+			return fmt.Errorf("invalid user name %q", u)
+		}
+	}
+	return nil
+}
+
+func shareVolumeSMB(c transaction.TxnCtx) error {
+
+	var volinfo volume.Volinfo
+	if err := c.Get("volinfo", &volinfo); err != nil {
+		return err
+	}
+
+	share := volinfo.SMBShare
+	params := [][]string{
+		{"vfs objects", "glusterfs"},
+		{"glusterfs:volume", volinfo.Name},
+		{"glusterfs:logfile", "/var/log/samba/glusterfs-" + volinfo.Name + ".%M.log"},
+		{"kernel share modes", "no"},
+	}
+	if share.GuestOK {
+		params = append(params, []string{"guest ok", "yes"})
+	}
+	if len(share.ValidUsers) > 0 {
+		params = append(params, []string{"valid users", strings.Join(share.ValidUsers, " ")})
+	}
+
+	if err := sambaNetConf("addshare", share.Name, "/", "writeable=y"); err != nil {
+		c.Logger().WithError(err).WithField(
+			"volume", volinfo.Name).Debug("shareVolumeSMB: failed to add SMB share")
+		return err
+	}
+	for _, p := range params {
+		if err := sambaNetConf("setparm", share.Name, p[0], p[1]); err != nil {
+			c.Logger().WithError(err).WithFields(log.Fields{
+				"volume": volinfo.Name,
+				"param":  p[0],
+			}).Debug("shareVolumeSMB: failed to set SMB share parameter")
+			sambaNetConf("delshare", share.Name)
+			return err
+		}
+	}
+	return nil
+}
+
+func unshareVolumeSMB(c transaction.TxnCtx) error {
+
+	var volinfo volume.Volinfo
+	if err := c.Get("volinfo", &volinfo); err != nil {
+		return err
+	}
+
+	name := volinfo.SMBShare.Name
+	if sambaNetConf("showshare", name) != nil {
+		// Not shared on this node, as when undoing a failed share
+		return nil
+	}
+
+	if err := sambaNetConf("delshare", name); err != nil {
+		c.Logger().WithError(err).WithField(
+			"volume", volinfo.Name).Debug("unshareVolumeSMB: failed to remove SMB share")
+		return err
+	}
+	return nil
+}
+
+func checkSMBShare(c transaction.TxnCtx) error {
+
+	var volinfo volume.Volinfo
+	if err := c.Get("volinfo", &volinfo); err != nil {
+		return err
+	}
+
+	active := sambaRunning() && sambaNetConf("showshare", volinfo.SMBShare.Name) == nil
+	c.SetNodeResult(gdctx.MyUUID, smbShareStatusTxnKey, active)
+	return nil
+}
+
+func registerVolSMBStepFuncs() {
+	var sfs = []struct {
+		name string
+		sf   transaction.StepFunc
+	}{
+		{"vol-smb.Share", shareVolumeSMB},
+		{"vol-smb.Unshare", unshareVolumeSMB},
+		{"vol-smb.Status", checkSMBShare},
+		{"vol-smb.Store", storeVolinfo},
+	}
+	for _, sf := range sfs {
+		transaction.RegisterStepFunc(sf.sf, sf.name)
+	}
+}
+
+func volumeSMBShareHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	var req VolSMBShareReq
+	if r.ContentLength != 0 {
+		if err := utils.GetJSONFromRequest(r, &req); err != nil {
+			restutils.SendHTTPError(w, http.StatusUnprocessableEntity, gderrors.ErrJSONParsingFailed.Error())
+			return
+		}
+	}
+	if err := validateSMBShareReq(&req); err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	volinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, gderrors.ErrVolNotFound.Error())
+		return
+	}
+	if volinfo.Status != volume.VolStarted {
+		restutils.SendHTTPError(w, http.StatusBadRequest, "volume must be started to be shared")
+		return
+	}
+	if volinfo.SMBShare != nil {
+		restutils.SendHTTPError(w, http.StatusConflict, "volume is already shared")
+		return
+	}
+
+	nodes, err := nodesFromIDs(req.Nodes)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	newvolinfo := *volinfo
+	newvolinfo.SMBShare = &volume.SMBShare{
+		Name:       smbShareName(volname),
+		GuestOK:    req.GuestOK,
+		ValidUsers: req.ValidUsers,
+		Nodes:      nodes,
+	}
+
+	status, err := runVolumeShareTxn(reqID, &newvolinfo, &newvolinfo, nodes,
+		"vol-smb.Share", "vol-smb.Unshare", "vol-smb.Store")
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"error":  err.Error(),
+			"volume": volname,
+		}).Error("failed to share volume over SMB")
+		restutils.SendHTTPError(w, status, err.Error())
+		return
+	}
+
+	restutils.SendHTTPResponse(w, http.StatusOK, newvolinfo.SMBShare)
+}
+
+func volumeSMBUnshareHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	volinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, gderrors.ErrVolNotFound.Error())
+		return
+	}
+	if volinfo.SMBShare == nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, "volume is not shared")
+		return
+	}
+
+	newvolinfo := *volinfo
+	newvolinfo.SMBShare = nil
+
+	status, err := runVolumeShareTxn(reqID, volinfo, &newvolinfo, volinfo.SMBShare.Nodes,
+		"vol-smb.Unshare", "vol-smb.Share", "vol-smb.Store")
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"error":  err.Error(),
+			"volume": volname,
+		}).Error("failed to remove SMB share of volume")
+		restutils.SendHTTPError(w, status, err.Error())
+		return
+	}
+
+	restutils.SendHTTPResponse(w, http.StatusOK, nil)
+}
+
+func volumeSMBStatusHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	volinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, gderrors.ErrVolNotFound.Error())
+		return
+	}
+	if volinfo.SMBShare == nil {
+		restutils.SendHTTPResponse(w, http.StatusOK, VolSMBShareStatus{})
+		return
+	}
+
+	resp := VolSMBShareStatus{Shared: true, Share: volinfo.SMBShare.Name}
+
+	// Nodes which are down are reported inactive without asking them
+	var alive []uuid.UUID
+	for _, node := range volinfo.SMBShare.Nodes {
+		if peer.IsOnline(node) {
+			alive = append(alive, node)
+		}
+	}
+
+	results := make(map[string]bool)
+	if len(alive) > 0 {
+		txn := transaction.NewTxn(reqID)
+		defer txn.Cleanup()
+		txn.Nodes = alive
+		txn.Steps = []*transaction.Step{
+			{
+				DoFunc: "vol-smb.Status",
+				Nodes:  txn.Nodes,
+			},
+		}
+		txn.Ctx.Set("volinfo", volinfo)
+
+		rtxn, err := txn.Do()
+		if err != nil {
+			logger.WithError(err).WithField("volume", volname).Error("failed to get SMB share status")
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, node := range alive {
+			var active bool
+			if err := rtxn.GetNodeResult(node, smbShareStatusTxnKey, &active); err == nil {
+				results[node.String()] = active
+			}
+		}
+	}
+
+	for _, node := range volinfo.SMBShare.Nodes {
+		resp.Nodes = append(resp.Nodes, ShareNodeStatus{ID: node, Active: results[node.String()]})
+	}
+	restutils.SendHTTPResponse(w, http.StatusOK, resp)
+}
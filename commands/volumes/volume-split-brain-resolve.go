@@ -0,0 +1,295 @@
+package volumecommands
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+// VolSplitBrainResolveReq is a request to resolve the split-brain of files of
+// a volume, given by their path or gfid, with a policy. SourceBrick is the
+// brick to resolve with for the source-brick policy, as host:path.
+type VolSplitBrainResolveReq struct {
+	Files       []string `json:"files"`
+	Policy      string   `json:"policy"`
+	SourceBrick string   `json:"source-brick,omitempty"`
+}
+
+// SplitBrainResolution is the outcome of resolving the split-brain of a file
+type SplitBrainResolution struct {
+	File     string   `json:"file"`
+	Resolved bool     `json:"resolved"`
+	Source   string   `json:"source,omitempty"`
+	Types    []string `json:"types,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// VolSplitBrainResolveResp is the outcome of resolving the split-brain of
+// each of the files of a request
+type VolSplitBrainResolveResp struct {
+	Files []SplitBrainResolution `json:"files"`
+}
+
+// splitBrainClear clears the blame on the source copy of a file by the copy of
+// the file on a brick
+type splitBrainClear struct {
+	BrickID string   `json:"brick-id"`
+	GFID    string   `json:"gfid"`
+	Source  int      `json:"source"`
+	Types   []string `json:"types"`
+}
+
+// splitBrainHeal is the source copy of a resolved file, which is added to the
+// AFR index of its brick for the self-heal daemon to heal the file from it
+type splitBrainHeal struct {
+	BrickID string `json:"brick-id"`
+	GFID    string `json:"gfid"`
+}
+
+func resolveSplitBrain(c transaction.TxnCtx) error {
+	var volname string
+	if err := c.Get("volname", &volname); err != nil {
+		return err
+	}
+	var clears []splitBrainClear
+	if err := c.Get("clears", &clears); err != nil {
+		return err
+	}
+	var heals []splitBrainHeal
+	if err := c.Get("heals", &heals); err != nil {
+		return err
+	}
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		return err
+	}
+
+	for _, sc := range clears {
+		b, ok := vol.BrickByID(sc.BrickID)
+		if !ok || !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+			continue
+		}
+		if err := volume.ClearPendingBlame(volname, *b, sc.GFID, sc.Source, sc.Types); err != nil {
+			c.Logger().WithError(err).WithFields(log.Fields{
+				"brick": b.Path,
+				"gfid":  sc.GFID,
+			}).Error("resolveSplitBrain: failed to clear AFR changelog")
+			return err
+		}
+	}
+
+	for _, h := range heals {
+		b, ok := vol.BrickByID(h.BrickID)
+		if !ok || !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+			continue
+		}
+		if err := volume.AddPendingHeal(*b, h.GFID); err != nil {
+			c.Logger().WithError(err).WithFields(log.Fields{
+				"brick": b.Path,
+				"gfid":  h.GFID,
+			}).Error("resolveSplitBrain: failed to add file to AFR index")
+			return err
+		}
+	}
+	return nil
+}
+
+func brickIndex(vol *volume.Volinfo, id string) int {
+	for i := range vol.Bricks {
+		if vol.Bricks[i].ID() == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitBrainClears returns the changelogs to clear to resolve the split-brain
+// of a file with the given source copy. Every other copy stops blaming the
+// source, which AFR then heals the other copies from.
+func splitBrainClears(vol *volume.Volinfo, f *volume.SplitBrainFile, source *volume.SplitBrainCopy, types []string) []splitBrainClear {
+	var clears []splitBrainClear
+	for _, c := range f.Copies {
+		if c.BrickID == source.BrickID {
+			continue
+		}
+		clears = append(clears, splitBrainClear{
+			BrickID: c.BrickID,
+			GFID:    f.GFID,
+			Source:  brickIndex(vol, source.BrickID),
+			Types:   types,
+		})
+	}
+	return clears
+}
+
+// planSplitBrainResolution finds the source copy of a file in split-brain by
+// the policy of the request, and the changelogs to clear to resolve the file
+// with it
+func planSplitBrainResolution(vol *volume.Volinfo, files []volume.SplitBrainFile, file string, req *VolSplitBrainResolveReq, sourceBrick string) (SplitBrainResolution, []splitBrainClear) {
+	res := SplitBrainResolution{File: file}
+
+	var f *volume.SplitBrainFile
+	for i := range files {
+		if files[i].Path == file || files[i].GFID == file {
+			f = &files[i]
+			break
+		}
+	}
+	if f == nil {
+		res.Error = fmt.Sprintf("%s is not in split-brain", file)
+		return res, nil
+	}
+
+	if sourceBrick != "" && brickIndex(vol, sourceBrick)/vol.ReplicaCount != f.ReplicaSet {
+		res.Error = fmt.Sprintf("%s is not in the replica set of %s", req.SourceBrick, file)
+		return res, nil
+	}
+	source, types, err := volume.SplitBrainSource(f, req.Policy, sourceBrick)
+	if err != nil {
+		res.Error = err.Error()
+		return res, nil
+	}
+
+	res.Source = source.BrickID
+	res.Types = types
+	return res, splitBrainClears(vol, f, source, types)
+}
+
+func checkSplitBrainResolveReq(vol *volume.Volinfo, req *VolSplitBrainResolveReq) (string, error) {
+	if len(req.Files) == 0 {
+		return "", fmt.Errorf("no files given to resolve")
+	}
+
+	switch req.Policy {
+	case volume.SplitBrainPolicyBiggerFile, volume.SplitBrainPolicyLatestMtime:
+		if req.SourceBrick != "" {
+			return "", fmt.Errorf("source brick can be given only with the %s policy", volume.SplitBrainPolicySourceBrick)
+		}
+		return "", nil
+	case volume.SplitBrainPolicySourceBrick:
+	default:
+		return "", fmt.Errorf("invalid split-brain policy %q", req.Policy)
+	}
+
+	host, path, err := utils.ParseHostAndBrickPath(req.SourceBrick)
+	if err != nil {
+		return "", err
+	}
+	id := brick.BrickID(host, path)
+	if _, ok := vol.BrickByID(id); !ok {
+		return "", fmt.Errorf("%s is not a brick of volume %s", req.SourceBrick, vol.Name)
+	}
+	return id, nil
+}
+
+// volumeSplitBrainResolveHandler resolves the split-brain of files of a volume
+// by choosing the copy to keep of each. The AFR changelogs of the copies are
+// changed, and the chosen copies are added to the AFR index of their bricks,
+// so that the self-heal daemon heals the files from them on its next crawl.
+func volumeSplitBrainResolveHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	var req VolSplitBrainResolveReq
+	if err := utils.GetJSONFromRequest(r, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, errors.ErrJSONParsingFailed.Error())
+		return
+	}
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+
+	sourceBrick, err := checkSplitBrainResolveReq(vol, &req)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	found, err := findSplitBrain(reqID, vol)
+	if err != nil {
+		logger.WithError(err).WithField("volume", volname).Error("failed to find files in split-brain")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var resp VolSplitBrainResolveResp
+	var clears []splitBrainClear
+	var heals []splitBrainHeal
+	nodes := make(map[string]uuid.UUID)
+	for _, file := range req.Files {
+		res, fileClears := planSplitBrainResolution(vol, found.Files, file, &req, sourceBrick)
+		for _, sc := range fileClears {
+			b, _ := vol.BrickByID(sc.BrickID)
+			nodes[b.NodeID.String()] = b.NodeID
+		}
+		if len(fileClears) > 0 {
+			b, _ := vol.BrickByID(res.Source)
+			nodes[b.NodeID.String()] = b.NodeID
+			heals = append(heals, splitBrainHeal{BrickID: res.Source, GFID: fileClears[0].GFID})
+		}
+		clears = append(clears, fileClears...)
+		resp.Files = append(resp.Files, res)
+	}
+
+	// A single file which can't be resolved fails the request, while a
+	// batch reports the outcome of each file
+	if len(req.Files) == 1 && resp.Files[0].Error != "" {
+		restutils.SendHTTPError(w, http.StatusBadRequest, resp.Files[0].Error)
+		return
+	}
+
+	if len(clears) > 0 {
+		lock, unlock, err := transaction.CreateLockSteps(volname)
+		if err != nil {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		txn := transaction.NewTxn(reqID)
+		defer txn.Cleanup()
+		for _, node := range nodes {
+			txn.Nodes = append(txn.Nodes, node)
+		}
+		txn.Steps = []*transaction.Step{
+			lock,
+			{
+				DoFunc: "vol-split-brain.Resolve",
+				Nodes:  txn.Nodes,
+			},
+			unlock,
+		}
+		txn.Ctx.Set("volname", volname)
+		txn.Ctx.Set("clears", clears)
+		txn.Ctx.Set("heals", heals)
+
+		if _, err := txn.Do(); err != nil {
+			logger.WithError(err).WithField("volume", volname).Error("failed to resolve split-brain")
+			status := http.StatusInternalServerError
+			if err == transaction.ErrLockTimeout {
+				status = http.StatusConflict
+			}
+			restutils.SendHTTPError(w, status, err.Error())
+			return
+		}
+	}
+
+	for i := range resp.Files {
+		resp.Files[i].Resolved = resp.Files[i].Error == ""
+	}
+	restutils.SendHTTPResponse(w, http.StatusOK, resp)
+}
@@ -0,0 +1,129 @@
+package volumecommands
+
+import (
+	"net/http"
+
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+const (
+	pendingHealsTxnKey string = "pendingheals"
+)
+
+// VolSplitBrainResp lists the files of a volume in split-brain. Bricks on
+// nodes which couldn't be reached are listed in UnreachableBricks, as files in
+// split-brain on them can't be found.
+type VolSplitBrainResp struct {
+	Files             []volume.SplitBrainFile `json:"files"`
+	UnreachableBricks []string                `json:"unreachable-bricks,omitempty"`
+}
+
+func getPendingHeals(c transaction.TxnCtx) error {
+	var volname string
+	if err := c.Get("volname", &volname); err != nil {
+		return err
+	}
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		return err
+	}
+
+	heals := make(map[string][]volume.PendingHeal)
+	for _, b := range vol.Bricks {
+		if !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+			continue
+		}
+		h, err := volume.GetPendingHeals(volname, b)
+		if err != nil {
+			c.Logger().WithError(err).WithField(
+				"brick", b.Path).Error("getPendingHeals: failed to read the AFR index of brick")
+			return err
+		}
+		heals[b.ID()] = h
+	}
+
+	c.SetNodeResult(gdctx.MyUUID, pendingHealsTxnKey, heals)
+	return nil
+}
+
+func registerVolSplitBrainStepFuncs() {
+	transaction.RegisterStepFunc(getPendingHeals, "vol-split-brain.GetPendingHeals")
+	transaction.RegisterStepFunc(resolveSplitBrain, "vol-split-brain.Resolve")
+}
+
+// findSplitBrain finds the files of the volume in split-brain from the AFR
+// indices of its bricks on the nodes which are alive
+func findSplitBrain(reqID string, vol *volume.Volinfo) (*VolSplitBrainResp, error) {
+	resp := &VolSplitBrainResp{Files: []volume.SplitBrainFile{}}
+	// Volumes without replication can't have split-brain
+	if vol.ReplicaCount < 2 {
+		return resp, nil
+	}
+
+	var nodes []uuid.UUID
+	nodes, resp.UnreachableBricks = reachableVolNodes(vol)
+
+	heals := make(map[string][]volume.PendingHeal)
+	if len(nodes) > 0 {
+		txn := transaction.NewTxn(reqID)
+		defer txn.Cleanup()
+		txn.Nodes = nodes
+		txn.Steps = []*transaction.Step{
+			{
+				DoFunc: "vol-split-brain.GetPendingHeals",
+				Nodes:  txn.Nodes,
+			},
+		}
+		txn.Ctx.Set("volname", vol.Name)
+
+		rtxn, err := txn.Do()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, node := range nodes {
+			var tmp map[string][]volume.PendingHeal
+			if err := rtxn.GetNodeResult(node, pendingHealsTxnKey, &tmp); err != nil {
+				return nil, err
+			}
+			for id, h := range tmp {
+				heals[id] = h
+			}
+		}
+	}
+
+	resp.Files = volume.FindSplitBrain(vol, heals)
+	return resp, nil
+}
+
+func volumeSplitBrainHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+
+	resp, err := findSplitBrain(reqID, vol)
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"error":  err.Error(),
+			"volume": volname,
+		}).Error("failed to get pending heals of bricks")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	restutils.SendHTTPResponse(w, http.StatusOK, resp)
+}
@@ -0,0 +1,127 @@
+package volumecommands
+
+import (
+	"net/http"
+
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+// VolAllowedCNsReq sets the common names of the certificates of the clients
+// allowed to connect to the bricks of a volume. An empty list allows any
+// client with a certificate signed by the CA.
+type VolAllowedCNsReq struct {
+	CNs []string `json:"cns"`
+}
+
+// volumeSSLCAHandler sends the CA certificates clients need to mount the
+// volume with I/O encryption, in PEM
+func volumeSSLCAHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	_, logger := restutils.GetReqIDandLogger(r)
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+	if !vol.Encryption.IO {
+		restutils.SendHTTPError(w, http.StatusBadRequest, "I/O encryption is not enabled on volume")
+		return
+	}
+
+	ca, err := utils.ReadSSLCA()
+	if err != nil {
+		logger.WithError(err).WithField("volume", volname).Error("failed to read SSL CA certificates")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	restutils.SendHTTPText(w, http.StatusOK, string(ca))
+}
+
+// volumeAllowedCNsHandler sets the clients allowed to connect to the bricks
+// of the volume, regenerating the brick volfiles like a volume option change
+func volumeAllowedCNsHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+
+	var req VolAllowedCNsReq
+	if err := utils.GetJSONFromRequest(r, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, errors.ErrJSONParsingFailed.Error())
+		return
+	}
+	if !vol.Encryption.IO {
+		restutils.SendHTTPError(w, http.StatusBadRequest, "I/O encryption is not enabled on volume")
+		return
+	}
+	if err := volume.ValidateAllowedCNs(req.CNs); err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	lock, unlock, err := transaction.CreateLockSteps(vol.Name)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	allNodes, err := peer.GetPeerIDs()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = vol.Nodes()
+	txn.Steps = []*transaction.Step{
+		lock,
+		{
+			DoFunc: "vol-option.UpdateVolinfo",
+			Nodes:  []uuid.UUID{gdctx.MyUUID},
+		},
+		{
+			DoFunc: "vol-option.RegenerateVolfiles",
+			Nodes:  allNodes,
+		},
+		{
+			DoFunc: "vol-option.NotifyVolfileChange",
+			Nodes:  allNodes,
+		},
+		unlock,
+	}
+
+	vol.SetAllowedCNs(req.CNs)
+	if err := txn.Ctx.Set("volinfo", vol); err != nil {
+		logger.WithError(err).Error("failed to set volinfo in transaction context")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if _, err := txn.Do(); err != nil {
+		logger.WithError(err).Error("volume allowed CNs transaction failed")
+		if err == transaction.ErrLockTimeout {
+			restutils.SendHTTPError(w, http.StatusConflict, err.Error())
+		} else {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	restutils.SendHTTPResponse(w, http.StatusOK, vol.Encryption)
+}
@@ -1,17 +1,26 @@
 package volumecommands
 
 import (
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
 
 	"github.com/gluster/glusterd2/errors"
 	"github.com/gluster/glusterd2/gdctx"
 	restutils "github.com/gluster/glusterd2/servers/rest/utils"
 	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
 	"github.com/gluster/glusterd2/volume"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
 	"github.com/pborman/uuid"
+	config "github.com/spf13/viper"
+)
+
+const (
+	nodeHealthTxnKey string = "nodehealth"
 )
 
 func startAllBricks(c transaction.TxnCtx) error {
@@ -31,12 +40,27 @@ func startAllBricks(c transaction.TxnCtx) error {
 			continue
 		}
 
+		// A brick on a filesystem gone read-only, usually after I/O
+		// errors, would fail every write
+		if ro, err := utils.IsFilesystemReadOnly(b.Path); err != nil {
+			c.Logger().WithError(err).WithField("brick", b.Path).Warn("failed to check if brick filesystem is read-only")
+		} else if ro {
+			if config.GetString("brick-readonly-policy") != volume.ReadOnlyBrickStartDegraded {
+				return fmt.Errorf("brick %s:%s: %s", b.Hostname, b.Path, errors.ErrBrickFilesystemReadOnly)
+			}
+			c.Logger().WithFields(log.Fields{
+				"volume": b.VolumeName,
+				"brick":  b.Hostname + ":" + b.Path,
+			}).Warn("brick filesystem is read-only, not starting brick")
+			continue
+		}
+
 		c.Logger().WithFields(log.Fields{
 			"volume": b.VolumeName,
 			"brick":  b.Hostname + ":" + b.Path,
 		}).Info("Starting brick")
 
-		if err := startBrick(b); err != nil {
+		if err := startBrick(b, volinfo.EffectiveBrickLimits(), volinfo.BrickArgs); err != nil {
 			return err
 		}
 	}
@@ -83,9 +107,121 @@ func stopAllBricks(c transaction.TxnCtx) error {
 	return nil
 }
 
+// checkBrickDevicesOnStart fails the volume start if the device of a brick
+// on this node has changed since it was created
+func checkBrickDevicesOnStart(c transaction.TxnCtx) error {
+	var volname string
+	if err := c.Get("volname", &volname); err != nil {
+		return err
+	}
+
+	volinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		return err
+	}
+
+	result := checkLocalBrickDevices(volinfo)
+	if len(result.Changes) > 0 {
+		return &result.Changes[0]
+	}
+	return nil
+}
+
+// checkBrickVolumeIDsOnStart fails the volume start if a brick on this node
+// isn't marked with the ID of the volume, so that a brick doesn't serve the
+// data of another volume
+func checkBrickVolumeIDsOnStart(c transaction.TxnCtx) error {
+	var volname string
+	if err := c.Get("volname", &volname); err != nil {
+		return err
+	}
+
+	volinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range volinfo.Bricks {
+		if !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+			continue
+		}
+		mismatch, err := volume.CheckBrickVolumeID(b)
+		if err != nil {
+			return err
+		}
+		if mismatch != nil {
+			c.Logger().WithFields(log.Fields{
+				"brick":    b.Hostname + ":" + b.Path,
+				"expected": mismatch.Expected,
+				"actual":   mismatch.Actual,
+			}).Error("volume ID of brick doesn't match the volume")
+			return mismatch
+		}
+	}
+	return nil
+}
+
+func getNodeHealth(c transaction.TxnCtx) error {
+	health, err := utils.GetNodeHealth()
+	if err != nil {
+		c.Logger().WithError(err).Error("getNodeHealth: failed to get node health")
+		return err
+	}
+
+	c.SetNodeResult(gdctx.MyUUID, nodeHealthTxnKey, health)
+	return nil
+}
+
 func registerVolStartStepFuncs() {
 	transaction.RegisterStepFunc(startAllBricks, "vol-start.Commit")
 	transaction.RegisterStepFunc(stopAllBricks, "vol-start.Undo")
+	transaction.RegisterStepFunc(getNodeHealth, "vol-start.GetNodeHealth")
+	transaction.RegisterStepFunc(checkBrickDevicesOnStart, "vol-start.CheckBrickDevices")
+	transaction.RegisterStepFunc(checkBrickVolumeIDsOnStart, "vol-start.CheckBrickVolumeIDs")
+}
+
+// getNodesHealth gets the current health of the given nodes
+func getNodesHealth(reqID string, nodes []uuid.UUID) (map[string]utils.NodeHealth, error) {
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = nodes
+	txn.Steps = []*transaction.Step{
+		{
+			DoFunc: "vol-start.GetNodeHealth",
+			Nodes:  txn.Nodes,
+		},
+	}
+
+	rtxn, err := txn.Do()
+	if err != nil {
+		return nil, err
+	}
+
+	health := make(map[string]utils.NodeHealth)
+	for _, node := range nodes {
+		var h utils.NodeHealth
+		if err := rtxn.GetNodeResult(node, nodeHealthTxnKey, &h); err != nil {
+			return nil, err
+		}
+		health[node.String()] = h
+	}
+	return health, nil
+}
+
+// orderNodesByHealth orders the nodes healthiest first, so that bricks are
+// started on them before the overloaded ones. Nodes without health data
+// keep their order, after the others.
+func orderNodesByHealth(nodes []uuid.UUID, health map[string]utils.NodeHealth) []uuid.UUID {
+	ordered := append([]uuid.UUID{}, nodes...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		hi, iok := health[ordered[i].String()]
+		hj, jok := health[ordered[j].String()]
+		if !iok || !jok {
+			return iok && !jok
+		}
+		return hi.Score() < hj.Score()
+	})
+	return ordered
 }
 
 func volumeStartHandler(w http.ResponseWriter, r *http.Request) {
@@ -103,7 +239,31 @@ func volumeStartHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// A simple one-step transaction to start the brick processes
+	// The volume IDs of the bricks are checked unless overridden, for
+	// recovering bricks whose markings were lost
+	var skipVolumeIDCheck bool
+	if v := r.URL.Query().Get("skipVolumeIDCheck"); v != "" {
+		if skipVolumeIDCheck, e = strconv.ParseBool(v); e != nil {
+			restutils.SendHTTPError(w, http.StatusBadRequest, "invalid value for skipVolumeIDCheck")
+			return
+		}
+	}
+
+	// Bricks are started one node at a time, healthiest node first, so
+	// that an overloaded node isn't pushed over first. The ordering is best
+	// effort, and the stored order is used if the health of the nodes
+	// can't be found.
+	nodes := vol.Nodes()
+	if health, err := getNodesHealth(reqID, nodes); err != nil {
+		logger.WithError(err).WithField("volume", volname).Warn("failed to get health of nodes, starting bricks in the stored order")
+	} else {
+		nodes = orderNodesByHealth(nodes, health)
+	}
+	logger.WithFields(log.Fields{
+		"volume": volname,
+		"nodes":  nodes,
+	}).Debug("starting bricks on nodes in order")
+
 	txn := transaction.NewTxn(reqID)
 	defer txn.Cleanup()
 	lock, unlock, err := transaction.CreateLockSteps(volname)
@@ -111,16 +271,30 @@ func volumeStartHandler(w http.ResponseWriter, r *http.Request) {
 		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	txn.Nodes = vol.Nodes()
-	txn.Steps = []*transaction.Step{
-		lock,
-		{
+	txn.Nodes = nodes
+	txn.Steps = []*transaction.Step{lock}
+	if config.GetBool("brick-device-check") {
+		txn.Steps = append(txn.Steps, &transaction.Step{
+			DoFunc: "vol-start.CheckBrickDevices",
+			Nodes:  txn.Nodes,
+		})
+	}
+	if !skipVolumeIDCheck {
+		txn.Steps = append(txn.Steps, &transaction.Step{
+			DoFunc: "vol-start.CheckBrickVolumeIDs",
+			Nodes:  txn.Nodes,
+		})
+	} else {
+		logger.WithField("volume", volname).Warn("starting volume without checking the volume IDs of its bricks")
+	}
+	for _, node := range nodes {
+		txn.Steps = append(txn.Steps, &transaction.Step{
 			DoFunc:   "vol-start.Commit",
 			UndoFunc: "vol-start.Undo",
-			Nodes:    txn.Nodes,
-		},
-		unlock,
+			Nodes:    []uuid.UUID{node},
+		})
 	}
+	txn.Steps = append(txn.Steps, unlock)
 	txn.Ctx.Set("volname", volname)
 
 	_, e = txn.Do()
@@ -0,0 +1,245 @@
+package volumecommands
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/pkg/api"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+const (
+	brickIOStatsTxnKey string = "brickiostats"
+)
+
+// VolFopStats are the statistics of a file operation of a volume. Latencies
+// are in microseconds.
+type VolFopStats struct {
+	Name       string     `json:"name"`
+	Count      api.Uint64 `json:"count"`
+	AvgLatency float64    `json:"avg-latency-usec"`
+	MinLatency float64    `json:"min-latency-usec"`
+	MaxLatency float64    `json:"max-latency-usec"`
+}
+
+// VolStatsResp are the I/O statistics of a volume, summed over its bricks,
+// since they were last reset, or since the bricks started if they never were.
+// Bricks on nodes which couldn't be reached aren't counted, and are listed in
+// UnreachableBricks.
+type VolStatsResp struct {
+	ResetAt           *time.Time    `json:"reset-at,omitempty"`
+	ReadBytes         api.Uint64    `json:"read-bytes"`
+	WriteBytes        api.Uint64    `json:"write-bytes"`
+	Fops              []VolFopStats `json:"fops"`
+	UnreachableBricks []string      `json:"unreachable-bricks,omitempty"`
+}
+
+func getBrickIOStats(c transaction.TxnCtx) error {
+	var volname string
+	if err := c.Get("volname", &volname); err != nil {
+		return err
+	}
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		return err
+	}
+
+	stats := make(map[string]volume.IOStats)
+	for _, b := range vol.Bricks {
+		if !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+			continue
+		}
+		s, err := volume.GetBrickIOStats(b)
+		if err != nil {
+			c.Logger().WithError(err).WithField(
+				"brick", b.Path).Error("getBrickIOStats: failed to read brick statistics")
+			return err
+		}
+		stats[b.ID()] = s
+	}
+
+	c.SetNodeResult(gdctx.MyUUID, brickIOStatsTxnKey, stats)
+	return nil
+}
+
+func registerVolStatsStepFuncs() {
+	transaction.RegisterStepFunc(getBrickIOStats, "vol-stats.GetBrickStats")
+}
+
+// collectBrickIOStats returns the cumulative statistics of the bricks on the
+// nodes, by BrickID
+func collectBrickIOStats(rtxn transaction.TxnCtx, nodes []uuid.UUID) (map[string]volume.IOStats, error) {
+	stats := make(map[string]volume.IOStats)
+	for _, node := range nodes {
+		var tmp map[string]volume.IOStats
+		if err := rtxn.GetNodeResult(node, brickIOStatsTxnKey, &tmp); err != nil {
+			return nil, err
+		}
+		for id, s := range tmp {
+			stats[id] = s
+		}
+	}
+	return stats, nil
+}
+
+func volumeStatsHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+	if vol.Status != volume.VolStarted {
+		restutils.SendHTTPError(w, http.StatusBadRequest, "volume must be started to get its statistics")
+		return
+	}
+
+	baseline, err := volume.GetIOStatsBaseline(vol.ID)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var resp VolStatsResp
+	var nodes []uuid.UUID
+	nodes, resp.UnreachableBricks = reachableVolNodes(vol)
+	if len(nodes) == 0 {
+		restutils.SendHTTPError(w, http.StatusServiceUnavailable, "no node of the volume is reachable")
+		return
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = nodes
+	txn.Steps = []*transaction.Step{
+		{
+			DoFunc: "vol-stats.GetBrickStats",
+			Nodes:  txn.Nodes,
+		},
+	}
+	txn.Ctx.Set("volname", volname)
+
+	rtxn, err := txn.Do()
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"error":  err.Error(),
+			"volume": volname,
+		}).Error("failed to get brick statistics")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	stats, err := collectBrickIOStats(rtxn, nodes)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	total := volume.NewIOStats()
+	for id, s := range stats {
+		total.Add(s.Since(baseline.Bricks[id]))
+	}
+
+	if !baseline.ResetAt.IsZero() {
+		resp.ResetAt = &baseline.ResetAt
+	}
+	resp.ReadBytes = total.ReadBytes
+	resp.WriteBytes = total.WriteBytes
+	resp.Fops = []VolFopStats{}
+	for name, f := range total.Fops {
+		resp.Fops = append(resp.Fops, VolFopStats{
+			Name:       name,
+			Count:      f.Count,
+			AvgLatency: f.AvgLatency(),
+			MinLatency: f.MinLatency,
+			MaxLatency: f.MaxLatency,
+		})
+	}
+	sort.Slice(resp.Fops, func(i, j int) bool { return resp.Fops[i].Name < resp.Fops[j].Name })
+
+	restutils.SendHTTPResponse(w, http.StatusOK, resp)
+}
+
+// volumeStatsResetHandler resets the statistics of the volume by recording the
+// current statistics of its bricks, which are subtracted from later ones
+func volumeStatsResetHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+	if vol.Status != volume.VolStarted {
+		restutils.SendHTTPError(w, http.StatusBadRequest, "volume must be started to reset its statistics")
+		return
+	}
+
+	nodes, unreachable := reachableVolNodes(vol)
+	if len(unreachable) > 0 {
+		restutils.SendHTTPError(w, http.StatusServiceUnavailable, "all nodes of the volume must be reachable to reset its statistics")
+		return
+	}
+
+	lock, unlock, err := transaction.CreateLockSteps(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = nodes
+	txn.Steps = []*transaction.Step{
+		lock,
+		{
+			DoFunc: "vol-stats.GetBrickStats",
+			Nodes:  txn.Nodes,
+		},
+		unlock,
+	}
+	txn.Ctx.Set("volname", volname)
+
+	rtxn, err := txn.Do()
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"error":  err.Error(),
+			"volume": volname,
+		}).Error("failed to get brick statistics")
+		if err == transaction.ErrLockTimeout {
+			restutils.SendHTTPError(w, http.StatusConflict, err.Error())
+		} else {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	stats, err := collectBrickIOStats(rtxn, nodes)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	baseline := &volume.IOStatsBaseline{ResetAt: time.Now().UTC(), Bricks: stats}
+	if err := volume.SetIOStatsBaseline(vol.ID, baseline); err != nil {
+		logger.WithError(err).WithField("volume", volname).Error("failed to store statistics baseline")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logger.WithField("volume", volname).Info("volume statistics reset")
+	restutils.SendHTTPResponse(w, http.StatusOK, VolStatsResp{ResetAt: &baseline.ResetAt, Fops: []VolFopStats{}})
+}
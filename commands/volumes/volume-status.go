@@ -8,9 +8,11 @@ import (
 	"github.com/gluster/glusterd2/daemon"
 	"github.com/gluster/glusterd2/errors"
 	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
 	"github.com/gluster/glusterd2/pmap"
 	restutils "github.com/gluster/glusterd2/servers/rest/utils"
 	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
 	"github.com/gluster/glusterd2/volume"
 
 	log "github.com/Sirupsen/logrus"
@@ -42,6 +44,13 @@ func checkStatus(ctx transaction.TxnCtx) error {
 		return err
 	}
 
+	// Bricks are reported with the address of this node on the storage
+	// network
+	storageAddress := gdctx.HostName
+	if self, err := peer.GetPeerF(gdctx.MyUUID.String()); err == nil {
+		storageAddress = self.StorageHost()
+	}
+
 	var brickStatuses []*brick.Brickstatus
 
 	for _, binfo := range vol.Bricks {
@@ -69,11 +78,27 @@ func checkStatus(ctx transaction.TxnCtx) error {
 			}
 		}
 
+		readOnlyFS, err := utils.IsFilesystemReadOnly(binfo.Path)
+		if err != nil {
+			ctx.Logger().WithError(err).WithField("brick", binfo.Path).Debug("checkStatus: failed to check if brick filesystem is read-only")
+		}
+
+		var inodes *brick.InodeUsage
+		if total, free, err := utils.GetBrickInodes(binfo.Path); err == nil && total > 0 {
+			inodes = &brick.InodeUsage{Total: total, Free: free, Used: total - free}
+		}
+
 		brickStatus := &brick.Brickstatus{
-			BInfo:  binfo,
-			Online: online,
-			Pid:    pid,
-			Port:   port,
+			ID:             binfo.ID(),
+			BInfo:          binfo,
+			StorageAddress: storageAddress,
+			Online:         online,
+			Pid:            pid,
+			Port:           port,
+			Limits:         vol.EffectiveBrickLimits(),
+			ExtraArgs:      vol.BrickArgs,
+			ReadOnlyFS:     readOnlyFS,
+			Inodes:         inodes,
 		}
 		brickStatuses = append(brickStatuses, brickStatus)
 	}
@@ -111,6 +136,12 @@ func volumeStatusHandler(w http.ResponseWriter, r *http.Request) {
 	volname := p["volname"]
 	reqID, logger := restutils.GetReqIDandLogger(r)
 
+	fields, err := restutils.GetFields(r, volume.VolStatus{})
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Ensure that the volume exists.
 	vol, err := volume.GetVolume(volname)
 	if err != nil {
@@ -159,6 +190,12 @@ func volumeStatusHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if vol.Barrier.Active() {
+		result.Barrier = vol.Barrier
+	}
+	result.ReadOnly = vol.ReadOnly
+	result.SelfHeal = vol.ReplicaCount > 1 && !vol.SelfHealDisabled
+
 	// Send aggregated result back to the client.
-	restutils.SendHTTPResponse(w, http.StatusOK, result)
+	restutils.SendHTTPResponse(w, http.StatusOK, restutils.Project(result, fields))
 }
@@ -97,15 +97,23 @@ func volumeStopHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	txn.Nodes = vol.Nodes()
-	txn.Steps = []*transaction.Step{
-		lock,
-		{
+
+	// The volume stops being exported and shared along with its bricks,
+	// and has to be exported or shared again once started
+	unshare, unshareNodes := unshareSteps(vol)
+	txn.Nodes = appendNodes(txn.Nodes, unshareNodes)
+
+	txn.Steps = []*transaction.Step{lock}
+	txn.Steps = append(txn.Steps, unshare...)
+	txn.Steps = append(txn.Steps,
+		&transaction.Step{
 			DoFunc: "vol-stop.Commit",
-			Nodes:  txn.Nodes,
+			Nodes:  vol.Nodes(),
 		},
 		unlock,
-	}
+	)
 	txn.Ctx.Set("volname", volname)
+	txn.Ctx.Set("volinfo", vol)
 
 	if _, err = txn.Do(); err != nil {
 		logger.WithError(err).WithField(
@@ -119,6 +127,8 @@ func volumeStopHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	vol.Status = volume.VolStopped
+	vol.NFSExport = nil
+	vol.SMBShare = nil
 
 	e = volume.AddOrUpdateVolumeFunc(vol)
 	if e != nil {
@@ -0,0 +1,122 @@
+package volumecommands
+
+import (
+	"net/http"
+
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+// volumeSubdirExportsHandler lists the subdirectory exports of the volume
+func volumeSubdirExportsHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+
+	exports := vol.SubdirExports
+	if exports == nil {
+		exports = []volume.SubdirExport{}
+	}
+	restutils.SendHTTPResponse(w, http.StatusOK, exports)
+}
+
+// volumeSubdirExportAddHandler exports a subdirectory of the volume to the
+// given clients. The auth.allow option of the bricks is regenerated with the
+// export, like a volume option change.
+func volumeSubdirExportAddHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+
+	var req volume.SubdirExport
+	if err := utils.GetJSONFromRequest(r, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, errors.ErrJSONParsingFailed.Error())
+		return
+	}
+	export, err := volume.ValidateSubdirExport(req)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := vol.CheckSubdirExportConflict(export); err != nil {
+		restutils.SendHTTPError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if !checkFeatureSupported(w, "subdir-exports") {
+		return
+	}
+
+	lock, unlock, err := transaction.CreateLockSteps(vol.Name)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	allNodes, err := peer.GetPeerIDs()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = vol.Nodes()
+	txn.Steps = []*transaction.Step{
+		lock,
+		{
+			DoFunc: "vol-option.UpdateVolinfo",
+			Nodes:  []uuid.UUID{gdctx.MyUUID},
+		},
+		{
+			DoFunc: "vol-option.RegenerateVolfiles",
+			Nodes:  allNodes,
+		},
+		{
+			DoFunc: "vol-option.NotifyVolfileChange",
+			Nodes:  allNodes,
+		},
+		unlock,
+	}
+
+	vol.SetSubdirExports(append(vol.SubdirExports, export))
+	if err := txn.Ctx.Set("volinfo", vol); err != nil {
+		logger.WithError(err).Error("failed to set volinfo in transaction context")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if _, err := txn.Do(); err != nil {
+		logger.WithError(err).Error("volume subdir export transaction failed")
+		if err == transaction.ErrLockTimeout {
+			restutils.SendHTTPError(w, http.StatusConflict, err.Error())
+		} else {
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	logger.WithFields(log.Fields{
+		"volume":  volname,
+		"subdir":  export.Path,
+		"clients": export.Clients,
+	}).Info("exported subdirectory of volume")
+	restutils.SendHTTPResponse(w, http.StatusCreated, vol.SubdirExports)
+}
@@ -0,0 +1,157 @@
+package volumecommands
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gluster/glusterd2/errors"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	"github.com/gorilla/mux"
+)
+
+// tunable is a volume option recommended for a workload. Tunables for
+// replicated volumes only aren't recommended for other volumes.
+type tunable struct {
+	option         string
+	value          string
+	replicatedOnly bool
+	reason         string
+}
+
+var (
+	cacheInvalidationTunables = []tunable{
+		{"upcall.cache-invalidation", "on", false,
+			"Lets the bricks tell clients when cached metadata changes, so that it can be cached for long."},
+		{"md-cache.cache-invalidation", "on", false,
+			"Makes clients drop the metadata the bricks invalidate, keeping their caches coherent."},
+		{"md-cache.md-cache-timeout", "600", false,
+			"Keeps file metadata cached on clients for 10 minutes, saving the lookups and stats which dominate."},
+	}
+
+	// workloadTunables are the tunables recommended for each workload hint
+	workloadTunables = map[string][]tunable{
+		"smallfile": append([]tunable{
+			{"dht.lookup-optimize", "on", false,
+				"Stops looking up files missing from their hashed brick on all the bricks, as creates are frequent."},
+			{"client.event-threads", "4", false,
+				"Handles the many small requests of a client concurrently."},
+			{"server.event-threads", "4", false,
+				"Handles the many small requests on the bricks concurrently."},
+		}, cacheInvalidationTunables...),
+		"largefile": {
+			{"write-behind.cache-size", "4MB", false,
+				"Aggregates more of the sequential writes before sending them to the bricks."},
+			{"read-ahead.page-count", "16", false,
+				"Reads further ahead of sequential readers."},
+			{"io-cache.cache-size", "256MB", false,
+				"Caches more of the file data read on clients."},
+			{"io-threads.thread-count", "32", false,
+				"Serves more concurrent reads and writes on the bricks."},
+		},
+		"vm-store": {
+			{"write-behind.strict-O_DIRECT", "on", false,
+				"Honours the O_DIRECT writes of hypervisors instead of caching them, so that guests don't lose acknowledged writes."},
+			{"afr.eager-lock", "on", true,
+				"Keeps the lock on an image across writes instead of taking one for every write."},
+			{"afr.quorum-type", "auto", true,
+				"Rejects writes when most of a replica set is down, so that images don't end up in split-brain."},
+			{"afr.data-self-heal-algorithm", "full", true,
+				"Heals images by copying them, rather than by checksumming large files on both sides."},
+		},
+		"metadata-heavy": append([]tunable{
+			{"dht.readdir-optimize", "on", false,
+				"Lists directories from a single brick where possible instead of from all the bricks."},
+			{"io-threads.thread-count", "32", false,
+				"Serves more concurrent metadata operations on the bricks."},
+		}, cacheInvalidationTunables...),
+	}
+)
+
+// VolTuneAdvisorReq is a request for the options recommended for a volume
+// having the given workload
+type VolTuneAdvisorReq struct {
+	Workload string `json:"workload"`
+}
+
+// TuneRecommendation is a volume option recommended for a workload, with the
+// reason. Current is the value the option is set to on the volume, if set.
+type TuneRecommendation struct {
+	Option  string `json:"option"`
+	Value   string `json:"value"`
+	Current string `json:"current,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+// VolTuneAdvice is the advice of the tune advisor for a volume. Options are
+// the recommended options the volume doesn't have yet, which can be set as
+// they are with the volume options request.
+type VolTuneAdvice struct {
+	Volume          string               `json:"volume"`
+	Workload        string               `json:"workload"`
+	Recommendations []TuneRecommendation `json:"recommendations"`
+	Options         map[string]string    `json:"options"`
+}
+
+func workloadHints() []string {
+	var hints []string
+	for hint := range workloadTunables {
+		hints = append(hints, hint)
+	}
+	sort.Strings(hints)
+	return hints
+}
+
+// adviseTunables returns the advice for the volume having the workload
+func adviseTunables(vol *volume.Volinfo, workload string) *VolTuneAdvice {
+	advice := &VolTuneAdvice{
+		Volume:          vol.Name,
+		Workload:        workload,
+		Recommendations: []TuneRecommendation{},
+		Options:         make(map[string]string),
+	}
+	for _, t := range workloadTunables[workload] {
+		if t.replicatedOnly && vol.ReplicaCount < 2 {
+			continue
+		}
+		current := vol.Options[t.option]
+		advice.Recommendations = append(advice.Recommendations, TuneRecommendation{
+			Option:  t.option,
+			Value:   t.value,
+			Current: current,
+			Reason:  t.reason,
+		})
+		if current != t.value {
+			advice.Options[t.option] = t.value
+		}
+	}
+	return advice
+}
+
+// volumeTuneAdvisorHandler returns the options recommended for the volume for
+// a workload hint, without setting them
+func volumeTuneAdvisorHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+
+	vol, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+
+	var req VolTuneAdvisorReq
+	if err := utils.GetJSONFromRequest(r, &req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, errors.ErrJSONParsingFailed.Error())
+		return
+	}
+	if _, ok := workloadTunables[req.Workload]; !ok {
+		restutils.SendHTTPError(w, http.StatusBadRequest,
+			"unknown workload "+req.Workload+", must be one of "+strings.Join(workloadHints(), ", "))
+		return
+	}
+
+	restutils.SendHTTPResponse(w, http.StatusOK, adviseTunables(vol, req.Workload))
+}
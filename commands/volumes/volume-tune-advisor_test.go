@@ -0,0 +1,45 @@
+package volumecommands
+
+import (
+	"testing"
+
+	"github.com/gluster/glusterd2/tests"
+	"github.com/gluster/glusterd2/volume"
+)
+
+// TestAdviseTunables validates the advice for volumes with and without
+// replication, some of whose options are already set
+func TestAdviseTunables(t *testing.T) {
+	replicated := &volume.Volinfo{
+		Name:         "vms",
+		ReplicaCount: 3,
+		Options: map[string]string{
+			"afr.eager-lock":  "on",
+			"afr.quorum-type": "fixed",
+		},
+	}
+	advice := adviseTunables(replicated, "vm-store")
+	tests.Assert(t, advice.Volume == "vms" && advice.Workload == "vm-store")
+	tests.Assert(t, len(advice.Recommendations) == 4)
+	for _, r := range advice.Recommendations {
+		tests.Assert(t, r.Reason != "" && r.Current == replicated.Options[r.Option])
+	}
+	// Options already set as recommended aren't to be set again
+	tests.Assert(t, len(advice.Options) == 3)
+	_, ok := advice.Options["afr.eager-lock"]
+	tests.Assert(t, !ok)
+	tests.Assert(t, advice.Options["afr.quorum-type"] == "auto")
+
+	// Tunables of replicated volumes aren't recommended for others
+	distributed := &volume.Volinfo{Name: "dist", ReplicaCount: 1}
+	advice = adviseTunables(distributed, "vm-store")
+	tests.Assert(t, len(advice.Recommendations) == 1 && len(advice.Options) == 1)
+	tests.Assert(t, advice.Options["write-behind.strict-O_DIRECT"] == "on")
+
+	// Every workload has recommendations for any volume
+	for _, hint := range workloadHints() {
+		advice = adviseTunables(distributed, hint)
+		tests.Assert(t, len(advice.Recommendations) > 0)
+		tests.Assert(t, len(advice.Options) == len(advice.Recommendations))
+	}
+}
@@ -0,0 +1,131 @@
+package volumecommands
+
+import (
+	"net/http"
+
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/pkg/api"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	"github.com/gorilla/mux"
+)
+
+// VolUtilizationResp is the space and inode utilization of a volume. The
+// volume capacity and usage count every replica set once, by its most
+// utilized brick. Alerts are the bricks which have crossed a space or inode
+// utilization threshold.
+type VolUtilizationResp struct {
+	Total           api.Uint64                `json:"total"`
+	Used            api.Uint64                `json:"used"`
+	Percent         int                       `json:"percent"`
+	Thresholds      []int                     `json:"thresholds"`
+	InodesTotal     api.Uint64                `json:"inodes-total"`
+	InodesUsed      api.Uint64                `json:"inodes-used"`
+	InodesPercent   int                       `json:"inodes-percent"`
+	InodeThresholds []int                     `json:"inode-thresholds"`
+	Bricks          []volume.BrickUtilization `json:"bricks"`
+	Alerts          []volume.BrickUtilization `json:"alerts,omitempty"`
+}
+
+// spaceUsage and inodeUsage return the total and the used space and inodes of
+// a brick
+func spaceUsage(u volume.BrickUtilization) (api.Uint64, api.Uint64) {
+	return u.Total, u.Used
+}
+
+func inodeUsage(u volume.BrickUtilization) (api.Uint64, api.Uint64) {
+	return u.InodesTotal, u.InodesUsed
+}
+
+// volumeUtilization sums up the utilization of the replica sets of the
+// volume, as given by usage. Replica sets having no sampled brick are left
+// out.
+func volumeUtilization(v *volume.Volinfo, bricks []volume.BrickUtilization,
+	usage func(volume.BrickUtilization) (api.Uint64, api.Uint64)) (api.Uint64, api.Uint64) {
+	sampled := make(map[string]volume.BrickUtilization)
+	for _, u := range bricks {
+		if total, _ := usage(u); total > 0 {
+			sampled[u.BrickID] = u
+		}
+	}
+
+	setSize := v.ReplicaCount
+	if setSize < 1 {
+		setSize = 1
+	}
+
+	var total, used api.Uint64
+	for i := 0; i < len(v.Bricks); i += setSize {
+		var worstTotal, worstUsed api.Uint64
+		for j := i; j < i+setSize && j < len(v.Bricks); j++ {
+			u, ok := sampled[v.Bricks[j].ID()]
+			if !ok {
+				continue
+			}
+			t, us := usage(u)
+			if worstTotal == 0 || float64(us)/float64(t) > float64(worstUsed)/float64(worstTotal) {
+				worstTotal, worstUsed = t, us
+			}
+		}
+		total += worstTotal
+		used += worstUsed
+	}
+	return total, used
+}
+
+func volumeUtilizationHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	_, logger := restutils.GetReqIDandLogger(r)
+
+	fields, err := restutils.GetFields(r, VolUtilizationResp{})
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	volinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+
+	thresholds, err := volinfo.UtilizationThresholds()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	inodeThresholds, err := volinfo.InodeUtilizationThresholds()
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	bricks, err := volume.GetBrickUtilizations(volinfo)
+	if err != nil {
+		logger.WithError(err).WithField("volume", volname).Error("failed to get brick utilizations")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp := VolUtilizationResp{
+		Thresholds:      thresholds,
+		InodeThresholds: inodeThresholds,
+		Bricks:          bricks,
+	}
+	resp.Total, resp.Used = volumeUtilization(volinfo, bricks, spaceUsage)
+	if resp.Total > 0 {
+		resp.Percent = int(resp.Used * 100 / resp.Total)
+	}
+	resp.InodesTotal, resp.InodesUsed = volumeUtilization(volinfo, bricks, inodeUsage)
+	if resp.InodesTotal > 0 {
+		resp.InodesPercent = int(resp.InodesUsed * 100 / resp.InodesTotal)
+	}
+	for _, u := range bricks {
+		if u.Threshold > 0 || u.InodeThreshold > 0 {
+			resp.Alerts = append(resp.Alerts, u)
+		}
+	}
+
+	restutils.SendHTTPResponse(w, http.StatusOK, restutils.Project(resp, fields))
+}
@@ -0,0 +1,314 @@
+package volumecommands
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gluster/glusterd2/brick"
+	gderrors "github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
+
+	"github.com/pborman/uuid"
+	config "github.com/spf13/viper"
+)
+
+const volValidateTxnKey = "volvalidate"
+
+// BrickCheck is the result of checking a brick of the request on its node
+type BrickCheck struct {
+	FSType string   `json:"fstype,omitempty"`
+	Issues []string `json:"issues,omitempty"`
+}
+
+// VolValidateBrick is the validation report of a brick of the request, given
+// as in the request
+type VolValidateBrick struct {
+	Brick  string    `json:"brick"`
+	NodeID uuid.UUID `json:"node-id,omitempty"`
+	BrickCheck
+}
+
+// VolValidateResp is the validation report of a volume create request. Issues
+// are keyed by the field of the request they are about, and those of a brick
+// are in the report of the brick, in the order of the bricks of the request.
+// Warnings don't fail volume create. Type and ReplicaCount are those the
+// volume would be created with, unless there are issues with them.
+type VolValidateResp struct {
+	Valid        bool                `json:"valid"`
+	Type         volume.VolType      `json:"type"`
+	ReplicaCount int                 `json:"replica"`
+	Issues       map[string][]string `json:"issues,omitempty"`
+	Bricks       []VolValidateBrick  `json:"bricks,omitempty"`
+	Warnings     []string            `json:"warnings,omitempty"`
+}
+
+func (resp *VolValidateResp) addIssue(field string, err error) {
+	if resp.Issues == nil {
+		resp.Issues = make(map[string][]string)
+	}
+	resp.Issues[field] = append(resp.Issues[field], err.Error())
+}
+
+// checkVolumeBricks checks the bricks of this node as volume create would,
+// without modifying them. Failed checks are results rather than step
+// failures, so that all the issues of all the bricks are found.
+func checkVolumeBricks(c transaction.TxnCtx) error {
+	var bricks []brick.Brickinfo
+	if err := c.Get("bricks", &bricks); err != nil {
+		return err
+	}
+
+	var req VolCreateRequest
+	if err := c.Get("req", &req); err != nil {
+		return err
+	}
+
+	policy, err := volume.GetBrickPathPolicy()
+	if err != nil {
+		return err
+	}
+
+	var nodeIssues []string
+	if req.Encryption.IO {
+		if err := utils.CheckSSLCerts(); err != nil {
+			nodeIssues = append(nodeIssues, err.Error())
+		}
+	}
+
+	results := make(map[string]BrickCheck)
+	for _, b := range bricks {
+		if !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+			continue
+		}
+		result := BrickCheck{Issues: nodeIssues}
+		for _, err := range volume.CheckBrickEntry(b, policy, req.Force) {
+			result.Issues = append(result.Issues, err.Error())
+		}
+		if fstype, err := utils.GetFSType(b.Path); err == nil {
+			result.FSType = fstype
+		}
+		results[b.Hostname+":"+b.Path] = result
+	}
+
+	c.SetNodeResult(gdctx.MyUUID, volValidateTxnKey, results)
+	return nil
+}
+
+func registerVolValidateStepFuncs() {
+	transaction.RegisterStepFunc(checkVolumeBricks, "vol-validate.Bricks")
+}
+
+// checkVolumeLayout finds the issues with the layout of the bricks, which
+// are all the bricks of the request: the arithmetic of the replica count,
+// the fault domains of the replica sets, the thin-arbiter and the placement
+// constraints
+func checkVolumeLayout(req *VolCreateRequest, bricks []brick.Brickinfo, resp *VolValidateResp) {
+	replicaCount, err := replicaCountForRequest(req)
+	if err != nil {
+		resp.addIssue("type", err)
+		return
+	}
+	resp.ReplicaCount = replicaCount
+	if len(req.Bricks)%replicaCount != 0 {
+		resp.addIssue("replica", fmt.Errorf("number of bricks %d is not a multiple of the replica count %d",
+			len(req.Bricks), replicaCount))
+		return
+	}
+	resp.Type = volume.InferVolumeType(len(req.Bricks), replicaCount)
+
+	// Bricks which failed to parse are already issues of their own
+	if bricks == nil || volume.ValidateBrickOrder(req.BrickOrder) != nil {
+		return
+	}
+	order := req.BrickOrder
+	if order == "" {
+		order = volume.BrickOrderAsGiven
+	}
+	bricks = volume.OrderBricks(bricks, replicaCount, order)
+
+	// Every replica set is checked on its own to find all the sets with
+	// bricks sharing a node
+	if !req.Force && replicaCount > 1 {
+		for i := 0; i < len(bricks); i += replicaCount {
+			if err := volume.ValidateNewReplicaSets(bricks[:i+replicaCount], replicaCount, i); err != nil {
+				resp.addIssue("bricks", err)
+			}
+		}
+	}
+
+	if req.ThinArbiter != "" {
+		ta, err := volume.ParseThinArbiter(req.ThinArbiter)
+		if err == nil {
+			err = volume.ValidateThinArbiter(ta, replicaCount, bricks)
+		}
+		if err == nil {
+			err = checkThinArbiterReachable(ta)
+		}
+		if err != nil {
+			resp.addIssue("thin-arbiter", err)
+		}
+	}
+
+	if req.Placement != nil {
+		labels, err := peer.GetNodeLabels()
+		if err != nil {
+			resp.addIssue("placement", err)
+			return
+		}
+		unpreferred, err := req.Placement.Check(bricks, replicaCount, 0, labels)
+		if err != nil {
+			resp.addIssue("placement", err)
+		}
+		if len(unpreferred) > 0 {
+			resp.Warnings = append(resp.Warnings, fmt.Sprintf(
+				"bricks are not on nodes with the preferred labels: %v", unpreferred))
+		}
+	}
+}
+
+// volumeValidateHandler validates a volume create request without creating
+// the volume or its bricks, and reports every issue found rather than only
+// the first
+func volumeValidateHandler(w http.ResponseWriter, r *http.Request) {
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	req := new(VolCreateRequest)
+	if err := utils.GetJSONFromRequest(r, req); err != nil {
+		restutils.SendHTTPError(w, http.StatusUnprocessableEntity, gderrors.ErrJSONParsingFailed.Error())
+		return
+	}
+	if len(req.BrickArgs) > 0 && !checkFeatureSupported(w, "brick-args") {
+		return
+	}
+
+	resp := new(VolValidateResp)
+	for _, fe := range volCreateRequestErrors(req) {
+		resp.addIssue(fe.field, fe.err)
+	}
+	if req.Name != "" && volume.ExistsFunc(req.Name) {
+		resp.addIssue("name", gderrors.ErrVolExists)
+	}
+	if err := areOptionNamesValid(req.Options); err != nil {
+		resp.addIssue("options", fmt.Errorf("invalid volume option specified: %s", err.Error()))
+	}
+
+	// Bricks are parsed one by one, to report all those which fail
+	var bricks []brick.Brickinfo
+	seen := make(map[string]bool)
+	for _, b := range req.Bricks {
+		report := VolValidateBrick{Brick: b}
+		entries, err := volume.NewBrickEntriesFunc([]string{b}, req.Name, nil)
+		if err != nil {
+			report.Issues = append(report.Issues, err.Error())
+		} else {
+			report.NodeID = entries[0].NodeID
+			key := entries[0].NodeID.String() + ":" + entries[0].Path
+			if seen[key] {
+				report.Issues = append(report.Issues, "brick is given more than once")
+			}
+			seen[key] = true
+			bricks = append(bricks, entries[0])
+		}
+		resp.Bricks = append(resp.Bricks, report)
+	}
+
+	if len(bricks) == len(req.Bricks) {
+		checkVolumeLayout(req, bricks, resp)
+	} else {
+		checkVolumeLayout(req, nil, resp)
+	}
+
+	// Bricks on nodes which are offline can't be checked, and would fail
+	// volume create
+	var nodes []uuid.UUID
+	alive := make(map[string]bool)
+	for _, b := range bricks {
+		id := b.NodeID.String()
+		if _, ok := alive[id]; !ok {
+			alive[id] = peer.IsOnline(b.NodeID)
+			if alive[id] {
+				nodes = append(nodes, b.NodeID)
+			}
+		}
+	}
+
+	results := make(map[string]BrickCheck)
+	if len(nodes) > 0 {
+		// Read-only transaction checking the bricks on their nodes
+		txn := transaction.NewTxn(reqID)
+		defer txn.Cleanup()
+		txn.Nodes = nodes
+		txn.Steps = []*transaction.Step{
+			{
+				DoFunc: "vol-validate.Bricks",
+				Nodes:  txn.Nodes,
+			},
+		}
+		txn.Ctx.Set("bricks", bricks)
+		txn.Ctx.Set("req", req)
+
+		rtxn, err := txn.Do()
+		if err != nil {
+			logger.WithError(err).Error("failed to check the bricks on their nodes")
+			restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, node := range nodes {
+			var checks map[string]BrickCheck
+			if err := rtxn.GetNodeResult(node, volValidateTxnKey, &checks); err != nil {
+				restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			for b, check := range checks {
+				results[b] = check
+			}
+		}
+	}
+
+	var fstypes []BrickFSType
+	types := make(map[string]bool)
+	next := 0
+	for i := range resp.Bricks {
+		report := &resp.Bricks[i]
+		if report.NodeID == nil {
+			// Failed to parse
+			continue
+		}
+		b := bricks[next]
+		next++
+		if !alive[b.NodeID.String()] {
+			report.Issues = append(report.Issues, fmt.Sprintf("node %s is offline", b.NodeID))
+			continue
+		}
+		check := results[b.Hostname+":"+b.Path]
+		report.Issues = append(report.Issues, check.Issues...)
+		report.FSType = check.FSType
+		if check.FSType != "" {
+			fstypes = append(fstypes, BrickFSType{Brick: report.Brick, FSType: check.FSType})
+			types[check.FSType] = true
+		}
+	}
+	if len(types) > 1 {
+		sort.Slice(fstypes, func(i, j int) bool { return fstypes[i].Brick < fstypes[j].Brick })
+		mixed := &MixedFSTypesError{Bricks: fstypes}
+		if config.GetBool("brick-fstype-strict") {
+			resp.addIssue("bricks", mixed)
+		} else {
+			resp.Warnings = append(resp.Warnings, mixed.Error())
+		}
+	}
+
+	resp.Valid = len(resp.Issues) == 0
+	for _, b := range resp.Bricks {
+		if len(b.Issues) > 0 {
+			resp.Valid = false
+		}
+	}
+	restutils.SendHTTPResponse(w, http.StatusOK, resp)
+}
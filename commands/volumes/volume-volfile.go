@@ -0,0 +1,133 @@
+package volumecommands
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+	"github.com/gluster/glusterd2/volgen"
+	"github.com/gluster/glusterd2/volume"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+const (
+	brickVolfileTxnKey string = "brickvolfile"
+)
+
+func getBrickVolfile(c transaction.TxnCtx) error {
+
+	var volinfo volume.Volinfo
+	if err := c.Get("volinfo", &volinfo); err != nil {
+		return err
+	}
+
+	var b brick.Brickinfo
+	if err := c.Get("brick", &b); err != nil {
+		return err
+	}
+
+	c.SetNodeResult(gdctx.MyUUID, brickVolfileTxnKey, volgen.GetBrickVolfile(&volinfo, &b))
+	return nil
+}
+
+func registerVolVolfileStepFuncs() {
+	transaction.RegisterStepFunc(getBrickVolfile, "vol-volfile.BrickVolfile")
+}
+
+// volumeVolfileHandler returns the client volfile of the volume, generated
+// afresh so that it reflects the current volume options. Notes on the volume
+// options which don't apply to the client graph, and on the volume not being
+// started, are added as volfile comments, so that the volfile stays usable.
+func volumeVolfileHandler(w http.ResponseWriter, r *http.Request) {
+	volname := mux.Vars(r)["volname"]
+	_, logger := restutils.GetReqIDandLogger(r)
+
+	volinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+
+	volfile, unapplied, err := volgen.ResolveClientVolfile(volinfo)
+	if err != nil {
+		logger.WithError(err).WithField("volume", volname).Error("failed to generate client volfile")
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var notes []string
+	if volinfo.Status != volume.VolStarted {
+		notes = append(notes, fmt.Sprintf("# volume %s is not started, clients can't mount it yet\n", volname))
+	}
+	if len(unapplied) > 0 {
+		notes = append(notes, "# options not applying to the client graph: "+strings.Join(unapplied, ", ")+"\n")
+	}
+	restutils.SendHTTPText(w, http.StatusOK, strings.Join(notes, "")+volfile)
+}
+
+// brickVolfileHandler returns the brick volfile of a brick, addressed by its
+// BrickID
+func brickVolfileHandler(w http.ResponseWriter, r *http.Request) {
+	p := mux.Vars(r)
+	volname := p["volname"]
+	reqID, logger := restutils.GetReqIDandLogger(r)
+
+	volinfo, err := volume.GetVolume(volname)
+	if err != nil {
+		restutils.SendHTTPError(w, http.StatusNotFound, errors.ErrVolNotFound.Error())
+		return
+	}
+
+	b, ok := volinfo.BrickByID(p["brickid"])
+	if !ok {
+		restutils.SendHTTPError(w, http.StatusNotFound, "brick not found")
+		return
+	}
+
+	if !peer.IsOnline(b.NodeID) {
+		restutils.SendHTTPError(w, http.StatusServiceUnavailable, "node hosting the brick is unreachable")
+		return
+	}
+
+	// The brick volfile depends on the local configuration of the node
+	// hosting the brick, so it is generated there
+	txn := transaction.NewTxn(reqID)
+	defer txn.Cleanup()
+	txn.Nodes = []uuid.UUID{b.NodeID}
+	txn.Steps = []*transaction.Step{
+		{
+			DoFunc: "vol-volfile.BrickVolfile",
+			Nodes:  txn.Nodes,
+		},
+	}
+	txn.Ctx.Set("volinfo", volinfo)
+	txn.Ctx.Set("brick", b)
+
+	rtxn, err := txn.Do()
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"error":  err.Error(),
+			"volume": volname,
+			"brick":  b.Hostname + ":" + b.Path,
+		}).Error("failed to get brick volfile")
+		restutils.SendHTTPError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	var volfile string
+	if err := rtxn.GetNodeResult(b.NodeID, brickVolfileTxnKey, &volfile); err != nil {
+		restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	restutils.SendHTTPText(w, http.StatusOK, volfile)
+}
@@ -2,12 +2,19 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"net"
 	"os"
 	"path"
+	"time"
 
+	"github.com/gluster/glusterd2/brick"
 	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	"github.com/gluster/glusterd2/servers/rest"
 	"github.com/gluster/glusterd2/store"
+	"github.com/gluster/glusterd2/utils"
+	"github.com/gluster/glusterd2/volume"
 
 	log "github.com/Sirupsen/logrus"
 	flag "github.com/spf13/pflag"
@@ -16,6 +23,7 @@ import (
 
 const (
 	defaultLogLevel      = "debug"
+	defaultLogFormat     = "text"
 	defaultClientAddress = ":24007"
 	defaultPeerAddress   = ":24008"
 
@@ -39,9 +47,79 @@ func parseFlags() {
 	flag.String("logfile", "-", "Log file name. (default: STDERR)")
 	flag.String("config", "", "Configuration file for GlusterD. By default looks for glusterd.(yaml|toml|json) in /etc/glusterd and current working directory.")
 	flag.String("loglevel", defaultLogLevel, "Severity of messages to be logged.")
+	flag.String("logformat", defaultLogFormat, "Format of the log output, one of text or json.")
+	flag.Bool("logcaller", false, "Include the file:line of the caller in log messages.")
+	flag.Int("log-max-size", 0, "Size in MiB beyond which the log files of glusterd2 and of the bricks are rotated. (default: never rotated)")
+	flag.Duration("log-max-age", 0, "Time after which rotated log files are removed. (default: kept by age)")
+	flag.Int("log-max-backups", 0, "Number of rotated log files kept for each log file. (default: all)")
 
 	flag.String("clientaddress", defaultClientAddress, "Address to bind the REST service.")
+	flag.String("rest-socket", "", "Path of a Unix domain socket to serve the REST API on, in addition to the client address.")
+	flag.Bool("rest-socket-only", false, "Serve the REST API only on the rest-socket, and not on the client address.")
+	flag.StringSlice("rest-socket-users", nil, "Users, besides root and the user glusterd2 runs as, allowed to connect to the rest-socket. Clients of the socket don't need REST auth tokens.")
+	flag.String("rest-cert-file", "", "Certificate file of the REST service, which serves HTTPS on the client address if given. The certificates are reloaded on SIGHUP.")
+	flag.String("rest-key-file", "", "Private key file of the rest-cert-file certificate.")
+	flag.String("rest-ca-file", "", "CA bundle REST clients must present a certificate signed by. (default: client certificates aren't required)")
+	flag.String("rest-tls-min-version", "1.2", "Minimum TLS version of the REST service, one of 1.0, 1.1 or 1.2.")
+	flag.Bool("rest-auth", false, "Require REST requests to carry a token of a user with the role the route needs, issued by /v1/auth/token for the secret in the auth-secret file of localstatedir. Reads needing only the viewer role are allowed without a token.")
+	flag.Duration("rest-auth-token-max-ttl", time.Hour, "Maximum time a REST API token is valid for.")
+	flag.Duration("rest-read-header-timeout", 10*time.Second, "Maximum time to read the headers of a REST request. (0 for the read timeout)")
+	flag.Duration("rest-read-timeout", time.Minute, "Maximum time to read a REST request, including its body. (0 for no limit)")
+	flag.Duration("rest-write-timeout", 10*time.Minute, "Maximum time to serve a REST request, after reading its headers. Requests taking longer should be made asynchronous. (0 for no limit)")
+	flag.Duration("max-request-timeout", 10*time.Minute, "Maximum deadline REST requests can set with the X-Request-Timeout header. Longer deadlines are capped to it. (0 for no limit)")
+	flag.Duration("rest-idle-timeout", 2*time.Minute, "Maximum time an idle keep-alive REST connection is kept open. (0 for the read timeout)")
 	flag.String("peeraddress", defaultPeerAddress, "Address to bind the inter glusterd2 RPC service.")
+	flag.String("storage-address", "", "Address clients reach the bricks of this node on, when storage traffic is on a network of its own. (default: the peer address)")
+	flag.String("addressfamily", utils.AddressFamilyAuto, "Preferred address family for local and peer addresses, one of auto, ipv4 or ipv6.")
+	flag.String("peer-identifier", peer.IdentifierUUID, "Canonical form peers are identified by in REST responses, one of uuid or hostname. Both forms are accepted in requests.")
+	flag.Duration("peer-probe-timeout", 30*time.Second, "Maximum time to wait for a peer being added to join the cluster.")
+
+	flag.Bool("brick-device-check", false, "Check that bricks are on the devices they were created on before starting a volume.")
+	flag.Bool("brick-fstype-strict", false, "Reject creating or expanding volumes whose bricks are on different filesystem types, which is only logged otherwise.")
+	flag.String("brick-readonly-policy", volume.ReadOnlyBrickFail, "What a volume start does with bricks whose filesystem is read-only, one of fail or start-degraded, which leaves them stopped.")
+	flag.Int("brick-max-depth", 0, "Maximum directory depth allowed for brick paths. (default: unlimited)")
+	flag.Int("default-replica-count", 3, "Replica count used for replicated volumes created without giving a replica count.")
+	flag.Int("brick-memory-limit", 0, "Maximum memory of a brick process in MiB, for volumes without limits of their own. Needs the cgroup v2 hierarchy to be delegated to glusterd2. (default: unlimited)")
+	flag.Int("brick-cpu-limit", 0, "Maximum CPU usage of a brick process as a percentage of one CPU, for volumes without limits of their own. Needs the cgroup v2 hierarchy to be delegated to glusterd2. (default: unlimited)")
+	flag.Int("brick-open-files-limit", 0, "Maximum number of open files of a brick process, for volumes without limits of their own. (default: unlimited)")
+	flag.Bool("readonly-followers", false, "Serve only read requests on nodes other than the leader, redirecting other requests to the leader.")
+	flag.String("leader-forwarding", "redirect", "How read-only followers forward mutating requests to the leader, one of redirect or proxy.")
+	flag.Float64("ratelimit-read", 0, "Number of read requests per second allowed on each REST route. (default: unlimited)")
+	flag.Float64("ratelimit-write", 0, "Number of mutating requests per second allowed on each REST route. (default: unlimited)")
+	flag.Int("ratelimit-burst", 0, "Number of requests allowed in a burst above the rate limits. (default: a second worth of requests)")
+	flag.Bool("ratelimit-per-client", false, "Apply the rate limits to each client address separately, instead of to all clients together.")
+	flag.Float64("ratelimit-global", 0, "Number of requests per second allowed on all the REST routes together. (default: unlimited)")
+	flag.Float64("ratelimit-client", 0, "Number of requests per second allowed from each client address on all the REST routes together. (default: unlimited)")
+	flag.Int("max-concurrent-txns", 0, "Maximum number of mutating requests served at once by this node. (default: unlimited)")
+	flag.Int("max-queued-txns", 0, "Number of mutating requests allowed to wait beyond max-concurrent-txns, instead of being rejected.")
+	flag.Duration("txn-queue-timeout", 30*time.Second, "Maximum time a mutating request waits to be served, after which it is rejected.")
+	flag.Duration("operation-result-ttl", 10*time.Minute, "Time the results of asynchronous requests are kept for after they complete.")
+	flag.String("ssl-cert-dir", "/etc/ssl", "Directory having the SSL certificate, key and CA files used for encryption.")
+	flag.String("ganesha-config-dir", "/etc/ganesha", "Configuration directory of NFS-Ganesha, where volume exports are written.")
+	flag.Duration("brick-start-timeout", 30*time.Second, "Maximum time a brick being started is waited on to serve, after which the start fails. (0 doesn't wait)")
+	flag.Int("barrier-timeout", 120, "Maximum time in seconds a volume barrier is held, after which bricks release it even if it isn't disabled.")
+	flag.Int("max-bricks", 0, "Maximum number of bricks allowed in a single request. (default: unlimited)")
+
+	flag.Duration("txn-reaper-ttl", 5*time.Minute, "Time after which a transaction whose initiator is down is considered stale and cleaned up.")
+	flag.Duration("txn-reaper-interval", time.Minute, "Interval between scans for stale transactions.")
+
+	flag.Duration("peer-liveness-interval", 5*time.Second, "Interval between checks of the liveness of peers.")
+	flag.Int("peer-offline-threshold", 3, "Number of consecutive failed liveness checks before a peer is marked offline, and successful ones before it is marked online again.")
+	flag.Duration("peer-offline-grace", 10*time.Second, "Minimum time a peer must be seen down before it is marked offline, or up before it is marked online again.")
+
+	flag.Duration("utilization-interval", time.Minute, "Interval between samples of the space utilization of local bricks.")
+	flag.String("utilization-thresholds", "80,90", "Comma separated brick utilization percentages, crossing which raises an event.")
+	flag.String("inode-utilization-thresholds", "80,90", "Comma separated brick inode utilization percentages, crossing which raises an event.")
+	flag.String("utilization-webhook", "", "URL to which brick utilization events are POSTed.")
+
+	flag.Duration("metrics-interval", time.Minute, "Interval between samples of the volume and brick metrics of local bricks.")
+	flag.Int("brick-stats-interval", 0, "Interval in seconds between dumps of the I/O statistics of the bricks, read for volume stats. (default: statistics disabled)")
+	flag.String("brick-stats-dir", "", "Directory the bricks dump their I/O statistics in. (default: /var/run/gluster)")
+	flag.Bool("metrics-utilization", false, "Export the space and inode utilization of each volume, and whether it is over a threshold, sampled every utilization-interval.")
+	flag.Bool("metrics-per-brick", false, "Export volume metrics for each brick too, which adds a series per brick of the cluster.")
+
+	flag.Duration("peer-rpc-pool-max-idle", time.Minute, "Time after which idle connections to peers are closed.")
+	flag.Duration("peer-rpc-pool-max-lifetime", 10*time.Minute, "Maximum time a connection to a peer is reused for.")
 
 	store.InitFlags()
 
@@ -136,6 +214,96 @@ func initConfig(confFile string) error {
 	// Use config given by flags
 	config.BindPFlags(flag.CommandLine)
 
+	// The local IP depends on the preferred address family, so it can be
+	// found only after the config has been read
+	switch utils.AddressFamily() {
+	case utils.AddressFamilyAuto, utils.AddressFamilyIPv4, utils.AddressFamilyIPv6:
+	default:
+		return errors.New("invalid address family specified")
+	}
+	for _, l := range []string{"log-max-size", "log-max-backups"} {
+		if config.GetInt(l) < 0 {
+			return fmt.Errorf("invalid %s specified", l)
+		}
+	}
+
+	for _, l := range []string{"brick-start-timeout", "store-retry-backoff",
+		"rest-read-header-timeout", "rest-read-timeout", "rest-write-timeout", "rest-idle-timeout",
+		"max-request-timeout"} {
+		if config.GetDuration(l) < 0 {
+			return fmt.Errorf("invalid %s specified", l)
+		}
+	}
+	for _, l := range []string{"peer-probe-timeout", "operation-result-ttl", "rest-auth-token-max-ttl"} {
+		if config.GetDuration(l) <= 0 {
+			return fmt.Errorf("invalid %s specified", l)
+		}
+	}
+
+	if config.GetBool("rest-socket-only") && config.GetString("rest-socket") == "" {
+		return errors.New("rest-socket-only requires a rest-socket")
+	}
+	if err := rest.ValidateSocketUsers(); err != nil {
+		return err
+	}
+	if (config.GetString("rest-cert-file") == "") != (config.GetString("rest-key-file") == "") {
+		return errors.New("rest-cert-file and rest-key-file must be given together")
+	}
+	if config.GetString("rest-ca-file") != "" && config.GetString("rest-cert-file") == "" {
+		return errors.New("rest-ca-file requires a rest-cert-file")
+	}
+	if _, err := rest.ParseTLSVersion(config.GetString("rest-tls-min-version")); err != nil {
+		return err
+	}
+	if err := rest.ValidateTLSConfig(); err != nil {
+		return fmt.Errorf("invalid REST TLS configuration: %s", err.Error())
+	}
+	switch config.GetString("peer-identifier") {
+	case peer.IdentifierUUID, peer.IdentifierHostname:
+	default:
+		return errors.New("invalid peer identifier specified")
+	}
+	switch config.GetString("brick-readonly-policy") {
+	case volume.ReadOnlyBrickFail, volume.ReadOnlyBrickStartDegraded:
+	default:
+		return errors.New("invalid brick read-only policy specified")
+	}
+	switch config.GetString("leader-forwarding") {
+	case "redirect", "proxy":
+	default:
+		return errors.New("invalid leader forwarding specified")
+	}
+
+	for _, l := range []string{"ratelimit-read", "ratelimit-write", "ratelimit-global", "ratelimit-client"} {
+		if config.GetFloat64(l) < 0 {
+			return fmt.Errorf("invalid %s specified", l)
+		}
+	}
+	for _, l := range []string{"max-concurrent-txns", "max-queued-txns", "brick-stats-interval", "store-retries"} {
+		if config.GetInt(l) < 0 {
+			return fmt.Errorf("invalid %s specified", l)
+		}
+	}
+
+	for _, l := range []string{"brick-memory-limit", "brick-cpu-limit", "brick-open-files-limit"} {
+		if config.GetInt(l) < 0 {
+			return fmt.Errorf("invalid %s specified", l)
+		}
+	}
+	if err := brick.DefaultLimits().Validate(); err != nil {
+		return err
+	}
+	for _, l := range []string{"utilization-thresholds", "inode-utilization-thresholds"} {
+		if _, err := volume.ParseThresholds(config.GetString(l)); err != nil {
+			return err
+		}
+	}
+
+	if err := gdctx.SetHostnameAndIP(); err != nil {
+		log.WithError(err).Error("failed to get and set hostname or IP")
+		return err
+	}
+
 	// Finally initialize missing config with defaults
 	if err := setDefaults(); err != nil {
 		return err
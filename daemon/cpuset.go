@@ -0,0 +1,77 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// onlineCPUsFile lists the CPUs of the node which are online
+var onlineCPUsFile = "/sys/devices/system/cpu/online"
+
+// maxCPUs bounds the CPUs of a cpuset, so that a large range doesn't make
+// parsing it allocate without limit. It is the most CPUs a Linux kernel is
+// built to support.
+const maxCPUs = 8192
+
+// ParseCPUSet parses a CPU list in the format used by cpusets and taskset,
+// like "0-3,8,10-11", and returns the CPUs in it. CPUs from maxCPUs on are
+// rejected.
+func ParseCPUSet(s string) (map[int]bool, error) {
+	cpus := make(map[int]bool)
+	for _, r := range strings.Split(strings.TrimSpace(s), ",") {
+		bounds := strings.SplitN(r, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil || first < 0 || first >= maxCPUs {
+			return nil, fmt.Errorf("invalid cpuset %q", s)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil || last < first || last >= maxCPUs {
+				return nil, fmt.Errorf("invalid cpuset %q", s)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus[cpu] = true
+		}
+	}
+	return cpus, nil
+}
+
+// onlineCPUs returns the CPUs of the node which are online
+func onlineCPUs() map[int]bool {
+	if b, err := ioutil.ReadFile(onlineCPUsFile); err == nil {
+		if cpus, err := ParseCPUSet(string(b)); err == nil {
+			return cpus
+		}
+	}
+
+	cpus := make(map[int]bool)
+	for cpu := 0; cpu < runtime.NumCPU(); cpu++ {
+		cpus[cpu] = true
+	}
+	return cpus
+}
+
+// CheckCPUSet returns an error if the cpuset of the limits has CPUs which
+// aren't online on this node. Nodes differ in their CPUs, so this is checked
+// when the daemon is started rather than in Validate.
+func (l Limits) CheckCPUSet() error {
+	if l.CPUSet == "" {
+		return nil
+	}
+
+	cpus, err := ParseCPUSet(l.CPUSet)
+	if err != nil {
+		return err
+	}
+	online := onlineCPUs()
+	for cpu := range cpus {
+		if !online[cpu] {
+			return fmt.Errorf("CPU %d of cpuset %q is not available on this node", cpu, l.CPUSet)
+		}
+	}
+	return nil
+}
@@ -105,7 +105,7 @@ func Start(d Daemon, wait bool) error {
 			"pid":  pid,
 		}).Debug("Started daemon successfully")
 
-		return nil
+		return setLimits(d, pid)
 	}
 
 	// If the process exits at some point later, do read it's
@@ -119,6 +119,31 @@ func Start(d Daemon, wait bool) error {
 		}).Debug("Child exited.")
 	}()
 
+	return setLimits(d, cmd.Process.Pid)
+}
+
+// setLimits applies the resource limits of the daemon, if it has any, to its
+// process. The limits can only be applied once the process is running, so a
+// process whose limits couldn't be applied is killed rather than left running
+// unlimited.
+func setLimits(d Daemon, pid int) error {
+	ld, ok := d.(LimitedDaemon)
+	if !ok {
+		return nil
+	}
+
+	if err := applyLimits(d, pid, ld.Limits()); err != nil {
+		log.WithFields(log.Fields{
+			"name":  d.Name(),
+			"pid":   pid,
+			"error": err.Error(),
+		}).Error("Could not apply resource limits, killing the daemon")
+		if process, perr := GetProcess(pid); perr == nil {
+			process.Kill()
+		}
+		_ = os.Remove(d.PidFile())
+		return err
+	}
 	return nil
 }
 
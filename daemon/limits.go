@@ -0,0 +1,169 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	minMemoryMB  = 64
+	minOpenFiles = 64
+
+	// cpuPeriod is the period in microseconds over which the CPU usage of
+	// a daemon is limited
+	cpuPeriod = 100000
+)
+
+var (
+	// cgroupRoot is the cgroup (v2) under which daemons with memory or CPU
+	// limits are placed. It must be on the unified hierarchy, and be
+	// delegated to glusterd2 with the memory, cpu and cpuset controllers
+	// enabled in its parent, like systemd does with Delegate=yes.
+	cgroupRoot = "/sys/fs/cgroup/glusterd2"
+)
+
+// Limits are the resource limits of a daemon process. A limit which is 0 is
+// not applied.
+type Limits struct {
+	// MemoryMB is the maximum memory the process can use, in MiB
+	MemoryMB uint64 `json:"memory-mb,omitempty"`
+	// CPUPercent is the maximum CPU the process can use, as a percentage
+	// of one CPU
+	CPUPercent uint64 `json:"cpu-percent,omitempty"`
+	// OpenFiles is the maximum number of files the process can have open
+	OpenFiles uint64 `json:"open-files,omitempty"`
+	// CPUSet is the list of CPUs the process is bound to, like "0-3,8"
+	CPUSet string `json:"cpuset,omitempty"`
+}
+
+// LimitedDaemon is a Daemon which has resource limits applied to it when it
+// is started
+type LimitedDaemon interface {
+	Daemon
+
+	// Limits should return the resource limits of the daemon
+	Limits() Limits
+}
+
+// WithDefaults returns the limits with the unset ones taken from defaults
+func (l Limits) WithDefaults(defaults Limits) Limits {
+	if l.MemoryMB == 0 {
+		l.MemoryMB = defaults.MemoryMB
+	}
+	if l.CPUPercent == 0 {
+		l.CPUPercent = defaults.CPUPercent
+	}
+	if l.OpenFiles == 0 {
+		l.OpenFiles = defaults.OpenFiles
+	}
+	if l.CPUSet == "" {
+		l.CPUSet = defaults.CPUSet
+	}
+	return l
+}
+
+// Validate returns an error if any of the limits are too low or too high to
+// be sensible
+func (l Limits) Validate() error {
+	if l.MemoryMB != 0 && l.MemoryMB < minMemoryMB {
+		return fmt.Errorf("memory limit must be at least %d MiB", minMemoryMB)
+	}
+	if maxCPU := uint64(100 * runtime.NumCPU()); l.CPUPercent > maxCPU {
+		return fmt.Errorf("CPU limit must be at most %d percent", maxCPU)
+	}
+	if l.OpenFiles != 0 && l.OpenFiles < minOpenFiles {
+		return fmt.Errorf("open files limit must be at least %d", minOpenFiles)
+	}
+	if l.CPUSet != "" {
+		if _, err := ParseCPUSet(l.CPUSet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cgroupName returns the name of the cgroup of the daemon
+func cgroupName(d Daemon) string {
+	return d.Name() + "-" + strings.Trim(strings.Replace(d.ID(), "/", "-", -1), "-")
+}
+
+func writeCgroupFile(dir string, file string, value string) error {
+	return ioutil.WriteFile(path.Join(dir, file), []byte(value), 0644)
+}
+
+// prlimit sets the resource limit of another process
+func prlimit(pid int, resource int, rlim *unix.Rlimit) error {
+	_, _, errno := unix.RawSyscall6(unix.SYS_PRLIMIT64, uintptr(pid), uintptr(resource),
+		uintptr(unsafe.Pointer(rlim)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// applyLimits applies the limits to the running process of the daemon. The
+// open files limit is set on the process, memory, CPU and the cpuset are
+// limited by placing the process in a cgroup of its own.
+func applyLimits(d Daemon, pid int, l Limits) error {
+
+	if l.OpenFiles != 0 {
+		rlim := &unix.Rlimit{Cur: l.OpenFiles, Max: l.OpenFiles}
+		if err := prlimit(pid, unix.RLIMIT_NOFILE, rlim); err != nil {
+			return err
+		}
+	}
+
+	if l.MemoryMB == 0 && l.CPUPercent == 0 && l.CPUSet == "" {
+		return nil
+	}
+	if err := l.CheckCPUSet(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(cgroupRoot, os.ModeDir|os.ModePerm); err != nil {
+		return err
+	}
+	// Enable the controllers for the cgroups of the daemons
+	controllers := "+memory +cpu"
+	if l.CPUSet != "" {
+		controllers += " +cpuset"
+	}
+	if err := writeCgroupFile(cgroupRoot, "cgroup.subtree_control", controllers); err != nil {
+		return errors.New("failed to enable cgroup controllers: " + err.Error())
+	}
+
+	dir := path.Join(cgroupRoot, cgroupName(d))
+	if err := os.MkdirAll(dir, os.ModeDir|os.ModePerm); err != nil {
+		return err
+	}
+
+	memory, cpu := "max", "max"
+	if l.MemoryMB != 0 {
+		memory = strconv.FormatUint(l.MemoryMB*1024*1024, 10)
+	}
+	if l.CPUPercent != 0 {
+		cpu = strconv.FormatUint(l.CPUPercent*cpuPeriod/100, 10)
+	}
+	if err := writeCgroupFile(dir, "memory.max", memory); err != nil {
+		return err
+	}
+	if err := writeCgroupFile(dir, "cpu.max", fmt.Sprintf("%s %d", cpu, cpuPeriod)); err != nil {
+		return err
+	}
+	if l.CPUSet != "" {
+		if err := writeCgroupFile(dir, "cpuset.cpus", l.CPUSet); err != nil {
+			return err
+		}
+	}
+
+	return writeCgroupFile(dir, "cgroup.procs", strconv.Itoa(pid))
+}
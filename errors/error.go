@@ -22,6 +22,7 @@ var (
 	ErrBrickUnderRootPartition = errors.New("Brick path is under root partition")
 	ErrBrickNotDirectory       = errors.New("Brick path is not a directory")
 	ErrBrickPathAlreadyInUse   = errors.New("Brick path is already in use by other gluster volume")
+	ErrBrickFilesystemReadOnly = errors.New("Brick filesystem is read-only")
 	ErrNoHostnamesPresent      = errors.New("no hostnames present")
 	ErrBrickPathConvertFail    = errors.New("Failed to convert the brickpath to absolute path")
 	ErrBrickNotLocal           = errors.New("Brickpath doesn't belong to localhost")
@@ -0,0 +1,79 @@
+// Package events implements a simple event bus for GlusterD, on which
+// notable cluster events are broadcast to interested listeners
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gluster/glusterd2/gdctx"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/pborman/uuid"
+)
+
+// Event represents a notable event that occurred in GlusterD
+type Event struct {
+	ID        uuid.UUID         `json:"id"`
+	Name      string            `json:"name"`
+	Data      map[string]string `json:"data,omitempty"`
+	Origin    uuid.UUID         `json:"origin"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// Handler is a function which gets called for every event broadcast
+type Handler func(*Event)
+
+var (
+	handlers     = make(map[string]Handler)
+	handlersLock sync.RWMutex
+)
+
+// New returns a new event with the given name and data, originating from
+// this node
+func New(name string, data map[string]string) *Event {
+	return &Event{
+		ID:        uuid.NewRandom(),
+		Name:      name,
+		Data:      data,
+		Origin:    gdctx.MyUUID,
+		Timestamp: time.Now(),
+	}
+}
+
+// Register registers a handler to be called for every event broadcast, and
+// returns an ID which can be used to unregister it
+func Register(h Handler) string {
+	handlersLock.Lock()
+	defer handlersLock.Unlock()
+
+	id := uuid.New()
+	handlers[id] = h
+	return id
+}
+
+// Unregister unregisters the handler with the given ID
+func Unregister(id string) {
+	handlersLock.Lock()
+	defer handlersLock.Unlock()
+
+	delete(handlers, id)
+}
+
+// Broadcast logs the given event and passes it to all registered handlers.
+// Handlers are called asynchronously, so a slow handler doesn't block the
+// code raising the event.
+func Broadcast(e *Event) {
+	log.WithFields(log.Fields{
+		"event": e.Name,
+		"id":    e.ID.String(),
+		"data":  e.Data,
+	}).Info("event")
+
+	handlersLock.RLock()
+	defer handlersLock.RUnlock()
+
+	for _, h := range handlers {
+		go h(e)
+	}
+}
@@ -0,0 +1,51 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const webhookTimeout = 10 * time.Second
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// NewWebhookHandler returns a Handler which POSTs the events with the given
+// names, or all events if no names are given, as JSON to the URL
+func NewWebhookHandler(url string, names ...string) Handler {
+	return func(e *Event) {
+		if len(names) > 0 && !contains(names, e.Name) {
+			return
+		}
+
+		b, err := json.Marshal(e)
+		if err != nil {
+			return
+		}
+
+		resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(b))
+		if err != nil {
+			log.WithError(err).WithField("url", url).Warn("failed to deliver event to webhook")
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.WithFields(log.Fields{
+				"url":    url,
+				"status": resp.StatusCode,
+			}).Warn("webhook refused event")
+		}
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
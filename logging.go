@@ -1,12 +1,16 @@
 package main
 
 import (
+	"fmt"
 	"io"
 	stdlog "log"
 	"os"
 	"path"
+	"runtime"
 	"strings"
 
+	"github.com/gluster/glusterd2/pkg/logrotate"
+
 	log "github.com/Sirupsen/logrus"
 )
 
@@ -25,7 +29,67 @@ func setLogOutput(w io.Writer) {
 	stdlog.SetOutput(log.StandardLogger().Writer())
 }
 
-func initLog(logdir string, logFileName string, logLevel string) error {
+// callerFormatter wraps a log formatter to add the file:line of the code
+// emitting a log entry to the entry. The entry data is copied instead of
+// being modified in place, as the same entry could be logged concurrently.
+type callerFormatter struct {
+	log.Formatter
+}
+
+func (f callerFormatter) Format(entry *log.Entry) ([]byte, error) {
+	data := make(log.Fields, len(entry.Data)+1)
+	for k, v := range entry.Data {
+		data[k] = v
+	}
+
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		// Skip the frames of logrus itself
+		if !strings.Contains(frame.Function, "Sirupsen/logrus") {
+			data["caller"] = fmt.Sprintf("%s:%d", path.Base(frame.File), frame.Line)
+			break
+		}
+		if !more {
+			break
+		}
+	}
+
+	e := *entry
+	e.Data = data
+	return f.Formatter.Format(&e)
+}
+
+// initLogFormat sets the format of the log output, either "text" or "json",
+// and optionally adds the caller file:line to every log entry. This needs
+// to be called before any other log lines are emitted, so that all of them are
+// formatted consistently.
+func initLogFormat(logFormat string, logCaller bool) error {
+	var formatter log.Formatter
+	var err error
+	switch strings.ToLower(logFormat) {
+	case "text":
+		formatter = &log.TextFormatter{FullTimestamp: true}
+	case "json":
+		formatter = &log.JSONFormatter{}
+	default:
+		formatter = &log.TextFormatter{FullTimestamp: true}
+		err = fmt.Errorf("invalid log format %s, must be one of text or json", logFormat)
+	}
+
+	if logCaller {
+		formatter = callerFormatter{formatter}
+	}
+	log.SetFormatter(formatter)
+
+	return err
+}
+
+// initLog sets up logging to the given log file, which is rotated as given by
+// rotation
+func initLog(logdir string, logFileName string, logLevel string, rotation logrotate.Config) error {
 	// Close the previously opened Log file
 	if logWriter != nil {
 		logWriter.Close()
@@ -39,7 +103,6 @@ func initLog(logdir string, logFileName string, logLevel string) error {
 		return err
 	}
 	log.SetLevel(l)
-	log.SetFormatter(&log.TextFormatter{FullTimestamp: true})
 
 	if strings.ToLower(logFileName) == "stderr" || logFileName == "-" {
 		setLogOutput(os.Stderr)
@@ -47,7 +110,12 @@ func initLog(logdir string, logFileName string, logLevel string) error {
 		setLogOutput(os.Stdout)
 	} else {
 		logFilePath := path.Join(logdir, logFileName)
-		logFile, err := openLogFile(logFilePath)
+		var logFile io.WriteCloser
+		if rotation.Enabled() {
+			logFile, err = logrotate.NewWriter(logFilePath, rotation)
+		} else {
+			logFile, err = openLogFile(logFilePath)
+		}
 		if err != nil {
 			setLogOutput(os.Stderr)
 			log.WithError(err).Debug("Failed to open log file %s", logFilePath)
@@ -7,11 +7,16 @@ import (
 	"strings"
 
 	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/middleware"
 	"github.com/gluster/glusterd2/peer"
+	"github.com/gluster/glusterd2/pkg/logrotate"
 	"github.com/gluster/glusterd2/servers"
+	"github.com/gluster/glusterd2/servers/rest"
 	"github.com/gluster/glusterd2/store"
+	"github.com/gluster/glusterd2/transaction"
 	"github.com/gluster/glusterd2/utils"
 	"github.com/gluster/glusterd2/version"
+	"github.com/gluster/glusterd2/volume"
 	"github.com/gluster/glusterd2/xlator"
 
 	log "github.com/Sirupsen/logrus"
@@ -23,10 +28,6 @@ import (
 
 func main() {
 
-	if err := gdctx.SetHostnameAndIP(); err != nil {
-		log.WithError(err).Fatal("Failed to get and set hostname or IP")
-	}
-
 	// Parse command-line arguments
 	parseFlags()
 
@@ -35,11 +36,25 @@ func main() {
 		return
 	}
 
+	logFormat, _ := flag.CommandLine.GetString("logformat")
+	logCaller, _ := flag.CommandLine.GetBool("logcaller")
+	if err := initLogFormat(logFormat, logCaller); err != nil {
+		log.WithError(err).Fatal("Failed to initialize logging")
+	}
+
 	logLevel, _ := flag.CommandLine.GetString("loglevel")
 	logdir, _ := flag.CommandLine.GetString("logdir")
 	logFileName, _ := flag.CommandLine.GetString("logfile")
+	logMaxSize, _ := flag.CommandLine.GetInt("log-max-size")
+	logMaxAge, _ := flag.CommandLine.GetDuration("log-max-age")
+	logMaxBackups, _ := flag.CommandLine.GetInt("log-max-backups")
+	rotation := logrotate.Config{
+		MaxSize:    int64(logMaxSize) << 20,
+		MaxAge:     logMaxAge,
+		MaxBackups: logMaxBackups,
+	}
 
-	if err := initLog(logdir, logFileName, logLevel); err != nil {
+	if err := initLog(logdir, logFileName, logLevel, rotation); err != nil {
 		log.WithError(err).Fatal("Failed to initialize logging")
 	}
 
@@ -84,10 +99,31 @@ func main() {
 		log.WithError(err).Fatal("Could not add self details into etcd")
 	}
 
+	if err := middleware.InitAuth(); err != nil {
+		log.WithError(err).Fatal("Failed to initialize REST API authentication")
+	}
+
+	// Preload the peer and volume lists, so that the first requests after
+	// a restart don't have to wait on the store
+	if err := peer.InitCache(); err != nil {
+		log.WithError(err).Warn("Failed to preload peers from store")
+	}
+	if err := volume.InitCache(); err != nil {
+		log.WithError(err).Warn("Failed to preload volumes from store")
+	}
+	if err := volume.RebuildBrickIndex(); err != nil {
+		log.WithError(err).Warn("Failed to rebuild the brick index")
+	}
+
 	// Start all servers (rest, peerrpc, sunrpc) managed by suture supervisor
 	super := initGD2Supervisor()
 	super.ServeBackground()
 	super.Add(servers.New())
+	super.Add(transaction.NewReaper())
+	super.Add(peer.NewLivenessTracker())
+	super.Add(volume.NewUtilizationWatcher())
+	super.Add(volume.NewBrickLogRotator())
+	super.Add(volume.NewMetricsCollector())
 	addMgmtService(super)
 
 	// Use the main goroutine as signal handling loop
@@ -109,10 +145,14 @@ func main() {
 			// re-initiate the logger instance.
 			if strings.ToLower(logFileName) != "stderr" && strings.ToLower(logFileName) != "stdout" && logFileName != "-" {
 				log.Info("Received SIGHUP, Reloading log file")
-				if err := initLog(logdir, logFileName, logLevel); err != nil {
+				if err := initLog(logdir, logFileName, logLevel, rotation); err != nil {
 					log.WithError(err).Fatal("Could not re-initialize logging")
 				}
 			}
+			// Renewed certificates of the ReST server are picked up too
+			if err := rest.ReloadTLS(); err != nil {
+				log.WithError(err).Error("Could not reload the ReST server TLS certificates, continuing with the current ones")
+			}
 		default:
 			continue
 		}
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gluster/glusterd2/operations"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/pborman/uuid"
+)
+
+// operationsPath is where the operations started by asynchronous requests
+// are polled
+const operationsPath = "/v1/operations/"
+
+// wantsAsync returns true if the request asks to be served asynchronously,
+// with the async query parameter or the respond-async preference of RFC 7240
+func wantsAsync(r *http.Request) bool {
+	if v := r.URL.Query().Get("async"); v != "" {
+		async, _ := strconv.ParseBool(v)
+		return async
+	}
+	for _, pref := range strings.Split(r.Header.Get("Prefer"), ",") {
+		if strings.EqualFold(strings.TrimSpace(pref), "respond-async") {
+			return true
+		}
+	}
+	return false
+}
+
+// responseRecorder keeps the response of a request, which is served in the
+// background or whose response is sent only once it is complete
+type responseRecorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *responseRecorder) WriteHeader(code int) {
+	if rec.code == 0 {
+		rec.code = code
+	}
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	return rec.body.Write(b)
+}
+
+// Async returns a middleware which serves mutating requests asking for it in
+// the background. Such requests are sent a 202 response with the operation
+// serving them, whose result is polled at /v1/operations/{id}. Other requests
+// are served as usual.
+func Async(method string) func(http.Handler) http.Handler {
+	if isReadRequest(&http.Request{Method: method}) {
+		return noLimit
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !wantsAsync(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			reqID, logger := restutils.GetReqIDandLogger(r)
+
+			// The request outlives the connection it came on, so its
+			// body is read now and it isn't cancelled with it
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			op, err := operations.New(reqID, r.Method, r.URL.Path)
+			if err != nil {
+				logger.WithError(err).Error("failed to register operation")
+				restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			// The operation is sent as it is now, before the request
+			// starts updating it
+			opJSON, err := json.Marshal(op)
+			if err != nil {
+				restutils.SendHTTPError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			bg := r.WithContext(context.Background())
+			bg.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			// The transactions of the request report their progress
+			// to the operation. Request IDs are set by clients, so
+			// the request is served under one generated here, which
+			// no other request can have.
+			bgReqID := uuid.NewRandom().String()
+			bg.Header = cloneHeader(r.Header)
+			bg.Header.Set(restutils.RequestIDHeader, bgReqID)
+			transaction.SetRequestProgressReporter(bgReqID, op)
+			go func() {
+				defer transaction.ClearRequestProgressReporter(bgReqID)
+				rec := &responseRecorder{header: make(http.Header)}
+				next.ServeHTTP(rec, bg)
+				if rec.code == 0 {
+					rec.code = http.StatusOK
+				}
+				if err := op.Complete(rec.code, rec.body.Bytes()); err != nil {
+					logger.WithError(err).WithField("operation", op.ID).Error("failed to record result of operation")
+				}
+			}()
+
+			logger.WithFields(log.Fields{
+				"operation": op.ID,
+				"served-as": bgReqID,
+				"method":    r.Method,
+				"path":      r.URL.Path,
+			}).Info("serving request asynchronously")
+			w.Header().Set("Location", operationsPath+op.ID)
+			restutils.SendHTTPResponse(w, http.StatusAccepted, json.RawMessage(opJSON))
+		})
+	}
+}
+
+func cloneHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
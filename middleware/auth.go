@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gluster/glusterd2/servers/rest/route"
+	"github.com/gluster/glusterd2/store"
+
+	"github.com/coreos/etcd/clientv3"
+	jwt "github.com/dgrijalva/jwt-go"
+	config "github.com/spf13/viper"
+)
+
+const (
+	// authTokenPath is the route issuing tokens, which clients can't have
+	// a token for yet
+	authTokenPath = "/v1/auth/token"
+
+	authSecretKey  = store.GlusterPrefix + "rest-auth-secret"
+	authSecretFile = "auth-secret"
+	authSecretSize = 32
+	authIssuer     = "glusterd2"
+)
+
+var (
+	// authSecret signs the tokens. It is shared by the cluster, so that
+	// a token issued by any node is accepted by all of them.
+	authSecret []byte
+
+	// ErrInvalidAuthSecret is returned when a token is requested with a
+	// secret other than the one of the cluster
+	ErrInvalidAuthSecret = errors.New("invalid secret")
+)
+
+func authEnabled() bool {
+	return config.GetBool("rest-auth")
+}
+
+// InitAuth loads the secret the REST API tokens are signed with, which is
+// generated by the first node of the cluster to start with rest-auth. The
+// secret is written to the auth-secret file in localstatedir, readable only
+// by the user glusterd2 runs as, for administrators to request tokens with.
+func InitAuth() error {
+	if !authEnabled() {
+		return nil
+	}
+
+	buf := make([]byte, authSecretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	secret := hex.EncodeToString(buf)
+
+	// Only the first node to get here stores its secret, the others get it
+	resp, err := store.Store.Txn(context.TODO()).
+		If(clientv3.Compare(clientv3.CreateRevision(authSecretKey), "=", 0)).
+		Then(clientv3.OpPut(authSecretKey, secret)).
+		Else(clientv3.OpGet(authSecretKey)).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		kvs := resp.Responses[0].GetResponseRange().Kvs
+		if len(kvs) != 1 {
+			return errors.New("failed to get the REST auth secret")
+		}
+		secret = string(kvs[0].Value)
+	}
+
+	file := path.Join(config.GetString("localstatedir"), authSecretFile)
+	if err := ioutil.WriteFile(file, []byte(secret+"\n"), 0600); err != nil {
+		return err
+	}
+	authSecret = []byte(secret)
+	return nil
+}
+
+// IssueToken returns a token for the user valid for the given duration,
+// capped at rest-auth-token-max-ttl, if the secret given is that of the
+// cluster. The user must have a role, and a token without a user is an admin
+// token.
+func IssueToken(secret, user string, ttl time.Duration) (string, time.Time, error) {
+	if !authEnabled() {
+		return "", time.Time{}, errors.New("REST API authentication is not enabled")
+	}
+	if subtle.ConstantTimeCompare([]byte(secret), authSecret) != 1 {
+		return "", time.Time{}, ErrInvalidAuthSecret
+	}
+	if _, err := userRole(user); err != nil {
+		return "", time.Time{}, err
+	}
+	if max := config.GetDuration("rest-auth-token-max-ttl"); ttl <= 0 || ttl > max {
+		ttl = max
+	}
+
+	now := time.Now()
+	expires := now.Add(ttl)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		Issuer:    authIssuer,
+		Subject:   user,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expires.Unix(),
+	}).SignedString(authSecret)
+	return token, expires, err
+}
+
+// verifyToken checks that the token is signed with the secret of the cluster
+// and hasn't expired, and returns the user it was issued to
+func verifyToken(token string) (string, error) {
+	claims := new(jwt.StandardClaims)
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Header["alg"])
+		}
+		return authSecret, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	// Tokens without an expiry would be valid forever
+	if claims.ExpiresAt == 0 || claims.Issuer != authIssuer {
+		return "", errors.New("token was not issued by glusterd2")
+	}
+	return claims.Subject, nil
+}
+
+// Authenticate returns a middleware which requires requests on a route to
+// carry a token, as an Authorization: Bearer header, of a user having the role
+// the route requires, if rest-auth is set. Read requests which only need a
+// viewer are allowed without a token.
+func Authenticate(method, path, role string) func(http.Handler) http.Handler {
+	if !authEnabled() || path == authTokenPath {
+		return noLimit
+	}
+	role = routeRole(method, role)
+	anonymous := isReadRequest(&http.Request{Method: method}) && role == route.RoleViewer
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" && anonymous {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !strings.HasPrefix(header, "Bearer ") {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="glusterd2"`)
+				http.Error(w, "authorization token required", http.StatusUnauthorized)
+				return
+			}
+			user, err := verifyToken(strings.TrimPrefix(header, "Bearer "))
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="glusterd2", error="invalid_token"`)
+				http.Error(w, "invalid authorization token: "+err.Error(), http.StatusUnauthorized)
+				return
+			}
+			have, err := userRole(user)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			if !hasRole(have, role) {
+				http.Error(w, fmt.Sprintf("token has the %s role, the request needs the %s role", have, role), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// socketClientKey marks the context of requests of REST socket clients
+type socketClientKey struct{}
+
+// SocketClient is a middleware marking the requests of the clients of the REST
+// Unix socket, whose credentials were checked when they connected. It must
+// only be used on the handler of the socket.
+func SocketClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), socketClientKey{}, true)))
+	})
+}
+
+func isSocketClient(r *http.Request) bool {
+	trusted, _ := r.Context().Value(socketClientKey{}).(bool)
+	return trusted
+}
+
+// forwardedTokenTTL is how long the tokens of requests proxied on behalf of
+// socket clients are valid for
+const forwardedTokenTTL = time.Minute
+
+// forwardAuthorization returns the Authorization header a request forwarded
+// to the leader is sent with, after checking that the request is
+// authenticated. Socket clients have no token of their own, so their
+// requests are given a short lived admin token. The leader still checks the
+// role the route needs.
+func forwardAuthorization(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if !authEnabled() || r.URL.Path == authTokenPath {
+		return header, nil
+	}
+
+	if isSocketClient(r) {
+		now := time.Now()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+			Issuer:    authIssuer,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(forwardedTokenTTL).Unix(),
+		}).SignedString(authSecret)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	}
+
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", errors.New("authorization token required")
+	}
+	if _, err := verifyToken(strings.TrimPrefix(header, "Bearer ")); err != nil {
+		return "", errors.New("invalid authorization token: " + err.Error())
+	}
+	return header, nil
+}
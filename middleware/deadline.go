@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/transaction"
+
+	"github.com/pborman/uuid"
+	config "github.com/spf13/viper"
+)
+
+// RequestTimeoutHeader is the request header bounding how long the request
+// may take, in seconds or as a duration like 90s
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// parseRequestTimeout parses the value of the request timeout header. The
+// timeout is capped to max-request-timeout.
+func parseRequestTimeout(v string) (time.Duration, error) {
+	timeout, err := time.ParseDuration(v)
+	if err != nil {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q, must be a number of seconds or a duration", RequestTimeoutHeader, v)
+		}
+		timeout = time.Duration(secs) * time.Second
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("invalid %s %q, must be positive", RequestTimeoutHeader, v)
+	}
+	if max := config.GetDuration("max-request-timeout"); max > 0 && timeout > max {
+		timeout = max
+	}
+	return timeout, nil
+}
+
+// deadlineResponseWriter replaces the server error response of a request
+// which failed on its deadline with a 504 response
+type deadlineResponseWriter struct {
+	http.ResponseWriter
+	ctx      context.Context
+	timeout  time.Duration
+	replaced bool
+}
+
+func (w *deadlineResponseWriter) WriteHeader(code int) {
+	if code >= http.StatusInternalServerError && w.ctx.Err() == context.DeadlineExceeded {
+		w.replaced = true
+		restutils.SendHTTPError(w.ResponseWriter, http.StatusGatewayTimeout,
+			fmt.Sprintf("request did not complete within its %s deadline, its changes were rolled back", w.timeout))
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *deadlineResponseWriter) Write(b []byte) (int, error) {
+	if w.replaced {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// RequestDeadline is a middleware which sets the deadline of requests having
+// the request timeout header. The transactions of the request are rolled
+// back once the deadline passes, and the request is sent a 504 response.
+// Requests served asynchronously don't keep the client waiting, so they
+// have no deadline.
+func RequestDeadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := r.Header.Get(RequestTimeoutHeader)
+		if v == "" || (wantsAsync(r) && !isReadRequest(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		timeout, err := parseRequestTimeout(v)
+		if err != nil {
+			restutils.SendHTTPError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		deadline, _ := ctx.Deadline()
+
+		// The transactions of the request look up its deadline by the
+		// request ID they are created with. Request IDs are set by
+		// clients, so the request is served under one generated here,
+		// which no other request can have.
+		_, logger := restutils.GetReqIDandLogger(r)
+		reqID := uuid.NewRandom().String()
+		logger.WithField("deadline-reqid", reqID).Debug("serving request with a deadline")
+		r = r.WithContext(ctx)
+		r.Header = cloneHeader(r.Header)
+		r.Header.Set(restutils.RequestIDHeader, reqID)
+		transaction.SetRequestDeadline(reqID, deadline)
+		defer transaction.ClearRequestDeadline(reqID)
+
+		next.ServeHTTP(&deadlineResponseWriter{ResponseWriter: w, ctx: ctx, timeout: timeout}, r)
+	})
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+)
+
+// etagMatches returns true if the If-None-Match header has the ETag, weakly
+// compared as RFC 7232 requires of If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// etagHeaders are the response headers which are part of the content of a
+// response, along with its body, like the pagination of lists
+var etagHeaders = []string{restutils.TotalCountHeader, restutils.ContinueHeader}
+
+// responseETag returns the ETag of a response, the hash of its body and of
+// its etagHeaders
+func responseETag(rec *responseRecorder) string {
+	h := sha256.New()
+	h.Write(rec.body.Bytes())
+	for _, k := range etagHeaders {
+		h.Write([]byte("\n" + k + ": " + rec.header.Get(k)))
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// ConditionalGet returns a middleware which tags the successful responses of
+// GET requests on a route with an ETag, the hash of their content, and sends
+// a 304 response without the content to requests whose If-None-Match header
+// has the tag. Clients polling a resource which hasn't changed then don't
+// receive it all again. Requests other than GET are served as usual.
+func ConditionalGet(method string) func(http.Handler) http.Handler {
+	if method != http.MethodGet {
+		return noLimit
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &responseRecorder{header: make(http.Header)}
+			next.ServeHTTP(rec, r)
+			if rec.code == 0 {
+				rec.code = http.StatusOK
+			}
+			for k, v := range rec.header {
+				w.Header()[k] = v
+			}
+			if rec.code != http.StatusOK {
+				w.WriteHeader(rec.code)
+				w.Write(rec.body.Bytes())
+				return
+			}
+
+			etag := responseETag(rec)
+			w.Header().Set("ETag", etag)
+			if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+				// A 304 response has no content
+				w.Header().Del("Content-Type")
+				w.Header().Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.WriteHeader(rec.code)
+			w.Write(rec.body.Bytes())
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/tests"
+)
+
+func getWithETag(h http.Handler, ifNoneMatch string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/v1/volumes", nil)
+	if ifNoneMatch != "" {
+		r.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// TestConditionalGet validates that responses are tagged with an ETag, and
+// that requests having it in If-None-Match are sent a 304 response
+func TestConditionalGet(t *testing.T) {
+	total := "2"
+	h := ConditionalGet("GET")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(restutils.TotalCountHeader, total)
+		w.Write([]byte(`[{"name":"vol1"},{"name":"vol2"}]`))
+	}))
+
+	w := getWithETag(h, "")
+	etag := w.Header().Get("ETag")
+	tests.Assert(t, w.Code == http.StatusOK && etag != "" && w.Body.Len() > 0)
+
+	for _, inm := range []string{etag, "W/" + etag, "*", `"other", ` + etag} {
+		w = getWithETag(h, inm)
+		tests.Assert(t, w.Code == http.StatusNotModified)
+		tests.Assert(t, w.Body.Len() == 0 && w.Header().Get("Content-Type") == "")
+		tests.Assert(t, w.Header().Get("ETag") == etag)
+	}
+
+	for _, inm := range []string{`"other"`, `"other", "another"`} {
+		w = getWithETag(h, inm)
+		tests.Assert(t, w.Code == http.StatusOK && w.Body.Len() > 0)
+	}
+
+	// The pagination headers are part of the content
+	total = "3"
+	w = getWithETag(h, etag)
+	tests.Assert(t, w.Code == http.StatusOK && w.Header().Get("ETag") != etag)
+
+	// Failed responses aren't tagged
+	h = ConditionalGet("GET")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	w = getWithETag(h, "*")
+	tests.Assert(t, w.Code == http.StatusNotFound && w.Header().Get("ETag") == "")
+}
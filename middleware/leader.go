@@ -0,0 +1,272 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	"github.com/gluster/glusterd2/store"
+	"github.com/gluster/glusterd2/utils"
+
+	log "github.com/Sirupsen/logrus"
+	config "github.com/spf13/viper"
+)
+
+const (
+	// LeaderAddressHeader is the response header having the REST address
+	// of the leader, when glusterd2 is running in read-only follower mode
+	LeaderAddressHeader = "X-Gluster-Leader"
+
+	// proxiedHeader marks requests proxied to the leader, so that they
+	// aren't proxied again if the leader has changed meanwhile
+	proxiedHeader = "X-Gluster-Proxied"
+
+	// forwardedForHeader has the address of the client of a request
+	// proxied to the leader
+	forwardedForHeader = "X-Forwarded-For"
+
+	// forwardProxy is the leader-forwarding mode in which mutating
+	// requests are proxied to the leader instead of being redirected
+	forwardProxy = "proxy"
+)
+
+// proxyTimeout bounds the time a request proxied to the leader can take,
+// like the default max-request-timeout. Requests with a shorter deadline are
+// cancelled along with the request of the client.
+const proxyTimeout = 10 * time.Minute
+
+// restScheme returns the scheme of the ReST servers of the cluster, whose
+// nodes are expected to all serve HTTPS or all serve HTTP
+func restScheme() string {
+	if config.GetString("rest-cert-file") != "" {
+		return "https"
+	}
+	return "http"
+}
+
+// newProxyClient returns the client requests are proxied to the leader with.
+// If the ReST servers require client certificates, this node presents its own
+// certificate to the leader, and trusts the certificate of the leader if it
+// is signed by the same CA bundle. The configuration is loaded for every
+// request, so that reloaded certificates are picked up.
+func newProxyClient() (*http.Client, error) {
+	transport := &http.Transport{
+		Proxy:             http.ProxyFromEnvironment,
+		DisableKeepAlives: true,
+	}
+	if caFile := config.GetString("rest-ca-file"); caFile != "" && restScheme() == "https" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		cert, err := tls.LoadX509KeyPair(config.GetString("rest-cert-file"), config.GetString("rest-key-file"))
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:      pool,
+			Certificates: []tls.Certificate{cert},
+		}
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   proxyTimeout,
+		// Redirects from the leader mean it is no longer the leader,
+		// and are not followed
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}, nil
+}
+
+func isReadRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// leaderAddress returns the REST address of the leader. Peers which haven't
+// advertised a REST address are assumed to use the same port as this node.
+func leaderAddress(p *peer.Peer) string {
+	if p.ClientAddress != "" {
+		return p.ClientAddress
+	}
+	if len(p.Addresses) == 0 {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addresses[0])
+	if err != nil {
+		host = p.Addresses[0]
+	}
+	_, port, err := net.SplitHostPort(config.GetString("clientaddress"))
+	if err != nil {
+		return ""
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// isProxiedByPeer returns true if the request was proxied to this node by one
+// of the peers. The headers of proxied requests are only trusted when the
+// request comes from the address of a peer, so that clients can't set them
+// to get around their limits.
+func isProxiedByPeer(r *http.Request) bool {
+	if r.Header.Get(proxiedHeader) == "" {
+		return false
+	}
+	peers, err := peer.GetPeersF()
+	if err != nil {
+		return false
+	}
+	host := remoteHost(r)
+	for _, p := range peers {
+		for _, addr := range append([]string{p.ClientAddress}, p.Addresses...) {
+			if addr == "" {
+				continue
+			}
+			phost, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				phost = addr
+			}
+			if utils.IsAddressSame(host, phost) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// proxyToLeader forwards the request to the leader, with the given
+// authorization, and streams the response of the leader back
+func proxyToLeader(w http.ResponseWriter, r *http.Request, address, authorization string) {
+	u := *r.URL
+	u.Scheme = restScheme()
+	u.Host = address
+
+	client, err := newProxyClient()
+	if err != nil {
+		log.WithError(err).Error("failed to load the TLS configuration to proxy requests with")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	req, err := http.NewRequest(r.Method, u.String(), r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	req = req.WithContext(r.Context())
+	// The request ID is passed on along with the rest of the headers
+	for k, v := range r.Header {
+		req.Header[k] = v
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	req.Header.Set(proxiedHeader, "1")
+	req.Header.Set(forwardedForHeader, clientKey(r))
+	req.ContentLength = r.ContentLength
+
+	resp, err := client.Do(req)
+	if err != nil {
+		log.WithError(err).WithField("leader", address).Error("failed to proxy request to leader")
+		http.Error(w, "failed to reach the leader, it may have changed", http.StatusServiceUnavailable)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTemporaryRedirect {
+		http.Error(w, "leader changed while proxying the request", http.StatusServiceUnavailable)
+		return
+	}
+
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		log.WithError(err).WithField("leader", address).Error("failed to stream response from leader")
+	}
+}
+
+// LeaderRedirect is a middleware which makes nodes other than the leader of
+// the store cluster read-only, when the readonly-followers option is set.
+// Reads are served locally from the store, while mutating requests are
+// redirected to the leader with a 307 response, or refused with a 421
+// response if the address of the leader isn't known. If the
+// leader-forwarding option is set to proxy, mutating requests are instead
+// proxied to the leader. Requests are authenticated before being forwarded.
+func LeaderRedirect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.GetBool("readonly-followers") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		leader, err := store.Store.Leader()
+		if err != nil {
+			// Without a known leader every node serves all requests
+			log.WithError(err).Debug("could not find the leader, serving request locally")
+			next.ServeHTTP(w, r)
+			return
+		}
+		if leader == gdctx.MyUUID.String() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var address string
+		if p, err := peer.GetPeerF(leader); err == nil {
+			address = leaderAddress(p)
+		}
+		if address != "" {
+			w.Header().Set(LeaderAddressHeader, address)
+		}
+
+		if isReadRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Header.Get(proxiedHeader) != "" {
+			// Proxied by a node which thought this node is the leader
+			http.Error(w, "leader changed while proxying the request", http.StatusServiceUnavailable)
+			return
+		}
+		if address == "" {
+			http.Error(w, "this node is read-only and the leader is unknown", 421) // Misdirected Request
+			return
+		}
+		// Requests are only forwarded once authenticated, so that the
+		// leader isn't sent requests of unknown clients
+		authorization, err := forwardAuthorization(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="glusterd2"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		// Socket clients can't authenticate to the leader themselves,
+		// so their requests are always proxied
+		if config.GetString("leader-forwarding") == forwardProxy || isSocketClient(r) {
+			proxyToLeader(w, r, address, authorization)
+			return
+		}
+		u := *r.URL
+		u.Scheme = restScheme()
+		u.Host = address
+		http.Redirect(w, r, u.String(), http.StatusTemporaryRedirect)
+	})
+}
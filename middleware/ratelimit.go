@@ -0,0 +1,261 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	config "github.com/spf13/viper"
+)
+
+const (
+	// HealthPath is the route of the health end point. It is never rate
+	// limited, so that liveness probes aren't throttled.
+	HealthPath = "/healthz"
+
+	// idleBucketsSweepInterval is the interval between removals of the
+	// per-client buckets which have filled up again
+	idleBucketsSweepInterval = time.Minute
+)
+
+var (
+	rateLimitRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "glusterd2_ratelimit_rate",
+		Help: "Number of requests per second allowed on a route.",
+	}, []string{"route", "class"})
+	rateLimitBurst = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "glusterd2_ratelimit_burst",
+		Help: "Number of requests allowed in a burst on a route.",
+	}, []string{"route", "class"})
+	rateLimitRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "glusterd2_ratelimit_requests_total",
+		Help: "Number of rate limited requests on a route, by whether they were allowed or throttled.",
+	}, []string{"route", "class", "result"})
+
+	limitersMu sync.Mutex
+	// limiters are shared by all the REST servers, so that a client can't
+	// get around a limit by using another of them
+	limiters = make(map[string]*rateLimiter)
+)
+
+func init() {
+	prometheus.MustRegister(rateLimitRate, rateLimitBurst, rateLimitRequests)
+}
+
+// RateLimit is a rate of requests allowed, along with the number of requests
+// allowed in a burst above it
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// tokenBucket allows a request for each token it has, and refills at the rate
+// of its limit up to the burst of its limit
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take takes a token from the bucket if it has one. Otherwise it returns the
+// time after which the bucket will have a token.
+func (b *tokenBucket) take(limit RateLimit, now time.Time) (bool, time.Duration) {
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+}
+
+func (b *tokenBucket) full(limit RateLimit, now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= float64(limit.Burst)
+}
+
+type rateLimiter struct {
+	route string
+	class string
+	limit RateLimit
+	// perClient limits each client address to the limit, instead of
+	// all the clients together
+	perClient bool
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func getRateLimiter(route, class string, limit RateLimit, perClient bool) *rateLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	key := route + "/" + class
+	if l, ok := limiters[key]; ok {
+		return l
+	}
+	l := &rateLimiter{
+		route:     route,
+		class:     class,
+		limit:     limit,
+		perClient: perClient,
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+	limiters[key] = l
+
+	rateLimitRate.WithLabelValues(route, class).Set(limit.Rate)
+	rateLimitBurst.WithLabelValues(route, class).Set(float64(limit.Burst))
+	return l
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// Clients on the Unix domain socket have no address
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientKey returns the address of the client of the request. Requests
+// proxied by a follower to the leader are keyed by the address of the client
+// of the follower.
+func clientKey(r *http.Request) string {
+	if forwardedFor := r.Header.Get(forwardedForHeader); forwardedFor != "" && isProxiedByPeer(r) {
+		return forwardedFor
+	}
+	return remoteHost(r)
+}
+
+func (l *rateLimiter) allow(r *http.Request) (bool, time.Duration) {
+	var key string
+	if l.perClient {
+		key = clientKey(r)
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Buckets which have filled up again are the same as new ones, and
+	// are removed so that the buckets of past clients don't pile up
+	if l.perClient && now.Sub(l.lastSweep) > idleBucketsSweepInterval {
+		for k, b := range l.buckets {
+			if b.full(l.limit, now) {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	return b.take(l.limit, now)
+}
+
+func (l *rateLimiter) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := l.allow(r)
+		if !ok {
+			rateLimitRequests.WithLabelValues(l.route, l.class, "throttled").Inc()
+			retry := int(math.Ceil(wait.Seconds()))
+			if retry < 1 {
+				retry = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retry))
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		rateLimitRequests.WithLabelValues(l.route, l.class, "allowed").Inc()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func noLimit(next http.Handler) http.Handler {
+	return next
+}
+
+// configuredLimit returns the limit of the rate set by the given option. The
+// burst defaults to a second worth of requests.
+func configuredLimit(option string) RateLimit {
+	limit := RateLimit{
+		Rate:  config.GetFloat64(option),
+		Burst: config.GetInt("ratelimit-burst"),
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = int(math.Ceil(limit.Rate))
+	}
+	return limit
+}
+
+// configuredRateLimit returns the configured limit for read or for mutating
+// requests
+func configuredRateLimit(read bool) RateLimit {
+	if read {
+		return configuredLimit("ratelimit-read")
+	}
+	return configuredLimit("ratelimit-write")
+}
+
+// RouteRateLimit returns a middleware which limits the requests on a route
+// with the given limit. Routes can use it to opt into a limit stricter than
+// the default limit of the route, which still applies along with it.
+func RouteRateLimit(route string, limit RateLimit) func(http.Handler) http.Handler {
+	if limit.Rate <= 0 || limit.Burst <= 0 {
+		return noLimit
+	}
+	return getRateLimiter(route, "route", limit, config.GetBool("ratelimit-per-client")).handler
+}
+
+// DefaultRateLimit returns a middleware which limits the requests on a route
+// with the configured read or write limit, depending on the method of the
+// route. Requests are returned a 429 response with a Retry-After header once
+// the limit is exceeded. Routes are not limited if the limit is not set.
+func DefaultRateLimit(route, method, path string) func(http.Handler) http.Handler {
+	if path == HealthPath {
+		return noLimit
+	}
+
+	read := isReadRequest(&http.Request{Method: method})
+	limit := configuredRateLimit(read)
+	if limit.Rate <= 0 {
+		return noLimit
+	}
+	class := "write"
+	if read {
+		class = "read"
+	}
+	return getRateLimiter(route, class, limit, config.GetBool("ratelimit-per-client")).handler
+}
+
+// ServerRateLimit is a middleware which limits all the requests to the REST
+// servers, whatever their route, to the configured ratelimit-client for each
+// client address and to ratelimit-global for all the clients together. These
+// apply along with the limits of the routes. Requests proxied by a follower
+// to the leader were limited by the follower, and aren't limited again.
+func ServerRateLimit(next http.Handler) http.Handler {
+	limited := next
+	if limit := configuredLimit("ratelimit-global"); limit.Rate > 0 {
+		limited = getRateLimiter("*", "global", limit, false).handler(limited)
+	}
+	// A client exceeding its own limit is throttled before it takes from
+	// the limit of all the clients
+	if limit := configuredLimit("ratelimit-client"); limit.Rate > 0 {
+		limited = getRateLimiter("*", "client", limit, true).handler(limited)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == HealthPath || isProxiedByPeer(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limited.ServeHTTP(w, r)
+	})
+}
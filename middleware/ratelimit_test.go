@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gluster/glusterd2/peer"
+	"github.com/gluster/glusterd2/tests"
+
+	config "github.com/spf13/viper"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func serve(h http.Handler, remoteAddr string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/v1/volumes", nil)
+	r.RemoteAddr = remoteAddr
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// TestRateLimiter validates that requests beyond the burst are throttled,
+// for all clients together or for each client
+func TestRateLimiter(t *testing.T) {
+	h := getRateLimiter("test-shared", "write", RateLimit{Rate: 1, Burst: 2}, false).handler(okHandler)
+	tests.Assert(t, serve(h, "10.0.0.1:1000", nil).Code == http.StatusOK)
+	tests.Assert(t, serve(h, "10.0.0.2:1000", nil).Code == http.StatusOK)
+	w := serve(h, "10.0.0.3:1000", nil)
+	tests.Assert(t, w.Code == http.StatusTooManyRequests)
+	tests.Assert(t, w.Header().Get("Retry-After") == "1")
+
+	h = getRateLimiter("test-per-client", "write", RateLimit{Rate: 1, Burst: 1}, true).handler(okHandler)
+	tests.Assert(t, serve(h, "10.0.0.1:1000", nil).Code == http.StatusOK)
+	tests.Assert(t, serve(h, "10.0.0.1:1001", nil).Code == http.StatusTooManyRequests)
+	tests.Assert(t, serve(h, "10.0.0.2:1000", nil).Code == http.StatusOK)
+}
+
+// TestServerRateLimit validates that requests proxied by a peer aren't
+// limited again, and are keyed by the client they were proxied for
+func TestServerRateLimit(t *testing.T) {
+	defer func(f func() ([]peer.Peer, error)) { peer.GetPeersF = f }(peer.GetPeersF)
+	peer.GetPeersF = func() ([]peer.Peer, error) {
+		return []peer.Peer{{Name: "follower", Addresses: []string{"10.0.1.1:24008"}}}, nil
+	}
+	defer config.Set("ratelimit-client", config.Get("ratelimit-client"))
+	defer config.Set("ratelimit-burst", config.Get("ratelimit-burst"))
+	config.Set("ratelimit-client", 1)
+	config.Set("ratelimit-burst", 1)
+
+	h := ServerRateLimit(okHandler)
+	tests.Assert(t, serve(h, "10.0.0.1:1000", nil).Code == http.StatusOK)
+	tests.Assert(t, serve(h, "10.0.0.1:1000", nil).Code == http.StatusTooManyRequests)
+
+	proxied := http.Header{proxiedHeader: {"1"}, forwardedForHeader: {"10.0.0.1"}}
+	for i := 0; i < 3; i++ {
+		tests.Assert(t, serve(h, "10.0.1.1:1000", proxied).Code == http.StatusOK)
+	}
+	// Only peers are trusted to have proxied requests
+	tests.Assert(t, serve(h, "10.0.0.2:1000", proxied).Code == http.StatusOK)
+	tests.Assert(t, serve(h, "10.0.0.2:1000", proxied).Code == http.StatusTooManyRequests)
+
+	r := httptest.NewRequest("POST", "/v1/volumes", nil)
+	r.RemoteAddr = "10.0.1.1:1000"
+	r.Header = proxied
+	tests.Assert(t, clientKey(r) == "10.0.0.1")
+	r.RemoteAddr = "10.0.0.3:1000"
+	tests.Assert(t, clientKey(r) == "10.0.0.3")
+}
+
+// TestLimitTransactions validates that mutating requests beyond the
+// transaction limits are rejected with a 429 response and a Retry-After
+func TestLimitTransactions(t *testing.T) {
+	defer SetTxnLimits(GetTxnLimits())
+	SetTxnLimits(TxnLimits{MaxConcurrent: 1, QueueTimeout: 2 * time.Second})
+
+	started, done := make(chan struct{}), make(chan struct{})
+	h := LimitTransactions("POST", "/v1/volumes")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-done
+	}))
+
+	go serve(h, "10.0.0.1:1000", nil)
+	<-started
+	w := serve(h, "10.0.0.1:1000", nil)
+	close(done)
+	tests.Assert(t, w.Code == http.StatusTooManyRequests)
+	tests.Assert(t, w.Header().Get("Retry-After") == "2")
+}
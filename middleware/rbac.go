@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gluster/glusterd2/servers/rest/route"
+	"github.com/gluster/glusterd2/store"
+
+	"github.com/coreos/etcd/clientv3"
+)
+
+const userRolesKey = store.GlusterPrefix + "rest-auth-roles"
+
+// roleRanks orders the roles, a role being allowed all that those of lower
+// rank are
+var roleRanks = map[string]int{
+	route.RoleViewer:   1,
+	route.RoleOperator: 2,
+	route.RoleAdmin:    3,
+}
+
+// ValidateRole checks that the role is one of admin, operator or viewer
+func ValidateRole(role string) error {
+	if _, ok := roleRanks[role]; !ok {
+		return fmt.Errorf("invalid role %s, must be one of %s, %s or %s",
+			role, route.RoleAdmin, route.RoleOperator, route.RoleViewer)
+	}
+	return nil
+}
+
+// routeRole returns the role required to request a route
+func routeRole(method, role string) string {
+	switch {
+	case role != "":
+		return role
+	case isReadRequest(&http.Request{Method: method}):
+		return route.RoleViewer
+	}
+	return route.RoleOperator
+}
+
+// hasRole returns true if the role is allowed all that the required one is
+func hasRole(role, required string) bool {
+	return roleRanks[role] >= roleRanks[required]
+}
+
+// getUserRoles returns the roles of the users, along with the revision of
+// the key they are stored in, which is 0 if no roles are set
+func getUserRoles() (map[string]string, int64, error) {
+	resp, err := store.Store.Get(context.TODO(), userRolesKey)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	roles := make(map[string]string)
+	if resp.Count != 1 {
+		return roles, 0, nil
+	}
+	if err := json.Unmarshal(resp.Kvs[0].Value, &roles); err != nil {
+		return nil, 0, err
+	}
+	return roles, resp.Kvs[0].ModRevision, nil
+}
+
+// GetUserRoles returns the roles of the users tokens are issued to
+func GetUserRoles() (map[string]string, error) {
+	roles, _, err := getUserRoles()
+	return roles, err
+}
+
+// UpdateUserRoles changes the roles of the users with update, and returns the
+// new roles. The roles are only replaced if no one changed them since they
+// were read, otherwise update is run again on the new roles, so that
+// concurrent changes aren't lost. Tokens already issued to a user have the
+// new role of the user from then on.
+func UpdateUserRoles(update func(roles map[string]string) error) (map[string]string, error) {
+	for {
+		roles, rev, err := getUserRoles()
+		if err != nil {
+			return nil, err
+		}
+		if err := update(roles); err != nil {
+			return nil, err
+		}
+
+		for user, role := range roles {
+			if user == "" {
+				return nil, errors.New("user name can not be empty")
+			}
+			if err := ValidateRole(role); err != nil {
+				return nil, err
+			}
+		}
+		b, err := json.Marshal(roles)
+		if err != nil {
+			return nil, err
+		}
+
+		txn, err := store.Store.Txn(context.TODO()).
+			If(clientv3.Compare(clientv3.ModRevision(userRolesKey), "=", rev)).
+			Then(clientv3.OpPut(userRolesKey, string(b))).
+			Commit()
+		if err != nil {
+			return nil, err
+		}
+		if txn.Succeeded {
+			return roles, nil
+		}
+	}
+}
+
+// userRole returns the role of the user a token was issued to. Tokens issued
+// without a user, to those having the cluster secret, are admin tokens.
+func userRole(user string) (string, error) {
+	if user == "" {
+		return route.RoleAdmin, nil
+	}
+	roles, err := GetUserRoles()
+	if err != nil {
+		return "", err
+	}
+	role, ok := roles[user]
+	if !ok {
+		return "", fmt.Errorf("user %s has no role", user)
+	}
+	return role, nil
+}
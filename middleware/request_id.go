@@ -3,6 +3,8 @@ package middleware
 import (
 	"net/http"
 
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+
 	"github.com/pborman/uuid"
 )
 
@@ -16,16 +18,19 @@ import (
 
 // ReqIDGenerator is a middleware which generates a UUID for each incoming
 // HTTP request and sets this UUID as a header in request and in response.
+// The transactions of the request are identified by it, and log it on every
+// node they run on.
 func ReqIDGenerator(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Use the request id sent by the client, if any
-		reqID := r.Header.Get("X-Request-ID")
-		if reqID == "" {
+		// Use the request id sent by the client, if any. It must be a
+		// UUID for transactions to be identified by it.
+		reqID := r.Header.Get(restutils.RequestIDHeader)
+		if uuid.Parse(reqID) == nil {
 			reqID = uuid.NewRandom().String()
-			r.Header.Set("X-Request-ID", reqID)
+			r.Header.Set(restutils.RequestIDHeader, reqID)
 		}
 		// Set response header
-		w.Header().Set("X-Request-ID", reqID)
+		w.Header().Set(restutils.RequestIDHeader, reqID)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -2,13 +2,57 @@ package middleware
 
 import (
 	"net/http"
+	"time"
+
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
 
 	log "github.com/Sirupsen/logrus"
-	"github.com/gorilla/handlers"
 )
 
-// LogRequest is a middleware which logs HTTP requests in the
-// Apache Common Log Format (CLF)
+// statusRecorder records the status and size of the response to a request
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.size += n
+	return n, err
+}
+
+// LogRequest is a middleware which logs HTTP requests once served, with the
+// request ID as a field so that the request can be correlated with the logs
+// of its transactions on all the nodes
 func LogRequest(next http.Handler) http.Handler {
-	return handlers.LoggingHandler(log.StandardLogger().Writer(), next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		_, logger := restutils.GetReqIDandLogger(r)
+		logger.WithFields(log.Fields{
+			"remote":   r.RemoteAddr,
+			"method":   r.Method,
+			"uri":      r.RequestURI,
+			"proto":    r.Proto,
+			"status":   rec.status,
+			"size":     rec.size,
+			"duration": time.Since(start).String(),
+		}).Info("HTTP request")
+	})
 }
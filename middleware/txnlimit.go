@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	config "github.com/spf13/viper"
+)
+
+// txnLimitsPath is the route changing the transaction limits, which is never
+// limited so that the limits can be raised while they are being hit
+const txnLimitsPath = "/v1/transactions/limits"
+
+var (
+	txnConcurrency = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "glusterd2_txn_concurrency",
+		Help: "Number of mutating requests being served by this node.",
+	})
+	txnQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "glusterd2_txn_queue_depth",
+		Help: "Number of mutating requests waiting to be served by this node.",
+	})
+	txnMaxConcurrent = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "glusterd2_txn_max_concurrent",
+		Help: "Maximum number of mutating requests served at once by this node, 0 if unlimited.",
+	})
+	txnRejected = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "glusterd2_txn_rejected_total",
+		Help: "Number of mutating requests rejected for exceeding the transaction limits.",
+	})
+
+	txns = &txnLimiter{}
+	// txnsOnce loads the configured limits on first use, after the
+	// configuration has been read
+	txnsOnce sync.Once
+)
+
+func init() {
+	prometheus.MustRegister(txnConcurrency, txnQueueDepth, txnMaxConcurrent, txnRejected)
+}
+
+// TxnLimits are the limits on the mutating requests, which run transactions,
+// served at once by a node. Requests beyond MaxConcurrent wait in a queue of
+// at most MaxQueued requests, for at most QueueTimeout. A MaxConcurrent of 0
+// doesn't limit requests.
+type TxnLimits struct {
+	MaxConcurrent int
+	MaxQueued     int
+	QueueTimeout  time.Duration
+}
+
+// txnLimiter is a semaphore with a bounded queue of waiters, whose size can be
+// changed while it is in use
+type txnLimiter struct {
+	mu     sync.Mutex
+	limits TxnLimits
+	active int
+	// queue has a channel for every waiting request, which is closed once
+	// the request is given a slot
+	queue []chan struct{}
+}
+
+func (l *txnLimiter) updateMetrics() {
+	txnConcurrency.Set(float64(l.active))
+	txnQueueDepth.Set(float64(len(l.queue)))
+	txnMaxConcurrent.Set(float64(l.limits.MaxConcurrent))
+}
+
+func (l *txnLimiter) hasSlot() bool {
+	return l.limits.MaxConcurrent <= 0 || l.active < l.limits.MaxConcurrent
+}
+
+// wake gives the free slots to the waiting requests, oldest first. It must be
+// called with the lock held.
+func (l *txnLimiter) wake() {
+	for len(l.queue) > 0 && l.hasSlot() {
+		close(l.queue[0])
+		l.queue = l.queue[1:]
+		l.active++
+	}
+	l.updateMetrics()
+}
+
+// acquire takes a slot, waiting for one in the queue if need be. It returns
+// false if the queue is full, or no slot was free before the timeout or the
+// request was cancelled.
+func (l *txnLimiter) acquire(r *http.Request) bool {
+	l.mu.Lock()
+	if l.hasSlot() {
+		l.active++
+		l.updateMetrics()
+		l.mu.Unlock()
+		return true
+	}
+	if len(l.queue) >= l.limits.MaxQueued {
+		l.mu.Unlock()
+		return false
+	}
+	ch := make(chan struct{})
+	l.queue = append(l.queue, ch)
+	l.updateMetrics()
+	timeout := l.limits.QueueTimeout
+	l.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ch:
+		return true
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, c := range l.queue {
+		if c == ch {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			l.updateMetrics()
+			return false
+		}
+	}
+	// The slot was given while timing out
+	return true
+}
+
+func (l *txnLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	l.wake()
+}
+
+func (l *txnLimiter) set(limits TxnLimits) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits = limits
+	l.wake()
+}
+
+func (l *txnLimiter) get() TxnLimits {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limits
+}
+
+func loadTxnLimits() {
+	txnsOnce.Do(func() {
+		txns.set(TxnLimits{
+			MaxConcurrent: config.GetInt("max-concurrent-txns"),
+			MaxQueued:     config.GetInt("max-queued-txns"),
+			QueueTimeout:  config.GetDuration("txn-queue-timeout"),
+		})
+	})
+}
+
+// GetTxnLimits returns the current transaction limits of this node
+func GetTxnLimits() TxnLimits {
+	loadTxnLimits()
+	return txns.get()
+}
+
+// SetTxnLimits changes the transaction limits of this node. Requests waiting
+// in the queue are let through if the new limits have room for them.
+func SetTxnLimits(limits TxnLimits) {
+	loadTxnLimits()
+	txns.set(limits)
+}
+
+// retryAfter returns the number of seconds after which a request rejected for
+// exceeding the limits can be retried, which is the time requests wait for in
+// the queue
+func (l *txnLimiter) retryAfter() int {
+	retry := int(math.Ceil(l.get().QueueTimeout.Seconds()))
+	if retry < 1 {
+		retry = 1
+	}
+	return retry
+}
+
+// LimitTransactions returns a middleware which limits the mutating requests
+// on a route served at once to the transaction limits of the node. Requests
+// which can't be served are returned a 429 response with a Retry-After
+// header. Read requests aren't limited.
+func LimitTransactions(method, path string) func(http.Handler) http.Handler {
+	if isReadRequest(&http.Request{Method: method}) || path == txnLimitsPath {
+		return noLimit
+	}
+	loadTxnLimits()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !txns.acquire(r) {
+				txnRejected.Inc()
+				w.Header().Set("Retry-After", strconv.Itoa(txns.retryAfter()))
+				http.Error(w, "too many concurrent transactions", http.StatusTooManyRequests)
+				return
+			}
+			defer txns.release()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,270 @@
+// Package operations implements the registry of asynchronous operations,
+// mutating requests which are served in the background while the client polls
+// for their result
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/store"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pborman/uuid"
+	config "github.com/spf13/viper"
+)
+
+const operationsPrefix = store.GlusterPrefix + "operations/"
+
+// The statuses of an operation
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// ErrOperationNotFound is returned for operations which don't exist, or whose
+// result has expired
+var ErrOperationNotFound = errors.New("operation not found")
+
+// NodeProgress is the progress of the transactions of an operation on a node
+type NodeProgress struct {
+	StepsDone int    `json:"steps-done"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Progress is the progress of the transactions of an operation. Steps counts
+// the steps of the transactions started so far, and Nodes has the progress
+// on each node the steps ran on, by node ID.
+type Progress struct {
+	Steps     int                      `json:"steps"`
+	StepsDone int                      `json:"steps-done"`
+	Step      string                   `json:"step,omitempty"`
+	Nodes     map[string]*NodeProgress `json:"nodes,omitempty"`
+}
+
+// Operation is a request served in the background. StatusCode and Result are
+// the response the request would have been sent if served synchronously, and
+// Error its error message if it failed.
+type Operation struct {
+	// mu serializes the updates of the progress of the operation,
+	// whose steps may run on several nodes at once
+	mu sync.Mutex
+	// version counts the updates of the operation, and lastSave is when
+	// its progress was last stored
+	version  int
+	lastSave time.Time
+
+	// saveMu serializes the writes of the operation to the store, which
+	// are done without holding mu, and saved is the version last written
+	saveMu sync.Mutex
+	saved  int
+
+	ID          string          `json:"id"`
+	RequestID   string          `json:"request-id"`
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	Node        uuid.UUID       `json:"node"`
+	Status      string          `json:"status"`
+	StartedAt   time.Time       `json:"started-at"`
+	CompletedAt *time.Time      `json:"completed-at,omitempty"`
+	StatusCode  int             `json:"status-code,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Progress    *Progress       `json:"progress,omitempty"`
+}
+
+// ResultTTL returns the time the results of completed operations are kept for
+func ResultTTL() time.Duration {
+	return config.GetDuration("operation-result-ttl")
+}
+
+func (op *Operation) save(opts ...clientv3.OpOption) error {
+	b, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	_, err = store.Store.Put(context.TODO(), operationsPrefix+op.ID, string(b), opts...)
+	return err
+}
+
+// progressSaveInterval is the least time between two writes of the progress
+// of an operation on a node step being done. Steps being started or done on
+// all their nodes are always written.
+const progressSaveInterval = time.Second
+
+// New registers a running operation for the request. The operation is tied to
+// the store session of this node, so that the operations of a node which goes
+// down don't remain running forever.
+func New(reqID, method, path string) (*Operation, error) {
+	op := &Operation{
+		ID:        uuid.NewRandom().String(),
+		RequestID: reqID,
+		Method:    method,
+		Path:      path,
+		Node:      gdctx.MyUUID,
+		Status:    StatusRunning,
+		StartedAt: time.Now().UTC(),
+	}
+	if err := op.save(clientv3.WithLease(store.Store.Session.Lease())); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// updated records an update of the operation, made with mu held, and returns
+// the operation as it now is if it is to be stored. Node steps being done
+// only have their progress stored every progressSaveInterval.
+func (op *Operation) updated(always bool) ([]byte, int) {
+	op.version++
+	if !always && time.Since(op.lastSave) < progressSaveInterval {
+		return nil, 0
+	}
+	op.lastSave = time.Now()
+	b, err := json.Marshal(op)
+	if err != nil {
+		log.WithError(err).WithField("operation", op.ID).Warn("failed to marshal progress of operation")
+		return nil, 0
+	}
+	return b, op.version
+}
+
+// store writes a version of the operation, unless a later one was written
+// already
+func (op *Operation) store(b []byte, version int, opts ...clientv3.OpOption) error {
+	op.saveMu.Lock()
+	defer op.saveMu.Unlock()
+	if b == nil || version <= op.saved {
+		return nil
+	}
+	if _, err := store.Store.Put(context.TODO(), operationsPrefix+op.ID, string(b), opts...); err != nil {
+		return err
+	}
+	op.saved = version
+	return nil
+}
+
+// saveProgress stores the progress of the running operation. Failing to is
+// not a failure of the operation.
+func (op *Operation) saveProgress(b []byte, version int) {
+	if err := op.store(b, version, clientv3.WithLease(store.Store.Session.Lease())); err != nil {
+		log.WithError(err).WithField("operation", op.ID).Warn("failed to store progress of operation")
+	}
+}
+
+// TxnStarted records the steps of a transaction of the operation
+func (op *Operation) TxnStarted(steps int) {
+	op.mu.Lock()
+	if op.Progress == nil {
+		op.Progress = &Progress{Nodes: make(map[string]*NodeProgress)}
+	}
+	op.Progress.Steps += steps
+	b, version := op.updated(true)
+	op.mu.Unlock()
+	op.saveProgress(b, version)
+}
+
+// NodeStepDone records the result of a step of the operation on a node
+func (op *Operation) NodeStepDone(step string, node uuid.UUID, err error) {
+	op.mu.Lock()
+	if op.Progress == nil {
+		op.mu.Unlock()
+		return
+	}
+	op.Progress.Step = step
+	np, ok := op.Progress.Nodes[node.String()]
+	if !ok {
+		np = new(NodeProgress)
+		op.Progress.Nodes[node.String()] = np
+	}
+	if err != nil {
+		np.Error = err.Error()
+	} else {
+		np.StepsDone++
+	}
+	b, version := op.updated(false)
+	op.mu.Unlock()
+	op.saveProgress(b, version)
+}
+
+// StepDone records a step of the operation done on all its nodes
+func (op *Operation) StepDone(step string) {
+	op.mu.Lock()
+	if op.Progress == nil {
+		op.mu.Unlock()
+		return
+	}
+	op.Progress.Step = step
+	op.Progress.StepsDone++
+	b, version := op.updated(true)
+	op.mu.Unlock()
+	op.saveProgress(b, version)
+}
+
+// Complete records the response of the operation, which is kept for
+// ResultTTL.
+func (op *Operation) Complete(statusCode int, body []byte) error {
+	op.mu.Lock()
+	now := time.Now().UTC()
+	op.CompletedAt = &now
+	op.StatusCode = statusCode
+
+	if statusCode < 400 {
+		op.Status = StatusSucceeded
+		var result json.RawMessage
+		if err := json.Unmarshal(body, &result); err == nil {
+			op.Result = result
+		}
+	} else {
+		op.Status = StatusFailed
+		var apiErr struct{ Error string }
+		if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Error != "" {
+			op.Error = apiErr.Error
+		} else {
+			op.Error = strings.TrimSpace(string(body))
+		}
+	}
+
+	b, version := op.updated(true)
+	op.mu.Unlock()
+	if b == nil {
+		return errors.New("failed to marshal operation")
+	}
+
+	ttl := int64(ResultTTL().Seconds())
+	if ttl < 1 {
+		ttl = 1
+	}
+	lease, err := store.Store.Grant(context.TODO(), ttl)
+	if err != nil {
+		return err
+	}
+	return op.store(b, version, clientv3.WithLease(lease.ID))
+}
+
+// Get returns the operation with the given ID
+func Get(id string) (*Operation, error) {
+	if uuid.Parse(id) == nil {
+		return nil, ErrOperationNotFound
+	}
+
+	resp, err := store.Store.Get(context.TODO(), operationsPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, ErrOperationNotFound
+	}
+
+	var op Operation
+	if err := json.Unmarshal(resp.Kvs[0].Value, &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
@@ -0,0 +1,37 @@
+package peer
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// labelKeyRe is the form of label keys, which are referred to in placement
+// constraints
+var labelKeyRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._/-]*$`)
+
+// ValidateLabels checks that the label keys are well formed, and that no
+// value is empty
+func ValidateLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if !labelKeyRe.MatchString(k) {
+			return fmt.Errorf("invalid label %q, must be letters, digits and . _ / - starting with a letter or digit", k)
+		}
+		if v == "" {
+			return fmt.Errorf("label %s has an empty value", k)
+		}
+	}
+	return nil
+}
+
+// GetNodeLabels returns the labels of every peer, by peer ID
+func GetNodeLabels() (map[string]map[string]string, error) {
+	peers, err := GetPeersF()
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[string]map[string]string, len(peers))
+	for _, p := range peers {
+		labels[p.ID.String()] = p.Labels
+	}
+	return labels, nil
+}
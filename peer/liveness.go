@@ -0,0 +1,192 @@
+package peer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gluster/glusterd2/events"
+	"github.com/gluster/glusterd2/store"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/pborman/uuid"
+	config "github.com/spf13/viper"
+)
+
+const (
+	defaultLivenessInterval = 5 * time.Second
+	defaultOfflineThreshold = 3
+	defaultOfflineGrace     = 10 * time.Second
+)
+
+// peerLiveness is the debounced liveness state of a peer
+type peerLiveness struct {
+	online   bool
+	lastSeen time.Time
+	// streak is the number of consecutive checks which disagreed with
+	// online, and streakStart the time of the first of them
+	streak      int
+	streakStart time.Time
+}
+
+var (
+	livenessLock sync.RWMutex
+	liveness     = make(map[string]*peerLiveness)
+)
+
+func offlineThreshold() int {
+	if t := config.GetInt("peer-offline-threshold"); t > 0 {
+		return t
+	}
+	return defaultOfflineThreshold
+}
+
+func offlineGrace() time.Duration {
+	if d := config.GetDuration("peer-offline-grace"); d > 0 {
+		return d
+	}
+	return defaultOfflineGrace
+}
+
+// updateLiveness records the result of a liveness check of a peer. The state
+// of the peer changes only after threshold consecutive checks disagreeing
+// with it, spanning at least the grace period. It returns true if the state
+// changed.
+func updateLiveness(id string, alive bool, now time.Time, threshold int, grace time.Duration) bool {
+	livenessLock.Lock()
+	defer livenessLock.Unlock()
+
+	l, ok := liveness[id]
+	if !ok {
+		l = &peerLiveness{online: alive}
+		liveness[id] = l
+	}
+	if alive {
+		l.lastSeen = now
+	}
+
+	if alive == l.online {
+		l.streak = 0
+		return false
+	}
+
+	if l.streak == 0 {
+		l.streakStart = now
+	}
+	l.streak++
+	if l.streak < threshold || now.Sub(l.streakStart) < grace {
+		return false
+	}
+
+	l.online = alive
+	l.streak = 0
+	return true
+}
+
+// forgetLiveness drops the liveness state of the peers for which keep returns
+// false
+func forgetLiveness(keep func(id string) bool) {
+	livenessLock.Lock()
+	defer livenessLock.Unlock()
+
+	for id := range liveness {
+		if !keep(id) {
+			delete(liveness, id)
+		}
+	}
+}
+
+// IsOnline returns the debounced liveness of the peer. Unlike
+// store.IsNodeAlive, transient failures don't make the peer offline. Peers
+// not checked yet are looked up in the store.
+func IsOnline(id uuid.UUID) bool {
+	livenessLock.RLock()
+	l, ok := liveness[id.String()]
+	livenessLock.RUnlock()
+
+	if !ok {
+		return store.Store.IsNodeAlive(id)
+	}
+	return l.online
+}
+
+// LastSeen returns the time the peer was last seen alive, or the zero time if
+// it hasn't been seen yet
+func LastSeen(id uuid.UUID) time.Time {
+	livenessLock.RLock()
+	defer livenessLock.RUnlock()
+
+	if l, ok := liveness[id.String()]; ok {
+		return l.lastSeen
+	}
+	return time.Time{}
+}
+
+func checkPeers() {
+	peers, err := GetPeersF()
+	if err != nil {
+		log.WithError(err).Debug("failed to get peers for liveness check")
+		return
+	}
+
+	// Peers deleted on other nodes are forgotten here
+	ids := make(map[string]bool)
+	for _, p := range peers {
+		ids[p.ID.String()] = true
+	}
+	forgetLiveness(func(id string) bool { return ids[id] })
+
+	now := time.Now()
+	threshold, grace := offlineThreshold(), offlineGrace()
+	for _, p := range peers {
+		alive := store.Store.IsNodeAlive(p.ID)
+		if !updateLiveness(p.ID.String(), alive, now, threshold, grace) {
+			continue
+		}
+
+		name := "peer-offline"
+		if alive {
+			name = "peer-online"
+		}
+		events.Broadcast(events.New(name, map[string]string{
+			"peer.id":   p.ID.String(),
+			"peer.name": p.Name,
+		}))
+	}
+}
+
+// LivenessTracker periodically checks the liveness of the peers, and keeps
+// track of their debounced liveness. It is a suture.Service.
+type LivenessTracker struct {
+	stop chan struct{}
+}
+
+// NewLivenessTracker returns a new LivenessTracker
+func NewLivenessTracker() *LivenessTracker {
+	return &LivenessTracker{stop: make(chan struct{})}
+}
+
+// Serve checks the liveness of the peers till the tracker is stopped
+func (t *LivenessTracker) Serve() {
+	interval := config.GetDuration("peer-liveness-interval")
+	if interval <= 0 {
+		interval = defaultLivenessInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	checkPeers()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			checkPeers()
+		}
+	}
+}
+
+// Stop stops the LivenessTracker
+func (t *LivenessTracker) Stop() {
+	close(t.stop)
+}
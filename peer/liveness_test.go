@@ -0,0 +1,46 @@
+package peer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gluster/glusterd2/tests"
+)
+
+// TestUpdateLiveness validates that a flapping peer keeps its state, and that
+// the state changes only after enough consecutive checks over the grace period
+func TestUpdateLiveness(t *testing.T) {
+	defer forgetLiveness(func(string) bool { return false })
+
+	const id = "flapping"
+	threshold, grace := 3, 10*time.Second
+	now := time.Now()
+	tests.Assert(t, !updateLiveness(id, true, now, threshold, grace))
+
+	// Failing checks interrupted by a successful one don't add up
+	for i := 0; i < 10; i++ {
+		now = now.Add(5 * time.Second)
+		tests.Assert(t, !updateLiveness(id, i%3 == 2, now, threshold, grace))
+	}
+	tests.Assert(t, liveness[id].online)
+
+	// Enough failing checks, but within the grace period
+	now = now.Add(time.Second)
+	for i := 0; i < threshold; i++ {
+		now = now.Add(time.Second)
+		tests.Assert(t, !updateLiveness(id, false, now, threshold, grace))
+	}
+
+	now = now.Add(grace)
+	tests.Assert(t, updateLiveness(id, false, now, threshold, grace))
+	tests.Assert(t, !liveness[id].online)
+
+	// Coming back online is debounced the same way
+	tests.Assert(t, !updateLiveness(id, true, now.Add(time.Second), threshold, grace))
+	tests.Assert(t, !updateLiveness(id, false, now.Add(2*time.Second), threshold, grace))
+	tests.Assert(t, !liveness[id].online)
+
+	forgetLiveness(func(p string) bool { return p != id })
+	_, ok := liveness[id]
+	tests.Assert(t, !ok)
+}
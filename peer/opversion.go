@@ -0,0 +1,102 @@
+package peer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gluster/glusterd2/version"
+)
+
+// LaggingPeer is a peer whose op-version is below the one required
+type LaggingPeer struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	OpVersion int    `json:"op-version"`
+}
+
+// OpVersionCheck is the result of checking whether the online peers support
+// an op-version. Offline peers can't be checked, and are listed in Offline.
+type OpVersionCheck struct {
+	Required int           `json:"required-op-version"`
+	Feasible bool          `json:"feasible"`
+	Lagging  []LaggingPeer `json:"lagging-peers,omitempty"`
+	Offline  []string      `json:"offline-peers,omitempty"`
+}
+
+// CheckOpVersion checks that every online peer supports the op-version, as
+// advertised by the peer itself when it starts or joins the cluster
+func CheckOpVersion(required int) (*OpVersionCheck, error) {
+	peers, err := GetPeersF()
+	if err != nil {
+		return nil, err
+	}
+
+	check := &OpVersionCheck{Required: required, Feasible: true}
+	for _, p := range peers {
+		if !IsOnline(p.ID) {
+			check.Offline = append(check.Offline, p.Ref())
+			continue
+		}
+		if p.OpVersion < required {
+			check.Feasible = false
+			check.Lagging = append(check.Lagging, LaggingPeer{
+				ID:        p.ID.String(),
+				Name:      p.Name,
+				OpVersion: p.OpVersion,
+			})
+		}
+	}
+	return check, nil
+}
+
+// ClusterOpVersion returns the op-version of the cluster, which is the lowest
+// op-version supported by its peers, offline ones included
+func ClusterOpVersion() (int, error) {
+	peers, err := GetPeersF()
+	if err != nil {
+		return 0, err
+	}
+
+	opVersion := version.MaxOpVersion
+	for _, p := range peers {
+		if p.OpVersion < opVersion {
+			opVersion = p.OpVersion
+		}
+	}
+	return opVersion, nil
+}
+
+// FeatureNotSupported is returned for a feature which some online peers don't
+// support
+type FeatureNotSupported struct {
+	Feature  string
+	Required int
+	Lagging  []LaggingPeer
+}
+
+func (e *FeatureNotSupported) Error() string {
+	var peers []string
+	for _, p := range e.Lagging {
+		peers = append(peers, fmt.Sprintf("%s (%s, op-version %d)", p.Name, p.ID, p.OpVersion))
+	}
+	return fmt.Sprintf("%s requires op-version %d, which peers %s don't support; upgrade them first",
+		e.Feature, e.Required, strings.Join(peers, ", "))
+}
+
+// CheckFeature returns a FeatureNotSupported error if the version-gated
+// feature isn't supported by every online peer. Features which aren't
+// version-gated are always supported.
+func CheckFeature(feature string) error {
+	required, ok := version.FeatureOpVersion(feature)
+	if !ok {
+		return nil
+	}
+	check, err := CheckOpVersion(required)
+	if err != nil {
+		return err
+	}
+	if !check.Feasible {
+		return &FeatureNotSupported{Feature: feature, Required: required, Lagging: check.Lagging}
+	}
+	return nil
+}
@@ -2,14 +2,77 @@
 package peer
 
 import (
+	"net"
+
+	"github.com/gluster/glusterd2/utils"
+
 	"github.com/pborman/uuid"
 )
 
 // Peer reperesents a GlusterD
 type Peer struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	Addresses []string  `json:"addresses"`
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	// Addresses are all the peer addresses the peer can be reached on.
+	// The first is the one the peer service listens on, the rest are its
+	// addresses on other networks.
+	Addresses []string `json:"addresses"`
+	// ClientAddress is the address of the REST service of the peer
+	ClientAddress string `json:"client-address,omitempty"`
+	// StorageAddress is the address clients reach the bricks of the peer
+	// on, when it is on a network of its own
+	StorageAddress string `json:"storage-address,omitempty"`
+	// OpVersion is the op-version the peer supports. Peers which predate
+	// advertising it have none.
+	OpVersion int `json:"op-version,omitempty"`
+	// Labels are attributes of the peer set by the operator, like its
+	// rack or disk type, which brick placement constraints refer to
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// StorageHost returns the host clients reach the bricks of the peer on
+func (p *Peer) StorageHost() string {
+	if p.StorageAddress != "" {
+		return p.StorageAddress
+	}
+	if len(p.Addresses) == 0 {
+		return p.Name
+	}
+	host, _, err := net.SplitHostPort(p.Addresses[0])
+	if err != nil {
+		return p.Addresses[0]
+	}
+	return host
+}
+
+// HasAddress returns true if the given address is any of the addresses of
+// the peer, including its storage address
+func (p *Peer) HasAddress(addr string) bool {
+	for _, paddr := range p.Addresses {
+		if utils.IsPeerAddressSame(addr, paddr) {
+			return true
+		}
+	}
+	if p.StorageAddress != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		return utils.IsAddressSame(host, p.StorageAddress)
+	}
+	return false
+}
+
+// HasSameHost returns true if the given address reaches the peer on a host
+// name or IP other than its own addresses, see utils.IsPeerHostSame. It
+// returns the address of the peer it matched.
+func (p *Peer) HasSameHost(addr string) (string, bool) {
+	for _, paddr := range p.Addresses {
+		if utils.IsPeerHostSame(addr, paddr) {
+			return paddr, true
+		}
+	}
+	return "", false
 }
 
 // ETCDConfig represents the structure which holds the ETCD env variables &
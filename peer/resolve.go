@@ -0,0 +1,82 @@
+package peer
+
+import (
+	"fmt"
+
+	"github.com/gluster/glusterd2/errors"
+
+	"github.com/pborman/uuid"
+	config "github.com/spf13/viper"
+)
+
+// The forms a peer can be identified by in the REST API, one of which is set
+// as the canonical one by the peer-identifier setting
+const (
+	IdentifierUUID     = "uuid"
+	IdentifierHostname = "hostname"
+)
+
+// Ref returns the canonical identifier of the peer in the REST API, its ID or
+// its name depending on the peer-identifier setting
+func (p *Peer) Ref() string {
+	if config.GetString("peer-identifier") == IdentifierHostname && p.Name != "" {
+		return p.Name
+	}
+	return p.ID.String()
+}
+
+// AmbiguousPeer is a reference to a peer, by name or address, which matches
+// more than one peer
+type AmbiguousPeer struct {
+	Ref string
+	By  string
+}
+
+func (e *AmbiguousPeer) Error() string {
+	return fmt.Sprintf("ambiguous peer, more than one peer has the %s %s, use its ID instead", e.By, e.Ref)
+}
+
+// ResolvePeer returns the peer identified by ref, which can be its ID, its
+// name, or any of its addresses, whatever the canonical form is. A name or an
+// address shared by more than one peer doesn't identify a peer, and an
+// AmbiguousPeer error is returned.
+func ResolvePeer(ref string) (*Peer, error) {
+	if uuid.Parse(ref) != nil {
+		return GetPeerF(ref)
+	}
+
+	peers, err := GetPeersF()
+	if err != nil {
+		return nil, err
+	}
+	var found *Peer
+	for i := range peers {
+		if peers[i].Name != ref {
+			continue
+		}
+		if found != nil {
+			return nil, &AmbiguousPeer{Ref: ref, By: "name"}
+		}
+		found = &peers[i]
+	}
+	if found != nil {
+		return found, nil
+	}
+
+	// Addresses are matched the way probes are, so that any of the
+	// addresses of the peer, or a host name resolving to one, works. A
+	// host name can resolve to the addresses of several peers.
+	for i := range peers {
+		if !peers[i].HasAddress(ref) {
+			continue
+		}
+		if found != nil {
+			return nil, &AmbiguousPeer{Ref: ref, By: "address"}
+		}
+		found = &peers[i]
+	}
+	if found == nil {
+		return nil, errors.ErrPeerNotFound
+	}
+	return found, nil
+}
@@ -1,17 +1,64 @@
 package peer
 
 import (
+	"net"
+
 	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/utils"
 
+	log "github.com/Sirupsen/logrus"
 	config "github.com/spf13/viper"
 )
 
+// selfAddresses returns the peer addresses of this node. The configured peer
+// address comes first, followed by the addresses on the other networks of
+// the node with the same port, so that the node can be matched by any of
+// them.
+func selfAddresses() []string {
+	peerAddress := config.GetString("peeraddress")
+	addresses := []string{peerAddress}
+
+	_, port, err := net.SplitHostPort(peerAddress)
+	if err != nil {
+		return addresses
+	}
+	ips, err := utils.GetLocalIPs()
+	if err != nil {
+		log.WithError(err).Warn("failed to get local addresses, advertising only the peer address")
+		return addresses
+	}
+	for _, ip := range ips {
+		a := net.JoinHostPort(ip, port)
+		if !utils.IsPeerAddressSame(a, peerAddress) {
+			addresses = append(addresses, a)
+		}
+	}
+	return addresses
+}
+
 // AddSelfDetails results in the peer adding its own details into etcd
 func AddSelfDetails() error {
+	// The REST service could be listening on all addresses, in which case
+	// the address used to reach this node is advertised
+	host, port, err := net.SplitHostPort(config.GetString("clientaddress"))
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		host = gdctx.HostIP
+	}
+
 	p := &Peer{
-		ID:        gdctx.MyUUID,
-		Name:      gdctx.HostName,
-		Addresses: []string{config.GetString("peeraddress")},
+		ID:             gdctx.MyUUID,
+		Name:           gdctx.HostName,
+		Addresses:      selfAddresses(),
+		ClientAddress:  net.JoinHostPort(host, port),
+		StorageAddress: config.GetString("storage-address"),
+		OpVersion:      gdctx.OpVersion,
+	}
+	// The labels are set by the operator, and kept across restarts
+	if old, err := GetPeerF(gdctx.MyUUID.String()); err == nil {
+		p.Labels = old.Labels
 	}
 
 	return AddOrUpdatePeer(p)
@@ -6,13 +6,13 @@ package peer
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"strings"
 
 	"github.com/gluster/glusterd2/errors"
 	"github.com/gluster/glusterd2/store"
-	"github.com/gluster/glusterd2/utils"
 
 	log "github.com/Sirupsen/logrus"
-	"github.com/coreos/etcd/clientv3"
 	"github.com/pborman/uuid"
 )
 
@@ -42,7 +42,9 @@ func AddOrUpdatePeer(p *Peer) error {
 
 	idStr := p.ID.String()
 
-	if _, err := store.Store.Put(context.TODO(), peerPrefix+idStr, string(json)); err != nil {
+	_, err = store.Store.Put(context.TODO(), peerPrefix+idStr, string(json))
+	store.Store.InvalidateCache(peerPrefix)
+	if err != nil {
 		return err
 	}
 
@@ -89,13 +91,13 @@ func GetInitialCluster() (string, error) {
 
 // GetPeers returns all available peers in the store
 func GetPeers() ([]Peer, error) {
-	resp, err := store.Store.Get(context.TODO(), peerPrefix, clientv3.WithPrefix())
+	kvs, err := store.Store.GetPrefix(peerPrefix)
 	if err != nil {
 		return nil, err
 	}
 	// There will be at least one peer (current node)
-	peers := make([]Peer, len(resp.Kvs))
-	for i, kv := range resp.Kvs {
+	peers := make([]Peer, len(kvs))
+	for i, kv := range kvs {
 		var p Peer
 
 		if err := json.Unmarshal(kv.Value, &p); err != nil {
@@ -111,15 +113,51 @@ func GetPeers() ([]Peer, error) {
 	return peers, nil
 }
 
+// GetPeersPage returns a page of the peers, ordered by ID, read from the
+// store without loading all the peers. See store.GetPrefixPage for after,
+// skip, limit and more.
+func GetPeersPage(after string, skip, limit int) ([]Peer, bool, error) {
+	kvs, more, err := store.Store.GetPrefixPage(peerPrefix, after, skip, limit)
+	if err != nil {
+		return nil, false, err
+	}
+
+	peers := make([]Peer, 0, len(kvs))
+	for _, kv := range kvs {
+		var p Peer
+		if err := json.Unmarshal(kv.Value, &p); err != nil {
+			log.WithFields(log.Fields{
+				"peer":  string(kv.Key),
+				"error": err,
+			}).Error("Failed to unmarshal peer")
+			continue
+		}
+		peers = append(peers, p)
+	}
+
+	return peers, more, nil
+}
+
+// CountPeers returns the number of peers
+func CountPeers() (int, error) {
+	return store.Store.CountPrefix(peerPrefix)
+}
+
+// InitCache preloads the peers into the store cache, so that listing peers
+// doesn't need to hit the store
+func InitCache() error {
+	return store.Store.CachePrefix(peerPrefix)
+}
+
 // GetPeerIDs returns peer id (uuid) of all peers in the store
 func GetPeerIDs() ([]uuid.UUID, error) {
-	resp, err := store.Store.Get(context.TODO(), peerPrefix, clientv3.WithPrefix())
+	kvs, err := store.Store.GetPrefix(peerPrefix)
 	if err != nil {
 		return nil, err
 	}
 
-	uuids := make([]uuid.UUID, len(resp.Kvs))
-	for i, kv := range resp.Kvs {
+	uuids := make([]uuid.UUID, len(kvs))
+	for i, kv := range kvs {
 		var p Peer
 		if err := json.Unmarshal(kv.Value, &p); err != nil {
 			log.WithFields(log.Fields{
@@ -153,6 +191,10 @@ func GetPeerByName(name string) (*Peer, error) {
 // DeletePeer deletes given peer from the store
 func DeletePeer(id string) error {
 	_, e := store.Store.Delete(context.TODO(), peerPrefix+id)
+	store.Store.InvalidateCache(peerPrefix)
+	if e == nil {
+		forgetLiveness(func(p string) bool { return p != id })
+	}
 	return e
 }
 
@@ -174,10 +216,8 @@ func GetPeerByAddr(addr string) (*Peer, error) {
 	}
 
 	for _, p := range peers {
-		for _, paddr := range p.Addresses {
-			if utils.IsPeerAddressSame(addr, paddr) {
-				return &p, nil
-			}
+		if p.HasAddress(addr) {
+			return &p, nil
 		}
 	}
 
@@ -192,16 +232,45 @@ func GetPeerByAddrs(addrs []string) (*Peer, error) {
 	}
 	for _, a := range addrs {
 		for _, p := range peers {
-			for _, paddr := range p.Addresses {
-				if utils.IsPeerAddressSame(a, paddr) {
-					return &p, nil
-				}
+			if p.HasAddress(a) {
+				return &p, nil
 			}
 		}
 	}
 	return nil, errors.ErrPeerNotFound
 }
 
+// PeerHostConflict is an address which is the same host as an existing peer
+// under a different name
+type PeerHostConflict struct {
+	Address     string
+	Peer        *Peer
+	PeerAddress string
+}
+
+func (c *PeerHostConflict) Error() string {
+	return fmt.Sprintf("address %s is the same host as %s of the existing peer %s (ID: %s)",
+		c.Address, c.PeerAddress, c.Peer.Name, c.Peer.ID.String())
+}
+
+// CheckPeerHostConflict returns a PeerHostConflict if any of the addresses is
+// the same host as an existing peer, which would add the host twice to the
+// cluster
+func CheckPeerHostConflict(addrs []string) error {
+	peers, err := GetPeers()
+	if err != nil {
+		return err
+	}
+	for _, a := range addrs {
+		for i := range peers {
+			if paddr, ok := peers[i].HasSameHost(a); ok {
+				return &PeerHostConflict{Address: a, Peer: &peers[i], PeerAddress: paddr}
+			}
+		}
+	}
+	return nil
+}
+
 // GetPeerIDByAddr returns the ID of the peer with the given address
 func GetPeerIDByAddr(addr string) (uuid.UUID, error) {
 	p, e := GetPeerByAddrF(addr)
@@ -210,3 +279,23 @@ func GetPeerIDByAddr(addr string) (uuid.UUID, error) {
 	}
 	return p.ID, nil
 }
+
+// GetPeersMetadata returns the stored metadata of the peers, by peer ID. The
+// metadata is served from the cache of this node, like the other peer reads,
+// unless uncached is set.
+func GetPeersMetadata(uncached bool) (map[string]json.RawMessage, error) {
+	get := store.Store.GetPrefix
+	if uncached {
+		get = store.Store.GetPrefixUncached
+	}
+	kvs, err := get(peerPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make(map[string]json.RawMessage, len(kvs))
+	for _, kv := range kvs {
+		peers[strings.TrimPrefix(string(kv.Key), peerPrefix)] = kv.Value
+	}
+	return peers, nil
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Uint64 is a 64-bit count which is encoded in JSON as a decimal string.
+// JavaScript clients hold JSON numbers as doubles, which silently lose
+// precision past 2^53, so the cumulative counters which can go past that use
+// this type: the byte and inode counts of the utilization responses, and the
+// byte and operation counts of the I/O statistics responses. Other numbers,
+// like the sizes of single files, are plain JSON numbers. Both strings and
+// plain numbers are accepted when decoding.
+type Uint64 uint64
+
+// MarshalJSON encodes the count as a JSON string
+func (n Uint64) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(strconv.FormatUint(uint64(n), 10))), nil
+}
+
+// UnmarshalJSON decodes the count from a JSON string or number
+func (n *Uint64) UnmarshalJSON(b []byte) error {
+	v, err := strconv.ParseUint(strings.Trim(string(b), `"`), 10, 64)
+	if err != nil {
+		return err
+	}
+	*n = Uint64(v)
+	return nil
+}
@@ -8,6 +8,12 @@ type VolCreateReq struct {
 	Bricks    []string          `json:"bricks"`
 	Options   map[string]string `json:"options,omitempty"`
 	Force     bool              `json:"force,omitempty"`
+
+	Type string `json:"type,omitempty"`
+
+	BrickTemplate string   `json:"brick-template,omitempty"`
+	Nodes         []string `json:"nodes,omitempty"`
+	BricksPerNode int      `json:"bricks-per-node,omitempty"`
 }
 
 // PeerAddReq represents a Peer Add Request
@@ -6,9 +6,10 @@ import (
 
 // Peer reperesents a GlusterD
 type Peer struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	Addresses []string  `json:"addresses"`
+	ID             uuid.UUID `json:"id"`
+	Name           string    `json:"name"`
+	Addresses      []string  `json:"addresses"`
+	StorageAddress string    `json:"storage-address,omitempty"`
 }
 
 // VolState is the current status of a volume
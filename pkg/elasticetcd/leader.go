@@ -51,6 +51,16 @@ func (ee *ElasticEtcd) startCampaign() {
 	}()
 }
 
+// Leader returns the name of the current leader of the elastic cluster
+func (ee *ElasticEtcd) Leader(ctx context.Context) (string, error) {
+	election := concurrency.NewElection(ee.Session(), electionKey)
+	resp, err := election.Leader(ctx)
+	if err != nil {
+		return "", err
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
 func (ee *ElasticEtcd) startLeader() error {
 	ee.watchVolunteers()
 	ee.watchIdealSize()
@@ -0,0 +1,160 @@
+// Package logrotate implements size based rotation of log files, with
+// retention of the rotated files by count and age
+package logrotate
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the format of the time of rotation in the names of
+// rotated log files, such as glusterd2-2018-01-02T15-04-05.000.log
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// Config is the rotation and retention of log files. A log file is rotated
+// once it grows beyond MaxSize bytes. Of the rotated files, at most
+// MaxBackups are kept, none older than MaxAge. A zero value disables the
+// corresponding limit.
+type Config struct {
+	MaxSize    int64
+	MaxAge     time.Duration
+	MaxBackups int
+}
+
+// Enabled returns true if log files are rotated
+func (c Config) Enabled() bool {
+	return c.MaxSize > 0
+}
+
+func backupName(path string, t time.Time) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + t.UTC().Format(backupTimeFormat) + ext
+}
+
+type backup struct {
+	path    string
+	rotated time.Time
+}
+
+// backups returns the rotated files of the log file, newest first
+func backups(path string) ([]backup, error) {
+	ext := filepath.Ext(path)
+	prefix := strings.TrimSuffix(path, ext) + "-"
+
+	matches, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []backup
+	for _, m := range matches {
+		t, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(m, prefix), ext))
+		if err != nil {
+			// Some other file with a similar name
+			continue
+		}
+		found = append(found, backup{m, t})
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].rotated.After(found[j].rotated)
+	})
+	return found, nil
+}
+
+// removeOldBackups removes the rotated files of the log file which exceed
+// the retention limits
+func removeOldBackups(path string, c Config) error {
+	found, err := backups(path)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i, b := range found {
+		if (c.MaxBackups > 0 && i >= c.MaxBackups) || (c.MaxAge > 0 && now.Sub(b.rotated) > c.MaxAge) {
+			if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RotateFile renames the log file to a backup named by the time of rotation,
+// and removes the backups exceeding the retention limits. The process writing
+// the log file must reopen it to write to a new file.
+func RotateFile(path string, c Config) error {
+	if err := os.Rename(path, backupName(path, time.Now())); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return removeOldBackups(path, c)
+}
+
+// Writer is an io.WriteCloser writing to a log file, which is rotated as
+// configured. Every write goes whole to a single file, so that log entries
+// aren't split across files whatever their format.
+type Writer struct {
+	mu     sync.Mutex
+	path   string
+	config Config
+	file   *os.File
+	size   int64
+}
+
+// NewWriter returns a Writer appending to the log file at the given path
+func NewWriter(path string, c Config) (*Writer, error) {
+	w := &Writer{path: path, config: c}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = fi.Size()
+	return nil
+}
+
+// Write writes to the log file, rotating it first if the write would take it
+// beyond the maximum size
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.config.Enabled() && w.size > 0 && w.size+int64(len(p)) > w.config.MaxSize {
+		if err := w.file.Close(); err != nil {
+			return 0, err
+		}
+		// A log file which fails to be rotated is reopened and written
+		// to as is, rather than losing the log entries
+		RotateFile(w.path, w.config)
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close closes the log file
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
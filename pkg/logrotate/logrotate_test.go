@@ -0,0 +1,105 @@
+package logrotate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gluster/glusterd2/tests"
+)
+
+// TestWriterRotation validates that a log file is rotated before a write
+// which would take it beyond the maximum size, and that writes aren't split
+func TestWriterRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrotate")
+	tests.Assert(t, err == nil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "glusterd2.log")
+
+	for _, tc := range []struct {
+		name    string
+		maxSize int64
+		writes  []string
+		backups int
+		current string
+	}{
+		{"disabled", 0, []string{"12345678\n", "abcdefgh\n"}, 0, "12345678\nabcdefgh\n"},
+		{"under the size", 20, []string{"12345678\n", "abcdefgh\n"}, 0, "12345678\nabcdefgh\n"},
+		{"beyond the size", 10, []string{"12345678\n", "abc\n"}, 1, "abc\n"},
+		{"every write", 4, []string{"12345678\n", "abcdefgh\n", "ijklmnop\n"}, 2, "ijklmnop\n"},
+	} {
+		w, err := NewWriter(path, Config{MaxSize: tc.maxSize})
+		tests.Assert(t, err == nil)
+		for _, s := range tc.writes {
+			// Backups are named by the millisecond they are rotated at
+			time.Sleep(2 * time.Millisecond)
+			n, err := w.Write([]byte(s))
+			tests.Assert(t, err == nil && n == len(s))
+		}
+		tests.Assert(t, w.Close() == nil)
+
+		found, err := backups(path)
+		tests.Assert(t, err == nil)
+		if len(found) != tc.backups {
+			t.Errorf("%s: got %d backups, expected %d", tc.name, len(found), tc.backups)
+		}
+		b, err := ioutil.ReadFile(path)
+		tests.Assert(t, err == nil)
+		if string(b) != tc.current {
+			t.Errorf("%s: log file has %q, expected %q", tc.name, b, tc.current)
+		}
+
+		for _, b := range found {
+			os.Remove(b.path)
+		}
+		os.Remove(path)
+	}
+}
+
+// TestRemoveOldBackups validates the retention of rotated files by count and
+// by age, newest first
+func TestRemoveOldBackups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrotate")
+	tests.Assert(t, err == nil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "glusterd2.log")
+
+	// Files named like backups, but which aren't, are kept
+	other := filepath.Join(dir, "glusterd2-old.log")
+	tests.Assert(t, ioutil.WriteFile(other, nil, 0600) == nil)
+
+	ages := []time.Duration{time.Hour, 3 * time.Hour, 2 * time.Hour, 5 * time.Hour}
+	for _, tc := range []struct {
+		name   string
+		config Config
+		kept   []time.Duration
+	}{
+		{"no limits", Config{}, []time.Duration{time.Hour, 2 * time.Hour, 3 * time.Hour, 5 * time.Hour}},
+		{"by count", Config{MaxBackups: 2}, []time.Duration{time.Hour, 2 * time.Hour}},
+		{"by age", Config{MaxAge: 4 * time.Hour}, []time.Duration{time.Hour, 2 * time.Hour, 3 * time.Hour}},
+		{"by both", Config{MaxBackups: 3, MaxAge: 150 * time.Minute}, []time.Duration{time.Hour, 2 * time.Hour}},
+	} {
+		now := time.Now()
+		for _, age := range ages {
+			tests.Assert(t, ioutil.WriteFile(backupName(path, now.Add(-age)), nil, 0600) == nil)
+		}
+
+		tests.Assert(t, removeOldBackups(path, tc.config) == nil)
+		found, err := backups(path)
+		tests.Assert(t, err == nil)
+		if len(found) != len(tc.kept) {
+			t.Errorf("%s: kept %d backups, expected %d", tc.name, len(found), len(tc.kept))
+		}
+		for i, b := range found {
+			if want := backupName(path, now.Add(-tc.kept[i])); b.path != want {
+				t.Errorf("%s: kept %s, expected %s", tc.name, b.path, want)
+			}
+			os.Remove(b.path)
+		}
+
+		_, err = os.Stat(other)
+		tests.Assert(t, err == nil)
+	}
+}
@@ -1,10 +1,12 @@
 package restclient
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"strings"
 
@@ -16,11 +18,38 @@ type Client struct {
 	baseURL  string
 	username string
 	password string
+	// token is sent with every request, for servers requiring
+	// authentication
+	token      string
+	httpClient *http.Client
 }
 
-// New creates new instance of Glusterd REST Client
+// unixScheme prefixes the base URLs of glusterd2 Unix domain sockets
+const unixScheme = "unix://"
+
+// New creates new instance of Glusterd REST Client. The base URL can be the
+// path of the REST socket of a local glusterd2, as unix:///path/to/socket.
 func New(baseURL string, username string, password string) *Client {
-	return &Client{baseURL, username, password}
+	c := &Client{baseURL: baseURL, username: username, password: password, httpClient: http.DefaultClient}
+	if strings.HasPrefix(baseURL, unixScheme) {
+		socket := strings.TrimPrefix(baseURL, unixScheme)
+		c.httpClient = &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		}
+		// The host is not used to connect
+		c.baseURL = "http://localhost"
+	}
+	return c
+}
+
+// SetToken sets the token sent with the requests, issued by /v1/auth/token
+func (c *Client) SetToken(token string) {
+	c.token = token
 }
 
 func parseHTTPError(jsonData []byte) string {
@@ -66,7 +95,10 @@ func (c *Client) do(method string, url string, data interface{}, expectStatusCod
 	}
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
-	resp, err1 := http.DefaultClient.Do(req)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err1 := c.httpClient.Do(req)
 	if err1 != nil {
 		return err1
 	}
@@ -77,7 +109,7 @@ func (c *Client) do(method string, url string, data interface{}, expectStatusCod
 		return err2
 	}
 	if resp.StatusCode != expectStatusCode {
-		return &UnexpectedStatusError{"Unexpected Status", expectStatusCode, resp.StatusCode, parseHTTPError(outputRaw)}
+		return &UnexpectedStatusError{"Unexpected Status", expectStatusCode, resp.StatusCode, parseHTTPError(outputRaw), resp.Header.Get("X-Request-ID")}
 	}
 
 	if output != nil {
@@ -11,8 +11,14 @@ type UnexpectedStatusError struct {
 	expected int
 	actual   int
 	resp     string
+	// reqID is the ID the server gave the request, which its logs on
+	// all the nodes have
+	reqID string
 }
 
 func (e *UnexpectedStatusError) Error() string {
-	return fmt.Sprintf("%s (expected=%d actual=%d)", e.resp, e.expected, e.actual)
+	if e.reqID == "" {
+		return fmt.Sprintf("%s (expected=%d actual=%d)", e.resp, e.expected, e.actual)
+	}
+	return fmt.Sprintf("%s (expected=%d actual=%d request-id=%s)", e.resp, e.expected, e.actual, e.reqID)
 }
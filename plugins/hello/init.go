@@ -23,17 +23,19 @@ func (p *Plugin) SunRPCProgram() sunrpc.Program {
 func (p *Plugin) RestRoutes() route.Routes {
 	return route.Routes{
 		route.Route{
-			Name:        "HelloGet",
-			Method:      "GET",
-			Pattern:     "/hello",
-			Version:     1,
-			HandlerFunc: helloGetHandler},
+			Name:         "HelloGet",
+			Method:       "GET",
+			Pattern:      "/hello",
+			Version:      1,
+			HandlerFunc:  helloGetHandler,
+			ResponseType: ""},
 		route.Route{
-			Name:        "HelloPost",
-			Method:      "POST",
-			Pattern:     "/hello",
-			Version:     1,
-			HandlerFunc: helloPostHandler},
+			Name:         "HelloPost",
+			Method:       "POST",
+			Pattern:      "/hello",
+			Version:      1,
+			HandlerFunc:  helloPostHandler,
+			ResponseType: ""},
 	}
 }
 
@@ -5,6 +5,7 @@ import (
 	"github.com/gluster/glusterd2/servers/sunrpc"
 
 	log "github.com/Sirupsen/logrus"
+	config "github.com/spf13/viper"
 	"github.com/thejerf/suture"
 )
 
@@ -17,7 +18,11 @@ func New() *suture.Supervisor {
 
 	m := newMuxSrv()
 
-	s.Add(rest.NewMuxed(m.m))
+	// With rest-socket-only the ReST API is reachable only over the Unix
+	// socket
+	if !config.GetBool("rest-socket-only") {
+		s.Add(rest.NewMuxed(m.m))
+	}
 	s.Add(sunrpc.NewMuxed(m.m))
 	s.Add(m)
 
@@ -7,6 +7,8 @@ import (
 	log "github.com/Sirupsen/logrus"
 	config "github.com/spf13/viper"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 // Server is the gRPC server
@@ -21,6 +23,8 @@ func New() *Server {
 		grpc.NewServer(),
 	}
 	registerServices(s.server)
+	// Used by peers to check pooled connections before reusing them
+	healthpb.RegisterHealthServer(s.server, health.NewServer())
 
 	return s
 }
@@ -0,0 +1,200 @@
+package peerrpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/prometheus/client_golang/prometheus"
+	config "github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	defaultPoolMaxIdle     = time.Minute
+	defaultPoolMaxLifetime = 10 * time.Minute
+
+	// Connections idle for longer than this are health checked before
+	// being reused
+	healthCheckAfter   = 5 * time.Second
+	healthCheckTimeout = 2 * time.Second
+)
+
+var (
+	poolConns = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "glusterd2_peerrpc_pool_connections",
+		Help: "Number of open connections in the peer RPC connection pool.",
+	})
+	poolHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "glusterd2_peerrpc_pool_hits_total",
+		Help: "Number of times a pooled peer RPC connection was reused.",
+	})
+	poolDials = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "glusterd2_peerrpc_pool_dials_total",
+		Help: "Number of new peer RPC connections made.",
+	})
+	poolEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "glusterd2_peerrpc_pool_evictions_total",
+		Help: "Number of peer RPC connections evicted from the pool.",
+	})
+
+	pool = &connPool{
+		conns:  make(map[string]*pooledConn),
+		byConn: make(map[*grpc.ClientConn]*pooledConn),
+	}
+)
+
+func init() {
+	prometheus.MustRegister(poolConns, poolHits, poolDials, poolEvictions)
+}
+
+type pooledConn struct {
+	conn     *grpc.ClientConn
+	created  time.Time
+	lastUsed time.Time
+	// refs is the number of users of the connection, the connection is
+	// closed on eviction only after all users are done with it
+	refs    int
+	evicted bool
+}
+
+// connPool is a pool of client connections to peer RPC servers, keyed by
+// the address of the server. gRPC multiplexes calls over a connection, so a
+// single connection is shared by all users of an address.
+type connPool struct {
+	sync.Mutex
+	conns map[string]*pooledConn
+	// byConn has all connections handed out, including those not pooled
+	byConn map[*grpc.ClientConn]*pooledConn
+}
+
+func poolMaxIdle() time.Duration {
+	if d := config.GetDuration("peer-rpc-pool-max-idle"); d > 0 {
+		return d
+	}
+	return defaultPoolMaxIdle
+}
+
+func poolMaxLifetime() time.Duration {
+	if d := config.GetDuration("peer-rpc-pool-max-lifetime"); d > 0 {
+		return d
+	}
+	return defaultPoolMaxLifetime
+}
+
+// release closes the connection if it has been evicted and has no users left.
+// Must be called with the pool locked.
+func (p *connPool) release(pc *pooledConn) {
+	if pc.evicted && pc.refs == 0 {
+		delete(p.byConn, pc.conn)
+		pc.conn.Close()
+	}
+}
+
+// evict removes the connection from the pool. Must be called with the pool
+// locked.
+func (p *connPool) evict(address string, pc *pooledConn) {
+	if pc.evicted {
+		return
+	}
+	if p.conns[address] == pc {
+		delete(p.conns, address)
+		poolConns.Dec()
+	}
+	pc.evicted = true
+	poolEvictions.Inc()
+	p.release(pc)
+}
+
+// sweep evicts connections which have been idle or alive for too long. Must
+// be called with the pool locked.
+func (p *connPool) sweep() {
+	now := time.Now()
+	for address, pc := range p.conns {
+		if pc.refs > 0 {
+			continue
+		}
+		if now.Sub(pc.lastUsed) > poolMaxIdle() || now.Sub(pc.created) > poolMaxLifetime() {
+			p.evict(address, pc)
+		}
+	}
+}
+
+func isHealthy(conn *grpc.ClientConn) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+
+	_, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	// Peers running older versions may not have the health service
+	return err == nil || grpc.Code(err) == codes.Unimplemented
+}
+
+// GetConn returns a client connection to the peer RPC server at the given
+// address, reusing a pooled connection if possible. The connection must be
+// returned with PutConn after use, and must not be closed by the caller.
+func GetConn(address string) (*grpc.ClientConn, error) {
+	pool.Lock()
+	pool.sweep()
+
+	if pc, ok := pool.conns[address]; ok {
+		pc.refs++
+		idle := time.Since(pc.lastUsed)
+		pool.Unlock()
+
+		if idle < healthCheckAfter || isHealthy(pc.conn) {
+			poolHits.Inc()
+			return pc.conn, nil
+		}
+
+		log.WithField("address", address).Debug("pooled peer RPC connection is unhealthy, reconnecting")
+		pool.Lock()
+		pc.refs--
+		pool.evict(address, pc)
+	}
+	pool.Unlock()
+
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	poolDials.Inc()
+
+	pool.Lock()
+	defer pool.Unlock()
+
+	pc := &pooledConn{conn: conn, created: time.Now(), lastUsed: time.Now(), refs: 1}
+	pool.byConn[conn] = pc
+	if _, ok := pool.conns[address]; ok {
+		// Another user connected to the same address meanwhile, so
+		// this connection is used just once without pooling it
+		pc.evicted = true
+	} else {
+		pool.conns[address] = pc
+		poolConns.Inc()
+	}
+
+	return conn, nil
+}
+
+// PutConn returns a connection obtained from GetConn to the pool. err is the
+// error, if any, from the calls made on the connection; a connection which
+// failed is evicted from the pool.
+func PutConn(address string, conn *grpc.ClientConn, err error) {
+	pool.Lock()
+	defer pool.Unlock()
+
+	pc, ok := pool.byConn[conn]
+	if !ok {
+		return
+	}
+
+	pc.refs--
+	pc.lastUsed = time.Now()
+	if err != nil {
+		pool.evict(address, pc)
+	}
+	pool.release(pc)
+}
@@ -0,0 +1,256 @@
+package rest
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gluster/glusterd2/pkg/api"
+	"github.com/gluster/glusterd2/servers/rest/route"
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/version"
+)
+
+// apiSpecPath serves the OpenAPI specification of the REST API
+const apiSpecPath = "/api-spec"
+
+var (
+	pathParamRe = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// apiSchema is the schema of a JSON value, in OpenAPI 2.0
+type apiSchema struct {
+	Type                 string                `json:"type,omitempty"`
+	Format               string                `json:"format,omitempty"`
+	Ref                  string                `json:"$ref,omitempty"`
+	Items                *apiSchema            `json:"items,omitempty"`
+	Properties           map[string]*apiSchema `json:"properties,omitempty"`
+	AdditionalProperties *apiSchema            `json:"additionalProperties,omitempty"`
+}
+
+type apiParameter struct {
+	Name        string     `json:"name"`
+	In          string     `json:"in"`
+	Description string     `json:"description,omitempty"`
+	Required    bool       `json:"required"`
+	Type        string     `json:"type,omitempty"`
+	Schema      *apiSchema `json:"schema,omitempty"`
+}
+
+type apiHeader struct {
+	Description string `json:"description"`
+	Type        string `json:"type"`
+}
+
+type apiResponse struct {
+	Description string               `json:"description"`
+	Schema      *apiSchema           `json:"schema,omitempty"`
+	Headers     map[string]apiHeader `json:"headers,omitempty"`
+}
+
+type apiOperation struct {
+	OperationID string                 `json:"operationId"`
+	Parameters  []apiParameter         `json:"parameters,omitempty"`
+	Responses   map[string]apiResponse `json:"responses"`
+}
+
+type apiInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// APISpec is the OpenAPI 2.0 (Swagger) specification of the REST API
+type APISpec struct {
+	Swagger     string                              `json:"swagger"`
+	Info        apiInfo                             `json:"info"`
+	Produces    []string                            `json:"produces"`
+	Consumes    []string                            `json:"consumes"`
+	Paths       map[string]map[string]*apiOperation `json:"paths"`
+	Definitions map[string]*apiSchema               `json:"definitions"`
+}
+
+// newAPISpec returns an empty specification of the REST API
+func newAPISpec() *APISpec {
+	return &APISpec{
+		Swagger:     "2.0",
+		Info:        apiInfo{Title: "GlusterD2 REST API", Version: strconv.Itoa(version.APIVersion)},
+		Produces:    []string{"application/json"},
+		Consumes:    []string{"application/json"},
+		Paths:       make(map[string]map[string]*apiOperation),
+		Definitions: make(map[string]*apiSchema),
+	}
+}
+
+// schemaOf returns the schema of the JSON encoding of values of type t.
+// Structs are added to the definitions of the specification, and referred
+// to by their package qualified name.
+func (s *APISpec) schemaOf(t reflect.Type) *apiSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// Types encoding themselves are described by what their zero value
+	// encodes to
+	if t.Implements(jsonMarshalerType) {
+		if b, err := json.Marshal(reflect.Zero(t).Interface()); err == nil && len(b) > 0 {
+			switch b[0] {
+			case '"':
+				return &apiSchema{Type: "string"}
+			case '{':
+				return &apiSchema{Type: "object"}
+			case '[':
+				return &apiSchema{Type: "array", Items: &apiSchema{}}
+			case 't', 'f':
+				return &apiSchema{Type: "boolean"}
+			case 'n':
+				return &apiSchema{}
+			}
+			return &apiSchema{Type: "number"}
+		}
+		return &apiSchema{}
+	}
+	if t.Implements(textMarshalerType) {
+		return &apiSchema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &apiSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &apiSchema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &apiSchema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &apiSchema{Type: "number"}
+	case reflect.String:
+		return &apiSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Encoded as base64
+			return &apiSchema{Type: "string", Format: "byte"}
+		}
+		return &apiSchema{Type: "array", Items: s.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &apiSchema{Type: "object", AdditionalProperties: s.schemaOf(t.Elem())}
+	case reflect.Struct:
+		name := path.Base(t.PkgPath()) + "." + t.Name()
+		if t.Name() == "" {
+			// Anonymous structs are described inline
+			return s.structSchema(t)
+		}
+		if _, ok := s.Definitions[name]; !ok {
+			// Added before describing the fields, for types referring
+			// to themselves
+			s.Definitions[name] = &apiSchema{Type: "object"}
+			s.Definitions[name] = s.structSchema(t)
+		}
+		return &apiSchema{Ref: "#/definitions/" + name}
+	}
+	return &apiSchema{}
+}
+
+// structSchema describes the JSON fields of a struct like encoding/json
+// encodes them, with the fields of embedded structs promoted
+func (s *APISpec) structSchema(t reflect.Type) *apiSchema {
+	schema := &apiSchema{Type: "object", Properties: make(map[string]*apiSchema)}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for n, p := range s.structSchema(ft).Properties {
+				if _, ok := schema.Properties[n]; !ok {
+					schema.Properties[n] = p
+				}
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			// Unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		schema.Properties[name] = s.schemaOf(f.Type)
+	}
+	return schema
+}
+
+// addRoute adds a route, served at urlPattern, to the specification
+func (s *APISpec) addRoute(r route.Route, urlPattern string) {
+	op := &apiOperation{
+		OperationID: r.Name,
+		Responses: map[string]apiResponse{
+			"default": {Description: "error", Schema: s.schemaOf(reflect.TypeOf(api.HTTPError{}))},
+		},
+	}
+
+	for _, m := range pathParamRe.FindAllStringSubmatch(urlPattern, -1) {
+		op.Parameters = append(op.Parameters, apiParameter{Name: m[1], In: "path", Required: true, Type: "string"})
+	}
+	if r.RequestType != nil {
+		op.Parameters = append(op.Parameters, apiParameter{
+			Name:     "body",
+			In:       "body",
+			Required: true,
+			Schema:   s.schemaOf(reflect.TypeOf(r.RequestType)),
+		})
+	}
+	if r.Paginated {
+		op.Parameters = append(op.Parameters,
+			apiParameter{Name: "limit", In: "query", Type: "integer",
+				Description: "maximum number of items of the page"},
+			apiParameter{Name: "offset", In: "query", Type: "integer",
+				Description: "number of items to skip before the page"},
+			apiParameter{Name: "continue", In: "query", Type: "string",
+				Description: "token of the previous page, sent in its " + restutils.ContinueHeader + " header"})
+	}
+
+	status := r.ResponseStatus
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp := apiResponse{Description: http.StatusText(status)}
+	if r.ResponseType != nil {
+		resp.Schema = s.schemaOf(reflect.TypeOf(r.ResponseType))
+	}
+	if r.Paginated {
+		resp.Headers = map[string]apiHeader{
+			restutils.TotalCountHeader: {Type: "integer",
+				Description: "total number of items of the list, sent with pages of the list"},
+			restutils.ContinueHeader: {Type: "string",
+				Description: "token to get the next page with, if there are items after the page"},
+		}
+	}
+	op.Responses[strconv.Itoa(status)] = resp
+
+	// OpenAPI has path parameters without their regular expressions
+	p := pathParamRe.ReplaceAllString(urlPattern, "{$1}")
+	if s.Paths[p] == nil {
+		s.Paths[p] = make(map[string]*apiOperation)
+	}
+	s.Paths[p][strings.ToLower(r.Method)] = op
+}
+
+// apiSpecHandler serves the specification of the routes of the server
+func (r *GDRest) apiSpecHandler(w http.ResponseWriter, req *http.Request) {
+	restutils.SendHTTPResponse(w, http.StatusOK, r.spec)
+}
@@ -11,19 +11,35 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
 	"github.com/soheilhy/cmux"
+	config "github.com/spf13/viper"
 )
 
 // GDRest is the GlusterD Rest server
 type GDRest struct {
 	Routes   *mux.Router
 	listener net.Listener
+	// socketPath is the path of the Unix domain socket listened on, if
+	// the server listens on one
+	socketPath string
+	// tls is true if the server serves HTTPS
+	tls bool
+	// spec is the API specification of the routes served
+	spec *APISpec
 }
 
 // New returns a GDRest object which can listen on the configured address
 func New(l net.Listener) *GDRest {
+	return newGDRest(l, "")
+}
+
+// newGDRest returns a GDRest object serving on l, which is listening on the
+// Unix domain socket at socketPath if given
+func newGDRest(l net.Listener, socketPath string) *GDRest {
 	rest := &GDRest{
-		mux.NewRouter(),
-		l,
+		Routes:     mux.NewRouter(),
+		listener:   l,
+		socketPath: socketPath,
+		spec:       newAPISpec(),
 	}
 
 	rest.registerRoutes()
@@ -31,16 +47,49 @@ func New(l net.Listener) *GDRest {
 	return rest
 }
 
-// NewMuxed returns a GDRest object which listens on a CMux multiplexed connection
+// NewMuxed returns a GDRest object which listens on a CMux multiplexed
+// connection. The server terminates TLS itself if a certificate is
+// configured.
 func NewMuxed(m cmux.CMux) *GDRest {
-	return New(m.Match(cmux.HTTP1Fast()))
+	if !TLSEnabled() {
+		return New(m.Match(cmux.HTTP1Fast()))
+	}
+
+	l, err := newTLSListener(m.Match(cmux.TLS()))
+	if err != nil {
+		log.WithError(err).Fatal("failed to create ReST TLS listener")
+	}
+	rest := New(l)
+	rest.tls = true
+	return rest
 }
 
 // Serve begins serving client HTTP requests served by REST server
 func (r *GDRest) Serve() {
-	chain := alice.New(middleware.LogRequest, middleware.ReqIDGenerator).Then(r.Routes)
-	log.WithField("ip:port", r.listener.Addr().String()).Info("Started GlusterD ReST server")
-	if err := http.Serve(r.listener, chain); err != nil {
+	chain := alice.New(middleware.ReqIDGenerator, middleware.LogRequest, middleware.ServerRateLimit, middleware.RequestDeadline, negotiateAPIVersion)
+	// Clients of the Unix socket were checked by their credentials when
+	// they connected, which must be known before their requests are
+	// forwarded to the leader
+	if r.socketPath != "" {
+		chain = chain.Append(middleware.SocketClient)
+	}
+	handler := chain.Append(middleware.LeaderRedirect).Then(r.Routes)
+	// The timeouts keep a few slow or idle clients from holding all the
+	// connections open. Requests outlasting the write timeout have their
+	// connection closed, so those expected to take long should be served
+	// asynchronously.
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: config.GetDuration("rest-read-header-timeout"),
+		ReadTimeout:       config.GetDuration("rest-read-timeout"),
+		WriteTimeout:      config.GetDuration("rest-write-timeout"),
+		IdleTimeout:       config.GetDuration("rest-idle-timeout"),
+	}
+	log.WithFields(log.Fields{
+		"ip:port": r.listener.Addr().String(),
+		"tls":     r.tls,
+	}).Info("Started GlusterD ReST server")
+	if err := srv.Serve(r.listener); err != nil {
 		//TODO: Correctly handle valid errors. We could also be having errors when stopping
 		log.WithError(err).Error("GlusterD ReST server failed")
 	}
@@ -51,5 +100,11 @@ func (r *GDRest) Serve() {
 func (r *GDRest) Stop() {
 	log.Debug("stopping the GlusterD ReST server")
 	// TODO: Graceful shutdown here
+	if r.socketPath != "" {
+		// Closing the listener of a Unix socket removes the socket file
+		if err := r.listener.Close(); err != nil {
+			log.WithError(err).WithField("socket", r.socketPath).Error("failed to close ReST socket listener")
+		}
+	}
 	log.Info("stopped GlusterD ReST server")
 }
@@ -5,6 +5,17 @@ import (
 	"net/http"
 )
 
+// Roles of the identities of REST requests, each allowed all that the roles
+// after it are
+const (
+	// RoleAdmin can manage the access to the cluster
+	RoleAdmin = "admin"
+	// RoleOperator can change the cluster and its volumes
+	RoleOperator = "operator"
+	// RoleViewer can only read the state of the cluster
+	RoleViewer = "viewer"
+)
+
 // Route models a route to be set on the GlusterD Rest server
 // This route style comes from the tutorial on
 // http://thenewstack.io/make-a-restful-json-api-go/
@@ -14,6 +25,24 @@ type Route struct {
 	Pattern     string
 	Version     int
 	HandlerFunc http.HandlerFunc
+	// Middleware are applied to the handler of the route alone, in the
+	// given order, such as a rate limit stricter than the default one
+	Middleware []func(http.Handler) http.Handler
+	// Role is the role required to request the route, if REST API
+	// authentication is enabled. By default read requests need a viewer
+	// and others an operator.
+	Role string
+	// RequestType and ResponseType are values of the types of the request
+	// and response bodies of the route, which its API specification is
+	// generated from. ResponseStatus is the status of a successful
+	// response, 200 if not given.
+	RequestType    interface{}
+	ResponseType   interface{}
+	ResponseStatus int
+	// Paginated routes send lists which can be paginated with the limit,
+	// offset and continue query parameters, along with the total number of
+	// items of the list in the X-Total-Count header
+	Paginated bool
 }
 
 // Routes is a table of many Route's
@@ -2,10 +2,13 @@ package rest
 
 import (
 	"fmt"
+	"net/http"
 
 	"github.com/gluster/glusterd2/commands"
+	"github.com/gluster/glusterd2/middleware"
 	"github.com/gluster/glusterd2/plugins"
 	"github.com/gluster/glusterd2/servers/rest/route"
+	"github.com/gluster/glusterd2/version"
 
 	log "github.com/Sirupsen/logrus"
 )
@@ -14,22 +17,63 @@ import (
 func (r *GDRest) setRoutes(routes route.Routes) {
 	for _, route := range routes {
 		var urlPattern string
-		if route.Name == "GetVersion" {
+		// Routes without a version, like /version and /metrics, are
+		// not under a versioned prefix
+		if route.Version == 0 {
 			urlPattern = route.Pattern
 		} else {
+			if route.Version < version.MinAPIVersion || route.Version > version.APIVersion {
+				log.WithFields(log.Fields{
+					"name":    route.Name,
+					"version": route.Version,
+				}).Fatal("route has an unsupported API version")
+			}
 			urlPattern = fmt.Sprintf("/v%d%s", route.Version, route.Pattern)
 		}
+		r.spec.addRoute(route, urlPattern)
 		log.WithFields(log.Fields{
 			"name":   route.Name,
 			"path":   urlPattern,
 			"method": route.Method,
 		}).Debug("Registering new route")
 
+		var handler http.Handler = route.HandlerFunc
+		for i := len(route.Middleware) - 1; i >= 0; i-- {
+			handler = route.Middleware[i](handler)
+		}
+		handler = middleware.ConditionalGet(route.Method)(handler)
+		handler = middleware.LimitTransactions(route.Method, urlPattern)(handler)
+		handler = middleware.Async(route.Method)(handler)
+		// Clients of the Unix socket are checked by their credentials
+		// when they connect instead
+		if r.socketPath == "" {
+			handler = middleware.Authenticate(route.Method, urlPattern, route.Role)(handler)
+		}
+		handler = middleware.DefaultRateLimit(route.Name, route.Method, urlPattern)(handler)
+
+		if route.Version == 0 {
+			r.Routes.
+				Methods(route.Method).
+				Path(urlPattern).
+				Name(route.Name).
+				Handler(handler)
+			continue
+		}
+
+		handler = withAPIVersion(route.Version, handler)
 		r.Routes.
 			Methods(route.Method).
 			Path(urlPattern).
 			Name(route.Name).
-			Handler(route.HandlerFunc)
+			Handler(handler)
+		// The route is also served without the version prefix, to
+		// clients negotiating its version with the X-Gluster-API-Version
+		// or Accept headers
+		r.Routes.
+			Methods(route.Method).
+			Path(route.Pattern).
+			MatcherFunc(apiVersionMatcher(route.Version)).
+			Handler(handler)
 	}
 }
 
@@ -50,4 +94,14 @@ func (r *GDRest) registerRoutes() {
 		}
 		p.RegisterStepFuncs()
 	}
+
+	r.setRoutes(route.Routes{
+		route.Route{
+			Name:         "APISpec",
+			Method:       "GET",
+			Pattern:      apiSpecPath,
+			HandlerFunc:  r.apiSpecHandler,
+			ResponseType: APISpec{},
+		},
+	})
 }
@@ -0,0 +1,126 @@
+package rest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	config "github.com/spf13/viper"
+)
+
+// tlsVersions are the minimum TLS versions rest-tls-min-version can be
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+}
+
+// tlsConfigs holds the TLS configuration of the ReST server, which is
+// replaced when the certificates are reloaded. Connections already
+// established keep on with the configuration they were accepted with.
+type tlsConfigs struct {
+	sync.RWMutex
+	current *tls.Config
+}
+
+var restTLS tlsConfigs
+
+func (c *tlsConfigs) get(*tls.ClientHelloInfo) (*tls.Config, error) {
+	c.RLock()
+	defer c.RUnlock()
+	return c.current, nil
+}
+
+func (c *tlsConfigs) set(cfg *tls.Config) {
+	c.Lock()
+	defer c.Unlock()
+	c.current = cfg
+}
+
+// TLSEnabled returns true if the ReST server on the client address is
+// configured to serve HTTPS
+func TLSEnabled() bool {
+	return config.GetString("rest-cert-file") != ""
+}
+
+// ParseTLSVersion returns the TLS version given as 1.0, 1.1 or 1.2
+func ParseTLSVersion(version string) (uint16, error) {
+	v, ok := tlsVersions[version]
+	if !ok {
+		return 0, fmt.Errorf("invalid TLS version %s, must be one of 1.0, 1.1 or 1.2", version)
+	}
+	return v, nil
+}
+
+// loadTLSConfig reads the configured certificate, key and CA bundle of the
+// ReST server. Clients must present a certificate signed by the CA bundle if
+// one is given.
+func loadTLSConfig() (*tls.Config, error) {
+	minVersion, err := ParseTLSVersion(config.GetString("rest-tls-min-version"))
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(config.GetString("rest-cert-file"), config.GetString("rest-key-file"))
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+	}
+
+	if caFile := config.GetString("rest-ca-file"); caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// ValidateTLSConfig checks that the configured certificate, key and CA bundle
+// of the ReST server can be loaded
+func ValidateTLSConfig() error {
+	if !TLSEnabled() {
+		return nil
+	}
+	_, err := loadTLSConfig()
+	return err
+}
+
+// ReloadTLS reloads the certificate, key and CA bundle of the ReST server,
+// to have certificates renewed without a restart. If they fail to load, the
+// ones loaded before are kept.
+func ReloadTLS() error {
+	if !TLSEnabled() {
+		return nil
+	}
+	cfg, err := loadTLSConfig()
+	if err != nil {
+		return err
+	}
+	restTLS.set(cfg)
+	log.WithField("cert", config.GetString("rest-cert-file")).Info("reloaded ReST server TLS certificates")
+	return nil
+}
+
+// newTLSListener returns a listener terminating TLS on the connections of l
+func newTLSListener(l net.Listener) (net.Listener, error) {
+	cfg, err := loadTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	restTLS.set(cfg)
+	return tls.NewListener(l, &tls.Config{GetConfigForClient: restTLS.get}), nil
+}
@@ -0,0 +1,168 @@
+package rest
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gluster/glusterd2/utils"
+
+	log "github.com/Sirupsen/logrus"
+	config "github.com/spf13/viper"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// socketDirMode and socketMode restrict the REST socket to the user
+	// glusterd2 runs as, and its group
+	socketDirMode = 0750
+	socketMode    = 0660
+)
+
+// removeStaleSocket removes the socket file left behind by an earlier
+// glusterd2 which didn't shut down cleanly. A socket which is still being
+// listened on is not removed.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	return os.Remove(path)
+}
+
+// listenUnix listens on a Unix domain socket at the given path, creating its
+// directory if needed
+func listenUnix(path string) (net.Listener, error) {
+	dir := filepath.Dir(path)
+	// A directory which already exists is left with its permissions
+	if err := os.MkdirAll(dir, os.ModeDir|socketDirMode); err != nil {
+		return nil, err
+	}
+	if err := utils.InitDir(dir); err != nil {
+		return nil, err
+	}
+
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, socketMode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// socketUIDs returns the users allowed to connect to the REST socket: root,
+// the user glusterd2 runs as and those given in rest-socket-users, by name or
+// by ID
+func socketUIDs() (map[uint32]bool, error) {
+	uids := map[uint32]bool{
+		0:                    true,
+		uint32(os.Geteuid()): true,
+	}
+	for _, name := range config.GetStringSlice("rest-socket-users") {
+		u, err := user.Lookup(name)
+		if err != nil {
+			if u, err = user.LookupId(name); err != nil {
+				return nil, fmt.Errorf("unknown rest-socket-users user %s", name)
+			}
+		}
+		uid, err := strconv.ParseUint(u.Uid, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		uids[uint32(uid)] = true
+	}
+	return uids, nil
+}
+
+// ValidateSocketUsers checks that the users of rest-socket-users exist
+func ValidateSocketUsers() error {
+	_, err := socketUIDs()
+	return err
+}
+
+// peerCredentials returns the credentials of the process at the other end of
+// a Unix socket connection, as they were when it connected
+func peerCredentials(conn net.Conn) (*unix.Ucred, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, fmt.Errorf("not a Unix socket connection")
+	}
+	// The credentials are read on a duplicate of the descriptor of the
+	// connection, which is closed straight after
+	f, err := uc.File()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return unix.GetsockoptUcred(int(f.Fd()), unix.SOL_SOCKET, unix.SO_PEERCRED)
+}
+
+// credListener accepts the connections of the REST socket whose processes
+// run as one of the allowed users, and closes the others. The clients of the
+// socket are trusted as the administrators of the cluster, and their requests
+// don't need a token.
+type credListener struct {
+	net.Listener
+	uids map[uint32]bool
+}
+
+func (l *credListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		cred, err := peerCredentials(conn)
+		if err != nil {
+			log.WithError(err).Warn("failed to get the credentials of a ReST socket client")
+			conn.Close()
+			continue
+		}
+		if !l.uids[cred.Uid] {
+			log.WithFields(log.Fields{
+				"uid": cred.Uid,
+				"pid": cred.Pid,
+			}).Warn("ReST socket client is not an allowed user, closing its connection")
+			conn.Close()
+			continue
+		}
+		return conn, nil
+	}
+}
+
+// NewUnix returns a GDRest object which listens on a Unix domain socket at
+// the given path. The socket file is removed when the server is stopped.
+func NewUnix(path string) *GDRest {
+	uids, err := socketUIDs()
+	if err != nil {
+		log.WithError(err).Fatal("failed to find the users allowed on the ReST socket")
+	}
+	l, err := listenUnix(path)
+	if err != nil {
+		log.WithError(err).WithField("socket", path).Fatal("failed to create ReST socket listener")
+	}
+
+	return newGDRest(&credListener{Listener: l, uids: uids}, path)
+}
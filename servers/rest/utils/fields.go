@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// jsonFields adds the names of the JSON fields of the struct type t to names,
+// by their lower case name. Fields of embedded structs are promoted like
+// encoding/json does.
+func jsonFields(t reflect.Type, names map[string]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			jsonFields(ft, names)
+			continue
+		}
+		if f.PkgPath != "" {
+			// Unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names[strings.ToLower(name)] = name
+	}
+}
+
+// GetFields parses the fields query parameter of a request, a comma separated
+// list of the JSON fields of rsp to send in the response. rsp is the response
+// which is to be sent, a struct or a list of structs. The field names are
+// matched case insensitively, and returned as named in the JSON encoding. nil
+// is returned if the request doesn't ask for fields.
+func GetFields(r *http.Request, rsp interface{}) ([]string, error) {
+	v := r.URL.Query().Get("fields")
+	if v == "" {
+		return nil, nil
+	}
+
+	t := reflect.TypeOf(rsp)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.New("fields can't be selected in this response")
+	}
+
+	names := make(map[string]string)
+	jsonFields(t, names)
+
+	var fields []string
+	for _, f := range strings.Split(v, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		name, ok := names[strings.ToLower(f)]
+		if !ok {
+			var valid []string
+			for _, n := range names {
+				valid = append(valid, n)
+			}
+			sort.Strings(valid)
+			return nil, fmt.Errorf("unknown field %s, valid fields are %s", f, strings.Join(valid, ","))
+		}
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+func projectObject(obj map[string]json.RawMessage, fields []string) map[string]json.RawMessage {
+	projected := make(map[string]json.RawMessage)
+	for _, f := range fields {
+		if v, ok := obj[f]; ok {
+			projected[f] = v
+		}
+	}
+	return projected
+}
+
+// Project returns the response projected to only the given JSON fields, as
+// returned by GetFields. The response is returned as is if no fields are
+// given.
+func Project(rsp interface{}, fields []string) interface{} {
+	if len(fields) == 0 {
+		return rsp
+	}
+
+	b, err := json.Marshal(rsp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response for projection")
+		return rsp
+	}
+
+	var list []map[string]json.RawMessage
+	if err := json.Unmarshal(b, &list); err == nil {
+		for i := range list {
+			list[i] = projectObject(list[i], fields)
+		}
+		return list
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(b, &obj); err == nil {
+		return projectObject(obj, fields)
+	}
+	return rsp
+}
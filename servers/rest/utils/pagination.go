@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// TotalCountHeader is the response header carrying the total number
+	// of items of a paginated list
+	TotalCountHeader = "X-Total-Count"
+	// ContinueHeader is the response header carrying the token to get the
+	// next page of a list with, if there are items after the page
+	ContinueHeader = "X-Continue"
+)
+
+// Pagination represents the limit and offset query parameters of a request
+// for a list. A Limit of 0 means there is no limit. After is the key of the
+// last item of the previous page, given as the continue token of that page,
+// which is an alternative to Offset that doesn't skip or repeat items if the
+// list changes between pages.
+type Pagination struct {
+	Limit  int
+	Offset int
+	After  string
+}
+
+// GetPagination parses the limit, offset and continue query parameters of a
+// request
+func GetPagination(r *http.Request) (*Pagination, error) {
+	var p Pagination
+	var err error
+
+	q := r.URL.Query()
+	if v := q.Get("limit"); v != "" {
+		if p.Limit, err = strconv.Atoi(v); err != nil || p.Limit < 0 {
+			return nil, errors.New("invalid limit")
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if p.Offset, err = strconv.Atoi(v); err != nil || p.Offset < 0 {
+			return nil, errors.New("invalid offset")
+		}
+	}
+	if v := q.Get("continue"); v != "" {
+		after, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil || len(after) == 0 {
+			return nil, errors.New("invalid continue token")
+		}
+		if p.Offset > 0 {
+			return nil, errors.New("only one of offset or continue can be given")
+		}
+		p.After = string(after)
+	}
+
+	return &p, nil
+}
+
+// Paginated returns true if the request asked for a page of the list
+func (p *Pagination) Paginated() bool {
+	return p.Limit > 0 || p.Offset > 0 || p.After != ""
+}
+
+// SetContinue sends the continue token of a page whose last item has the
+// given key, if there are items after the page
+func (p *Pagination) SetContinue(w http.ResponseWriter, last string, more bool) {
+	if more && last != "" {
+		w.Header().Set(ContinueHeader, base64.RawURLEncoding.EncodeToString([]byte(last)))
+	}
+}
+
+// Bounds returns the start and end indices of the page within a list of
+// total items, to be used as list[start:end]
+func (p *Pagination) Bounds(total int) (int, int) {
+	start := p.Offset
+	if start > total {
+		start = total
+	}
+	end := total
+	if p.Limit > 0 && start+p.Limit < total {
+		end = start + p.Limit
+	}
+	return start, end
+}
@@ -8,6 +8,10 @@ import (
 	log "github.com/Sirupsen/logrus"
 )
 
+// RequestIDHeader is the request and response header having the ID of a
+// request, which the transactions of the request are identified by
+const RequestIDHeader = "X-Request-ID"
+
 // APIError is the placeholder for error string to report back to the client
 type APIError struct {
 	Error string
@@ -31,6 +35,15 @@ func SendHTTPResponse(w http.ResponseWriter, statusCode int, rsp interface{}) {
 	return
 }
 
+// SendHTTPText sends a plain text response back to the client
+func SendHTTPText(w http.ResponseWriter, statusCode int, text string) {
+	w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+	w.WriteHeader(statusCode)
+	if _, e := w.Write([]byte(text)); e != nil {
+		log.WithField("error", e).Error("Failed to send the response")
+	}
+}
+
 // SendHTTPError is to report error back to the client
 func SendHTTPError(rw http.ResponseWriter, statusCode int, errMsg string) {
 	bytes, _ := json.Marshal(APIError{Error: errMsg})
@@ -41,6 +54,6 @@ func SendHTTPError(rw http.ResponseWriter, statusCode int, errMsg string) {
 // GetReqIDandLogger returns a request ID and a request-scoped logger having
 // the request ID as a logging field.
 func GetReqIDandLogger(r *http.Request) (string, *log.Entry) {
-	reqID := r.Header.Get("X-Request-ID")
+	reqID := r.Header.Get(RequestIDHeader)
 	return reqID, log.WithField("reqid", reqID)
 }
@@ -0,0 +1,70 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	restutils "github.com/gluster/glusterd2/servers/rest/utils"
+	"github.com/gluster/glusterd2/version"
+
+	"github.com/gorilla/mux"
+)
+
+// APIVersionHeader is the request header clients ask for an API version with,
+// and the response header telling the version a request was served with
+const APIVersionHeader = "X-Gluster-API-Version"
+
+// acceptVersionRe matches the media type asking for an API version in an
+// Accept header, like application/vnd.gluster.v1+json
+var acceptVersionRe = regexp.MustCompile(`application/vnd\.gluster\.v(\d+)\+json`)
+
+// requestedAPIVersion returns the API version the request asks for, either
+// with the X-Gluster-API-Version header or the Accept header. Requests not
+// asking for a version are served with the latest one.
+func requestedAPIVersion(r *http.Request) (int, error) {
+	s := r.Header.Get(APIVersionHeader)
+	if s == "" {
+		m := acceptVersionRe.FindStringSubmatch(r.Header.Get("Accept"))
+		if m == nil {
+			return version.APIVersion, nil
+		}
+		s = m[1]
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < version.MinAPIVersion || v > version.APIVersion {
+		return 0, fmt.Errorf("unsupported API version %s, supported versions are %v", s, version.APIVersions())
+	}
+	return v, nil
+}
+
+// negotiateAPIVersion rejects requests asking for an API version which isn't
+// served
+func negotiateAPIVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := requestedAPIVersion(r); err != nil {
+			restutils.SendHTTPError(w, http.StatusNotAcceptable, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiVersionMatcher matches requests on unversioned paths which asked for the
+// given API version, or for none if it is the latest one
+func apiVersionMatcher(v int) mux.MatcherFunc {
+	return func(r *http.Request, rm *mux.RouteMatch) bool {
+		requested, err := requestedAPIVersion(r)
+		return err == nil && requested == v
+	}
+}
+
+// withAPIVersion tells clients the API version of the responses of a route
+func withAPIVersion(v int, next http.Handler) http.Handler {
+	s := strconv.Itoa(v)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(APIVersionHeader, s)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -4,8 +4,10 @@ package servers
 import (
 	"github.com/gluster/glusterd2/servers/muxsrv"
 	"github.com/gluster/glusterd2/servers/peerrpc"
+	"github.com/gluster/glusterd2/servers/rest"
 
 	log "github.com/Sirupsen/logrus"
+	config "github.com/spf13/viper"
 	"github.com/thejerf/suture"
 )
 
@@ -18,6 +20,9 @@ func New() *suture.Supervisor {
 	s := suture.New("gd2-servers", suture.Spec{Log: logger})
 	s.Add(peerrpc.New()) // grpc
 	s.Add(muxsrv.New())  // sunrpc + http
+	if path := config.GetString("rest-socket"); path != "" {
+		s.Add(rest.NewUnix(path)) // http over a Unix socket
+	}
 
 	return s
 }
@@ -0,0 +1,186 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/mvcc/mvccpb"
+)
+
+// prefixCache holds the key-values stored under a prefix. The generation is
+// bumped on every invalidation, so that a fill racing with a change to the
+// prefix doesn't end up caching the old key-values.
+type prefixCache struct {
+	sync.Mutex
+	kvs   []*mvccpb.KeyValue
+	valid bool
+	gen   uint64
+}
+
+func (c *prefixCache) invalidate() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.kvs = nil
+	c.valid = false
+	c.gen++
+}
+
+func (s *GDStore) getCache(prefix string) *prefixCache {
+	s.cachesLock.Lock()
+	defer s.cachesLock.Unlock()
+
+	return s.caches[prefix]
+}
+
+// CachePrefix preloads the key-values stored under the given prefix into an
+// in-memory cache, which is kept up to date by watching the prefix for
+// changes. Reads of the prefix with GetPrefix are served from the cache.
+func (s *GDStore) CachePrefix(prefix string) error {
+	s.cachesLock.Lock()
+	if s.caches == nil {
+		s.caches = make(map[string]*prefixCache)
+	}
+	if _, ok := s.caches[prefix]; ok {
+		s.cachesLock.Unlock()
+		return nil
+	}
+	c := new(prefixCache)
+	s.caches[prefix] = c
+	s.cachesLock.Unlock()
+
+	// Start watching before the initial load, so that no change made in
+	// between is missed.
+	wch := s.Watch(s.Ctx(), prefix, clientv3.WithPrefix())
+	go func() {
+		for resp := range wch {
+			if resp.Err() != nil {
+				log.WithError(resp.Err()).WithField("prefix", prefix).Debug("store cache watch failed")
+				break
+			}
+			c.invalidate()
+			if _, err := s.fillCache(prefix, c); err != nil {
+				log.WithError(err).WithField("prefix", prefix).Debug("failed to refresh store cache")
+			}
+		}
+
+		// Without a watch the cache can't be trusted anymore, so stop
+		// caching the prefix altogether.
+		s.cachesLock.Lock()
+		delete(s.caches, prefix)
+		s.cachesLock.Unlock()
+		c.invalidate()
+	}()
+
+	_, err := s.fillCache(prefix, c)
+	return err
+}
+
+func (s *GDStore) fillCache(prefix string, c *prefixCache) ([]*mvccpb.KeyValue, error) {
+	c.Lock()
+	gen := c.gen
+	c.Unlock()
+
+	resp, err := s.Get(context.TODO(), prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if c.gen == gen {
+		c.kvs = resp.Kvs
+		c.valid = true
+	}
+
+	return resp.Kvs, nil
+}
+
+// InvalidateCache drops the cached key-values of the given prefix, if it is
+// being cached. This must be called after changing keys under a cached
+// prefix, so that reads following the change on this node don't have to
+// wait for the watch to catch up.
+func (s *GDStore) InvalidateCache(prefix string) {
+	if c := s.getCache(prefix); c != nil {
+		c.invalidate()
+	}
+}
+
+// GetPrefix returns the key-values stored under the given prefix. If the
+// prefix is cached, the key-values are served from the cache, falling back
+// to the store on a miss.
+func (s *GDStore) GetPrefix(prefix string) ([]*mvccpb.KeyValue, error) {
+	c := s.getCache(prefix)
+	if c == nil {
+		return s.GetPrefixUncached(prefix)
+	}
+
+	c.Lock()
+	if c.valid {
+		kvs := c.kvs
+		c.Unlock()
+		return kvs, nil
+	}
+	c.Unlock()
+
+	return s.fillCache(prefix, c)
+}
+
+// GetPrefixUncached returns the key-values stored under the given prefix, read
+// from the store even if the prefix is cached on this node
+func (s *GDStore) GetPrefixUncached(prefix string) ([]*mvccpb.KeyValue, error) {
+	resp, err := s.Get(context.TODO(), prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	return resp.Kvs, nil
+}
+
+// GetPrefixPage returns a page of the key-values stored under the given
+// prefix, in the order of their keys. The page starts after the key after,
+// given without the prefix, skipping the first skip key-values, and has up
+// to limit key-values, or all of them for a limit of 0. more is true if
+// there are key-values after the page. Only the page is read, and always
+// from the store, so that long lists don't have to be loaded whole.
+func (s *GDStore) GetPrefixPage(prefix, after string, skip, limit int) ([]*mvccpb.KeyValue, bool, error) {
+	start := prefix
+	if after != "" {
+		// The smallest key after the given one
+		start = prefix + after + "\x00"
+	}
+	end := clientv3.GetPrefixRangeEnd(prefix)
+
+	if skip > 0 {
+		resp, err := s.Get(context.TODO(), start, clientv3.WithRange(end),
+			clientv3.WithKeysOnly(), clientv3.WithLimit(int64(skip)))
+		if err != nil {
+			return nil, false, err
+		}
+		if len(resp.Kvs) < skip {
+			return nil, false, nil
+		}
+		start = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+
+	opts := []clientv3.OpOption{clientv3.WithRange(end)}
+	if limit > 0 {
+		opts = append(opts, clientv3.WithLimit(int64(limit)))
+	}
+	resp, err := s.Get(context.TODO(), start, opts...)
+	if err != nil {
+		return nil, false, err
+	}
+	return resp.Kvs, resp.More, nil
+}
+
+// CountPrefix returns the number of keys stored under the given prefix,
+// without reading them
+func (s *GDStore) CountPrefix(prefix string) (int, error) {
+	resp, err := s.Get(context.TODO(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	if err != nil {
+		return 0, err
+	}
+	return int(resp.Count), nil
+}
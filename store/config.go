@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"time"
 
 	"github.com/gluster/glusterd2/pkg/elasticetcd"
 
@@ -34,6 +35,8 @@ func InitFlags() {
 	flag.StringSlice(etcdEndpointsOpt, nil, fmt.Sprintf("ETCD endpoints of a remote etcd cluster for the store to connect to. (Defaults to: %s)", elasticetcd.DefaultEndpoint))
 	flag.StringSlice(etcdCURLsOpt, nil, fmt.Sprintf("URLs which etcd server will use for peer to peer communication. (Defaults to: %s)", elasticetcd.DefaultCURL))
 	flag.StringSlice(etcdPURLsOpt, nil, fmt.Sprintf("URLs which etcd server will use to receive etcd client requests. (Defaults to: %s)", elasticetcd.DefaultPURL))
+	flag.Int(storeRetriesOpt, 5, "Number of times store requests failing with transient errors, like during a leader election, are retried. (0 doesn't retry)")
+	flag.Duration(storeRetryBackoffOpt, 100*time.Millisecond, "Time waited before the first retry of a store request, doubled on every further retry.")
 }
 
 // Config is the GD2 store configuration
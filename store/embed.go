@@ -33,7 +33,7 @@ func newEmbedStore(sconf *Config) (*GDStore, error) {
 		return nil, err
 	}
 
-	return &GDStore{*sconf, ee.Client(), ee.Session(), ee}, nil
+	return &GDStore{conf: *sconf, Client: ee.Client(), Session: ee.Session(), ee: ee}, nil
 }
 
 func (s *GDStore) closeEmbedStore() {
@@ -32,7 +32,7 @@ func newRemoteStore(conf *Config) (*GDStore, error) {
 		return nil, e
 	}
 
-	return &GDStore{*conf, c, s, nil}, nil
+	return &GDStore{conf: *conf, Client: c, Session: s}, nil
 }
 
 func (s *GDStore) closeRemoteStore() {
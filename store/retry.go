@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
+	config "github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	storeRetriesOpt      = "store-retries"
+	storeRetryBackoffOpt = "store-retry-backoff"
+
+	// maxStoreRetryBackoff caps the doubling of the wait between retries
+	maxStoreRetryBackoff = 2 * time.Second
+)
+
+// isTransientError returns true if the store request failed for a reason
+// which goes away on its own, like a leader election or a lost connection.
+// Errors of the request itself, like failed comparisons, aren't transient.
+func isTransientError(err error) bool {
+	if e, ok := err.(rpctypes.EtcdError); ok {
+		return e.Code() == codes.Unavailable
+	}
+	return grpc.Code(err) == codes.Unavailable
+}
+
+// retryStoreOp calls f till it doesn't fail with a transient error, at most
+// store-retries more times, waiting store-retry-backoff, doubled on every
+// retry, in between. It doesn't retry past the deadline of the context.
+func retryStoreOp(ctx context.Context, op string, key string, f func() error) error {
+	retries := config.GetInt(storeRetriesOpt)
+	backoff := config.GetDuration(storeRetryBackoffOpt)
+
+	err := f()
+	for i := 1; i <= retries && err != nil && isTransientError(err); i++ {
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(backoff).After(deadline) {
+			break
+		}
+		log.WithError(err).WithFields(log.Fields{
+			"op":      op,
+			"key":     key,
+			"attempt": i,
+			"backoff": backoff,
+		}).Warn("transient store error, retrying")
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		if backoff *= 2; backoff > maxStoreRetryBackoff {
+			backoff = maxStoreRetryBackoff
+		}
+		err = f()
+	}
+	return err
+}
+
+// retryKV retries the gets, puts and deletes of the store which fail with a
+// transient error. Puts and deletes are idempotent, so retrying one which was
+// applied before its response was lost is harmless. Transactions aren't
+// retried, as their comparisons may no longer hold.
+type retryKV struct {
+	clientv3.KV
+}
+
+func (kv *retryKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	var resp *clientv3.GetResponse
+	err := retryStoreOp(ctx, "get", key, func() (err error) {
+		resp, err = kv.KV.Get(ctx, key, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (kv *retryKV) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	var resp *clientv3.PutResponse
+	err := retryStoreOp(ctx, "put", key, func() (err error) {
+		resp, err = kv.KV.Put(ctx, key, val, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (kv *retryKV) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	var resp *clientv3.DeleteResponse
+	err := retryStoreOp(ctx, "delete", key, func() (err error) {
+		resp, err = kv.KV.Delete(ctx, key, opts...)
+		return err
+	})
+	return resp, err
+}
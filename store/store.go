@@ -2,9 +2,11 @@
 package store
 
 import (
+	"context"
 	"errors"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/gluster/glusterd2/pkg/elasticetcd"
 
@@ -25,6 +27,8 @@ var (
 
 	// ErrStoreInitedAlready is returned when the store is already intialized
 	ErrStoreInitedAlready = errors.New("store has been intialized already")
+	// ErrNoLeader is returned when the store cluster has no leader
+	ErrNoLeader = errors.New("store has no leader")
 )
 
 // GDStore is the GlusterD centralized store
@@ -35,6 +39,9 @@ type GDStore struct {
 	*concurrency.Session
 
 	ee *elasticetcd.ElasticEtcd
+
+	caches     map[string]*prefixCache
+	cachesLock sync.Mutex
 }
 
 // Init initializes the GD2 store
@@ -90,6 +97,10 @@ func New(conf *Config) (*GDStore, error) {
 		}
 	}
 
+	// Requests failing during normal churn of the store cluster, like
+	// leader elections during rolling restarts, are retried
+	store.Client.KV = &retryKV{store.Client.KV}
+
 	if err = store.publishLiveness(); err != nil {
 		return nil, err
 	}
@@ -121,3 +132,16 @@ func (s *GDStore) UpdateEndpoints() error {
 	s.conf.Endpoints = s.Client.Endpoints()
 	return s.conf.Save()
 }
+
+// Leader returns the ID of the node leading the store cluster. Only the
+// embedded store has a leader, ErrNoLeader is returned for a remote store.
+func (s *GDStore) Leader() (string, error) {
+	if s.ee == nil {
+		return "", ErrNoLeader
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.ee.Leader(ctx)
+}
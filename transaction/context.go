@@ -26,6 +26,10 @@ type TxnCtx interface {
 	Logger() log.FieldLogger
 	// Prefix returns the prefix to be used for storing values
 	Prefix() string
+	// Context returns the context the step runs with, which is done once
+	// the deadline of the transaction passes. Steps which can take long
+	// should give up when it is done.
+	Context() context.Context
 }
 
 // Tctx represents structure for transaction context
@@ -36,6 +40,8 @@ type Tctx struct {
 	logFields log.Fields
 
 	prefix string // The prefix under which the data is to be stored
+
+	ctx context.Context // The context of the step being run, not exported
 }
 
 // NewCtx returns a new empty TxnCtx with no parent, no associated data and the default logger.
@@ -61,6 +67,7 @@ func (c *Tctx) NewCtx() *Tctx {
 		log:       c.log,
 		logFields: c.logFields,
 		prefix:    c.prefix,
+		ctx:       c.ctx,
 	}
 }
 
@@ -166,6 +173,21 @@ func (c *Tctx) Prefix() string {
 	return c.prefix
 }
 
+// Context returns the context the step runs with
+func (c *Tctx) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// withContext returns a copy of the context for a step to run with ctx
+func (c *Tctx) withContext(ctx context.Context) *Tctx {
+	n := *c
+	n.ctx = ctx
+	return &n
+}
+
 // Implementing the JSON Marshaler and Unmarshaler interfaces to allow Contexts
 // to be exported Using an temporary struct to allow Context to be serialized
 // using JSON.  Cannot serialize Context.Log otherwise.
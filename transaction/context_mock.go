@@ -1,6 +1,7 @@
 package transaction
 
 import (
+	"context"
 	"errors"
 	log "github.com/Sirupsen/logrus"
 	"github.com/pborman/uuid"
@@ -60,3 +61,8 @@ func (m *MockTctx) Logger() log.FieldLogger {
 func (m MockTctx) Prefix() string {
 	return "mock"
 }
+
+// Context returns a context which is never done
+func (m *MockTctx) Context() context.Context {
+	return context.Background()
+}
@@ -0,0 +1,53 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrDeadlineExceeded is returned when the deadline of the request running a
+// transaction passes before the transaction is done. The steps already done
+// are rolled back.
+var ErrDeadlineExceeded = errors.New("request deadline exceeded, the transaction was rolled back")
+
+// deadlines are the deadlines of the requests being served, by request ID.
+// Transactions are created with the request ID rather than the context of the
+// request, so the deadline is looked up by it. Like for progress reporters,
+// the request ID must be one generated by glusterd2 rather than one set by
+// the client, which another request could be sent with.
+var deadlines = struct {
+	sync.Mutex
+	m map[string]time.Time
+}{m: make(map[string]time.Time)}
+
+// SetRequestDeadline sets the deadline the transactions of the request must
+// be done by
+func SetRequestDeadline(reqID string, deadline time.Time) {
+	deadlines.Lock()
+	defer deadlines.Unlock()
+	deadlines.m[reqID] = deadline
+}
+
+// ClearRequestDeadline removes the deadline of the request, once it is served
+func ClearRequestDeadline(reqID string) {
+	deadlines.Lock()
+	defer deadlines.Unlock()
+	delete(deadlines.m, reqID)
+}
+
+func requestDeadline(reqID string) time.Time {
+	deadlines.Lock()
+	defer deadlines.Unlock()
+	return deadlines.m[reqID]
+}
+
+// context returns the context the steps of the transaction run with, which
+// is cancelled at the deadline of the transaction if it has one
+func (t *Txn) context() (context.Context, context.CancelFunc) {
+	if t.Deadline.IsZero() {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithDeadline(context.Background(), t.Deadline)
+}
@@ -39,7 +39,7 @@ func createLockStepFunc(key string) (string, string, error) {
 
 	lockFunc := func(c TxnCtx) error {
 
-		ctx, cancel := context.WithTimeout(context.Background(), lockObtainTimeout)
+		ctx, cancel := context.WithTimeout(c.Context(), lockObtainTimeout)
 		defer cancel()
 
 		c.Logger().WithField("key", key).Debug("attempting to lock")
@@ -0,0 +1,52 @@
+package transaction
+
+import (
+	"sync"
+
+	"github.com/pborman/uuid"
+)
+
+// ProgressReporter is told of the progress of the transactions of a request,
+// for the request to report it while it is being served
+type ProgressReporter interface {
+	// TxnStarted is called when a transaction of the request starts,
+	// with the number of its steps
+	TxnStarted(steps int)
+	// NodeStepDone is called when a step is done on a node, with the
+	// error of the step on the node if it failed
+	NodeStepDone(step string, node uuid.UUID, err error)
+	// StepDone is called when a step is done on all its nodes
+	StepDone(step string)
+}
+
+// reporters are the progress reporters of the requests being served, by
+// request ID. Like deadlines, they are looked up by the request ID the
+// transactions are created with. The request ID must be one generated by
+// glusterd2 rather than one set by the client, which another request could
+// be sent with.
+var reporters = struct {
+	sync.Mutex
+	m map[string]ProgressReporter
+}{m: make(map[string]ProgressReporter)}
+
+// SetRequestProgressReporter sets the reporter told of the progress of the
+// transactions of the request
+func SetRequestProgressReporter(reqID string, r ProgressReporter) {
+	reporters.Lock()
+	defer reporters.Unlock()
+	reporters.m[reqID] = r
+}
+
+// ClearRequestProgressReporter removes the progress reporter of the request,
+// once it is served
+func ClearRequestProgressReporter(reqID string) {
+	reporters.Lock()
+	defer reporters.Unlock()
+	delete(reporters.m, reqID)
+}
+
+func requestProgressReporter(reqID string) ProgressReporter {
+	reporters.Lock()
+	defer reporters.Unlock()
+	return reporters.m[reqID]
+}
@@ -0,0 +1,167 @@
+package transaction
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gluster/glusterd2/events"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/peer"
+	"github.com/gluster/glusterd2/store"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pborman/uuid"
+	config "github.com/spf13/viper"
+)
+
+const (
+	pendingTxnPrefix = store.GlusterPrefix + "pending-transaction/"
+
+	defaultReaperTTL      = 5 * time.Minute
+	defaultReaperInterval = time.Minute
+)
+
+// pendingTxn is the marker stored for a transaction that is in progress
+type pendingTxn struct {
+	Initiator uuid.UUID
+	// Lease is the lease of the initiator's store session, to which the
+	// cluster locks obtained by the transaction are attached
+	Lease     clientv3.LeaseID
+	StartTime time.Time
+}
+
+func markPending(t *Txn) error {
+	p := pendingTxn{
+		Initiator: gdctx.MyUUID,
+		Lease:     store.Store.Session.Lease(),
+		StartTime: time.Now(),
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	_, err = store.Store.Put(context.TODO(), pendingTxnPrefix+t.ID.String(), string(b))
+	return err
+}
+
+// Reaper cleans up the leftovers of transactions whose initiator died before
+// the transaction completed. It is a suture.Service.
+type Reaper struct {
+	stop chan struct{}
+}
+
+// NewReaper returns a new transaction Reaper
+func NewReaper() *Reaper {
+	return &Reaper{stop: make(chan struct{})}
+}
+
+// Serve periodically scans the store for stale transactions and reaps them
+func (r *Reaper) Serve() {
+	interval := config.GetDuration("txn-reaper-interval")
+	if interval <= 0 {
+		interval = defaultReaperInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			reapStaleTxns()
+		}
+	}
+}
+
+// Stop stops the Reaper
+func (r *Reaper) Stop() {
+	close(r.stop)
+}
+
+// isLeaseExpired returns true if the given store lease no longer exists. A
+// lease which can't be looked up is taken to be alive.
+func isLeaseExpired(lease clientv3.LeaseID) bool {
+	resp, err := store.Store.TimeToLive(context.TODO(), lease)
+	if err != nil {
+		return false
+	}
+	return resp.TTL <= 0
+}
+
+func reapStaleTxns() {
+	ttl := config.GetDuration("txn-reaper-ttl")
+	if ttl <= 0 {
+		ttl = defaultReaperTTL
+	}
+
+	resp, err := store.Store.Get(context.TODO(), pendingTxnPrefix, clientv3.WithPrefix())
+	if err != nil {
+		log.WithError(err).Debug("failed to get pending transactions")
+		return
+	}
+
+	for _, kv := range resp.Kvs {
+		var p pendingTxn
+		if err := json.Unmarshal(kv.Value, &p); err != nil {
+			log.WithError(err).WithField("key", string(kv.Key)).Debug("failed to unmarshal pending transaction")
+			continue
+		}
+
+		if time.Since(p.StartTime) < ttl {
+			continue
+		}
+		// Only transactions whose initiator's store session has expired
+		// are reaped, their locks having been released along with it. The
+		// initiator could have restarted, in which case it is online but
+		// the transaction still has been abandoned with the session of
+		// the earlier instance. A session which is still alive is never
+		// ended here, as it could outlast a transient store failure.
+		if !isLeaseExpired(p.Lease) {
+			if !peer.IsOnline(p.Initiator) {
+				log.WithFields(log.Fields{
+					"reqid":     string(kv.Key)[len(pendingTxnPrefix):],
+					"initiator": p.Initiator.String(),
+				}).Debug("initiator of stale transaction is offline, waiting for its session to expire")
+			}
+			continue
+		}
+
+		reapTxn(string(kv.Key)[len(pendingTxnPrefix):], &p)
+	}
+}
+
+func reapTxn(id string, p *pendingTxn) {
+	logger := log.WithFields(log.Fields{
+		"reqid":     id,
+		"initiator": p.Initiator.String(),
+	})
+
+	if _, err := store.Store.Delete(context.TODO(), txnPrefix+id, clientv3.WithPrefix()); err != nil {
+		logger.WithError(err).Warn("failed to remove context of stale transaction")
+		return
+	}
+
+	// Other nodes could be reaping the same transaction, only the one
+	// removing the marker reports it.
+	dresp, err := store.Store.Delete(context.TODO(), pendingTxnPrefix+id)
+	if err != nil {
+		logger.WithError(err).Warn("failed to remove stale transaction marker")
+		return
+	}
+	if dresp.Deleted == 0 {
+		return
+	}
+
+	logger.Info("reaped stale transaction")
+	events.Broadcast(events.New("transaction-reaped", map[string]string{
+		"reqid":     id,
+		"initiator": p.Initiator.String(),
+		"started":   p.StartTime.String(),
+	}))
+}
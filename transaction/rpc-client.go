@@ -5,18 +5,17 @@ import (
 	"errors"
 
 	"github.com/gluster/glusterd2/peer"
+	"github.com/gluster/glusterd2/servers/peerrpc"
 	"github.com/gluster/glusterd2/utils"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/pborman/uuid"
 	netctx "golang.org/x/net/context"
-	"google.golang.org/grpc"
 )
 
-// RunStepOn will run the step on the specified node
-func RunStepOn(step string, node uuid.UUID, c TxnCtx) (TxnCtx, error) {
-	// TODO: I'm creating connections on demand. This should be changed so that
-	// we have long term connections.
+// RunStepOn will run the step on the specified node. The RPC is cancelled
+// with ctx.
+func RunStepOn(ctx netctx.Context, step string, node uuid.UUID, c TxnCtx) (TxnCtx, error) {
 	p, err := peer.GetPeerF(node.String())
 	if err != nil {
 		c.Logger().WithFields(log.Fields{
@@ -28,28 +27,27 @@ func RunStepOn(step string, node uuid.UUID, c TxnCtx) (TxnCtx, error) {
 
 	logger := c.Logger().WithField("remotepeer", p.ID.String()+"("+p.Name+")")
 
-	var conn *grpc.ClientConn
-
 	remote, err := utils.FormRemotePeerAddress(p.Addresses[0])
 	if err != nil {
 		return nil, err
 	}
 
-	conn, err = grpc.Dial(remote, grpc.WithInsecure())
-	if err == nil && conn != nil {
-		logger.WithFields(log.Fields{
-			"remote": remote,
-		}).Debug("connected to remote")
-	}
-
-	if conn == nil {
+	conn, err := peerrpc.GetConn(remote)
+	if err != nil {
 		logger.WithFields(log.Fields{
 			"error":  err,
 			"remote": p.Addresses,
 		}).Error("failed to grpc.Dial remote")
 		return nil, err
 	}
-	defer conn.Close()
+	logger.WithField("remote", remote).Debug("connected to remote")
+
+	// The connection is returned to the pool with the result of the RPC,
+	// so that it is evicted if the RPC failed
+	var rpcErr error
+	defer func() {
+		peerrpc.PutConn(remote, conn, rpcErr)
+	}()
 
 	client := NewTxnSvcClient(conn)
 
@@ -65,8 +63,9 @@ func RunStepOn(step string, node uuid.UUID, c TxnCtx) (TxnCtx, error) {
 
 	var rsp *TxnStepResp
 
-	rsp, err = client.RunStep(netctx.TODO(), req)
+	rsp, err = client.RunStep(ctx, req)
 	if err != nil {
+		rpcErr = err
 		logger.WithFields(log.Fields{
 			"error": err,
 			"rpc":   "TxnSvc.RunStep",
@@ -38,12 +38,16 @@ func (p *txnSvc) RunStep(rpcCtx context.Context, req *TxnStepReq) (*TxnStepResp,
 
 	logger.Debug("running step")
 
+	// The step is cancelled along with the RPC, at the deadline of the
+	// transaction
+	ctx.ctx = rpcCtx
+
 	resp := new(TxnStepResp)
 
 	// Execute the step function, build and return result
 	err = f(&ctx)
 	if err != nil {
-		logger.WithError(err).Debug("step function failed")
+		logger.WithError(err).Error("step function failed")
 		resp.Error = err.Error()
 	} else {
 		b, err := json.Marshal(ctx)
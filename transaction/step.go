@@ -1,6 +1,7 @@
 package transaction
 
 import (
+	"context"
 	"errors"
 
 	"github.com/gluster/glusterd2/gdctx"
@@ -36,51 +37,68 @@ var (
 	ErrStepFuncNotFound = errors.New("StepFunc was not found")
 )
 
-// do runs the DoFunc on the nodes
-func (s *Step) do(c TxnCtx) error {
-	return runStepFuncOnNodes(s.DoFunc, c, s.Nodes)
+// do runs the DoFunc on the nodes, telling progress, if not nil, of the
+// result on each node. Running it on remote nodes is cancelled with ctx.
+func (s *Step) do(ctx context.Context, c TxnCtx, progress ProgressReporter) error {
+	err := runStepFuncOnNodes(ctx, s.DoFunc, c, s.Nodes, progress)
+	if err == nil && progress != nil {
+		progress.StepDone(s.DoFunc)
+	}
+	return err
 }
 
-// undo runs the UndoFunc on the nodes
+// undo runs the UndoFunc on the nodes. It is never cancelled, so that the
+// changes are undone even if the transaction failed on its deadline.
 func (s *Step) undo(c TxnCtx) error {
 	if s.UndoFunc != "" {
-		return runStepFuncOnNodes(s.UndoFunc, c, s.Nodes)
+		return runStepFuncOnNodes(context.Background(), s.UndoFunc, c, s.Nodes, nil)
 	}
 	return nil
 }
 
-func runStepFuncOnNodes(name string, c TxnCtx, nodes []uuid.UUID) error {
-	var (
-		i    int
-		node uuid.UUID
-	)
-	done := make(chan error)
+// nodeStepResult is the result of a step function on a node
+type nodeStepResult struct {
+	node uuid.UUID
+	err  error
+}
+
+func runStepFuncOnNodes(ctx context.Context, name string, c TxnCtx, nodes []uuid.UUID, progress ProgressReporter) error {
+	done := make(chan nodeStepResult)
 	defer close(done)
 
-	for i, node = range nodes {
-		go runStepFuncOnNode(name, c, node, done)
+	for _, node := range nodes {
+		go runStepFuncOnNode(ctx, name, c, node, done)
 	}
 
 	// TODO: Need to properly aggregate results
 	var err error
-	for i >= 0 {
-		err = <-done
-		i--
+	for range nodes {
+		result := <-done
+		if progress != nil {
+			progress.NodeStepDone(name, result.node, result.err)
+		}
+		if result.err != nil {
+			err = result.err
+		}
 	}
 	return err
 }
 
-func runStepFuncOnNode(name string, c TxnCtx, node uuid.UUID, done chan<- error) {
+func runStepFuncOnNode(ctx context.Context, name string, c TxnCtx, node uuid.UUID, done chan<- nodeStepResult) {
 	if uuid.Equal(node, gdctx.MyUUID) {
-		done <- runStepFuncLocal(name, c)
+		done <- nodeStepResult{node, runStepFuncLocal(ctx, name, c)}
 	} else {
-		done <- runStepFuncRemote(name, c, node)
+		done <- nodeStepResult{node, runStepFuncRemote(ctx, name, c, node)}
 	}
 }
 
-func runStepFuncLocal(name string, c TxnCtx) error {
+func runStepFuncLocal(ctx context.Context, name string, c TxnCtx) error {
 	c.Logger().WithField("stepfunc", name).Debug("running step function")
 
+	if tc, ok := c.(*Tctx); ok {
+		c = tc.withContext(ctx)
+	}
+
 	stepFunc, ok := GetStepFunc(name)
 	if !ok {
 		return ErrStepFuncNotFound
@@ -89,8 +107,8 @@ func runStepFuncLocal(name string, c TxnCtx) error {
 	//TODO: Results need to be aggregated
 }
 
-func runStepFuncRemote(step string, c TxnCtx, node uuid.UUID) error {
-	rsp, err := RunStepOn(step, node, c)
+func runStepFuncRemote(ctx context.Context, step string, c TxnCtx, node uuid.UUID) error {
+	rsp, err := RunStepOn(ctx, step, node, c)
 	//TODO: Results need to be aggregated
 	_ = rsp
 	return err
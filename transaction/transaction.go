@@ -4,7 +4,9 @@ package transaction
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/gluster/glusterd2/peer"
 	"github.com/gluster/glusterd2/store"
 
 	log "github.com/Sirupsen/logrus"
@@ -25,6 +27,12 @@ type Txn struct {
 	Ctx   TxnCtx
 	Steps []*Step
 	Nodes []uuid.UUID
+	// Deadline is the time the transaction must be done by, if not zero,
+	// which is that of the request the transaction is created for
+	Deadline time.Time
+	// progress is told of the progress of the transaction, if the request
+	// it is created for reports its progress
+	progress ProgressReporter
 }
 
 // NewTxn returns an initialized Txn without any steps
@@ -40,6 +48,8 @@ func NewTxn(id string) *Txn {
 	t.Ctx = NewCtxWithLogFields(log.Fields{
 		"reqid": t.ID.String(),
 	}).WithPrefix(prefix)
+	t.Deadline = requestDeadline(id)
+	t.progress = requestProgressReporter(id)
 
 	return t
 }
@@ -58,19 +68,34 @@ func NewTxnWithLoggingContext(f log.Fields, id string) *Txn {
 // Cleanup cleans the leftovers after a transaction ends
 func (t *Txn) Cleanup() {
 	store.Store.Delete(context.TODO(), t.Ctx.Prefix(), clientv3.WithPrefix())
+	store.Store.Delete(context.TODO(), pendingTxnPrefix+t.ID.String())
 }
 
 // Do runs the transaction on the cluster
 func (t *Txn) Do() (TxnCtx, error) {
 	t.Ctx.Logger().Debug("Starting transaction")
 
+	ctx, cancel := t.context()
+	defer cancel()
+
 	// verify that all nodes are online
 	for _, node := range t.Nodes {
-		if !store.Store.IsNodeAlive(node) {
+		if !peer.IsOnline(node) {
 			return nil, fmt.Errorf("node %s is probably down", node.String())
 		}
 	}
 
+	// Mark the transaction as pending, so that its leftovers can be
+	// cleaned up by the reaper if this node dies before Cleanup
+	if err := markPending(t); err != nil {
+		t.Ctx.Logger().WithError(err).Error("failed to mark transaction as pending")
+		return nil, err
+	}
+
+	if t.progress != nil {
+		t.progress.TxnStarted(len(t.Steps))
+	}
+
 	//Do the steps
 	for i, s := range t.Steps {
 		//TODO: Renable (correctly) if All/Leader keys are fixed
@@ -80,7 +105,16 @@ func (t *Txn) Do() (TxnCtx, error) {
 		////s.Nodes[0] = LeaderName
 		//}
 
-		if e := s.do(t.Ctx); e != nil {
+		if ctx.Err() != nil {
+			t.Ctx.Logger().WithField("deadline", t.Deadline).Error("Transaction deadline exceeded, rolling back changes")
+			t.undo(i - 1)
+			return nil, ErrDeadlineExceeded
+		}
+
+		if e := s.do(ctx, t.Ctx, t.progress); e != nil {
+			if ctx.Err() != nil {
+				e = ErrDeadlineExceeded
+			}
 			t.Ctx.Logger().WithError(e).Error("Transaction failed, rolling back changes")
 			t.undo(i)
 			return nil, e
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// tcpEstablished is the state of established connections in /proc/net/tcp
+const tcpEstablished = "01"
+
+// Connection is an established TCP connection to a local port
+type Connection struct {
+	RemoteIP   string
+	RemotePort int
+}
+
+// CountConnections returns the number of established TCP connections to the
+// given local port, over IPv4 and IPv6
+func CountConnections(port int) (int, error) {
+	conns, err := ListConnections(port)
+	if err != nil {
+		return 0, err
+	}
+	return len(conns), nil
+}
+
+// ListConnections returns the established TCP connections to the given local
+// port, over IPv4 and IPv6
+func ListConnections(port int) ([]Connection, error) {
+	var conns []Connection
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		c, err := listConnections(table, port)
+		if os.IsNotExist(err) {
+			// No IPv6 support
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		conns = append(conns, c...)
+	}
+	return conns, nil
+}
+
+// nativeEndian is the byte order of this host
+var nativeEndian = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// parseProcNetAddress parses an address of /proc/net/tcp or /proc/net/tcp6,
+// which is the hex IP, in 32-bit words of host byte order, and the hex port
+func parseProcNetAddress(s string) (net.IP, int, error) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return nil, 0, fmt.Errorf("invalid address %q", s)
+	}
+	port, err := strconv.ParseUint(s[i+1:], 16, 16)
+	if err != nil {
+		return nil, 0, err
+	}
+	b, err := hex.DecodeString(s[:i])
+	if err != nil || (len(b) != net.IPv4len && len(b) != net.IPv6len) {
+		return nil, 0, fmt.Errorf("invalid address %q", s)
+	}
+	ip := make(net.IP, len(b))
+	for w := 0; w < len(b); w += 4 {
+		binary.BigEndian.PutUint32(ip[w:], nativeEndian.Uint32(b[w:]))
+	}
+	return ip, int(port), nil
+}
+
+func listConnections(table string, port int) ([]Connection, error) {
+	f, err := os.Open(table)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var conns []Connection
+	scanner := bufio.NewScanner(f)
+	// Skip the header
+	scanner.Scan()
+	for scanner.Scan() {
+		// sl local_address rem_address st ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[3] != tcpEstablished {
+			continue
+		}
+		_, p, err := parseProcNetAddress(fields[1])
+		if err != nil || p != port {
+			continue
+		}
+		ip, rp, err := parseProcNetAddress(fields[2])
+		if err != nil {
+			continue
+		}
+		conns = append(conns, Connection{RemoteIP: ip.String(), RemotePort: rp})
+	}
+	return conns, scanner.Err()
+}
+
+// KillConnection closes the established TCP connection to the given local
+// port. The socket is destroyed by the kernel, so that the process serving it
+// sees it reset. ss doesn't fail if the kernel has no socket destroy support,
+// so the connections are listed again to check that it is gone.
+var KillConnection = func(port int, conn Connection) error {
+	remote := net.JoinHostPort(conn.RemoteIP, strconv.Itoa(conn.RemotePort))
+	out, err := exec.Command("ss", "-K", "state", "established",
+		"dst", remote, "sport", "=", ":"+strconv.Itoa(port)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(string(out)))
+	}
+
+	conns, err := ListConnections(port)
+	if err != nil {
+		return err
+	}
+	for _, c := range conns {
+		if c == conn {
+			return fmt.Errorf("connection from %s is still established, the kernel may lack socket destroy support", remote)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// NodeHealth is the current load and free memory of a node. MemTotal and
+// MemAvailable are in KiB, and Load is the 1 minute load average.
+type NodeHealth struct {
+	MemTotal     uint64  `json:"mem-total"`
+	MemAvailable uint64  `json:"mem-available"`
+	Load         float64 `json:"load"`
+	CPUs         int     `json:"cpus"`
+}
+
+// Score rates the health of the node, lower being healthier. It adds up the
+// load per CPU and the fraction of memory in use, so that a node with no
+// free memory counts as much as one with every CPU busy.
+func (h NodeHealth) Score() float64 {
+	score := h.Load / float64(h.CPUs)
+	if h.MemTotal > 0 {
+		score += 1 - float64(h.MemAvailable)/float64(h.MemTotal)
+	}
+	return score
+}
+
+func readMeminfo() (uint64, uint64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	var total, available uint64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total, err = strconv.ParseUint(fields[1], 10, 64)
+		case "MemAvailable:":
+			available, err = strconv.ParseUint(fields[1], 10, 64)
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	if total == 0 {
+		return 0, 0, fmt.Errorf("MemTotal not found in /proc/meminfo")
+	}
+	return total, available, nil
+}
+
+// GetNodeHealth returns the current health of this node
+func GetNodeHealth() (*NodeHealth, error) {
+	total, available, err := readMeminfo()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadFile("/proc/loadavg")
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("invalid /proc/loadavg")
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NodeHealth{
+		MemTotal:     total,
+		MemAvailable: available,
+		Load:         load,
+		CPUs:         runtime.NumCPU(),
+	}, nil
+}
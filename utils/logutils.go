@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"github.com/gluster/glusterd2/pkg/logrotate"
+
+	config "github.com/spf13/viper"
+)
+
+// LogRotateConfig returns the configured rotation of log files
+func LogRotateConfig() logrotate.Config {
+	return logrotate.Config{
+		MaxSize:    int64(config.GetInt("log-max-size")) << 20,
+		MaxAge:     config.GetDuration("log-max-age"),
+		MaxBackups: config.GetInt("log-max-backups"),
+	}
+}
@@ -2,35 +2,171 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"net"
+	"strconv"
 	"strings"
 
 	config "github.com/spf13/viper"
 )
 
-// FormRemotePeerAddress will check and validate peeraddress provided. It will
-// return an address of the form <ip:port>
-func FormRemotePeerAddress(peeraddress string) (string, error) {
+const (
+	// AddressFamilyAuto lets the address family be picked as it comes
+	AddressFamilyAuto = "auto"
+	// AddressFamilyIPv4 restricts addresses to IPv4
+	AddressFamilyIPv4 = "ipv4"
+	// AddressFamilyIPv6 restricts addresses to IPv6
+	AddressFamilyIPv6 = "ipv6"
+)
+
+// AddressFamily returns the configured preferred address family
+func AddressFamily() string {
+	family := strings.ToLower(config.GetString("addressfamily"))
+	if family == "" {
+		return AddressFamilyAuto
+	}
+	return family
+}
+
+// isAddressFamily returns true if the IP belongs to the given address family
+func isAddressFamily(ip net.IP, family string) bool {
+	switch family {
+	case AddressFamilyIPv4:
+		return ip.To4() != nil
+	case AddressFamilyIPv6:
+		return ip.To4() == nil
+	}
+	return true
+}
+
+// resolveHost returns an IP of the given host in the preferred address
+// family. The host is returned as is when no address family is preferred.
+func resolveHost(host string) (string, error) {
+	family := AddressFamily()
+	if family == AddressFamilyAuto {
+		return host, nil
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if !isAddressFamily(ip, family) {
+			return "", fmt.Errorf("address %s is not an %s address", host, family)
+		}
+		return host, nil
+	}
 
-	host, port, err := net.SplitHostPort(peeraddress)
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return "", err
+	}
+	for _, ip := range ips {
+		if isAddressFamily(ip, family) {
+			return ip.String(), nil
+		}
+	}
+	return "", fmt.Errorf("no %s address found for %s", family, host)
+}
+
+// splitHostPort splits the address into its host and port like
+// net.SplitHostPort, but also takes addresses without a port, including IPv6
+// addresses given as they are or in brackets. hasPort is false for those.
+func splitHostPort(address string) (host string, port string, hasPort bool, err error) {
+	if ip := parseIP(strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")); ip != nil {
+		return strings.TrimSuffix(strings.TrimPrefix(address, "["), "]"), "", false, nil
+	}
+
+	host, port, err = net.SplitHostPort(address)
 	if err != nil {
 		// net.SplitHostPort() returns an error if port is missing.
 		if strings.HasSuffix(err.Error(), "missing port in address") {
-			host = peeraddress
-			port = config.GetString("defaultpeerport")
-		} else {
-			return "", err
+			return address, "", false, nil
 		}
+		return "", "", false, err
+	}
+	return host, port, true, nil
+}
+
+// FormRemotePeerAddress will check and validate peeraddress provided. It will
+// return an address of the form <ip:port>, or [<ip>]:<port> for IPv6
+func FormRemotePeerAddress(peeraddress string) (string, error) {
+
+	host, port, hasPort, err := splitHostPort(peeraddress)
+	if err != nil {
+		return "", err
+	}
+	if !hasPort {
+		port = config.GetString("defaultpeerport")
 	}
 
 	if host == "" {
 		return "", errors.New("Invalid peer address")
 	}
 
-	remotePeerAddress := host + ":" + port
+	host, err = resolveHost(host)
+	if err != nil {
+		return "", err
+	}
+
+	remotePeerAddress := net.JoinHostPort(host, port)
 	return remotePeerAddress, nil
 }
 
+// ValidatePeerAddress checks that the peer address is a host with an optional
+// port, without resolving the host. It catches malformed addresses before
+// the peer is reached out to.
+func ValidatePeerAddress(peeraddress string) error {
+	host, port, hasPort, err := splitHostPort(peeraddress)
+	if err != nil {
+		return err
+	}
+	if hasPort {
+		n, err := strconv.Atoi(port)
+		if err != nil || n <= 0 || n > 65535 {
+			return fmt.Errorf("invalid port in peer address %s", peeraddress)
+		}
+	}
+
+	host = strings.TrimSpace(host)
+	if host == "" || strings.ContainsAny(host, " /") {
+		return fmt.Errorf("invalid host in peer address %s", peeraddress)
+	}
+	return nil
+}
+
+// IsPeerHostSame checks if two peer addresses reach the same glusterd2, on
+// the same port of a host known by different names, such as a hostname and
+// one of its IPs.
+func IsPeerHostSame(addr1 string, addr2 string) bool {
+	host1, port1 := splitPeerAddress(addr1)
+	host2, port2 := splitPeerAddress(addr2)
+	return port1 == port2 && IsAddressSame(host1, host2)
+}
+
+// splitPeerAddress splits the peer address into its host and port, which is
+// the default peer port if the address has none
+func splitPeerAddress(peeraddress string) (string, string) {
+	host, port, hasPort, err := splitHostPort(peeraddress)
+	if err != nil {
+		return peeraddress, config.GetString("defaultpeerport")
+	}
+	if !hasPort {
+		port = config.GetString("defaultpeerport")
+	}
+	return host, port
+}
+
+// TransportAddressFamily returns the transport.address-family of the bricks
+// and clients reaching the given host, inet6 if IPv6 is the preferred address
+// family or the host is an IPv6 address, and inet otherwise
+func TransportAddressFamily(host string) string {
+	if AddressFamily() == AddressFamilyIPv6 {
+		return "inet6"
+	}
+	if ip := parseIP(host); ip != nil && ip.To4() == nil {
+		return "inet6"
+	}
+	return "inet"
+}
+
 // IsPeerAddressSame checks if two peer addresses are same by normalizing
 // each address to <ip>:<port> form.
 func IsPeerAddressSame(addr1 string, addr2 string) bool {
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	config "github.com/spf13/viper"
+)
+
+// SSL certificate files used by the gluster processes, in the directory
+// given by the ssl-cert-dir option
+var sslCertFiles = []string{"glusterfs.pem", "glusterfs.key", sslCAFile}
+
+// sslCAFile has the certificates of the CAs which signed the certificates
+// of clients and bricks
+const sslCAFile = "glusterfs.ca"
+
+// CheckSSLCerts returns an error naming the SSL certificate files missing on
+// this node, if any
+func CheckSSLCerts() error {
+	var missing []string
+	for _, f := range sslCertFiles {
+		p := path.Join(config.GetString("ssl-cert-dir"), f)
+		if _, err := os.Stat(p); err != nil {
+			missing = append(missing, p)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("missing SSL certificate files %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// ReadSSLCA returns the CA certificates of this node in PEM, which clients
+// need to verify the bricks. The file is checked to have only valid
+// certificates.
+func ReadSSLCA() ([]byte, error) {
+	p := path.Join(config.GetString("ssl-cert-dir"), sslCAFile)
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	count := 0
+	for rest := b; ; count++ {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("%s has a %s, not a certificate", p, block.Type)
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, fmt.Errorf("%s has an invalid certificate: %s", p, err.Error())
+		}
+	}
+	if count == 0 {
+		return nil, errors.New(p + " has no certificates")
+	}
+	return b, nil
+}
@@ -4,6 +4,8 @@ package utils
 import "C"
 
 import (
+	"encoding/hex"
+	"fmt"
 	"net"
 	"os"
 	"path"
@@ -35,26 +37,46 @@ var (
 	Setxattr = unix.Setxattr
 	// Getxattr calls unix.Getxattr
 	Getxattr = unix.Getxattr
+	// Listxattr calls unix.Listxattr
+	Listxattr = unix.Listxattr
 )
 
+// glusterXattrPrefixes is the namespace of xattrs that gluster sets on bricks
+var glusterXattrPrefixes = []string{
+	"trusted.glusterfs.",
+	"trusted.gfid",
+	"trusted.afr.",
+	"trusted.ec.",
+}
+
 //PosixPathMax represents C's POSIX_PATH_MAX
 const PosixPathMax = C._POSIX_PATH_MAX
 
+// localNames are the names of this node which aren't looked up
+var localNames = []string{"localhost", "localhost6", "ip6-localhost", "ip6-loopback"}
+
+// parseIP parses an IP address, which can be an IPv6 address with a zone,
+// returning nil if the host isn't an IP address
+func parseIP(host string) net.IP {
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		host = host[:i]
+	}
+	return net.ParseIP(host)
+}
+
 // IsLocalAddress checks whether a given host/IP is local
-// Does lookup only after string matching IP addresses
+// Does lookup only after matching IP addresses. IP addresses are compared
+// parsed, as IPv6 addresses can be written in several ways.
 func IsLocalAddress(address string) (bool, error) {
 	var host string
 
 	host, _, _ = net.SplitHostPort(address)
 	if host == "" {
-		host = address
+		host = strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
 	}
 
-	localNames := []string{"127.0.0.1", "localhost", "::1"}
-	for _, name := range localNames {
-		if host == name {
-			return true, nil
-		}
+	if StringInSlice(host, localNames) {
+		return true, nil
 	}
 
 	laddrs, e := net.InterfaceAddrs()
@@ -67,17 +89,17 @@ func IsLocalAddress(address string) (bool, error) {
 		lips = append(lips, lipa.IP)
 	}
 
-	for _, ip := range lips {
-		if host == ip.String() {
-			return true, nil
+	rips := []net.IP{parseIP(host)}
+	if rips[0] == nil {
+		rips, e = net.LookupIP(host)
+		if e != nil {
+			return false, e
 		}
 	}
-
-	rips, e := net.LookupIP(host)
-	if e != nil {
-		return false, e
-	}
 	for _, rip := range rips {
+		if rip.IsLoopback() {
+			return true, nil
+		}
 		for _, lip := range lips {
 			if lip.Equal(rip) {
 				return true, nil
@@ -88,7 +110,17 @@ func IsLocalAddress(address string) (bool, error) {
 }
 
 // ParseHostAndBrickPath parses the host & brick path out of req.Bricks list
+// IPv6 addresses can be given as they are, or in brackets as [<address>]:<path>.
 func ParseHostAndBrickPath(brickPath string) (string, string, error) {
+	if strings.HasPrefix(brickPath, "[") {
+		i := strings.Index(brickPath, "]:")
+		if i == -1 {
+			log.WithField("brick", brickPath).Error(errors.ErrInvalidBrickPath.Error())
+			return "", "", errors.ErrInvalidBrickPath
+		}
+		return brickPath[1:i], brickPath[i+2:], nil
+	}
+
 	i := strings.LastIndex(brickPath, ":")
 	if i == -1 {
 		log.WithField("brick", brickPath).Error(errors.ErrInvalidBrickPath.Error())
@@ -110,6 +142,28 @@ func ValidateBrickPathLength(brickPath string) error {
 	return nil
 }
 
+// CanonicalizeBrickPath returns the absolute and cleaned form of the given
+// brick path, with symlinks in the existing ancestors of the path resolved.
+// The brick directory itself may not exist yet, so only the longest existing
+// prefix of the path is resolved.
+func CanonicalizeBrickPath(brickPath string) (string, error) {
+	p, err := filepath.Abs(brickPath)
+	if err != nil {
+		return "", err
+	}
+
+	var suffix string
+	for dir := p; dir != "/"; dir = filepath.Dir(dir) {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(resolved, suffix), nil
+		}
+		suffix = filepath.Join(filepath.Base(dir), suffix)
+	}
+
+	return p, nil
+}
+
 //ValidateBrickSubDirLength validates the length of each sub directories under
 //the brick path
 func ValidateBrickSubDirLength(brickPath string) error {
@@ -125,6 +179,37 @@ func ValidateBrickSubDirLength(brickPath string) error {
 	return nil
 }
 
+// BrickPathDepth returns the number of directories the canonical brick path
+// is nested in
+func BrickPathDepth(canonicalPath string) int {
+	return strings.Count(strings.TrimSuffix(canonicalPath, string(os.PathSeparator)), string(os.PathSeparator))
+}
+
+// ValidateBrickPathDepth checks that the canonical form of the brick path
+// is not nested deeper than maxDepth directories. A maxDepth of 0 or less
+// means there is no limit.
+func ValidateBrickPathDepth(brickPath string, maxDepth int) error {
+	if maxDepth <= 0 {
+		return nil
+	}
+
+	p, err := CanonicalizeBrickPath(brickPath)
+	if err != nil {
+		return err
+	}
+
+	depth := BrickPathDepth(p)
+	if depth > maxDepth {
+		log.WithFields(log.Fields{
+			"path":     p,
+			"depth":    depth,
+			"maxdepth": maxDepth,
+		}).Error("brick path is nested too deep")
+		return fmt.Errorf("brick path %s has a depth of %d, exceeding the maximum allowed depth of %d", brickPath, depth, maxDepth)
+	}
+	return nil
+}
+
 //GetDeviceID fetches the device id of the device containing the file/directory
 func GetDeviceID(f os.FileInfo) (int, error) {
 	s := f.Sys()
@@ -137,33 +222,123 @@ func GetDeviceID(f os.FileInfo) (int, error) {
 	return -1, errors.ErrDeviceIDNotFound
 }
 
+// GetBrickAvailableSpace returns the total and the available space, in
+// bytes, of the file system having the brick
+func GetBrickAvailableSpace(brickPath string) (uint64, uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(brickPath, &st); err != nil {
+		return 0, 0, err
+	}
+	return st.Blocks * uint64(st.Bsize), st.Bavail * uint64(st.Bsize), nil
+}
+
+// GetBrickInodes returns the total and the free inodes of the file system
+// having the brick
+func GetBrickInodes(brickPath string) (uint64, uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(brickPath, &st); err != nil {
+		return 0, 0, err
+	}
+	return st.Files, st.Ffree, nil
+}
+
+// filesystemTypes are the names of the filesystem types bricks are commonly
+// found on, by their statfs magic number
+var filesystemTypes = map[uint32]string{
+	0x58465342: "xfs",
+	0xEF53:     "ext4",
+	0x9123683E: "btrfs",
+	0x2FC12FC1: "zfs",
+	0x01021994: "tmpfs",
+	0x6969:     "nfs",
+	0x794C7630: "overlayfs",
+	0x65735546: "fuse",
+}
+
+// GetFSType returns the type of the filesystem having the path, or which
+// would have it if it doesn't exist yet. Unknown types are returned as their
+// magic number, like 0x1234.
+func GetFSType(p string) (string, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(nearestExistingPath(p), &st); err != nil {
+		return "", err
+	}
+	if fstype, ok := filesystemTypes[uint32(st.Type)]; ok {
+		return fstype, nil
+	}
+	return fmt.Sprintf("0x%x", uint32(st.Type)), nil
+}
+
+// IsKnownFSType returns false for the filesystem types GetFSType doesn't know
+// the name of
+func IsKnownFSType(fstype string) bool {
+	return !strings.HasPrefix(fstype, "0x")
+}
+
+// IsFilesystemReadOnly returns true if the filesystem having the path is
+// mounted read-only, as happens when it is remounted after I/O errors
+func IsFilesystemReadOnly(path string) (bool, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return false, err
+	}
+	return st.Flags&unix.ST_RDONLY != 0, nil
+}
+
+// BrickValidationOpts are the options for validating a brick path
+type BrickValidationOpts struct {
+	// Force skips the checks on the mount point of the brick
+	Force bool
+	// ReadOnly skips creating the brick directory and the .glusterfs
+	// directory in it, for callers which must not modify the brick
+	ReadOnly bool
+	// Recover accepts a brick which is already marked with the ID of the
+	// volume it is validated for, as when recovering the bricks of a node,
+	// and leaves the marking intact. A brick marked with the ID of another
+	// volume is still rejected.
+	Recover bool
+}
+
 //ValidateBrickPathStats checks whether the brick directory can be created with
 //certain validations like directory checks, whether directory is part of mount
 //point etc
 func ValidateBrickPathStats(brickPath string, host string, force bool) error {
+	return ValidateBrickPathStatsWithOpts(brickPath, host, BrickValidationOpts{Force: force})
+}
+
+// ValidateBrickPathStatsWithOpts is ValidateBrickPathStats with options. A
+// read-only validation of a brick path that doesn't exist yet is done against
+// its nearest existing ancestor.
+func ValidateBrickPathStatsWithOpts(brickPath string, host string, opts BrickValidationOpts) error {
 	var created bool
 	var rootStat, brickStat, parentStat os.FileInfo
-	err := os.MkdirAll(brickPath, os.ModeDir|os.ModePerm)
+	var err error
+	if !opts.ReadOnly {
+		err = os.MkdirAll(brickPath, os.ModeDir|os.ModePerm)
+		if err != nil {
+			if !os.IsExist(err) {
+				log.WithFields(log.Fields{
+					"host":  host,
+					"brick": brickPath,
+				}).Error("Failed to create brick - ", err.Error())
+				return err
+			}
+		} else {
+			created = true
+		}
+	}
+	brickStat, err = os.Lstat(brickPath)
 	if err != nil {
-		if !os.IsExist(err) {
+		if !opts.ReadOnly || !os.IsNotExist(err) {
 			log.WithFields(log.Fields{
 				"host":  host,
 				"brick": brickPath,
-			}).Error("Failed to create brick - ", err.Error())
+			}).Error("Failed to stat on brick path - ", err.Error())
 			return err
 		}
-	} else {
-		created = true
+		brickStat = nil
 	}
-	brickStat, err = os.Lstat(brickPath)
-	if err != nil {
-		log.WithFields(log.Fields{
-			"host":  host,
-			"brick": brickPath,
-		}).Error("Failed to stat on brick path - ", err.Error())
-		return err
-	}
-	if !created && !brickStat.IsDir() {
+	if brickStat != nil && !created && !brickStat.IsDir() {
 		log.WithFields(log.Fields{
 			"host":  host,
 			"brick": brickPath,
@@ -179,6 +354,10 @@ func ValidateBrickPathStats(brickPath string, host string, force bool) error {
 
 	parentBrick := path.Dir(brickPath)
 	parentStat, err = os.Lstat(parentBrick)
+	for opts.ReadOnly && os.IsNotExist(err) && parentBrick != "/" {
+		parentBrick = path.Dir(parentBrick)
+		parentStat, err = os.Lstat(parentBrick)
+	}
 	if err != nil {
 		log.WithFields(log.Fields{
 			"host":        host,
@@ -188,7 +367,7 @@ func ValidateBrickPathStats(brickPath string, host string, force bool) error {
 		return err
 	}
 
-	if !force {
+	if !opts.Force {
 		var parentDeviceID, rootDeviceID, brickDeviceID int
 		var e error
 		parentDeviceID, e = GetDeviceID(parentStat)
@@ -205,13 +384,17 @@ func ValidateBrickPathStats(brickPath string, host string, force bool) error {
 			log.Error("Failed to find the device id of '/'")
 			return err
 		}
-		brickDeviceID, e = GetDeviceID(brickStat)
-		if e != nil {
-			log.WithFields(log.Fields{
-				"host":  host,
-				"brick": brickPath,
-			}).Error("Failed to find the device id of the brick")
-			return err
+		// A brick yet to be created would be on the device of its parent
+		brickDeviceID = parentDeviceID
+		if brickStat != nil {
+			brickDeviceID, e = GetDeviceID(brickStat)
+			if e != nil {
+				log.WithFields(log.Fields{
+					"host":  host,
+					"brick": brickPath,
+				}).Error("Failed to find the device id of the brick")
+				return err
+			}
 		}
 		if brickDeviceID != parentDeviceID {
 			log.WithFields(log.Fields{
@@ -229,6 +412,10 @@ func ValidateBrickPathStats(brickPath string, host string, force bool) error {
 
 	}
 
+	if opts.ReadOnly {
+		return nil
+	}
+
 	// Workaround till https://review.gluster.org/#/c/18003/ gets in
 	if err := os.MkdirAll(filepath.Join(brickPath, ".glusterfs", "indices"), os.ModeDir|os.ModePerm); err != nil {
 		log.WithError(err).Error("failed to create .glusterfs/indices directory")
@@ -242,9 +429,25 @@ func ValidateBrickPathStats(brickPath string, host string, force bool) error {
 //attribute support and it also sets some internal xattrs to mark the brick in
 //use
 func ValidateXattrSupport(brickPath string, host string, volid uuid.UUID, force bool) error {
+	return ValidateXattrSupportWithOpts(brickPath, host, volid, BrickValidationOpts{Force: force})
+}
+
+// ValidateXattrSupportWithOpts is ValidateXattrSupport with options. A
+// read-only validation doesn't mark the brick in use, and checks the nearest
+// existing ancestor of a brick path that doesn't exist yet. The test xattr is
+// still set and removed, as that is the only way to check for support.
+func ValidateXattrSupportWithOpts(brickPath string, host string, volid uuid.UUID, opts BrickValidationOpts) error {
 	var err error
+	if opts.ReadOnly {
+		brickPath = nearestExistingPath(brickPath)
+	}
 	err = Setxattr(brickPath, "trusted.glusterfs.test", []byte("working"), 0)
-	if err != nil {
+	if err == unix.EROFS {
+		log.WithFields(log.Fields{
+			"brickPath": brickPath,
+			"host":      host}).Error(errors.ErrBrickFilesystemReadOnly.Error())
+		return errors.ErrBrickFilesystemReadOnly
+	} else if err != nil {
 		log.WithFields(log.Fields{"error": err.Error(),
 			"brickPath": brickPath,
 			"host":      host,
@@ -259,7 +462,23 @@ func ValidateXattrSupport(brickPath string, host string, volid uuid.UUID, force
 			"xattr":     testXattr}).Error("removexattr failed")
 		return err
 	}
-	if !force {
+	if opts.Recover {
+		recovered, err := checkRecoveredVolumeID(brickPath, volid)
+		if err != nil {
+			log.WithFields(log.Fields{"error": err.Error(),
+				"brickPath": brickPath,
+				"host":      host}).Error("brick can't be recovered")
+			return err
+		}
+		if recovered {
+			log.WithFields(log.Fields{
+				"brickPath": brickPath,
+				"host":      host,
+				"volume-id": volid.String()}).Info("brick already belongs to the volume, leaving it as is")
+			return nil
+		}
+	}
+	if !opts.Force {
 		if isBrickPathAlreadyInUse(brickPath) {
 			log.WithFields(log.Fields{
 				"brickPath": brickPath,
@@ -267,6 +486,9 @@ func ValidateXattrSupport(brickPath string, host string, volid uuid.UUID, force
 			return errors.ErrBrickPathAlreadyInUse
 		}
 	}
+	if opts.ReadOnly {
+		return nil
+	}
 	err = Setxattr(brickPath, volumeIDXattr, []byte(volid), 0)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err.Error(),
@@ -279,6 +501,49 @@ func ValidateXattrSupport(brickPath string, host string, volid uuid.UUID, force
 	return nil
 }
 
+// checkRecoveredVolumeID returns true if the brick is marked with the given
+// volume ID, and false if it isn't marked at all. A brick marked with a
+// different volume ID is an error.
+func checkRecoveredVolumeID(brickPath string, volid uuid.UUID) (bool, error) {
+	found, err := GetBrickVolumeID(brickPath)
+	switch {
+	case err != nil:
+		return false, err
+	case found == nil:
+		return false, nil
+	case !uuid.Equal(found, volid):
+		return false, fmt.Errorf("brick %s belongs to volume %s, not to the recovered volume %s", brickPath, found, volid)
+	}
+	return true, nil
+}
+
+// GetBrickVolumeID returns the volume ID the brick is marked with, or nil if
+// it isn't marked
+func GetBrickVolumeID(brickPath string) (uuid.UUID, error) {
+	buf := make([]byte, 16)
+	size, err := Getxattr(brickPath, volumeIDXattr, buf)
+	switch {
+	case err == unix.ENODATA:
+		return nil, nil
+	case err == unix.ERANGE || (err == nil && size != len(buf)):
+		return nil, fmt.Errorf("brick %s is marked with an invalid volume ID", brickPath)
+	case err != nil:
+		return nil, err
+	}
+	return uuid.UUID(buf), nil
+}
+
+// nearestExistingPath returns the path if it exists, or else its nearest
+// existing ancestor
+func nearestExistingPath(p string) string {
+	for ; p != "/"; p = path.Dir(p) {
+		if _, err := os.Lstat(p); !os.IsNotExist(err) {
+			return p
+		}
+	}
+	return p
+}
+
 func isBrickPathAlreadyInUse(brickPath string) bool {
 	keys := []string{gfidXattr, volumeIDXattr}
 	var p string
@@ -300,6 +565,56 @@ func isBrickPathAlreadyInUse(brickPath string) bool {
 	return false
 }
 
+func isGlusterXattr(name string) bool {
+	for _, prefix := range glusterXattrPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetGlusterXattrs returns the gluster xattrs set on the given path, with
+// their values hex encoded as done by `getfattr -e hex`. Xattrs outside the
+// gluster namespace are ignored.
+func GetGlusterXattrs(path string) (map[string]string, error) {
+	xattrs := make(map[string]string)
+
+	size, err := Listxattr(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		return xattrs, nil
+	}
+
+	buf := make([]byte, size)
+	size, err = Listxattr(path, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range strings.Split(string(buf[:size]), "\x00") {
+		if name == "" || !isGlusterXattr(name) {
+			continue
+		}
+
+		vsize, err := Getxattr(path, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, vsize)
+		if vsize > 0 {
+			if vsize, err = Getxattr(path, name, value); err != nil {
+				return nil, err
+			}
+		}
+		xattrs[name] = "0x" + hex.EncodeToString(value[:vsize])
+	}
+
+	return xattrs, nil
+}
+
 // InitDir creates directory path and checks if files can be created in it.
 // Returns error if path is not a directory or if directory doesn't have
 // write permission.
@@ -320,17 +635,37 @@ func InitDir(path string) error {
 	return nil
 }
 
-// GetLocalIP will give local IP address of this node
+// GetLocalIP will give local IP address of this node, in the preferred
+// address family. Without a preferred family, an IPv4 address is picked if
+// the node has one, and an IPv6 one otherwise.
 func GetLocalIP() (string, error) {
 	addrs, err := net.InterfaceAddrs()
 	if err != nil {
 		return "", err
 	}
+	return pickLocalIP(addrs, AddressFamily())
+}
 
-	for _, address := range addrs {
-		// check the address type and if it is not a loopback then return it
-		if ipnet, ok := address.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
-			if ipnet.IP.To4() != nil {
+// pickLocalIP picks the address of the node in the given family from its
+// interface addresses
+func pickLocalIP(addrs []net.Addr, family string) (string, error) {
+	families := []string{family}
+	if family == AddressFamilyAuto {
+		families = []string{AddressFamilyIPv4, AddressFamilyIPv6}
+	}
+
+	for _, f := range families {
+		for _, address := range addrs {
+			// check the address type and if it is not a loopback then return it
+			ipnet, ok := address.(*net.IPNet)
+			if !ok || ipnet.IP.IsLoopback() {
+				continue
+			}
+			// link-local IPv6 addresses aren't usable without a zone
+			if f == AddressFamilyIPv6 && ipnet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			if isAddressFamily(ipnet.IP, f) {
 				return ipnet.IP.String(), nil
 			}
 		}
@@ -338,6 +673,49 @@ func GetLocalIP() (string, error) {
 	return "", errors.ErrIPAddressNotFound
 }
 
+// virtualInterfacePrefixes are the name prefixes of the bridges and virtual
+// interfaces of containers and VMs, whose addresses aren't reachable from
+// other nodes
+var virtualInterfacePrefixes = []string{"docker", "virbr", "br-", "veth", "cni", "flannel", "lxcbr"}
+
+func isVirtualInterface(name string) bool {
+	for _, prefix := range virtualInterfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetLocalIPs returns the IP addresses of this node other nodes can reach it
+// on. Loopback and link-local addresses, and those of interfaces which are
+// down or are container or VM bridges, are left out.
+func GetLocalIPs() ([]string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || isVirtualInterface(iface.Name) {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, address := range addrs {
+			ipnet, ok := address.(*net.IPNet)
+			if !ok || ipnet.IP.IsLoopback() || ipnet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			ips = append(ips, ipnet.IP.String())
+		}
+	}
+	return ips, nil
+}
+
 // GetFuncName returns the name of the passed function pointer
 func GetFuncName(fn interface{}) string {
 	return runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
@@ -371,11 +749,102 @@ func IsAddressSame(host1, host2 string) bool {
 		return false
 	}
 
-	for _, a := range addrs1 {
-		if StringInSlice(a, addrs2) {
-			return true
+	// The addresses are compared parsed, as IPv6 addresses can be written
+	// in several ways
+	for _, a1 := range addrs1 {
+		for _, a2 := range addrs2 {
+			if ip1, ip2 := parseIP(a1), parseIP(a2); a1 == a2 || (ip1 != nil && ip1.Equal(ip2)) {
+				return true
+			}
 		}
 	}
 
 	return false
 }
+
+// RemoveVolumeIDXattr removes the volume ID xattr from the brick, if it is set
+// to the given volume ID. It undoes the marking done by ValidateXattrSupport.
+func RemoveVolumeIDXattr(brickPath string, volid uuid.UUID) error {
+	if err := removeVolumeIDXattr(brickPath, volid); err != unix.ENODATA {
+		return err
+	}
+	return nil
+}
+
+// RemoveBrickXattrs undoes the preparation of a brick for the given volume,
+// done by ValidateXattrSupport and ValidateBrickPathStats. It removes the
+// volume ID xattr, if set to the given volume ID, and the .glusterfs
+// directories created for the brick, if they are empty.
+//
+// Without force, it fails on the first item which can't be removed, including
+// items which are missing. With force it is best-effort, for cleaning up
+// bricks whose preparation failed midway: it also removes a leftover test
+// xattr, ignores missing items, and returns an error only for failures other
+// than those, after trying every item.
+func RemoveBrickXattrs(brickPath string, volid uuid.UUID, force bool) error {
+	glusterDir := filepath.Join(brickPath, ".glusterfs")
+	items := []struct {
+		name   string
+		remove func() error
+	}{
+		{volumeIDXattr, func() error { return removeVolumeIDXattr(brickPath, volid) }},
+		{testXattr, func() error {
+			if !force {
+				return nil
+			}
+			return Removexattr(brickPath, testXattr)
+		}},
+		{filepath.Join(glusterDir, "indices"), func() error { return removeEmptyDir(filepath.Join(glusterDir, "indices")) }},
+		{glusterDir, func() error { return removeEmptyDir(glusterDir) }},
+	}
+
+	var errs []string
+	for _, item := range items {
+		err := item.remove()
+		if err == nil {
+			continue
+		}
+		if !force {
+			return err
+		}
+		log.WithError(err).WithFields(log.Fields{
+			"brick": brickPath,
+			"item":  item.name,
+		}).Debug("failed to remove brick item")
+		if err != unix.ENODATA && !os.IsNotExist(err) {
+			errs = append(errs, item.name+": "+err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to clean up brick %s: %s", brickPath, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// removeVolumeIDXattr removes the volume ID xattr from the brick if it is set
+// to the given volume ID, failing with ENODATA if it isn't set
+func removeVolumeIDXattr(brickPath string, volid uuid.UUID) error {
+	buf := make([]byte, len(volid))
+	size, err := Getxattr(brickPath, volumeIDXattr, buf)
+	if err == unix.ERANGE {
+		// Set to an ID of a different length
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !uuid.Equal(uuid.UUID(buf[:size]), volid) {
+		return nil
+	}
+	return Removexattr(brickPath, volumeIDXattr)
+}
+
+// removeEmptyDir removes the directory if it is empty. A directory which
+// isn't empty is in use, and is left alone.
+func removeEmptyDir(dir string) error {
+	err := os.Remove(dir)
+	if pe, ok := err.(*os.PathError); ok && (pe.Err == unix.ENOTEMPTY || pe.Err == unix.EEXIST) {
+		return nil
+	}
+	return err
+}
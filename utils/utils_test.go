@@ -1,10 +1,24 @@
 package utils
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"golang.org/x/sys/unix"
 
@@ -13,6 +27,7 @@ import (
 	"github.com/pborman/uuid"
 
 	heketitests "github.com/heketi/tests"
+	config "github.com/spf13/viper"
 )
 
 func TestIsLocalAddress(t *testing.T) {
@@ -34,6 +49,16 @@ func TestIsLocalAddress(t *testing.T) {
 	local, e = IsLocalAddress("122.122.122.122.122")
 	tests.Assert(t, local == false)
 	tests.Assert(t, e != nil)
+
+	for _, a := range []string{"::1", "[::1]", "[::1]:24007", "0:0:0:0:0:0:0:1", "ip6-localhost"} {
+		local, e = IsLocalAddress(a)
+		tests.Assert(t, local == true)
+		tests.Assert(t, e == nil)
+	}
+
+	local, e = IsLocalAddress("2001:db8::1")
+	tests.Assert(t, local == false)
+	tests.Assert(t, e == nil)
 }
 
 func TestParseHostAndBrickPath(t *testing.T) {
@@ -57,6 +82,16 @@ func TestParseHostAndBrickPath(t *testing.T) {
 	tests.Assert(t, e == nil)
 	tests.Assert(t, h == "a:b")
 	tests.Assert(t, b == "c")
+
+	for _, p := range []string{"[2001:db8::1]:/brick", "2001:db8::1:/brick"} {
+		h, b, e = ParseHostAndBrickPath(p)
+		tests.Assert(t, e == nil)
+		tests.Assert(t, h == "2001:db8::1")
+		tests.Assert(t, b == brick)
+	}
+
+	_, _, e = ParseHostAndBrickPath("[2001:db8::1]/brick")
+	tests.Assert(t, e != nil)
 }
 
 func TestValidateBrickPathLength(t *testing.T) {
@@ -120,3 +155,344 @@ func TestValidateXattrSupport(t *testing.T) {
 	tests.Assert(t, ValidateXattrSupport("/tmp/b1", "localhost", uuid.NewRandom(), true) == baderror)
 
 }
+
+func TestValidateXattrSupportRecover(t *testing.T) {
+	volid := uuid.NewRandom()
+	var onDisk []byte
+	var set bool
+	defer heketitests.Patch(&Setxattr, func(path string, attr string, data []byte, flags int) (err error) {
+		if attr == volumeIDXattr {
+			set = true
+		}
+		return nil
+	}).Restore()
+	defer heketitests.Patch(&Removexattr, tests.MockRemovexattr).Restore()
+	defer heketitests.Patch(&Getxattr, func(path string, attr string, dest []byte) (sz int, err error) {
+		if attr != volumeIDXattr || onDisk == nil {
+			return 0, unix.ENODATA
+		}
+		return copy(dest, onDisk), nil
+	}).Restore()
+
+	opts := BrickValidationOpts{Recover: true}
+
+	// A brick of the volume is left as it is
+	onDisk = volid
+	tests.Assert(t, ValidateXattrSupportWithOpts("/tmp/b1", "localhost", volid, opts) == nil)
+	tests.Assert(t, !set)
+
+	// A brick of another volume is rejected with both IDs
+	other := uuid.NewRandom()
+	onDisk = other
+	err := ValidateXattrSupportWithOpts("/tmp/b1", "localhost", volid, opts)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, strings.Contains(err.Error(), volid.String()) && strings.Contains(err.Error(), other.String()))
+
+	// An unused brick is marked as usual
+	onDisk = nil
+	tests.Assert(t, ValidateXattrSupportWithOpts("/tmp/b1", "localhost", volid, opts) == nil)
+	tests.Assert(t, set)
+}
+
+func TestGetGlusterXattrs(t *testing.T) {
+	attrs := map[string][]byte{
+		"trusted.glusterfs.volume-id": {0xab, 0xcd},
+		"trusted.afr.vol-client-0":    {0, 0, 0, 1},
+		"user.comment":                []byte("not gluster"),
+	}
+	defer heketitests.Patch(&Listxattr, func(path string, dest []byte) (sz int, err error) {
+		var names []byte
+		for name := range attrs {
+			names = append(names, append([]byte(name), 0)...)
+		}
+		if dest == nil {
+			return len(names), nil
+		}
+		return copy(dest, names), nil
+	}).Restore()
+	defer heketitests.Patch(&Getxattr, func(path string, attr string, dest []byte) (sz int, err error) {
+		if dest == nil {
+			return len(attrs[attr]), nil
+		}
+		return copy(dest, attrs[attr]), nil
+	}).Restore()
+
+	xattrs, err := GetGlusterXattrs("/tmp/b1")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, len(xattrs) == 2)
+	tests.Assert(t, xattrs["trusted.glusterfs.volume-id"] == "0xabcd")
+	tests.Assert(t, xattrs["trusted.afr.vol-client-0"] == "0x00000001")
+}
+
+func TestValidateBrickPathDepth(t *testing.T) {
+	tests.Assert(t, ValidateBrickPathDepth("/gd2-nonexistent/a/b/c", 0) == nil)
+	tests.Assert(t, ValidateBrickPathDepth("/gd2-nonexistent/a/b/c", 4) == nil)
+	tests.Assert(t, ValidateBrickPathDepth("/gd2-nonexistent/a/b/c/", 4) == nil)
+	tests.Assert(t, ValidateBrickPathDepth("/gd2-nonexistent/a/b/c", 3) != nil)
+}
+
+func TestRemoveVolumeIDXattr(t *testing.T) {
+	volid := uuid.NewRandom()
+	stored := []byte(volid)
+	removed := false
+
+	defer heketitests.Patch(&Getxattr, func(path string, attr string, dest []byte) (sz int, err error) {
+		if stored == nil {
+			return 0, unix.ENODATA
+		}
+		return copy(dest, stored), nil
+	}).Restore()
+	defer heketitests.Patch(&Removexattr, func(path string, attr string) (err error) {
+		removed = true
+		return nil
+	}).Restore()
+
+	// Marked by a different volume, left alone
+	tests.Assert(t, RemoveVolumeIDXattr("/tmp/b1", uuid.NewRandom()) == nil)
+	tests.Assert(t, !removed)
+
+	tests.Assert(t, RemoveVolumeIDXattr("/tmp/b1", volid) == nil)
+	tests.Assert(t, removed)
+
+	// Not marked at all
+	stored = nil
+	removed = false
+	tests.Assert(t, RemoveVolumeIDXattr("/tmp/b1", volid) == nil)
+	tests.Assert(t, !removed)
+}
+
+func TestValidateBrickPathStatsReadOnly(t *testing.T) {
+	opts := BrickValidationOpts{Force: true, ReadOnly: true}
+	tests.Assert(t, ValidateBrickPathStatsWithOpts("/tmp/bricks-ro/b1", "host", opts) == nil)
+	_, err := os.Stat("/tmp/bricks-ro")
+	tests.Assert(t, os.IsNotExist(err))
+}
+
+func TestValidateXattrSupportReadOnly(t *testing.T) {
+	var set []string
+	defer heketitests.Patch(&Setxattr, func(path string, attr string, data []byte, flags int) (err error) {
+		set = append(set, path+" "+attr)
+		return nil
+	}).Restore()
+	defer heketitests.Patch(&Getxattr, tests.MockGetxattr).Restore()
+	defer heketitests.Patch(&Removexattr, tests.MockRemovexattr).Restore()
+
+	// A brick that doesn't exist yet is checked on its existing ancestor,
+	// and isn't marked in use
+	opts := BrickValidationOpts{Force: true, ReadOnly: true}
+	tests.Assert(t, ValidateXattrSupportWithOpts("/tmp/nonexistent/b1", "localhost", uuid.NewRandom(), opts) == nil)
+	tests.Assert(t, len(set) == 1)
+	tests.Assert(t, set[0] == "/tmp "+testXattr)
+}
+
+func TestValidatePeerAddress(t *testing.T) {
+	for _, a := range []string{"node1", "node1:24008", "192.168.1.10", "[fe80::1]:24008", "2001:db8::1", "[2001:db8::1]"} {
+		tests.Assert(t, ValidatePeerAddress(a) == nil)
+	}
+	for _, a := range []string{"", ":24008", "node1:", "node1:port", "node1:70000", "node 1", "/node1"} {
+		tests.Assert(t, ValidatePeerAddress(a) != nil)
+	}
+}
+
+func TestIsPeerHostSame(t *testing.T) {
+	port := config.GetString("defaultpeerport")
+	config.Set("defaultpeerport", "24008")
+	defer config.Set("defaultpeerport", port)
+
+	tests.Assert(t, IsPeerHostSame("localhost", "127.0.0.1:24008"))
+	tests.Assert(t, IsPeerHostSame("127.0.0.1", "127.0.0.1"))
+	tests.Assert(t, !IsPeerHostSame("localhost:24009", "127.0.0.1:24008"))
+	tests.Assert(t, !IsPeerHostSame("127.0.0.1", "192.0.2.1"))
+
+	tests.Assert(t, IsPeerHostSame("::1", "[::1]:24008"))
+	tests.Assert(t, IsPeerHostSame("2001:db8::1", "[2001:DB8:0::1]"))
+	tests.Assert(t, !IsPeerHostSame("2001:db8::1", "2001:db8::2"))
+}
+
+func TestFormRemotePeerAddress(t *testing.T) {
+	port := config.GetString("defaultpeerport")
+	config.Set("defaultpeerport", "24008")
+	defer config.Set("defaultpeerport", port)
+
+	for addr, expected := range map[string]string{
+		"node1":               "node1:24008",
+		"node1:24009":         "node1:24009",
+		"2001:db8::1":         "[2001:db8::1]:24008",
+		"[2001:db8::1]":       "[2001:db8::1]:24008",
+		"[2001:db8::1]:24009": "[2001:db8::1]:24009",
+	} {
+		a, err := FormRemotePeerAddress(addr)
+		tests.Assert(t, err == nil)
+		tests.Assert(t, a == expected)
+	}
+}
+
+func TestPickLocalIP(t *testing.T) {
+	ipnet := func(s string) net.Addr {
+		return &net.IPNet{IP: net.ParseIP(s), Mask: net.CIDRMask(64, 128)}
+	}
+	v6only := []net.Addr{ipnet("::1"), ipnet("fe80::1"), ipnet("2001:db8::1")}
+	dual := append([]net.Addr{ipnet("192.0.2.1")}, v6only...)
+
+	ip, err := pickLocalIP(v6only, AddressFamilyAuto)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, ip == "2001:db8::1")
+
+	ip, err = pickLocalIP(dual, AddressFamilyAuto)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, ip == "192.0.2.1")
+
+	ip, err = pickLocalIP(dual, AddressFamilyIPv6)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, ip == "2001:db8::1")
+
+	_, err = pickLocalIP(v6only, AddressFamilyIPv4)
+	tests.Assert(t, err != nil)
+}
+
+func TestTransportAddressFamily(t *testing.T) {
+	family := config.GetString("addressfamily")
+	defer config.Set("addressfamily", family)
+
+	config.Set("addressfamily", AddressFamilyAuto)
+	tests.Assert(t, TransportAddressFamily("node1") == "inet")
+	tests.Assert(t, TransportAddressFamily("192.0.2.1") == "inet")
+	tests.Assert(t, TransportAddressFamily("2001:db8::1") == "inet6")
+
+	config.Set("addressfamily", AddressFamilyIPv6)
+	tests.Assert(t, TransportAddressFamily("node1") == "inet6")
+}
+
+func TestReadSSLCA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gd2-ssl")
+	tests.Assert(t, err == nil)
+	defer os.RemoveAll(dir)
+	defer config.Set("ssl-cert-dir", config.GetString("ssl-cert-dir"))
+	config.Set("ssl-cert-dir", dir)
+
+	_, err = ReadSSLCA()
+	tests.Assert(t, err != nil)
+
+	ioutil.WriteFile(filepath.Join(dir, sslCAFile), []byte("not a certificate"), 0600)
+	_, err = ReadSSLCA()
+	tests.Assert(t, err != nil)
+
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: []byte("garbage")}
+	ioutil.WriteFile(filepath.Join(dir, sslCAFile), pem.EncodeToMemory(block), 0600)
+	_, err = ReadSSLCA()
+	tests.Assert(t, err != nil)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tests.Assert(t, err == nil)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gd2-test-ca"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	tests.Assert(t, err == nil)
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	ioutil.WriteFile(filepath.Join(dir, sslCAFile), ca, 0600)
+	b, err := ReadSSLCA()
+	tests.Assert(t, err == nil && string(b) == string(ca))
+}
+
+func TestRemoveBrickXattrs(t *testing.T) {
+	volid := uuid.NewRandom()
+	var removed []string
+
+	defer heketitests.Patch(&Getxattr, func(path string, attr string, dest []byte) (sz int, err error) {
+		return 0, unix.ENODATA
+	}).Restore()
+	defer heketitests.Patch(&Removexattr, func(path string, attr string) (err error) {
+		if attr == testXattr {
+			return unix.EPERM
+		}
+		removed = append(removed, attr)
+		return nil
+	}).Restore()
+
+	// A brick whose preparation failed before anything was set
+	tests.Assert(t, RemoveBrickXattrs("/gd2-nonexistent/b1", volid, false) != nil)
+	err := RemoveBrickXattrs("/gd2-nonexistent/b1", volid, true)
+	tests.Assert(t, err != nil && strings.Contains(err.Error(), testXattr))
+	tests.Assert(t, len(removed) == 0)
+
+	brick, err := ioutil.TempDir("", "gd2-brick")
+	tests.Assert(t, err == nil)
+	defer os.RemoveAll(brick)
+	tests.Assert(t, os.MkdirAll(filepath.Join(brick, ".glusterfs", "indices"), 0755) == nil)
+	defer heketitests.Patch(&Removexattr, func(path string, attr string) (err error) {
+		return unix.ENODATA
+	}).Restore()
+	tests.Assert(t, RemoveBrickXattrs(brick, volid, true) == nil)
+	_, err = os.Stat(filepath.Join(brick, ".glusterfs"))
+	tests.Assert(t, os.IsNotExist(err))
+}
+
+// procNetAddress formats an address as in /proc/net/tcp, the IP being in
+// 32-bit words of host byte order
+func procNetAddress(ip net.IP, port int) string {
+	b := make([]byte, len(ip))
+	for w := 0; w < len(ip); w += 4 {
+		nativeEndian.PutUint32(b[w:], binary.BigEndian.Uint32(ip[w:]))
+	}
+	return fmt.Sprintf("%X:%04X", b, port)
+}
+
+// TestParseProcNetAddress validates the parsing of the addresses of
+// /proc/net/tcp and /proc/net/tcp6, whatever the byte order of the host
+func TestParseProcNetAddress(t *testing.T) {
+	for _, tc := range []struct {
+		ip   string
+		port int
+	}{
+		{"127.0.0.1", 49153},
+		{"10.0.2.15", 22},
+		{"::ffff:10.0.2.15", 22},
+		{"fe80::1:2:3:4", 24007},
+		{"::", 0},
+	} {
+		ip := net.ParseIP(tc.ip)
+		if v4 := ip.To4(); v4 != nil && !strings.Contains(tc.ip, ":") {
+			ip = v4
+		}
+		s := procNetAddress(ip, tc.port)
+		parsed, port, err := parseProcNetAddress(s)
+		if err != nil || !parsed.Equal(ip) || port != tc.port {
+			t.Errorf("parseProcNetAddress(%s): expected %s port %d, got %v port %d, %v", s, tc.ip, tc.port, parsed, port, err)
+		}
+	}
+
+	for _, s := range []string{"0100007F", "0100007F:", "0100007F:10000", "01007F:0016", "ZZ00007F:0016"} {
+		if _, _, err := parseProcNetAddress(s); err == nil {
+			t.Errorf("parseProcNetAddress(%s): expected an error", s)
+		}
+	}
+}
+
+// TestListConnections validates that only the established connections to the
+// port are listed from a /proc/net/tcp table
+func TestListConnections(t *testing.T) {
+	local := procNetAddress(net.ParseIP("10.0.0.1").To4(), 49152)
+	lines := []string{
+		"  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode",
+		"   0: " + local + " " + procNetAddress(net.ParseIP("10.0.0.2").To4(), 1000) + " 01 00000000:00000000 00:00000000 00000000     0        0 1",
+		"   1: " + local + " " + procNetAddress(net.ParseIP("10.0.0.3").To4(), 1001) + " 0A 00000000:00000000 00:00000000 00000000     0        0 2",
+		"   2: " + procNetAddress(net.ParseIP("10.0.0.1").To4(), 24007) + " " + procNetAddress(net.ParseIP("10.0.0.4").To4(), 1002) + " 01 00000000:00000000 00:00000000 00000000     0        0 3",
+		"   3: " + local + " " + procNetAddress(net.ParseIP("10.0.0.5").To4(), 1003) + " 01 00000000:00000000 00:00000000 00000000     0        0 4",
+		"   4: garbage",
+	}
+	f, err := ioutil.TempFile("", "tcp")
+	tests.Assert(t, err == nil)
+	defer os.Remove(f.Name())
+	f.WriteString(strings.Join(lines, "\n") + "\n")
+	f.Close()
+
+	conns, err := listConnections(f.Name(), 49152)
+	tests.Assert(t, err == nil && len(conns) == 2)
+	tests.Assert(t, conns[0] == Connection{RemoteIP: "10.0.0.2", RemotePort: 1000})
+	tests.Assert(t, conns[1] == Connection{RemoteIP: "10.0.0.5", RemotePort: 1003})
+}
@@ -0,0 +1,17 @@
+package version
+
+// featureOpVersions are the op-versions which every node of the cluster must
+// support for a feature to be used. Features changing what the bricks or
+// glusterd2 of every node have to understand are listed here.
+var featureOpVersions = map[string]int{
+	"subdir-exports": 40100,
+	"read-only":      40100,
+	"brick-args":     40100,
+}
+
+// FeatureOpVersion returns the op-version the feature requires, and false if
+// the feature isn't version-gated
+func FeatureOpVersion(feature string) (int, bool) {
+	v, ok := featureOpVersions[feature]
+	return v, ok
+}
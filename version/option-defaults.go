@@ -0,0 +1,33 @@
+package version
+
+import "sort"
+
+// optionDefaults are the volume option defaults recommended from each
+// op-version on. Volumes created before an op-version keep the options they
+// had, until their options are migrated. The defaults of an op-version add
+// to, or override, those of the earlier op-versions.
+var optionDefaults = map[int]map[string]string{
+	40100: {
+		"dht.lookup-optimize": "on",
+	},
+}
+
+// OptionDefaults returns the volume option defaults recommended at the
+// op-version
+func OptionDefaults(opVersion int) map[string]string {
+	var versions []int
+	for v := range optionDefaults {
+		if v <= opVersion {
+			versions = append(versions, v)
+		}
+	}
+	sort.Ints(versions)
+
+	defaults := make(map[string]string)
+	for _, v := range versions {
+		for k, val := range optionDefaults[v] {
+			defaults[k] = val
+		}
+	}
+	return defaults
+}
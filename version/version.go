@@ -9,8 +9,12 @@ import (
 
 // MaxOpVersion and APIVersion supported
 const (
-	MaxOpVersion = 40000
+	MaxOpVersion = 40100
 	APIVersion   = 1
+	// MinAPIVersion is the oldest REST API version still served. All the
+	// versions from it up to APIVersion are served, each under its own
+	// /v<version> prefix.
+	MinAPIVersion = 1
 )
 
 // GlusterdVersion and GitSHA
@@ -23,6 +27,15 @@ func init() {
 	flag.Bool("version", false, "Show the version information")
 }
 
+// APIVersions returns the REST API versions served
+func APIVersions() []int {
+	var versions []int
+	for v := MinAPIVersion; v <= APIVersion; v++ {
+		versions = append(versions, v)
+	}
+	return versions
+}
+
 // DumpVersionInfo prints all version information
 func DumpVersionInfo() {
 	fmt.Printf("glusterd version: %s\n", GlusterdVersion)
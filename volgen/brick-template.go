@@ -66,7 +66,7 @@ end-volume
 
 volume <volume-name>-read-only
     type features/read-only
-    option read-only off
+    option read-only <read-only>
     subvolumes <volume-name>-worm
 end-volume
 
@@ -101,8 +101,8 @@ end-volume
 
 volume <volume-name>-barrier
     type features/barrier
-    option barrier-timeout 120
-    option barrier disable
+    option barrier-timeout <barrier-timeout>
+    option barrier <barrier>
     subvolumes <volume-name>-marker
 end-volume
 
@@ -125,10 +125,10 @@ end-volume
 
 volume <volume-name>-io-stats
     type debug/io-stats
-    option count-fop-hits off
-    option latency-measurement off
+    option count-fop-hits <io-stats>
+    option latency-measurement <io-stats>
     option log-level INFO
-    option unique-id <brick-path>
+    option unique-id <brick-path><io-stats-dump>
     subvolumes <volume-name>-quota
 end-volume
 
@@ -139,11 +139,11 @@ end-volume
 
 volume <volume-name>-server
     type protocol/server
-    option auth.addr.<brick-path>.allow *
+    option auth.addr.<brick-path>.allow <auth-allow>
     option auth-path <brick-path>
     option auth.login.<trusted-username>.password <trusted-password>
     option auth.login.<brick-path>.allow <trusted-username>
-    option transport.address-family inet
+    option transport.address-family <address-family>
     option transport-type tcp
     subvolumes <brick-path>
 end-volume
@@ -62,7 +62,8 @@ var clientVolfileAFRTemplate = `
 volume <volume-name>-replicate<child-index>
     type cluster/replicate
     option use-compound-fops off
-    option afr-pending-xattr <afr-pending-xattr>
+    option self-heal-daemon <self-heal-daemon>
+    option afr-pending-xattr <afr-pending-xattr><thin-arbiter>
     subvolumes <afr-subvolumes>
 end-volume
 `
@@ -73,10 +74,22 @@ volume <volume-name>-client-<child-index>
     option send-gids true
     option password <trusted-password>
     option username <trusted-username>
-    option transport.address-family inet
+    option transport.address-family <address-family>
     option transport-type tcp
     option remote-subvolume <brick-path>
     option remote-host <remote-host>
     option ping-timeout 42
 end-volume
 `
+
+var clientThinArbiterTemplate = `
+volume <ta-client>
+    type protocol/client
+    option transport.address-family <address-family>
+    option transport-type tcp
+    option remote-subvolume <ta-path>
+    option remote-host <remote-host>
+    option remote-port <remote-port>
+    option ping-timeout 42
+end-volume
+`
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/peer"
 	"github.com/gluster/glusterd2/store"
 	"github.com/gluster/glusterd2/utils"
 	"github.com/gluster/glusterd2/volume"
@@ -21,6 +22,10 @@ import (
 // TODO: differentiate between various types of client volfiles
 var volfilePrefix = store.GlusterPrefix + "volfiles/"
 
+// defaultBarrierTimeout is the barrier timeout of bricks whose barrier isn't
+// enabled
+const defaultBarrierTimeout = "120"
+
 // TODO: This is a quick and dirty reference implementation that should
 // be replaced when real volgen with dependency resolution is ready.
 // This is not complete either - works only for dist, rep and dist-rep
@@ -29,6 +34,29 @@ var volfilePrefix = store.GlusterPrefix + "volfiles/"
 // GenerateClientVolfile generates the client volfile and stores it in etcd
 func GenerateClientVolfile(vinfo *volume.Volinfo) error {
 
+	volfile, err := GetClientVolfile(vinfo)
+	if err != nil {
+		return err
+	}
+
+	if _, err := store.Store.Put(context.TODO(), volfilePrefix+vinfo.Name, volfile); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetClientVolfile generates and returns the client volfile of the volume,
+// with the volume options set in the xlators they apply to
+func GetClientVolfile(vinfo *volume.Volinfo) (string, error) {
+	volfile, _, err := ResolveClientVolfile(vinfo)
+	return volfile, err
+}
+
+// clientVolfileGraph generates the graph of the client volfile of the volume,
+// before the volume options are set in it
+func clientVolfileGraph(vinfo *volume.Volinfo) (string, error) {
+
 	volfile := new(bytes.Buffer)
 
 	// Insert leaf nodes i.e client xlators
@@ -36,9 +64,14 @@ func GenerateClientVolfile(vinfo *volume.Volinfo) error {
 
 		address, err := utils.FormRemotePeerAddress(b.Hostname)
 		if err != nil {
-			return err
+			return "", err
 		}
 		remoteHost, _, _ := net.SplitHostPort(address)
+		// Clients reach the bricks of peers having a storage address
+		// on it
+		if p, err := peer.GetPeerF(b.NodeID.String()); err == nil && p.StorageAddress != "" {
+			remoteHost = p.StorageAddress
+		}
 
 		replacer := strings.NewReplacer(
 			"<child-index>", strconv.Itoa(index),
@@ -46,7 +79,8 @@ func GenerateClientVolfile(vinfo *volume.Volinfo) error {
 			"<volume-name>", vinfo.Name,
 			"<trusted-username>", vinfo.Auth.Username,
 			"<trusted-password>", vinfo.Auth.Password,
-			"<remote-host>", remoteHost)
+			"<remote-host>", remoteHost,
+			"<address-family>", utils.TransportAddressFamily(remoteHost))
 
 		volfile.WriteString(replacer.Replace(clientLeafTemplate))
 	}
@@ -70,11 +104,34 @@ func GenerateClientVolfile(vinfo *volume.Volinfo) error {
 			} else {
 				childIndex = "-" + strconv.Itoa(rindex)
 			}
+			// The thin-arbiter is reached through a client xlator of
+			// its own, which AFR expects to be its last subvolume.
+			// It is numbered after the data bricks of the replica
+			// set, as the name is also its pending xattr.
+			thinArbiter := ""
+			if ta := vinfo.ThinArbiter; ta != nil {
+				taClient := fmt.Sprintf("%s-ta-%d", vinfo.Name, rindex*(vinfo.ReplicaCount+1)+vinfo.ReplicaCount)
+				taReplacer := strings.NewReplacer(
+					"<ta-client>", taClient,
+					"<ta-path>", ta.Path,
+					"<remote-host>", ta.Host,
+					"<remote-port>", ta.Port,
+					"<address-family>", utils.TransportAddressFamily(ta.Host))
+				volfile.WriteString(taReplacer.Replace(clientThinArbiterTemplate))
+				subvols = append(subvols, taClient)
+				thinArbiter = "\n    option thin-arbiter " + ta.Address() + ":" + ta.Path
+			}
+			selfHeal := "on"
+			if vinfo.SelfHealDisabled {
+				selfHeal = "off"
+			}
 			replacer := strings.NewReplacer(
 				"<volume-name>", vinfo.Name,
+				"<self-heal-daemon>", selfHeal,
 				"<afr-pending-xattr>", strings.Join(subvols, ","),
 				"<afr-subvolumes>", strings.Join(subvols, " "),
-				"<child-index>", childIndex)
+				"<child-index>", childIndex,
+				"<thin-arbiter>", thinArbiter)
 			volfile.WriteString(replacer.Replace(clientVolfileAFRTemplate))
 		}
 	}
@@ -106,11 +163,7 @@ func GenerateClientVolfile(vinfo *volume.Volinfo) error {
 	replacer := strings.NewReplacer("<volume-name>", vinfo.Name, "<wb-subvol>", wbSubvol)
 	volfile.WriteString(replacer.Replace(clientVolfileBaseTemplate))
 
-	if _, err := store.Store.Put(context.TODO(), volfilePrefix+vinfo.Name, volfile.String()); err != nil {
-		return err
-	}
-
-	return nil
+	return volfile.String(), nil
 }
 
 // DeleteClientVolfile deletes the client volfile (duh!)
@@ -140,20 +193,59 @@ func GenerateBrickVolfile(vinfo *volume.Volinfo, binfo *brick.Brickinfo) error {
 	}
 	defer f.Close()
 
+	if _, err = f.WriteString(GetBrickVolfile(vinfo, binfo)); err != nil {
+		return err
+	}
+	f.Sync()
+
+	return nil
+}
+
+// GetBrickVolfile generates and returns the brick volfile for a single brick.
+// This must be called on the node hosting the brick.
+func GetBrickVolfile(vinfo *volume.Volinfo, binfo *brick.Brickinfo) string {
+
+	barrier, barrierTimeout := "disable", defaultBarrierTimeout
+	if vinfo.Barrier.Active() {
+		barrier = "enable"
+		barrierTimeout = strconv.Itoa(vinfo.Barrier.Timeout)
+	}
+
+	// The bricks dump their I/O statistics periodically if enabled, for
+	// them to be read by glusterd2
+	ioStats, ioStatsDump := "off", ""
+	if interval := config.GetInt("brick-stats-interval"); interval > 0 {
+		ioStats = "on"
+		ioStatsDump = "\n    option ios-dump-interval " + strconv.Itoa(interval) +
+			"\n    option ios-dump-format json"
+	}
+
+	readOnly := "off"
+	if vinfo.ReadOnly {
+		readOnly = "on"
+	}
+
+	authAllow := "*"
+	if allow, ok := vinfo.Options[volume.AuthAllowOption]; ok && allow != "" {
+		authAllow = allow
+	}
+
 	replacer := strings.NewReplacer(
+		"<barrier>", barrier,
+		"<auth-allow>", authAllow,
+		"<read-only>", readOnly,
+		"<io-stats>", ioStats,
+		"<io-stats-dump>", ioStatsDump,
+		"<barrier-timeout>", barrierTimeout,
 		"<volume-name>", vinfo.Name,
 		"<volume-id>", vinfo.ID.String(),
 		"<brick-path>", binfo.Path,
 		"<trusted-username>", vinfo.Auth.Username,
 		"<trusted-password>", vinfo.Auth.Password,
+		"<address-family>", utils.TransportAddressFamily(binfo.Hostname),
 		"<local-state-dir>", config.GetString("localstatedir"))
 
-	if _, err = replacer.WriteString(f, brickVolfileTemplate); err != nil {
-		return err
-	}
-	f.Sync()
-
-	return nil
+	return replacer.Replace(brickVolfileTemplate)
 }
 
 // DeleteBrickVolfile deletes the brick volfile of a single brick
@@ -0,0 +1,110 @@
+package volgen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/errors"
+	"github.com/gluster/glusterd2/peer"
+	"github.com/gluster/glusterd2/tests"
+	"github.com/gluster/glusterd2/volume"
+
+	"github.com/pborman/uuid"
+)
+
+func TestGetClientVolfileThinArbiter(t *testing.T) {
+	defer func(f func(string) (*peer.Peer, error)) { peer.GetPeerF = f }(peer.GetPeerF)
+	peer.GetPeerF = func(id string) (*peer.Peer, error) {
+		return nil, errors.ErrPeerNotFound
+	}
+
+	vinfo := &volume.Volinfo{
+		Name:         "vol",
+		ReplicaCount: 2,
+		Bricks: []brick.Brickinfo{
+			{Hostname: "192.168.1.1", Path: "/bricks/b1"},
+			{Hostname: "192.168.1.2", Path: "/bricks/b2"},
+			{Hostname: "192.168.1.1", Path: "/bricks/b3"},
+			{Hostname: "192.168.1.2", Path: "/bricks/b4"},
+		},
+		ThinArbiter: &volume.ThinArbiter{Host: "192.168.1.3", Port: "24007", Path: "/bricks/ta"},
+	}
+
+	volfile, err := GetClientVolfile(vinfo)
+	tests.Assert(t, err == nil)
+
+	// Every replica set has its own thin-arbiter client as its last
+	// subvolume, named after the data bricks of the set
+	for _, ta := range []string{"vol-ta-2", "vol-ta-5"} {
+		tests.Assert(t, strings.Contains(volfile, "volume "+ta+"\n    type protocol/client"))
+	}
+	tests.Assert(t, strings.Contains(volfile, "option remote-subvolume /bricks/ta\n    option remote-host 192.168.1.3\n    option remote-port 24007"))
+	tests.Assert(t, strings.Contains(volfile, "option afr-pending-xattr vol-client-0,vol-client-1,vol-ta-2\n    option thin-arbiter 192.168.1.3:24007:/bricks/ta"))
+	tests.Assert(t, strings.Contains(volfile, "subvolumes vol-client-0 vol-client-1 vol-ta-2\n"))
+	tests.Assert(t, strings.Contains(volfile, "option afr-pending-xattr vol-client-2,vol-client-3,vol-ta-5\n"))
+	tests.Assert(t, strings.Contains(volfile, "subvolumes vol-client-2 vol-client-3 vol-ta-5\n"))
+
+	// The thin-arbiter client is defined before the AFR using it
+	tests.Assert(t, strings.Index(volfile, "volume vol-ta-2") < strings.Index(volfile, "volume vol-replicate-0"))
+
+	vinfo.ThinArbiter = nil
+	volfile, err = GetClientVolfile(vinfo)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, !strings.Contains(volfile, "-ta-"))
+	tests.Assert(t, !strings.Contains(volfile, "thin-arbiter"))
+}
+
+// TestGetClientVolfileStorageAddress validates that clients reach the bricks
+// of peers having a storage address on it
+func TestGetClientVolfileStorageAddress(t *testing.T) {
+	storageNode := uuid.NewRandom()
+	defer func(f func(string) (*peer.Peer, error)) { peer.GetPeerF = f }(peer.GetPeerF)
+	peer.GetPeerF = func(id string) (*peer.Peer, error) {
+		if id == storageNode.String() {
+			return &peer.Peer{Addresses: []string{"192.168.1.1:24008"}, StorageAddress: "10.0.0.1"}, nil
+		}
+		return &peer.Peer{Addresses: []string{"192.168.1.2:24008"}}, nil
+	}
+
+	vinfo := &volume.Volinfo{
+		Name:         "vol",
+		ReplicaCount: 1,
+		Bricks: []brick.Brickinfo{
+			{NodeID: storageNode, Hostname: "192.168.1.1", Path: "/bricks/b1"},
+			{NodeID: uuid.NewRandom(), Hostname: "192.168.1.2", Path: "/bricks/b2"},
+		},
+	}
+	volfile, err := GetClientVolfile(vinfo)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, strings.Contains(volfile, "option remote-subvolume /bricks/b1\n    option remote-host 10.0.0.1\n"))
+	tests.Assert(t, strings.Contains(volfile, "option remote-subvolume /bricks/b2\n    option remote-host 192.168.1.2\n"))
+}
+
+// TestGetClientVolfileOptions validates that the volume options are set in
+// the xlators of the client graph they apply to
+func TestGetClientVolfileOptions(t *testing.T) {
+	defer func(f func(string) (*peer.Peer, error)) { peer.GetPeerF = f }(peer.GetPeerF)
+	peer.GetPeerF = func(id string) (*peer.Peer, error) {
+		return nil, errors.ErrPeerNotFound
+	}
+
+	vinfo := &volume.Volinfo{
+		Name:         "vol",
+		ReplicaCount: 1,
+		Bricks:       []brick.Brickinfo{{Hostname: "192.168.1.1", Path: "/bricks/b1"}},
+		Options: map[string]string{
+			"write-behind.cache-size": "4MB",
+			"client.ping-timeout":     "10",
+			"brick.posix.batch-fsync": "on",
+		},
+	}
+	volfile, unapplied, err := ResolveClientVolfile(vinfo)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, strings.Contains(volfile, "type performance/write-behind\n    option cache-size 4MB\n"))
+	tests.Assert(t, strings.Contains(volfile, "option ping-timeout 10\n") && !strings.Contains(volfile, "ping-timeout 42"))
+	tests.Assert(t, len(unapplied) == 1 && unapplied[0] == "brick.posix.batch-fsync")
+
+	plain, err := GetClientVolfile(vinfo)
+	tests.Assert(t, err == nil && plain == volfile)
+}
@@ -0,0 +1,113 @@
+package volgen
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gluster/glusterd2/volume"
+)
+
+// optionXlatorNames are the names volume options use for the xlators whose
+// shared object is named differently from their volfile type
+var optionXlatorNames = map[string]string{
+	"cluster/replicate":  "afr",
+	"cluster/distribute": "dht",
+}
+
+// optionXlatorName returns the name volume options use for an xlator of the
+// given volfile type, like write-behind for performance/write-behind
+func optionXlatorName(xlType string) string {
+	if name, ok := optionXlatorNames[xlType]; ok {
+		return name
+	}
+	return path.Base(xlType)
+}
+
+// clientGraphOptions groups the volume options applying to the client graph
+// by xlator, as option key and value pairs. Options qualified with a graph
+// other than the client one are left out.
+func clientGraphOptions(vinfo *volume.Volinfo) map[string]map[string]string {
+	opts := make(map[string]map[string]string)
+	for name, value := range vinfo.Options {
+		graph, xl, key := volume.SplitVolumeOptionName(name)
+		if xl == "" || (graph != "" && graph != "client") {
+			continue
+		}
+		if opts[xl] == nil {
+			opts[xl] = make(map[string]string)
+		}
+		opts[xl][key] = value
+	}
+	return opts
+}
+
+// ResolveClientVolfile generates the client volfile of the volume with the
+// volume options set in the xlators they apply to, overriding the values of
+// the template. The options which don't apply to any xlator of the client
+// graph are returned, sorted.
+func ResolveClientVolfile(vinfo *volume.Volinfo) (string, []string, error) {
+	volfile, err := clientVolfileGraph(vinfo)
+	if err != nil {
+		return "", nil, err
+	}
+	opts := clientGraphOptions(vinfo)
+	inGraph := make(map[string]bool)
+
+	var out, section []string
+	var xlType string
+	var optionsSet bool
+	for _, line := range strings.Split(volfile, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 2 && fields[0] == "volume":
+			section = []string{line}
+			xlType, optionsSet = "", false
+			continue
+		case section == nil:
+			out = append(out, line)
+			continue
+		case len(fields) == 2 && fields[0] == "type":
+			xlType = fields[1]
+		case len(fields) >= 2 && fields[0] == "option":
+			// Overridden by the volume option, set below
+			if _, ok := opts[optionXlatorName(xlType)][fields[1]]; ok {
+				continue
+			}
+		case len(fields) >= 1 && (fields[0] == "subvolumes" || fields[0] == "end-volume"):
+			// Set before the subvolumes, once per xlator
+			if !optionsSet {
+				xl := optionXlatorName(xlType)
+				section = append(section, optionLines(opts[xl])...)
+				inGraph[xl] = true
+				optionsSet = true
+			}
+		}
+		section = append(section, line)
+		if len(fields) == 1 && fields[0] == "end-volume" {
+			out = append(out, section...)
+			section = nil
+		}
+	}
+
+	var unapplied []string
+	for name := range vinfo.Options {
+		graph, xl, _ := volume.SplitVolumeOptionName(name)
+		if !inGraph[xl] || (graph != "" && graph != "client") {
+			unapplied = append(unapplied, name)
+		}
+	}
+	sort.Strings(unapplied)
+
+	return strings.Join(out, "\n"), unapplied, nil
+}
+
+// optionLines returns the volfile lines setting the options, sorted by key
+func optionLines(opts map[string]string) []string {
+	var lines []string
+	for key, value := range opts {
+		lines = append(lines, "    option "+key+" "+value)
+	}
+	sort.Strings(lines)
+	return lines
+}
@@ -0,0 +1,65 @@
+package volume
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/utils"
+)
+
+// The policies for starting a volume having bricks on read-only filesystems
+const (
+	// ReadOnlyBrickFail fails the volume start
+	ReadOnlyBrickFail = "fail"
+	// ReadOnlyBrickStartDegraded starts the volume without those bricks
+	ReadOnlyBrickStartDegraded = "start-degraded"
+)
+
+// BrickDeviceChange is a brick whose device is not the one it was created on,
+// as happens if the file system of the brick is remounted from a different
+// device or replaced behind the back of glusterd2
+type BrickDeviceChange struct {
+	BrickID  string `json:"brick-id"`
+	Hostname string `json:"hostname"`
+	Path     string `json:"path"`
+	Recorded int    `json:"recorded-device-id"`
+	Current  int    `json:"current-device-id"`
+}
+
+func (c *BrickDeviceChange) Error() string {
+	return fmt.Sprintf("device of brick %s:%s changed from %d to %d", c.Hostname, c.Path, c.Recorded, c.Current)
+}
+
+// BrickDeviceID returns the ID of the device the brick is currently on
+func BrickDeviceID(b brick.Brickinfo) (int, error) {
+	fi, err := os.Stat(b.Path)
+	if err != nil {
+		return 0, err
+	}
+	return utils.GetDeviceID(fi)
+}
+
+// CheckBrickDevice compares the device the brick is on with the device it was
+// created on. nil is returned if the device hasn't changed, or if the device
+// of the brick wasn't recorded.
+func CheckBrickDevice(b brick.Brickinfo) (*BrickDeviceChange, error) {
+	if b.DeviceID == 0 {
+		return nil, nil
+	}
+
+	current, err := BrickDeviceID(b)
+	if err != nil {
+		return nil, err
+	}
+	if current == b.DeviceID {
+		return nil, nil
+	}
+	return &BrickDeviceChange{
+		BrickID:  b.ID(),
+		Hostname: b.Hostname,
+		Path:     b.Path,
+		Recorded: b.DeviceID,
+		Current:  current,
+	}, nil
+}
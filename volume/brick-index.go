@@ -0,0 +1,203 @@
+package volume
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gluster/glusterd2/store"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pborman/uuid"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// brickIndexPrefix has the bricks of each node as
+	// <prefix><node ID>/<brick ID>, so that the bricks of a node can be
+	// listed without going through every volume
+	brickIndexPrefix = store.GlusterPrefix + "brickindex/"
+)
+
+// BrickIndexEntry is a brick in the index of the bricks of each node
+type BrickIndexEntry struct {
+	BrickID  string    `json:"brick-id"`
+	NodeID   uuid.UUID `json:"node-id"`
+	Volume   string    `json:"volume"`
+	VolumeID uuid.UUID `json:"volume-id"`
+	Path     string    `json:"path"`
+}
+
+func brickIndexKey(nodeID uuid.UUID, brickID string) string {
+	return brickIndexPrefix + nodeID.String() + "/" + brickID
+}
+
+// brickIndexEntries returns the index entries of the bricks of the volume,
+// by index key
+func brickIndexEntries(v *Volinfo) map[string]BrickIndexEntry {
+	entries := make(map[string]BrickIndexEntry)
+	if v == nil {
+		return entries
+	}
+	for _, b := range v.Bricks {
+		entries[brickIndexKey(b.NodeID, b.ID())] = BrickIndexEntry{
+			BrickID:  b.ID(),
+			NodeID:   b.NodeID,
+			Volume:   v.Name,
+			VolumeID: v.ID,
+			Path:     b.Path,
+		}
+	}
+	return entries
+}
+
+// maxTxnOps is the most operations etcd takes in a single transaction
+const maxTxnOps = 128
+
+// brickIndexOps returns the store operations updating the brick index from
+// the old bricks of a volume to its new ones. Either may be nil, for a volume
+// being created or deleted. Only the entries which changed are written.
+func brickIndexOps(old, new *Volinfo) ([]clientv3.Op, error) {
+	var ops []clientv3.Op
+	oldEntries := brickIndexEntries(old)
+	newEntries := brickIndexEntries(new)
+	for key := range oldEntries {
+		if _, ok := newEntries[key]; !ok {
+			ops = append(ops, clientv3.OpDelete(key))
+		}
+	}
+	for key, e := range newEntries {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		if o, ok := oldEntries[key]; ok {
+			if ob, err := json.Marshal(o); err == nil && string(ob) == string(b) {
+				continue
+			}
+		}
+		ops = append(ops, clientv3.OpPut(key, string(b)))
+	}
+	return ops, nil
+}
+
+// commitVolumeOps commits the operations storing or deleting volumes along
+// with the brick index operations. The volume operations are committed in a
+// single transaction, with as many index operations as fit in the limit of
+// etcd of maxTxnOps. The rest are committed after it, in batches. Should one
+// of those fail, the index is rebuilt from the volumes just stored, and an
+// error is returned only if that fails too.
+func commitVolumeOps(volOps []clientv3.Op, indexOps []clientv3.Op) error {
+	if len(volOps) > maxTxnOps {
+		return fmt.Errorf("can't update more than %d volumes together", maxTxnOps)
+	}
+	n := len(indexOps)
+	if n > maxTxnOps-len(volOps) {
+		n = maxTxnOps - len(volOps)
+	}
+	ops := make([]clientv3.Op, 0, len(volOps)+n)
+	ops = append(ops, volOps...)
+	ops = append(ops, indexOps[:n]...)
+	if _, err := store.Store.Txn(context.TODO()).Then(ops...).Commit(); err != nil {
+		return err
+	}
+
+	if err := commitInBatches(indexOps[n:]); err != nil {
+		log.WithError(err).Warn("failed to update the brick index, rebuilding it")
+		if rerr := RebuildBrickIndex(); rerr != nil {
+			return fmt.Errorf("volumes stored, but the brick index is stale: %s", rerr.Error())
+		}
+	}
+	return nil
+}
+
+// commitInBatches commits the operations in as many transactions as needed
+// to stay within the limit of etcd
+func commitInBatches(ops []clientv3.Op) error {
+	for len(ops) > 0 {
+		n := len(ops)
+		if n > maxTxnOps {
+			n = maxTxnOps
+		}
+		if _, err := store.Store.Txn(context.TODO()).Then(ops[:n]...).Commit(); err != nil {
+			return err
+		}
+		ops = ops[n:]
+	}
+	return nil
+}
+
+// getStoredVolume returns the volume as it is in the store, or nil if it
+// isn't
+func getStoredVolume(name string) (*Volinfo, error) {
+	resp, err := store.Store.Get(context.TODO(), volumePrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Count != 1 {
+		return nil, nil
+	}
+	var v Volinfo
+	if err := json.Unmarshal(resp.Kvs[0].Value, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// GetNodeBricks returns the bricks on the given node, from the brick index
+func GetNodeBricks(nodeID uuid.UUID) ([]BrickIndexEntry, error) {
+	resp, err := store.Store.Get(context.TODO(), brickIndexPrefix+nodeID.String()+"/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]BrickIndexEntry, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var e BrickIndexEntry
+		if err := json.Unmarshal(kv.Value, &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// RebuildBrickIndex brings the brick index in line with the bricks of the
+// volumes in the store. The index is updated along with the volumes, so this
+// only adds volumes stored before the index existed or whose index update was
+// cut short, and drops entries of volumes deleted since.
+func RebuildBrickIndex() error {
+	volumes, err := GetVolumes()
+	if err != nil {
+		return err
+	}
+	resp, err := store.Store.Get(context.TODO(), brickIndexPrefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return err
+	}
+
+	var ops []clientv3.Op
+	entries := make(map[string]BrickIndexEntry)
+	for i := range volumes {
+		for key, e := range brickIndexEntries(&volumes[i]) {
+			entries[key] = e
+		}
+	}
+	for _, kv := range resp.Kvs {
+		if _, ok := entries[string(kv.Key)]; !ok {
+			ops = append(ops, clientv3.OpDelete(string(kv.Key)))
+		} else {
+			delete(entries, string(kv.Key))
+		}
+	}
+	for key, e := range entries {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, clientv3.OpPut(key, string(b)))
+	}
+
+	return commitInBatches(ops)
+}
@@ -0,0 +1,106 @@
+package volume
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/daemon"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/pkg/logrotate"
+	"github.com/gluster/glusterd2/utils"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/pborman/uuid"
+)
+
+const (
+	brickLogCheckInterval = time.Minute
+)
+
+// BrickLogRotator periodically rotates the log files of the bricks on this
+// node which have grown beyond the configured size. Bricks reopen their log
+// files on SIGHUP, which they are sent once their log file is rotated. It is
+// a suture.Service.
+type BrickLogRotator struct {
+	stop chan struct{}
+}
+
+// NewBrickLogRotator returns a new BrickLogRotator
+func NewBrickLogRotator() *BrickLogRotator {
+	return &BrickLogRotator{stop: make(chan struct{})}
+}
+
+// Serve rotates the brick log files till the rotator is stopped
+func (r *BrickLogRotator) Serve() {
+	ticker := time.NewTicker(brickLogCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			if c := utils.LogRotateConfig(); c.Enabled() {
+				r.rotate(c)
+			}
+		}
+	}
+}
+
+// Stop stops the BrickLogRotator
+func (r *BrickLogRotator) Stop() {
+	close(r.stop)
+}
+
+func (r *BrickLogRotator) rotate(c logrotate.Config) {
+	volumes, err := GetVolumes()
+	if err != nil {
+		log.WithError(err).Error("failed to get volumes to rotate brick logs")
+		return
+	}
+
+	for _, v := range volumes {
+		for _, b := range v.Bricks {
+			if !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+				continue
+			}
+			if err := rotateBrickLog(b, c); err != nil {
+				log.WithError(err).WithField("brick", b.Path).Error("failed to rotate brick log")
+			}
+		}
+	}
+}
+
+func rotateBrickLog(b brick.Brickinfo, c logrotate.Config) error {
+	brickDaemon, err := brick.NewGlusterfsd(b)
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(brickDaemon.LogFile())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fi.Size() < c.MaxSize {
+		return nil
+	}
+
+	if err := logrotate.RotateFile(brickDaemon.LogFile(), c); err != nil {
+		return err
+	}
+
+	// A brick which isn't running opens a new log file when it is started
+	pid, err := daemon.ReadPidFromFile(brickDaemon.PidFile())
+	if err != nil {
+		return nil
+	}
+	process, err := daemon.GetProcess(pid)
+	if err != nil {
+		return nil
+	}
+	return process.Signal(syscall.SIGHUP)
+}
@@ -0,0 +1,85 @@
+package volume
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gluster/glusterd2/brick"
+)
+
+// Brick orderings of a volume. The order of the bricks decides the order of
+// the distribute subvolumes, and so the hash ranges DHT assigns to them.
+//
+// With BrickOrderAsGiven the bricks are kept in the order of the request, and
+// bricks added by an expand are appended. This keeps the layout predictable
+// for those placing data by hand, but the subvolumes added by an expand all
+// land at the end of the hash ring, so a rebalance shifts the ranges of every
+// existing subvolume and moves data between most of them.
+//
+// With BrickOrderHashed the replica sets are placed on the hash ring by the
+// BrickID of their first brick. Subvolumes added by an expand land between
+// existing ones, so a rebalance mostly moves data from their neighbours on
+// the ring. The cost is that the order of the bricks of the volume differs
+// from the request. Replica set membership is never changed.
+const (
+	BrickOrderAsGiven = "as-given"
+	BrickOrderHashed  = "hashed"
+)
+
+// ValidateBrickOrder returns an error if the brick ordering is unknown. An
+// empty ordering is the same as BrickOrderAsGiven.
+func ValidateBrickOrder(order string) error {
+	switch order {
+	case "", BrickOrderAsGiven, BrickOrderHashed:
+		return nil
+	}
+	return fmt.Errorf("invalid brick order %s", order)
+}
+
+// OrderBricks returns the bricks in the given ordering. Consecutive groups of
+// replicaCount bricks are the replica sets, which are kept together.
+func OrderBricks(bricks []brick.Brickinfo, replicaCount int, order string) []brick.Brickinfo {
+	if order != BrickOrderHashed || replicaCount < 1 || len(bricks)%replicaCount != 0 {
+		return bricks
+	}
+
+	var sets [][]brick.Brickinfo
+	for i := 0; i < len(bricks); i += replicaCount {
+		sets = append(sets, bricks[i:i+replicaCount])
+	}
+	sort.SliceStable(sets, func(i, j int) bool {
+		return sets[i][0].ID() < sets[j][0].ID()
+	})
+
+	ordered := make([]brick.Brickinfo, 0, len(bricks))
+	for _, set := range sets {
+		ordered = append(ordered, set...)
+	}
+	return ordered
+}
+
+// ExpandBricks returns the bricks of a volume with the bricks added by an
+// expand placed in the given ordering. The existing replica sets keep their
+// order, whatever ordering placed them, as reordering them would move the hash
+// ranges of subvolumes which didn't change. With BrickOrderHashed each new set
+// is inserted before the first existing set whose first brick has a greater
+// BrickID.
+func ExpandBricks(bricks, newBricks []brick.Brickinfo, replicaCount int, order string) []brick.Brickinfo {
+	if order != BrickOrderHashed || replicaCount < 1 || len(bricks)%replicaCount != 0 || len(newBricks)%replicaCount != 0 {
+		return append(append([]brick.Brickinfo{}, bricks...), newBricks...)
+	}
+
+	expanded := append([]brick.Brickinfo{}, bricks...)
+	for i := 0; i < len(newBricks); i += replicaCount {
+		set := newBricks[i : i+replicaCount]
+		at := len(expanded)
+		for j := 0; j < len(expanded); j += replicaCount {
+			if expanded[j].ID() > set[0].ID() {
+				at = j
+				break
+			}
+		}
+		expanded = append(expanded[:at], append(append([]brick.Brickinfo{}, set...), expanded[at:]...)...)
+	}
+	return expanded
+}
@@ -0,0 +1,119 @@
+package volume
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gluster/glusterd2/store"
+	"github.com/gluster/glusterd2/utils"
+)
+
+const brickPathPolicyKey = store.GlusterPrefix + "brick-path-policy"
+
+// BrickPathPolicy is the cluster policy brick paths of new bricks must follow,
+// checked on their canonical form. The zero value of each field means no
+// restriction, so the default policy allows any brick path.
+type BrickPathPolicy struct {
+	MaxDepth            int      `json:"max-depth,omitempty"`
+	MaxLength           int      `json:"max-length,omitempty"`
+	RequiredPrefix      string   `json:"required-prefix,omitempty"`
+	ForbiddenSubstrings []string `json:"forbidden-substrings,omitempty"`
+}
+
+// BrickPathPolicyError is returned when a brick path doesn't follow the
+// cluster brick path policy, with the reason
+type BrickPathPolicyError struct {
+	Path   string
+	Reason string
+}
+
+func (e *BrickPathPolicyError) Error() string {
+	return fmt.Sprintf("brick path %s doesn't follow the cluster brick path policy: %s", e.Path, e.Reason)
+}
+
+// Validate checks that the policy itself is sane
+func (p *BrickPathPolicy) Validate() error {
+	if p.MaxDepth < 0 {
+		return fmt.Errorf("max-depth can't be negative")
+	}
+	if p.MaxLength < 0 {
+		return fmt.Errorf("max-length can't be negative")
+	}
+	if p.RequiredPrefix != "" && !filepath.IsAbs(p.RequiredPrefix) {
+		return fmt.Errorf("required-prefix %s is not an absolute path", p.RequiredPrefix)
+	}
+	for _, s := range p.ForbiddenSubstrings {
+		if s == "" {
+			return fmt.Errorf("forbidden-substrings can't have an empty string")
+		}
+	}
+	return nil
+}
+
+// Check checks that the canonical brick path follows the policy
+func (p *BrickPathPolicy) Check(brickPath string) error {
+	sep := string(os.PathSeparator)
+
+	if p.RequiredPrefix != "" {
+		prefix := filepath.Clean(p.RequiredPrefix)
+		if brickPath != prefix && !strings.HasPrefix(brickPath, strings.TrimSuffix(prefix, sep)+sep) {
+			return &BrickPathPolicyError{brickPath, "it must be under " + prefix}
+		}
+	}
+	if p.MaxLength > 0 && len(brickPath) > p.MaxLength {
+		return &BrickPathPolicyError{brickPath,
+			fmt.Sprintf("it is %d characters long, more than the maximum of %d", len(brickPath), p.MaxLength)}
+	}
+	if depth := utils.BrickPathDepth(brickPath); p.MaxDepth > 0 && depth > p.MaxDepth {
+		return &BrickPathPolicyError{brickPath,
+			fmt.Sprintf("it is %d directories deep, more than the maximum of %d", depth, p.MaxDepth)}
+	}
+	for _, s := range p.ForbiddenSubstrings {
+		if strings.Contains(brickPath, s) {
+			return &BrickPathPolicyError{brickPath, fmt.Sprintf("it contains the forbidden string %q", s)}
+		}
+	}
+	return nil
+}
+
+// checkBrickPathPolicy checks the canonical form of the brick path against
+// the policy
+func checkBrickPathPolicy(policy *BrickPathPolicy, brickPath string) error {
+	p, err := canonicalizeBrickPathFunc(brickPath)
+	if err != nil {
+		return err
+	}
+	return policy.Check(p)
+}
+
+// GetBrickPathPolicy returns the cluster brick path policy
+func GetBrickPathPolicy() (*BrickPathPolicy, error) {
+	resp, err := store.Store.Get(context.TODO(), brickPathPolicyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := new(BrickPathPolicy)
+	if resp.Count != 1 {
+		return policy, nil
+	}
+	if err := json.Unmarshal(resp.Kvs[0].Value, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// SetBrickPathPolicy replaces the cluster brick path policy. Bricks already
+// created aren't checked again.
+func SetBrickPathPolicy(policy *BrickPathPolicy) error {
+	b, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	_, err = store.Store.Put(context.TODO(), brickPathPolicyKey, string(b))
+	return err
+}
@@ -0,0 +1,52 @@
+package volume
+
+import (
+	"fmt"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/utils"
+
+	"github.com/pborman/uuid"
+)
+
+// BrickVolumeIDMismatch is a brick whose directory isn't marked with the ID
+// of its volume, as happens if the directory was repurposed for another
+// volume or the device of the brick was swapped
+type BrickVolumeIDMismatch struct {
+	BrickID  string `json:"brick-id"`
+	Hostname string `json:"hostname"`
+	Path     string `json:"path"`
+	Expected string `json:"expected-volume-id"`
+	// Actual is empty if the brick isn't marked with any volume ID
+	Actual string `json:"actual-volume-id"`
+}
+
+func (m *BrickVolumeIDMismatch) Error() string {
+	if m.Actual == "" {
+		return fmt.Sprintf("brick %s:%s isn't marked with a volume ID, expected %s", m.Hostname, m.Path, m.Expected)
+	}
+	return fmt.Sprintf("brick %s:%s is marked with volume ID %s, expected %s", m.Hostname, m.Path, m.Actual, m.Expected)
+}
+
+// CheckBrickVolumeID compares the volume ID the brick is marked with on disk
+// with the ID of its volume. nil is returned if they match.
+func CheckBrickVolumeID(b brick.Brickinfo) (*BrickVolumeIDMismatch, error) {
+	actual, err := utils.GetBrickVolumeID(b.Path)
+	if err != nil {
+		return nil, err
+	}
+	if uuid.Equal(actual, b.VolumeID) {
+		return nil, nil
+	}
+
+	m := &BrickVolumeIDMismatch{
+		BrickID:  b.ID(),
+		Hostname: b.Hostname,
+		Path:     b.Path,
+		Expected: b.VolumeID.String(),
+	}
+	if actual != nil {
+		m.Actual = actual.String()
+	}
+	return m, nil
+}
@@ -0,0 +1,58 @@
+package volume
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/gluster/glusterd2/store"
+)
+
+const defaultBrickRootKey = store.GlusterPrefix + "default-brick-root"
+
+// GetDefaultBrickRootFunc returns the cluster default brick root
+var GetDefaultBrickRootFunc = GetDefaultBrickRoot
+
+// DefaultBrickRoot is the directory under which bricks are placed as
+// {root}/{volume}/brick{index} on every node, for volumes created with only a
+// list of nodes. Root is empty if no default brick root is set.
+type DefaultBrickRoot struct {
+	Root string `json:"root"`
+}
+
+// Validate checks that the default brick root is an absolute path
+func (r *DefaultBrickRoot) Validate() error {
+	if r.Root != "" && !filepath.IsAbs(r.Root) {
+		return fmt.Errorf("default brick root %s is not an absolute path", r.Root)
+	}
+	return nil
+}
+
+// GetDefaultBrickRoot returns the cluster default brick root
+func GetDefaultBrickRoot() (*DefaultBrickRoot, error) {
+	resp, err := store.Store.Get(context.TODO(), defaultBrickRootKey)
+	if err != nil {
+		return nil, err
+	}
+
+	root := new(DefaultBrickRoot)
+	if resp.Count != 1 {
+		return root, nil
+	}
+	if err := json.Unmarshal(resp.Kvs[0].Value, root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// SetDefaultBrickRoot replaces the cluster default brick root. Bricks already
+// created stay where they are.
+func SetDefaultBrickRoot(root *DefaultBrickRoot) error {
+	b, err := json.Marshal(root)
+	if err != nil {
+		return err
+	}
+	_, err = store.Store.Put(context.TODO(), defaultBrickRootKey, string(b))
+	return err
+}
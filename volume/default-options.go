@@ -0,0 +1,150 @@
+package volume
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/gluster/glusterd2/store"
+)
+
+const defaultOptionsKey = store.GlusterPrefix + "default-volume-options"
+
+// GetDefaultOptions returns the cluster default options of new volumes
+func GetDefaultOptions() (map[string]string, error) {
+	resp, err := store.Store.Get(context.TODO(), defaultOptionsKey)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := make(map[string]string)
+	if resp.Count != 1 {
+		return opts, nil
+	}
+	if err := json.Unmarshal(resp.Kvs[0].Value, &opts); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// SetDefaultOptions replaces the cluster default options of new volumes.
+// Volumes already created keep their options.
+func SetDefaultOptions(opts map[string]string) error {
+	b, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+	_, err = store.Store.Put(context.TODO(), defaultOptionsKey, string(b))
+	return err
+}
+
+// ApplyDefaultOptions sets the default options on the volume which weren't
+// set explicitly, and records them in DefaultOptions
+func (v *Volinfo) ApplyDefaultOptions(defaults map[string]string) {
+	if v.Options == nil {
+		v.Options = make(map[string]string)
+	}
+	for k, val := range defaults {
+		if _, ok := v.Options[k]; ok {
+			continue
+		}
+		v.Options[k] = val
+		v.DefaultOptions = append(v.DefaultOptions, k)
+	}
+	sort.Strings(v.DefaultOptions)
+}
+
+// ApplyRecommendedOptions sets the options recommended at the op-version on
+// the volume which weren't set explicitly or from the cluster defaults, and
+// records them in RecommendedOptions
+func (v *Volinfo) ApplyRecommendedOptions(recommended map[string]string, opVersion int) {
+	if v.Options == nil {
+		v.Options = make(map[string]string)
+	}
+	for k, val := range recommended {
+		if _, ok := v.Options[k]; ok {
+			continue
+		}
+		v.Options[k] = val
+		v.RecommendedOptions = append(v.RecommendedOptions, k)
+	}
+	sort.Strings(v.RecommendedOptions)
+	v.OptionsOpVersion = opVersion
+}
+
+// ClearDefaultOption records that the option is no longer set from the
+// cluster defaults or recommendations, as it was set explicitly on the volume
+func (v *Volinfo) ClearDefaultOption(key string) {
+	v.DefaultOptions = removeOption(v.DefaultOptions, key)
+	v.RecommendedOptions = removeOption(v.RecommendedOptions, key)
+}
+
+func removeOption(keys []string, key string) []string {
+	for i, k := range keys {
+		if k == key {
+			return append(keys[:i], keys[i+1:]...)
+		}
+	}
+	return keys
+}
+
+// OptionChange is a change of a volume option to its recommended default.
+// Current is empty if the option isn't set on the volume.
+type OptionChange struct {
+	Option      string `json:"option"`
+	Current     string `json:"current,omitempty"`
+	Recommended string `json:"recommended"`
+}
+
+// isRecommendedOption returns true if the option was set from the defaults
+// recommended at an op-version, rather than explicitly or from the cluster
+// defaults
+func (v *Volinfo) isRecommendedOption(key string) bool {
+	for _, k := range v.RecommendedOptions {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// OptionChanges returns the changes bringing the options of the volume to the
+// recommended defaults, sorted by option. Only the options unset or set from
+// earlier recommendations are changed. Options set explicitly on the volume or
+// from the cluster defaults are returned as skipped when they differ from the
+// recommended default.
+func (v *Volinfo) OptionChanges(recommended map[string]string) ([]OptionChange, []OptionChange) {
+	changes := []OptionChange{}
+	skipped := []OptionChange{}
+	for k, val := range recommended {
+		current, set := v.Options[k]
+		if current == val && set {
+			continue
+		}
+		c := OptionChange{Option: k, Current: current, Recommended: val}
+		if set && !v.isRecommendedOption(k) {
+			skipped = append(skipped, c)
+		} else {
+			changes = append(changes, c)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Option < changes[j].Option })
+	sort.Slice(skipped, func(i, j int) bool { return skipped[i].Option < skipped[j].Option })
+	return changes, skipped
+}
+
+// ApplyOptionChanges sets the options to their defaults recommended at the
+// op-version, recording them in RecommendedOptions
+func (v *Volinfo) ApplyOptionChanges(changes []OptionChange, opVersion int) {
+	if v.Options == nil {
+		v.Options = make(map[string]string)
+	}
+	for _, c := range changes {
+		v.Options[c.Option] = c.Recommended
+		if !v.isRecommendedOption(c.Option) {
+			v.RecommendedOptions = append(v.RecommendedOptions, c.Option)
+		}
+	}
+	sort.Strings(v.RecommendedOptions)
+	v.OptionsOpVersion = opVersion
+}
@@ -0,0 +1,36 @@
+package volume
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SSLAllowOption is the brick option restricting the clients allowed to
+// connect over SSL by the common names of their certificates
+const SSLAllowOption = "server.ssl-allow"
+
+// ValidateAllowedCNs checks the common names of the clients allowed to
+// connect to the bricks. The option lists them separated by commas, which
+// they thus can't have.
+func ValidateAllowedCNs(cns []string) error {
+	for _, cn := range cns {
+		if strings.TrimSpace(cn) == "" {
+			return fmt.Errorf("empty client common name")
+		}
+		if strings.Contains(cn, ",") {
+			return fmt.Errorf("invalid client common name %q", cn)
+		}
+	}
+	return nil
+}
+
+// SetAllowedCNs sets the common names of the clients allowed to connect to
+// the bricks of the volume, allowing every client if none are given
+func (v *Volinfo) SetAllowedCNs(cns []string) {
+	v.Encryption.AllowedCNs = cns
+	if len(cns) == 0 {
+		delete(v.Options, SSLAllowOption)
+		return
+	}
+	v.Options[SSLAllowOption] = strings.Join(cns, ",")
+}
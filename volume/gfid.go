@@ -0,0 +1,176 @@
+package volume
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/utils"
+
+	"github.com/pborman/uuid"
+)
+
+// The fields of a file its copies can disagree on
+const (
+	gfidMismatchPath = "path"
+	gfidMismatchSize = "size"
+	gfidMismatchType = "type"
+)
+
+// GFIDCopy is the copy of a file on a brick, found by its gfid. Paths are
+// the paths of the hard links of the file relative to the root of the volume,
+// and are empty if they couldn't be found.
+type GFIDCopy struct {
+	BrickID  string    `json:"brick-id"`
+	Hostname string    `json:"hostname"`
+	Paths    []string  `json:"paths,omitempty"`
+	Dir      bool      `json:"dir,omitempty"`
+	Size     int64     `json:"size"`
+	Mtime    time.Time `json:"mtime"`
+}
+
+// GFIDReplicaSet has the copies of a file in a replica set of a volume.
+// Mismatches are the fields the copies disagree on, one of path, size or
+// type. Missing are the IDs of the bricks of the replica set which don't have
+// a copy of the file.
+type GFIDReplicaSet struct {
+	ReplicaSet int        `json:"replica-set"`
+	Copies     []GFIDCopy `json:"copies"`
+	Mismatches []string   `json:"mismatches,omitempty"`
+	Missing    []string   `json:"missing,omitempty"`
+}
+
+// GFIDLookup is a file of a volume found by its gfid. A directory is found in
+// every replica set, while other files are in only one. Consistent is false
+// if the copies of the file in any of the replica sets disagree, or if a
+// replica set lacks a copy.
+type GFIDLookup struct {
+	GFID        string           `json:"gfid"`
+	Paths       []string         `json:"paths"`
+	ReplicaSets []GFIDReplicaSet `json:"replica-sets"`
+	Consistent  bool             `json:"consistent"`
+}
+
+// NormalizeGFID returns the gfid in the form it is named by in the
+// .glusterfs directory of bricks
+func NormalizeGFID(gfid string) (string, error) {
+	id := uuid.Parse(gfid)
+	if id == nil {
+		return "", fmt.Errorf("invalid gfid %s", gfid)
+	}
+	return strings.ToLower(id.String()), nil
+}
+
+// LookupGFID finds the copy of the file with the given gfid on a brick of the
+// volume. nil is returned if the brick doesn't have the file.
+func LookupGFID(b brick.Brickinfo, gfid string) (*GFIDCopy, error) {
+	p := gfidPath(b.Path, gfid)
+	fi, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var xattrs map[string]string
+	if !fi.IsDir() {
+		if xattrs, err = utils.GetGlusterXattrs(p); err != nil {
+			return nil, err
+		}
+	}
+	c := &GFIDCopy{
+		BrickID:  b.ID(),
+		Hostname: b.Hostname,
+		Dir:      fi.IsDir(),
+		Size:     fi.Size(),
+		Mtime:    fi.ModTime(),
+	}
+	// A file is reported even if its paths can't be found
+	c.Paths, _ = gfidToPaths(b.Path, gfid, xattrs)
+	return c, nil
+}
+
+// gfidMismatches returns the fields the copies of a file disagree on. The
+// paths of the copies are compared as sets, as a file has as many paths as
+// hard links. The sizes of directories differ with their file systems, and
+// aren't compared.
+func gfidMismatches(copies []GFIDCopy) []string {
+	var mismatches []string
+	first := copies[0]
+	firstPaths := strings.Join(first.Paths, "\x00")
+	for _, field := range []string{gfidMismatchPath, gfidMismatchType, gfidMismatchSize} {
+		for _, c := range copies[1:] {
+			if (field == gfidMismatchPath && strings.Join(c.Paths, "\x00") != firstPaths) ||
+				(field == gfidMismatchType && c.Dir != first.Dir) ||
+				(field == gfidMismatchSize && !c.Dir && !first.Dir && c.Size != first.Size) {
+				mismatches = append(mismatches, field)
+				break
+			}
+		}
+	}
+	return mismatches
+}
+
+// CompareGFIDCopies groups the copies of the file found on the bricks of the
+// volume, by BrickID, by replica set, and finds where they disagree or are
+// missing. Bricks in unreachable aren't reported missing, as they couldn't be
+// looked up. nil is returned if no copies were found.
+func CompareGFIDCopies(v *Volinfo, gfid string, copies map[string]GFIDCopy, unreachable []string) *GFIDLookup {
+	if len(copies) == 0 {
+		return nil
+	}
+
+	// Directories are in every replica set, other files in the one they
+	// are found in
+	dir := false
+	for _, c := range copies {
+		dir = dir || c.Dir
+	}
+	skip := make(map[string]bool)
+	for _, id := range unreachable {
+		skip[id] = true
+	}
+
+	lookup := &GFIDLookup{GFID: gfid, Paths: []string{}, Consistent: true}
+	replicaCount := v.ReplicaCount
+	if replicaCount < 1 {
+		replicaCount = 1
+	}
+
+	paths := make(map[string]bool)
+	for first := 0; first+replicaCount <= len(v.Bricks); first += replicaCount {
+		set := GFIDReplicaSet{ReplicaSet: first / replicaCount}
+		for i := first; i < first+replicaCount; i++ {
+			id := v.Bricks[i].ID()
+			c, ok := copies[id]
+			if !ok {
+				if !skip[id] {
+					set.Missing = append(set.Missing, id)
+				}
+				continue
+			}
+			set.Copies = append(set.Copies, c)
+			for _, p := range c.Paths {
+				if !paths[p] {
+					paths[p] = true
+					lookup.Paths = append(lookup.Paths, p)
+				}
+			}
+		}
+		if len(set.Copies) == 0 && !dir {
+			continue
+		}
+		if len(set.Copies) > 0 {
+			set.Mismatches = gfidMismatches(set.Copies)
+		}
+		if len(set.Mismatches) > 0 || len(set.Missing) > 0 {
+			lookup.Consistent = false
+		}
+		lookup.ReplicaSets = append(lookup.ReplicaSets, set)
+	}
+	sort.Strings(lookup.Paths)
+	return lookup
+}
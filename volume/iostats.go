@@ -0,0 +1,247 @@
+package volume
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/pkg/api"
+	"github.com/gluster/glusterd2/store"
+
+	"github.com/pborman/uuid"
+	config "github.com/spf13/viper"
+)
+
+const (
+	ioStatsPrefix = store.GlusterPrefix + "iostats/"
+
+	// defaultBrickStatsDir is where the io-stats translator of the bricks
+	// dumps its statistics
+	defaultBrickStatsDir = "/var/run/gluster"
+
+	// ioStatsCumulative is the section of an io-stats dump with the
+	// statistics since the brick started, as opposed to those of the last
+	// interval
+	ioStatsCumulative = ".aggr."
+)
+
+// FopStats are the statistics of a file operation. TotalLatency is the sum
+// of the latencies of the operations, in microseconds.
+type FopStats struct {
+	Count        api.Uint64 `json:"count"`
+	TotalLatency float64    `json:"total-latency-usec"`
+	MinLatency   float64    `json:"min-latency-usec"`
+	MaxLatency   float64    `json:"max-latency-usec"`
+}
+
+// AvgLatency returns the average latency of the operations in microseconds
+func (s FopStats) AvgLatency() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.TotalLatency / float64(s.Count)
+}
+
+// IOStats are the cumulative I/O statistics of a brick, or of a volume, with
+// the statistics of every file operation by its name
+type IOStats struct {
+	ReadBytes  api.Uint64          `json:"read-bytes"`
+	WriteBytes api.Uint64          `json:"write-bytes"`
+	Fops       map[string]FopStats `json:"fops"`
+}
+
+// NewIOStats returns empty I/O statistics
+func NewIOStats() IOStats {
+	return IOStats{Fops: make(map[string]FopStats)}
+}
+
+// Add adds the statistics of another brick
+func (s *IOStats) Add(o IOStats) {
+	s.ReadBytes += o.ReadBytes
+	s.WriteBytes += o.WriteBytes
+	for name, f := range o.Fops {
+		cur := s.Fops[name]
+		if cur.MinLatency == 0 || (f.MinLatency > 0 && f.MinLatency < cur.MinLatency) {
+			cur.MinLatency = f.MinLatency
+		}
+		if f.MaxLatency > cur.MaxLatency {
+			cur.MaxLatency = f.MaxLatency
+		}
+		cur.Count += f.Count
+		cur.TotalLatency += f.TotalLatency
+		s.Fops[name] = cur
+	}
+}
+
+// Since returns the statistics accumulated since the baseline was taken. A
+// brick restarted since then has counters lower than the baseline, and all
+// of its statistics are counted. The minimum and maximum latencies can't be
+// reset, and are those since the brick started.
+func (s IOStats) Since(baseline IOStats) IOStats {
+	if s.ReadBytes < baseline.ReadBytes || s.WriteBytes < baseline.WriteBytes {
+		return s
+	}
+	for name, f := range s.Fops {
+		if f.Count < baseline.Fops[name].Count {
+			return s
+		}
+	}
+
+	since := IOStats{
+		ReadBytes:  s.ReadBytes - baseline.ReadBytes,
+		WriteBytes: s.WriteBytes - baseline.WriteBytes,
+		Fops:       make(map[string]FopStats),
+	}
+	for name, f := range s.Fops {
+		b := baseline.Fops[name]
+		if f.Count == b.Count {
+			continue
+		}
+		f.Count -= b.Count
+		f.TotalLatency -= b.TotalLatency
+		since.Fops[name] = f
+	}
+	return since
+}
+
+// parseIOStatsDump parses the cumulative statistics of a JSON io-stats dump,
+// which has keys such as <prefix>.aggr.read_bytes and
+// <prefix>.aggr.fop.WRITE.count
+func parseIOStatsDump(b []byte) (IOStats, error) {
+	var dump map[string]interface{}
+	if err := json.Unmarshal(b, &dump); err != nil {
+		return IOStats{}, err
+	}
+
+	stats := NewIOStats()
+	for key, value := range dump {
+		i := strings.Index(key, ioStatsCumulative)
+		if i < 0 {
+			continue
+		}
+		var v float64
+		switch value := value.(type) {
+		case float64:
+			v = value
+		case string:
+			var err error
+			if v, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		default:
+			continue
+		}
+
+		name := key[i+len(ioStatsCumulative):]
+		switch {
+		case name == "read_bytes":
+			stats.ReadBytes = api.Uint64(v)
+		case name == "write_bytes":
+			stats.WriteBytes = api.Uint64(v)
+		case strings.HasPrefix(name, "fop."):
+			parts := strings.SplitN(strings.TrimPrefix(name, "fop."), ".", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			f := stats.Fops[parts[0]]
+			switch parts[1] {
+			case "count":
+				f.Count = api.Uint64(v)
+			case "latency_ave_usec":
+				// Converted to the total once the count is known
+				f.TotalLatency = v
+			case "latency_min_usec":
+				f.MinLatency = v
+			case "latency_max_usec":
+				f.MaxLatency = v
+			}
+			stats.Fops[parts[0]] = f
+		}
+	}
+
+	for name, f := range stats.Fops {
+		if f.Count == 0 {
+			delete(stats.Fops, name)
+			continue
+		}
+		f.TotalLatency *= float64(f.Count)
+		stats.Fops[name] = f
+	}
+	return stats, nil
+}
+
+// BrickStatsDir returns the directory the bricks dump their statistics in
+func BrickStatsDir() string {
+	if dir := config.GetString("brick-stats-dir"); dir != "" {
+		return dir
+	}
+	return defaultBrickStatsDir
+}
+
+// GetBrickIOStats returns the cumulative I/O statistics of a brick of this
+// node from its last io-stats dump. A brick which hasn't dumped its
+// statistics yet has none.
+func GetBrickIOStats(b brick.Brickinfo) (IOStats, error) {
+	// The dumps are named by the unique-id of the io-stats translator of
+	// the brick, which is its path with slashes replaced by dashes
+	name := strings.Replace(b.Path, "/", "-", -1)
+	matches, err := filepath.Glob(filepath.Join(BrickStatsDir(), "*_"+name+".dump"))
+	if err != nil || len(matches) == 0 {
+		return NewIOStats(), err
+	}
+	// Dumps of earlier brick processes can be around as well
+	sort.Strings(matches)
+	dump, err := ioutil.ReadFile(matches[len(matches)-1])
+	if err != nil {
+		return NewIOStats(), err
+	}
+	return parseIOStatsDump(dump)
+}
+
+// IOStatsBaseline is the cumulative I/O statistics of the bricks of a volume,
+// by BrickID, when its statistics were last reset
+type IOStatsBaseline struct {
+	ResetAt time.Time          `json:"reset-at"`
+	Bricks  map[string]IOStats `json:"bricks"`
+}
+
+// GetIOStatsBaseline returns the baseline of the I/O statistics of the
+// volume. A volume whose statistics were never reset has an empty baseline.
+func GetIOStatsBaseline(volID uuid.UUID) (*IOStatsBaseline, error) {
+	resp, err := store.Store.Get(context.TODO(), ioStatsPrefix+volID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	baseline := &IOStatsBaseline{Bricks: make(map[string]IOStats)}
+	if len(resp.Kvs) == 0 {
+		return baseline, nil
+	}
+	if err := json.Unmarshal(resp.Kvs[0].Value, baseline); err != nil {
+		return nil, err
+	}
+	return baseline, nil
+}
+
+// SetIOStatsBaseline stores the baseline of the I/O statistics of the volume
+func SetIOStatsBaseline(volID uuid.UUID, baseline *IOStatsBaseline) error {
+	b, err := json.Marshal(baseline)
+	if err != nil {
+		return err
+	}
+	_, err = store.Store.Put(context.TODO(), ioStatsPrefix+volID.String(), string(b))
+	return err
+}
+
+// DeleteIOStatsBaseline removes the baseline of the I/O statistics of a
+// deleted volume
+func DeleteIOStatsBaseline(volID uuid.UUID) error {
+	_, err := store.Store.Delete(context.TODO(), ioStatsPrefix+volID.String())
+	return err
+}
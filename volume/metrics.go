@@ -0,0 +1,223 @@
+package volume
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/daemon"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/pmap"
+	"github.com/gluster/glusterd2/utils"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/pborman/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	config "github.com/spf13/viper"
+)
+
+const defaultMetricsInterval = time.Minute
+
+// volumeGauges are the gauges of the bricks of this node, by volume, and
+// brickGauges the same gauges by brick
+var (
+	volumeGauges = newBrickGauges("glusterd2_volume", "bricks_online", "of the volume on this node", "volume")
+	brickGauges  = newBrickGauges("glusterd2_brick", "online", "of the brick", "volume", "brick")
+)
+
+type brickGaugeSet struct {
+	online      *prometheus.GaugeVec
+	bytesUsed   *prometheus.GaugeVec
+	bytesFree   *prometheus.GaugeVec
+	inodesUsed  *prometheus.GaugeVec
+	inodesFree  *prometheus.GaugeVec
+	healPending *prometheus.GaugeVec
+	clients     *prometheus.GaugeVec
+}
+
+func newBrickGauges(prefix string, online string, of string, labels ...string) *brickGaugeSet {
+	gauge := func(name string, help string) *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prefix + "_" + name,
+			Help: help + " " + of + ".",
+		}, labels)
+	}
+	return &brickGaugeSet{
+		online:      gauge(online, "Number of online bricks"),
+		bytesUsed:   gauge("bytes_used", "Bytes used on the file systems of the bricks"),
+		bytesFree:   gauge("bytes_free", "Bytes available on the file systems of the bricks"),
+		inodesUsed:  gauge("inodes_used", "Inodes used on the file systems of the bricks"),
+		inodesFree:  gauge("inodes_free", "Inodes free on the file systems of the bricks"),
+		healPending: gauge("heal_pending", "Number of files pending heal on the bricks"),
+		clients:     gauge("client_connections", "Number of client connections to the bricks"),
+	}
+}
+
+func (g *brickGaugeSet) collectors() []prometheus.Collector {
+	return []prometheus.Collector{g.online, g.bytesUsed, g.bytesFree, g.inodesUsed, g.inodesFree, g.healPending, g.clients}
+}
+
+func (g *brickGaugeSet) reset() {
+	for _, c := range g.collectors() {
+		c.(*prometheus.GaugeVec).Reset()
+	}
+}
+
+func (g *brickGaugeSet) add(m *brickMetrics, labels ...string) {
+	g.online.WithLabelValues(labels...).Add(m.online)
+	g.bytesUsed.WithLabelValues(labels...).Add(m.bytesUsed)
+	g.bytesFree.WithLabelValues(labels...).Add(m.bytesFree)
+	g.inodesUsed.WithLabelValues(labels...).Add(m.inodesUsed)
+	g.inodesFree.WithLabelValues(labels...).Add(m.inodesFree)
+	g.healPending.WithLabelValues(labels...).Add(m.healPending)
+	g.clients.WithLabelValues(labels...).Add(m.clients)
+}
+
+func init() {
+	prometheus.MustRegister(volumeGauges.collectors()...)
+	prometheus.MustRegister(brickGauges.collectors()...)
+}
+
+// brickMetrics is a sample of the gauges of a brick
+type brickMetrics struct {
+	online      float64
+	bytesUsed   float64
+	bytesFree   float64
+	inodesUsed  float64
+	inodesFree  float64
+	healPending float64
+	clients     float64
+}
+
+// countPendingHeals returns the number of entries in the AFR index of the
+// brick, which is the number of files with pending heals the way the heal
+// count of glusterfs has it, without checking every file
+func countPendingHeals(b brick.Brickinfo) (int, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(b.Path, afrIndexDir))
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), afrIndexBaseName) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// sampleBrick samples the gauges of a brick of this node. Gauges which can't
+// be sampled are left at 0.
+func sampleBrick(b brick.Brickinfo) *brickMetrics {
+	m := &brickMetrics{}
+
+	if total, avail, err := utils.GetBrickAvailableSpace(b.Path); err == nil {
+		m.bytesUsed = float64(total - avail)
+		m.bytesFree = float64(avail)
+	}
+	if total, free, err := utils.GetBrickInodes(b.Path); err == nil {
+		m.inodesUsed = float64(total - free)
+		m.inodesFree = float64(free)
+	}
+	if n, err := countPendingHeals(b); err == nil {
+		m.healPending = float64(n)
+	}
+
+	brickDaemon, err := brick.NewGlusterfsd(b)
+	if err != nil {
+		return m
+	}
+	pid, err := daemon.ReadPidFromFile(brickDaemon.PidFile())
+	if err != nil {
+		return m
+	}
+	if _, err := daemon.GetProcess(pid); err != nil {
+		return m
+	}
+	m.online = 1
+
+	if port := pmap.RegistrySearch(b.Path, pmap.GfPmapPortBrickserver); port > 0 {
+		if n, err := utils.CountConnections(port); err == nil {
+			m.clients = float64(n)
+		}
+	}
+	return m
+}
+
+// MetricsCollector periodically samples the gauges of the bricks on this
+// node, which are exported by volume, and by brick if metrics-per-brick is
+// set. Scrapes get the last sample. It is a suture.Service.
+type MetricsCollector struct {
+	stop chan struct{}
+}
+
+// NewMetricsCollector returns a new MetricsCollector
+func NewMetricsCollector() *MetricsCollector {
+	return &MetricsCollector{stop: make(chan struct{})}
+}
+
+// Serve samples the brick gauges till the collector is stopped
+func (c *MetricsCollector) Serve() {
+	interval := config.GetDuration("metrics-interval")
+	if interval <= 0 {
+		interval = defaultMetricsInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.sample()
+		}
+	}
+}
+
+// Stop stops the MetricsCollector
+func (c *MetricsCollector) Stop() {
+	close(c.stop)
+}
+
+func (c *MetricsCollector) sample() {
+	volumes, err := GetVolumes()
+	if err != nil {
+		log.WithError(err).Debug("failed to get volumes for metrics sampling")
+		return
+	}
+	perBrick := config.GetBool("metrics-per-brick")
+
+	type sample struct {
+		volume string
+		brick  string
+		m      *brickMetrics
+	}
+	var samples []sample
+	for _, v := range volumes {
+		for _, b := range v.Bricks {
+			if !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+				continue
+			}
+			samples = append(samples, sample{v.Name, b.Hostname + ":" + b.Path, sampleBrick(b)})
+		}
+	}
+
+	// The gauges are reset so that volumes and bricks which are gone
+	// aren't reported anymore
+	volumeGauges.reset()
+	brickGauges.reset()
+	for _, s := range samples {
+		volumeGauges.add(s.m, s.volume)
+		if perBrick {
+			brickGauges.add(s.m, s.volume, s.brick)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package volume
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gluster/glusterd2/brick"
+)
+
+// PlacementConstraints are constraints on the nodes bricks are placed on, in
+// terms of the labels of the nodes. Require has the labels the nodes of the
+// bricks must have. SpreadBy is a label, like a rack or a zone, whose value
+// must differ between the nodes of the bricks of a replica set. Prefer has
+// the labels the nodes of the bricks should have, which is only reported
+// when they don't.
+type PlacementConstraints struct {
+	Require  map[string]string `json:"require,omitempty"`
+	SpreadBy string            `json:"spread-by,omitempty"`
+	Prefer   map[string]string `json:"prefer,omitempty"`
+}
+
+// PlacementError is returned when bricks don't meet a placement constraint,
+// with the reason
+type PlacementError struct {
+	Constraint string
+	Bricks     []string
+	Reason     string
+}
+
+func (e *PlacementError) Error() string {
+	return fmt.Sprintf("placement constraint %s not met by %s: %s", e.Constraint, strings.Join(e.Bricks, ", "), e.Reason)
+}
+
+func brickName(b brick.Brickinfo) string {
+	return b.Hostname + ":" + b.Path
+}
+
+// missingLabels returns the labels, as key=value, which the node labels
+// don't match, sorted
+func missingLabels(nodeLabels, labels map[string]string) []string {
+	var missing []string
+	for k, v := range labels {
+		if nodeLabels[k] != v {
+			missing = append(missing, k+"="+v)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// Check checks that the new bricks, those from index first on, meet the
+// constraints, given the labels of the nodes by node ID. The new bricks on
+// nodes without the preferred labels are returned.
+func (c *PlacementConstraints) Check(bricks []brick.Brickinfo, replicaCount, first int, labels map[string]map[string]string) ([]string, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	var unpreferred []string
+	for _, b := range bricks[first:] {
+		nodeLabels := labels[b.NodeID.String()]
+		if missing := missingLabels(nodeLabels, c.Require); len(missing) > 0 {
+			return nil, &PlacementError{
+				Constraint: "require",
+				Bricks:     []string{brickName(b)},
+				Reason:     fmt.Sprintf("node %s doesn't have the labels %s", b.Hostname, strings.Join(missing, ", ")),
+			}
+		}
+		if len(missingLabels(nodeLabels, c.Prefer)) > 0 {
+			unpreferred = append(unpreferred, brickName(b))
+		}
+	}
+
+	if c.SpreadBy == "" {
+		return unpreferred, nil
+	}
+	err := newReplicaSets(bricks, replicaCount, first, func(index int, set []brick.Brickinfo) error {
+		seen := make(map[string]string)
+		var names []string
+		for _, b := range set {
+			names = append(names, brickName(b))
+		}
+		for _, b := range set {
+			value, ok := labels[b.NodeID.String()][c.SpreadBy]
+			if !ok {
+				return &PlacementError{
+					Constraint: "spread-by",
+					Bricks:     names,
+					Reason:     fmt.Sprintf("node %s of replica set %d has no %s label", b.Hostname, index, c.SpreadBy),
+				}
+			}
+			if other, ok := seen[value]; ok {
+				return &PlacementError{
+					Constraint: "spread-by",
+					Bricks:     names,
+					Reason: fmt.Sprintf("bricks %s and %s of replica set %d are both on %s=%s",
+						other, brickName(b), index, c.SpreadBy, value),
+				}
+			}
+			seen[value] = brickName(b)
+		}
+		return nil
+	})
+	return unpreferred, err
+}
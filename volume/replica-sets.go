@@ -0,0 +1,92 @@
+package volume
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/gdctx"
+
+	"github.com/pborman/uuid"
+)
+
+// ReplicaSetError is a replica set of a volume whose bricks would not be
+// independent copies of its data, as two of them share a node or a device
+type ReplicaSetError struct {
+	ReplicaSet int
+	Bricks     []string
+	Reason     string
+}
+
+func (e *ReplicaSetError) Error() string {
+	return fmt.Sprintf("replica set %d (%s) has %s", e.ReplicaSet, strings.Join(e.Bricks, ", "), e.Reason)
+}
+
+func newReplicaSetError(index int, set []brick.Brickinfo, reason string) *ReplicaSetError {
+	e := &ReplicaSetError{ReplicaSet: index, Reason: reason}
+	for _, b := range set {
+		e.Bricks = append(e.Bricks, b.Hostname+":"+b.Path)
+	}
+	return e
+}
+
+// newReplicaSets calls f with the replica sets of the bricks which have any of
+// the new bricks, those from index first on. The replica sets of the existing
+// bricks aren't checked again, as volumes created before the sets were
+// validated may not pass.
+func newReplicaSets(bricks []brick.Brickinfo, replicaCount, first int, f func(int, []brick.Brickinfo) error) error {
+	if replicaCount < 2 {
+		return nil
+	}
+	for i := 0; i+replicaCount <= len(bricks); i += replicaCount {
+		if i+replicaCount <= first {
+			continue
+		}
+		if err := f(i/replicaCount, bricks[i:i+replicaCount]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateNewReplicaSets checks that the replica sets with the new bricks, the
+// bricks from index first on, have each of their bricks on a different node.
+// Bricks of a replica set on the same node are lost together with it.
+func ValidateNewReplicaSets(bricks []brick.Brickinfo, replicaCount, first int) error {
+	if replicaCount > 1 && len(bricks)%replicaCount != 0 {
+		return fmt.Errorf("number of bricks %d is not a multiple of the replica count %d", len(bricks), replicaCount)
+	}
+	return newReplicaSets(bricks, replicaCount, first, func(index int, set []brick.Brickinfo) error {
+		for i := range set {
+			for j := i + 1; j < len(set); j++ {
+				if uuid.Equal(set[i].NodeID, set[j].NodeID) {
+					return newReplicaSetError(index, set, "more than one brick on node "+set[i].Hostname)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// ValidateNewReplicaSetDevices checks that the bricks of this node in the
+// replica sets with the new bricks are on different devices. It is needed
+// only if the bricks of a replica set are allowed on the same node.
+func ValidateNewReplicaSetDevices(bricks []brick.Brickinfo, replicaCount, first int) error {
+	return newReplicaSets(bricks, replicaCount, first, func(index int, set []brick.Brickinfo) error {
+		devices := make(map[int]bool)
+		for _, b := range set {
+			if !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+				continue
+			}
+			dev, err := BrickDeviceID(b)
+			if err != nil {
+				return err
+			}
+			if devices[dev] {
+				return newReplicaSetError(index, set, fmt.Sprintf("more than one brick on device %d of node %s", dev, b.Hostname))
+			}
+			devices[dev] = true
+		}
+		return nil
+	})
+}
@@ -0,0 +1,477 @@
+package volume
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/utils"
+
+	"github.com/pborman/uuid"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// afrIndexDir has an entry named by the gfid of every file of a brick
+	// with pending AFR operations
+	afrIndexDir      = ".glusterfs/indices/xattrop"
+	afrIndexBaseName = "xattrop-"
+
+	afrPendingXattrPrefix = "trusted.afr."
+	gfid2pathXattrPrefix  = "trusted.gfid2path."
+	rootGFID              = "00000000-0000-0000-0000-000000000001"
+
+	// The kinds of split-brain, by the AFR changelog counter in conflict
+	SplitBrainData     = "data"
+	SplitBrainMetadata = "metadata"
+	SplitBrainEntry    = "entry"
+
+	// The policies to resolve a split-brain with, as named by the gluster
+	// heal CLI. Data split-brain can be resolved by choosing the bigger
+	// file or the latest modified one, while all kinds can be resolved by
+	// choosing the copy on a given source brick.
+	SplitBrainPolicyBiggerFile  = "bigger-file"
+	SplitBrainPolicyLatestMtime = "latest-mtime"
+	SplitBrainPolicySourceBrick = "source-brick"
+)
+
+var splitBrainTypes = []string{SplitBrainData, SplitBrainMetadata, SplitBrainEntry}
+
+// PendingHeal is a file of a brick having pending AFR operations. Pending are
+// the data, metadata and entry operations the copy of the file on the brick
+// blames the other bricks of its replica set for, by the index of the brick
+// in the volume.
+type PendingHeal struct {
+	GFID    string            `json:"gfid"`
+	Path    string            `json:"path,omitempty"`
+	Size    int64             `json:"size"`
+	Mtime   time.Time         `json:"mtime"`
+	Pending map[int][3]uint32 `json:"pending,omitempty"`
+}
+
+// SplitBrainCopy is one of the conflicting copies of a file in split-brain
+type SplitBrainCopy struct {
+	BrickID  string    `json:"brick-id"`
+	Hostname string    `json:"hostname"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Mtime    time.Time `json:"mtime"`
+}
+
+// SplitBrainFile is a file in split-brain in a replica set of a volume.
+// Path is empty if the path of the file couldn't be found from its gfid.
+type SplitBrainFile struct {
+	GFID       string           `json:"gfid"`
+	Path       string           `json:"path,omitempty"`
+	ReplicaSet int              `json:"replica-set"`
+	Types      []string         `json:"types"`
+	Copies     []SplitBrainCopy `json:"copies"`
+	Policies   []string         `json:"policies"`
+}
+
+func gfidPath(brickPath string, gfid string) string {
+	return filepath.Join(brickPath, ".glusterfs", gfid[0:2], gfid[2:4], gfid)
+}
+
+// gfidToPath returns the first of the paths of the file with the given gfid,
+// relative to the root of the volume
+func gfidToPath(brickPath string, gfid string, xattrs map[string]string) (string, error) {
+	paths, err := gfidToPaths(brickPath, gfid, xattrs)
+	if err != nil {
+		return "", err
+	}
+	return paths[0], nil
+}
+
+// gfidToPaths returns the sorted paths of the file with the given gfid,
+// relative to the root of the volume. A file has a path for each of its hard
+// links. Directories are found through their gfid symlinks, and other files
+// through their gfid2path xattrs.
+func gfidToPaths(brickPath string, gfid string, xattrs map[string]string) ([]string, error) {
+	p := gfidPath(brickPath, gfid)
+	if fi, err := os.Lstat(p); err != nil {
+		return nil, err
+	} else if fi.Mode()&os.ModeSymlink != 0 {
+		root, err := filepath.EvalSymlinks(brickPath)
+		if err != nil {
+			return nil, err
+		}
+		resolved, err := filepath.EvalSymlinks(p)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(root, resolved)
+		if err != nil {
+			return nil, err
+		}
+		return []string{path.Join("/", rel)}, nil
+	}
+
+	seen := make(map[string]bool)
+	var paths []string
+	for name, value := range xattrs {
+		if !strings.HasPrefix(name, gfid2pathXattrPrefix) {
+			continue
+		}
+		b, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+		if err != nil {
+			continue
+		}
+		// The value is the gfid of the parent and the name of the file
+		parts := strings.SplitN(strings.TrimRight(string(b), "\x00"), "/", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		parent := "/"
+		if parts[0] != rootGFID {
+			if parent, err = gfidToPath(brickPath, parts[0], nil); err != nil {
+				continue
+			}
+		}
+		if fp := path.Join(parent, parts[1]); !seen[fp] {
+			seen[fp] = true
+			paths = append(paths, fp)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no path found for gfid %s", gfid)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// parsePendingXattrs returns the AFR changelog of a file from its xattrs, by
+// the index of the blamed brick in the volume
+func parsePendingXattrs(volname string, xattrs map[string]string) map[int][3]uint32 {
+	prefix := afrPendingXattrPrefix + volname + "-client-"
+	pending := make(map[int][3]uint32)
+	for name, value := range xattrs {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimPrefix(name, prefix))
+		if err != nil {
+			continue
+		}
+		b, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+		if err != nil || len(b) < 12 {
+			continue
+		}
+		var counts [3]uint32
+		for i := range counts {
+			counts[i] = binary.BigEndian.Uint32(b[i*4:])
+		}
+		if counts != [3]uint32{} {
+			pending[index] = counts
+		}
+	}
+	return pending
+}
+
+// GetPendingHeals returns the files of a brick of the volume which have
+// pending AFR operations, from the AFR index of the brick. Index entries
+// whose file is gone are skipped.
+func GetPendingHeals(volname string, b brick.Brickinfo) ([]PendingHeal, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(b.Path, afrIndexDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var heals []PendingHeal
+	for _, e := range entries {
+		gfid := e.Name()
+		if strings.HasPrefix(gfid, afrIndexBaseName) || len(gfid) < 4 {
+			continue
+		}
+
+		// The gfid path of a directory is a symlink to it, and is
+		// followed along with the xattrs
+		p := gfidPath(b.Path, gfid)
+		fi, err := os.Stat(p)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		xattrs, err := utils.GetGlusterXattrs(p)
+		if err != nil {
+			return nil, err
+		}
+
+		heal := PendingHeal{
+			GFID:    gfid,
+			Size:    fi.Size(),
+			Mtime:   fi.ModTime(),
+			Pending: parsePendingXattrs(volname, xattrs),
+		}
+		if len(heal.Pending) == 0 {
+			continue
+		}
+		// Files are reported by their gfid alone if their path can't
+		// be found
+		heal.Path, _ = gfidToPath(b.Path, gfid, xattrs)
+		heals = append(heals, heal)
+	}
+	return heals, nil
+}
+
+// splitBrainPolicies returns the policies which can resolve a split-brain of
+// the given types. Choosing the bigger or the latest modified file is offered
+// only for data split-brain, and only if the copies differ in it.
+func splitBrainPolicies(types []string, copies []SplitBrainCopy) []string {
+	var policies []string
+	for _, t := range types {
+		if t != SplitBrainData {
+			continue
+		}
+		sizes := make(map[int64]bool)
+		mtimes := make(map[int64]bool)
+		for _, c := range copies {
+			sizes[c.Size] = true
+			mtimes[c.Mtime.UnixNano()] = true
+		}
+		if len(sizes) > 1 {
+			policies = append(policies, SplitBrainPolicyBiggerFile)
+		}
+		if len(mtimes) > 1 {
+			policies = append(policies, SplitBrainPolicyLatestMtime)
+		}
+	}
+	return append(policies, SplitBrainPolicySourceBrick)
+}
+
+// FindSplitBrain finds the files of the volume in split-brain, from the
+// pending heals of its bricks by BrickID. A file is in split-brain if every
+// brick of its replica set is blamed by another for the same kind of
+// operation, leaving no brick whose copy AFR could heal the others from.
+func FindSplitBrain(v *Volinfo, heals map[string][]PendingHeal) []SplitBrainFile {
+	files := []SplitBrainFile{}
+	if v.ReplicaCount < 2 {
+		return files
+	}
+
+	for first := 0; first+v.ReplicaCount <= len(v.Bricks); first += v.ReplicaCount {
+		// The copies of each file in the replica set, by brick index
+		copies := make(map[string]map[int]PendingHeal)
+		for i := first; i < first+v.ReplicaCount; i++ {
+			for _, h := range heals[v.Bricks[i].ID()] {
+				if copies[h.GFID] == nil {
+					copies[h.GFID] = make(map[int]PendingHeal)
+				}
+				copies[h.GFID][i] = h
+			}
+		}
+
+		var gfids []string
+		for gfid := range copies {
+			gfids = append(gfids, gfid)
+		}
+		sort.Strings(gfids)
+
+		for _, gfid := range gfids {
+			var types []string
+			for t, kind := range splitBrainTypes {
+				blamed := 0
+				for i := first; i < first+v.ReplicaCount; i++ {
+					for j, c := range copies[gfid] {
+						if j != i && c.Pending[i][t] > 0 {
+							blamed++
+							break
+						}
+					}
+				}
+				if blamed == v.ReplicaCount {
+					types = append(types, kind)
+				}
+			}
+			if len(types) == 0 {
+				continue
+			}
+
+			f := SplitBrainFile{
+				GFID:       gfid,
+				ReplicaSet: first / v.ReplicaCount,
+				Types:      types,
+			}
+			for i := first; i < first+v.ReplicaCount; i++ {
+				c, ok := copies[gfid][i]
+				if !ok {
+					continue
+				}
+				if f.Path == "" {
+					f.Path = c.Path
+				}
+				f.Copies = append(f.Copies, SplitBrainCopy{
+					BrickID:  v.Bricks[i].ID(),
+					Hostname: v.Bricks[i].Hostname,
+					Path:     v.Bricks[i].Path,
+					Size:     c.Size,
+					Mtime:    c.Mtime,
+				})
+			}
+			f.Policies = splitBrainPolicies(types, f.Copies)
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// SplitBrainSource returns the copy a file in split-brain is resolved with by
+// the given policy, along with the kinds of split-brain the policy resolves.
+// sourceBrick is the BrickID of the source brick for the source-brick policy.
+func SplitBrainSource(f *SplitBrainFile, policy string, sourceBrick string) (*SplitBrainCopy, []string, error) {
+	allowed := false
+	for _, p := range f.Policies {
+		if p == policy {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return nil, nil, fmt.Errorf("policy %s can't resolve the split-brain of %s", policy, f.GFID)
+	}
+
+	var source *SplitBrainCopy
+	tie := false
+	for i := range f.Copies {
+		c := &f.Copies[i]
+		switch policy {
+		case SplitBrainPolicyBiggerFile:
+			if source == nil || c.Size > source.Size {
+				source, tie = c, false
+			} else if c.Size == source.Size {
+				tie = true
+			}
+		case SplitBrainPolicyLatestMtime:
+			if source == nil || c.Mtime.After(source.Mtime) {
+				source, tie = c, false
+			} else if c.Mtime.Equal(source.Mtime) {
+				tie = true
+			}
+		case SplitBrainPolicySourceBrick:
+			if c.BrickID == sourceBrick {
+				source = c
+			}
+		}
+	}
+
+	if policy == SplitBrainPolicySourceBrick {
+		if source == nil {
+			return nil, nil, fmt.Errorf("brick %s has no copy of %s in split-brain", sourceBrick, f.GFID)
+		}
+		return source, f.Types, nil
+	}
+	if tie {
+		return nil, nil, fmt.Errorf("more than one copy of %s qualifies as %s", f.GFID, policy)
+	}
+	return source, []string{SplitBrainData}, nil
+}
+
+// ClearPendingBlame clears the pending operations of the given kinds which the
+// copy of a file on a brick blames the brick with the given index in the
+// volume for. Once no copy blames that brick, AFR heals the file from it.
+func ClearPendingBlame(volname string, b brick.Brickinfo, gfid string, index int, types []string) error {
+	p := gfidPath(b.Path, gfid)
+	name := fmt.Sprintf("%s%s-client-%d", afrPendingXattrPrefix, volname, index)
+
+	value := make([]byte, 12)
+	size, err := utils.Getxattr(p, name, value)
+	if err != nil {
+		return err
+	}
+	if size != len(value) {
+		return fmt.Errorf("invalid AFR changelog %s on %s", name, p)
+	}
+
+	for t, kind := range splitBrainTypes {
+		for _, k := range types {
+			if k == kind {
+				binary.BigEndian.PutUint32(value[t*4:], 0)
+			}
+		}
+	}
+	return utils.Setxattr(p, name, value, 0)
+}
+
+// MarkRootPendingHeal makes the root of a brick blame the bricks with the
+// given indices in the volume for pending entry and metadata operations, and
+// adds the root to the AFR index of the brick. The self-heal daemon then heals
+// the whole tree of the brick onto those bricks.
+func MarkRootPendingHeal(volname string, b brick.Brickinfo, indices []int) error {
+	for _, index := range indices {
+		name := fmt.Sprintf("%s%s-client-%d", afrPendingXattrPrefix, volname, index)
+
+		value := make([]byte, 12)
+		size, err := utils.Getxattr(b.Path, name, value)
+		if err != nil && err != unix.ENODATA {
+			return err
+		}
+		if err == nil && size != len(value) {
+			return fmt.Errorf("invalid AFR changelog %s on %s", name, b.Path)
+		}
+
+		// Only the metadata and entry counters are raised, the data of a
+		// directory isn't healed
+		for _, t := range []int{1, 2} {
+			binary.BigEndian.PutUint32(value[t*4:], binary.BigEndian.Uint32(value[t*4:])+1)
+		}
+		if err := utils.Setxattr(b.Path, name, value, 0); err != nil {
+			return err
+		}
+	}
+	return addAFRIndexEntry(b.Path, rootGFID)
+}
+
+// AddPendingHeal adds the copy of the file with the gfid on the brick to the
+// AFR index of the brick, for the self-heal daemon to heal the file from it
+func AddPendingHeal(b brick.Brickinfo, gfid string) error {
+	return addAFRIndexEntry(b.Path, gfid)
+}
+
+// addAFRIndexEntry adds the gfid to the AFR index of the brick. The entries of
+// the index are hard links to its base file, which is created if the brick
+// has none yet.
+func addAFRIndexEntry(brickPath string, gfid string) error {
+	dir := filepath.Join(brickPath, afrIndexDir)
+	if err := os.MkdirAll(dir, 0600); err != nil {
+		return err
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var base string
+	for _, e := range entries {
+		if e.Name() == gfid {
+			return nil
+		}
+		if strings.HasPrefix(e.Name(), afrIndexBaseName) {
+			base = filepath.Join(dir, e.Name())
+		}
+	}
+	if base == "" {
+		base = filepath.Join(dir, afrIndexBaseName+uuid.NewRandom().String())
+		f, err := os.OpenFile(base, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		f.Close()
+	}
+
+	err = os.Link(base, filepath.Join(dir, gfid))
+	if os.IsExist(err) {
+		return nil
+	}
+	return err
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"strings"
 
 	"github.com/gluster/glusterd2/store"
+
+	"github.com/coreos/etcd/clientv3"
 	"github.com/pborman/uuid"
 
 	log "github.com/Sirupsen/logrus"
-	"github.com/coreos/etcd/clientv3"
 )
 
 const (
@@ -25,13 +27,38 @@ var (
 
 // AddOrUpdateVolume marshals to volume object and passes to store to add/update
 func AddOrUpdateVolume(v *Volinfo) error {
-	json, e := json.Marshal(v)
-	if e != nil {
-		log.WithField("error", e).Error("Failed to marshal the volinfo object")
-		return e
+	return AddOrUpdateVolumes([]*Volinfo{v})
+}
+
+// AddOrUpdateVolumes stores the volumes together in a single transaction, so
+// that either all of them or none are updated
+func AddOrUpdateVolumes(volinfos []*Volinfo) error {
+	var volOps, indexOps []clientv3.Op
+	for _, v := range volinfos {
+		json, e := json.Marshal(v)
+		if e != nil {
+			log.WithField("error", e).Error("Failed to marshal the volinfo object")
+			return e
+		}
+		volOps = append(volOps, clientv3.OpPut(volumePrefix+v.Name, string(json)))
+
+		// The brick index is updated along with the volume, so that it never
+		// lists bricks the volume doesn't have. Index updates too large for
+		// the transaction of the volumes are completed by commitVolumeOps.
+		old, e := getStoredVolume(v.Name)
+		if e != nil {
+			log.WithError(e).Error("Couldn't retrive volume from store")
+			return e
+		}
+		ops, e := brickIndexOps(old, v)
+		if e != nil {
+			return e
+		}
+		indexOps = append(indexOps, ops...)
 	}
 
-	_, e = store.Store.Put(context.TODO(), volumePrefix+v.Name, string(json))
+	e := commitVolumeOps(volOps, indexOps)
+	store.Store.InvalidateCache(volumePrefix)
 	if e != nil {
 		log.WithError(e).Error("Couldn't add volume to store")
 		return e
@@ -63,20 +90,29 @@ func GetVolume(name string) (*Volinfo, error) {
 
 //DeleteVolume passes the volname to store to delete the volume object
 func DeleteVolume(name string) error {
-	_, e := store.Store.Delete(context.TODO(), volumePrefix+name)
+	old, e := getStoredVolume(name)
+	if e != nil {
+		return e
+	}
+	ops, e := brickIndexOps(old, nil)
+	if e != nil {
+		return e
+	}
+	e = commitVolumeOps([]clientv3.Op{clientv3.OpDelete(volumePrefix + name)}, ops)
+	store.Store.InvalidateCache(volumePrefix)
 	return e
 }
 
 // GetVolumesList returns a map of volume names to their UUIDs
 func GetVolumesList() (map[string]uuid.UUID, error) {
-	resp, e := store.Store.Get(context.TODO(), volumePrefix, clientv3.WithPrefix())
+	kvs, e := store.Store.GetPrefix(volumePrefix)
 	if e != nil {
 		return nil, e
 	}
 
 	volumes := make(map[string]uuid.UUID)
 
-	for _, kv := range resp.Kvs {
+	for _, kv := range kvs {
 		var vol Volinfo
 
 		if err := json.Unmarshal(kv.Value, &vol); err != nil {
@@ -96,14 +132,14 @@ func GetVolumesList() (map[string]uuid.UUID, error) {
 //GetVolumes retrives the json objects from the store and converts them into
 //respective volinfo objects
 func GetVolumes() ([]Volinfo, error) {
-	resp, e := store.Store.Get(context.TODO(), volumePrefix, clientv3.WithPrefix())
+	kvs, e := store.Store.GetPrefix(volumePrefix)
 	if e != nil {
 		return nil, e
 	}
 
-	volumes := make([]Volinfo, len(resp.Kvs))
+	volumes := make([]Volinfo, len(kvs))
 
-	for i, kv := range resp.Kvs {
+	for i, kv := range kvs {
 		var vol Volinfo
 
 		if err := json.Unmarshal(kv.Value, &vol); err != nil {
@@ -120,6 +156,42 @@ func GetVolumes() ([]Volinfo, error) {
 	return volumes, nil
 }
 
+// GetVolumesPage returns a page of the volumes, ordered by name, read from the
+// store without loading all the volumes. See store.GetPrefixPage for after,
+// skip, limit and more.
+func GetVolumesPage(after string, skip, limit int) ([]Volinfo, bool, error) {
+	kvs, more, e := store.Store.GetPrefixPage(volumePrefix, after, skip, limit)
+	if e != nil {
+		return nil, false, e
+	}
+
+	volumes := make([]Volinfo, 0, len(kvs))
+	for _, kv := range kvs {
+		var vol Volinfo
+		if err := json.Unmarshal(kv.Value, &vol); err != nil {
+			log.WithFields(log.Fields{
+				"volume": string(kv.Key),
+				"error":  err,
+			}).Error("Failed to unmarshal volume")
+			continue
+		}
+		volumes = append(volumes, vol)
+	}
+
+	return volumes, more, nil
+}
+
+// CountVolumes returns the number of volumes
+func CountVolumes() (int, error) {
+	return store.Store.CountPrefix(volumePrefix)
+}
+
+// InitCache preloads the volumes into the store cache, so that listing
+// volumes doesn't need to hit the store
+func InitCache() error {
+	return store.Store.CachePrefix(volumePrefix)
+}
+
 //Exists check whether a given volume exist or not
 func Exists(name string) bool {
 	resp, e := store.Store.Get(context.TODO(), volumePrefix+name)
@@ -129,3 +201,23 @@ func Exists(name string) bool {
 
 	return resp.Count == 1
 }
+
+// GetVolumesMetadata returns the stored metadata of the volumes, by volume
+// name. The metadata is served from the cache of this node, like the other
+// volume reads, unless uncached is set.
+func GetVolumesMetadata(uncached bool) (map[string]json.RawMessage, error) {
+	get := store.Store.GetPrefix
+	if uncached {
+		get = store.Store.GetPrefixUncached
+	}
+	kvs, e := get(volumePrefix)
+	if e != nil {
+		return nil, e
+	}
+
+	volumes := make(map[string]json.RawMessage, len(kvs))
+	for _, kv := range kvs {
+		volumes[strings.TrimPrefix(string(kv.Key), volumePrefix)] = kv.Value
+	}
+	return volumes, nil
+}
@@ -6,8 +6,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"path/filepath"
+	"time"
 
 	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/daemon"
 	"github.com/gluster/glusterd2/errors"
 	"github.com/gluster/glusterd2/gdctx"
 	"github.com/gluster/glusterd2/peer"
@@ -15,6 +17,7 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/pborman/uuid"
+	config "github.com/spf13/viper"
 )
 
 // VolState is the current status of a volume
@@ -54,6 +57,19 @@ const (
 	DistDisperse
 )
 
+// InferVolumeType returns the type of a volume made of the given number of
+// bricks with the given replica count
+func InferVolumeType(brickCount int, replicaCount int) VolType {
+	switch {
+	case brickCount == 1 || replicaCount <= 1:
+		return Distribute
+	case brickCount == replicaCount:
+		return Replicate
+	default:
+		return DistReplicate
+	}
+}
+
 // Volinfo repesents a volume
 type Volinfo struct {
 	ID           uuid.UUID
@@ -68,6 +84,113 @@ type Volinfo struct {
 	Version      uint64
 	Bricks       []brick.Brickinfo
 	Auth         VolAuth // TODO: should not be returned to client
+
+	// BrickOrder is the ordering of the bricks, one of BrickOrderAsGiven
+	// or BrickOrderHashed. Bricks added by an expand are ordered the same
+	// way.
+	BrickOrder string
+
+	// BrickLimits are the resource limits of the brick processes of the
+	// volume. Limits not set here are taken from the node configuration.
+	BrickLimits daemon.Limits
+
+	// BrickArgs are extra arguments the brick processes of the volume are
+	// started with, from those allowed by brick.ValidateExtraArgs
+	BrickArgs []string
+
+	Encryption VolEncryption
+
+	// ThinArbiter is the thin-arbiter of a replica 2 volume, nil if the
+	// volume has none
+	ThinArbiter *ThinArbiter
+
+	// NFSExport is the NFS-Ganesha export of the volume, nil if the
+	// volume isn't exported
+	NFSExport *NFSExport
+
+	// SMBShare is the Samba share of the volume, nil if the volume isn't
+	// shared
+	SMBShare *SMBShare
+
+	// Barrier is the I/O barrier of the bricks of the volume
+	Barrier VolBarrier
+
+	// ReadOnly makes the bricks of the volume reject writes, while it
+	// stays started
+	ReadOnly bool
+
+	// SubdirExports are the subdirectories of the volume exported to
+	// clients of their own
+	SubdirExports []SubdirExport
+
+	// SelfHealDisabled stops the self-heal daemon from healing the
+	// replicated volume. Clients still heal the files they access.
+	SelfHealDisabled bool
+
+	// DefaultOptions are the options of the volume taken from the cluster
+	// default options at create, rather than given explicitly
+	DefaultOptions []string
+
+	// RecommendedOptions are the options of the volume taken from the
+	// defaults recommended at OptionsOpVersion, which are changed when the
+	// options of the volume are migrated to a later op-version
+	RecommendedOptions []string
+	OptionsOpVersion   int
+}
+
+// EffectiveBrickLimits returns the resource limits applied to the brick
+// processes of the volume on this node
+func (v *Volinfo) EffectiveBrickLimits() daemon.Limits {
+	return v.BrickLimits.WithDefaults(brick.DefaultLimits())
+}
+
+// BrickByID returns the brick of the volume having the given BrickID
+func (v *Volinfo) BrickByID(id string) (*brick.Brickinfo, bool) {
+	for i := range v.Bricks {
+		if v.Bricks[i].ID() == id {
+			return &v.Bricks[i], true
+		}
+	}
+	return nil, false
+}
+
+// NFSExport is an export of a volume by NFS-Ganesha
+type NFSExport struct {
+	ExportID int         `json:"export-id"`
+	Nodes    []uuid.UUID `json:"nodes"`
+}
+
+// SMBShare is a share of a volume by Samba
+type SMBShare struct {
+	Name       string      `json:"name"`
+	GuestOK    bool        `json:"guest-ok,omitempty"`
+	ValidUsers []string    `json:"valid-users,omitempty"`
+	Nodes      []uuid.UUID `json:"nodes"`
+}
+
+// VolBarrier is the state of the I/O barrier of a volume. While the barrier
+// is enabled, the bricks hold back acknowledging writes. The bricks release
+// the barrier themselves once Timeout seconds have passed, so a barrier is
+// active only till Expires even if it is never disabled.
+type VolBarrier struct {
+	Enabled bool      `json:"enabled"`
+	Timeout int       `json:"timeout,omitempty"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// Active returns true if the barrier is enabled and hasn't expired
+func (b VolBarrier) Active() bool {
+	return b.Enabled && time.Now().Before(b.Expires)
+}
+
+// VolEncryption tells which on-wire encryption is enabled for a volume
+type VolEncryption struct {
+	// IO enables SSL for the I/O path between clients and bricks
+	IO bool `json:"io,omitempty"`
+	// AllowedCNs are the common names of the certificates of the clients
+	// allowed to connect to the bricks over SSL, any client with a
+	// certificate signed by the CA if empty
+	AllowedCNs []string `json:"allowed-cns,omitempty"`
 }
 
 // VolAuth represents username and password used by trusted/internal clients
@@ -79,6 +202,14 @@ type VolAuth struct {
 // VolStatus represents collective status of the bricks that make up the volume
 type VolStatus struct {
 	Brickstatuses []brick.Brickstatus
+	// Barrier is the I/O barrier of the volume, which is reported
+	// disabled once it has expired
+	Barrier VolBarrier
+	// ReadOnly is true if the volume rejects writes
+	ReadOnly bool
+	// SelfHeal is true if the self-heal daemon heals the volume, which
+	// is never the case for volumes which aren't replicated
+	SelfHeal bool
 	// TODO: Add further fields like memory usage, brick filesystem, fd consumed,
 	// clients connected etc.
 }
@@ -108,6 +239,9 @@ func NewBrickEntries(bricks []string, volName string, volID uuid.UUID) ([]brick.
 			if e != nil {
 				return nil, e
 			}
+			// The hostname is part of the ID of the brick, so it
+			// stays the peer address even if clients reach the
+			// brick on the storage address of the peer
 			binfo.Hostname = p.Addresses[0]
 		} else {
 			binfo.NodeID, e = peer.GetPeerIDByAddrF(host)
@@ -127,6 +261,16 @@ func NewBrickEntries(bricks []string, volName string, volID uuid.UUID) ([]brick.
 
 // ValidateBrickEntries validates the brick list
 func ValidateBrickEntries(bricks []brick.Brickinfo, volID uuid.UUID, force bool) (int, error) {
+	return ValidateBrickEntriesWithOpts(bricks, volID, utils.BrickValidationOpts{Force: force})
+}
+
+// ValidateBrickEntriesWithOpts is ValidateBrickEntries with options
+func ValidateBrickEntriesWithOpts(bricks []brick.Brickinfo, volID uuid.UUID, opts utils.BrickValidationOpts) (int, error) {
+
+	policy, err := GetBrickPathPolicy()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 
 	for _, b := range bricks {
 		if !uuid.Equal(b.NodeID, gdctx.MyUUID) {
@@ -150,15 +294,27 @@ func ValidateBrickEntries(bricks []brick.Brickinfo, volID uuid.UUID, force bool)
 		if err != nil {
 			return http.StatusBadRequest, err
 		}
-		err = isBrickPathAvailable(b.Hostname, b.Path)
+		err = utils.ValidateBrickPathDepth(b.Path, config.GetInt("brick-max-depth"))
 		if err != nil {
 			return http.StatusBadRequest, err
 		}
-		err = validateBrickPathStatsFunc(b.Path, b.Hostname, force)
+		// Recovered bricks were created before the policy, and are
+		// kept wherever they are
+		if !opts.Recover {
+			err = checkBrickPathPolicy(policy, b.Path)
+			if err != nil {
+				return http.StatusBadRequest, err
+			}
+		}
+		err = isBrickPathAvailable(b.NodeID, b.Path)
 		if err != nil {
 			return http.StatusBadRequest, err
 		}
-		err = utils.ValidateXattrSupport(b.Path, b.Hostname, volID, force)
+		err = validateBrickPathStatsFunc(b.Path, b.Hostname, opts.Force)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		err = utils.ValidateXattrSupportWithOpts(b.Path, b.Hostname, volID, opts)
 		if err != nil {
 			return http.StatusBadRequest, err
 		}
@@ -166,6 +322,43 @@ func ValidateBrickEntries(bricks []brick.Brickinfo, volID uuid.UUID, force bool)
 	return 0, nil
 }
 
+// CheckBrickEntry runs the checks of ValidateBrickEntriesWithOpts on a brick
+// of this node without modifying the brick, and returns all the checks which
+// failed rather than only the first
+func CheckBrickEntry(b brick.Brickinfo, policy *BrickPathPolicy, force bool) []error {
+	local, err := utils.IsLocalAddress(b.Hostname)
+	if err != nil {
+		return []error{err}
+	}
+	if !local {
+		return []error{errors.ErrBrickNotLocal}
+	}
+
+	var errs []error
+	checks := []func() error{
+		func() error { return utils.ValidateBrickPathLength(b.Path) },
+		func() error { return utils.ValidateBrickSubDirLength(b.Path) },
+		func() error { return utils.ValidateBrickPathDepth(b.Path, config.GetInt("brick-max-depth")) },
+		func() error { return checkBrickPathPolicy(policy, b.Path) },
+		func() error { return isBrickPathAvailable(b.NodeID, b.Path) },
+	}
+	for _, check := range checks {
+		if err := check(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	opts := utils.BrickValidationOpts{Force: force, ReadOnly: true}
+	if err := utils.ValidateBrickPathStatsWithOpts(b.Path, b.Hostname, opts); err != nil {
+		// The xattr checks need the path to be usable as a brick
+		return append(errs, err)
+	}
+	if err := utils.ValidateXattrSupportWithOpts(b.Path, b.Hostname, b.VolumeID, opts); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
 func (v *Volinfo) String() string {
 	b, err := json.Marshal(v)
 	if err != nil {
@@ -0,0 +1,99 @@
+package volume
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// AuthAllowOption is the volume option listing the clients allowed to mount
+// the volume, and its subdirectories
+const AuthAllowOption = "auth.allow"
+
+// SubdirExport is a subdirectory of a volume which the given clients, by
+// address or hostname, which may have wildcards, are allowed to mount. Once
+// a volume has exports, only the clients of an export of / can mount the
+// whole volume.
+type SubdirExport struct {
+	Path    string   `json:"path"`
+	Clients []string `json:"clients"`
+}
+
+// ValidateSubdirExport checks the subdirectory and clients of an export, and
+// returns the export with its path cleaned. The path must be within the
+// volume.
+func ValidateSubdirExport(e SubdirExport) (SubdirExport, error) {
+	if !path.IsAbs(e.Path) {
+		return e, fmt.Errorf("subdirectory %q must be an absolute path within the volume", e.Path)
+	}
+	for _, elem := range strings.Split(e.Path, "/") {
+		if elem == ".." {
+			return e, fmt.Errorf("subdirectory %q is outside the volume", e.Path)
+		}
+	}
+	// The option separates exports with commas and their clients with
+	// parentheses and pipes
+	if strings.ContainsAny(e.Path, ",()| \t\n") {
+		return e, fmt.Errorf("invalid subdirectory %q", e.Path)
+	}
+	e.Path = path.Clean(e.Path)
+
+	if len(e.Clients) == 0 {
+		return e, fmt.Errorf("no clients given for subdirectory %s", e.Path)
+	}
+	for _, c := range e.Clients {
+		if strings.TrimSpace(c) == "" || strings.ContainsAny(c, ",()| \t\n") {
+			return e, fmt.Errorf("invalid client %q", c)
+		}
+	}
+	return e, nil
+}
+
+// isSubdir returns true if dir is below parent
+func isSubdir(dir, parent string) bool {
+	if parent == "/" {
+		return dir != "/"
+	}
+	return strings.HasPrefix(dir, parent+"/")
+}
+
+// CheckSubdirExportConflict returns an error if the export conflicts with the
+// existing exports of the volume. Exports of the same subdirectory, or of
+// nested subdirectories other than /, conflict, as the clients of the outer
+// one could reach the inner one too.
+func (v *Volinfo) CheckSubdirExportConflict(e SubdirExport) error {
+	for _, cur := range v.SubdirExports {
+		switch {
+		case cur.Path == e.Path:
+			return fmt.Errorf("subdirectory %s is already exported", e.Path)
+		case cur.Path != "/" && isSubdir(e.Path, cur.Path):
+			return fmt.Errorf("subdirectory %s is within the exported subdirectory %s", e.Path, cur.Path)
+		case e.Path != "/" && isSubdir(cur.Path, e.Path):
+			return fmt.Errorf("subdirectory %s contains the exported subdirectory %s", e.Path, cur.Path)
+		}
+	}
+	return nil
+}
+
+// SetSubdirExports sets the subdirectory exports of the volume, and the
+// auth.allow option allowing their clients to mount them as
+// /<subdir>(<clients>),... The whole volume is only listed, as /(<clients>),
+// if it is exported.
+func (v *Volinfo) SetSubdirExports(exports []SubdirExport) {
+	sort.Slice(exports, func(i, j int) bool { return exports[i].Path < exports[j].Path })
+	v.SubdirExports = exports
+	if len(exports) == 0 {
+		delete(v.Options, AuthAllowOption)
+		return
+	}
+
+	if v.Options == nil {
+		v.Options = make(map[string]string)
+	}
+	var entries []string
+	for _, e := range exports {
+		entries = append(entries, e.Path+"("+strings.Join(e.Clients, "|")+")")
+	}
+	v.Options[AuthAllowOption] = strings.Join(entries, ",")
+}
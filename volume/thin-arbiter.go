@@ -0,0 +1,62 @@
+package volume
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/gluster/glusterd2/brick"
+	"github.com/gluster/glusterd2/utils"
+)
+
+// DefaultThinArbiterPort is the port the thin-arbiter process listens on if
+// the thin-arbiter address has no port
+const DefaultThinArbiterPort = "24007"
+
+// ThinArbiter is the thin-arbiter of a replica 2 volume, a brick on a node
+// outside the cluster which holds only the replica each replica set last
+// agreed to be good, and breaks ties between the two data bricks.
+type ThinArbiter struct {
+	Host string `json:"host"`
+	Port string `json:"port"`
+	Path string `json:"path"`
+}
+
+// Address returns the address the thin-arbiter process is reached on
+func (ta *ThinArbiter) Address() string {
+	return net.JoinHostPort(ta.Host, ta.Port)
+}
+
+// ParseThinArbiter parses a thin-arbiter given as <host>[:<port>]:<path>
+func ParseThinArbiter(s string) (*ThinArbiter, error) {
+	i := strings.LastIndex(s, ":/")
+	if i <= 0 {
+		return nil, fmt.Errorf("invalid thin-arbiter %s, expected <host>[:<port>]:<path>", s)
+	}
+
+	ta := &ThinArbiter{Host: s[:i], Port: DefaultThinArbiterPort, Path: filepath.Clean(s[i+1:])}
+	if host, port, err := net.SplitHostPort(ta.Host); err == nil {
+		ta.Host, ta.Port = host, port
+	}
+	if err := utils.ValidatePeerAddress(ta.Address()); err != nil {
+		return nil, fmt.Errorf("invalid thin-arbiter address: %s", err.Error())
+	}
+	return ta, nil
+}
+
+// ValidateThinArbiter checks that the volume can have the thin-arbiter. Only
+// replica 2 volumes can, and the thin-arbiter must be on a node other than
+// the nodes of the data bricks.
+func ValidateThinArbiter(ta *ThinArbiter, replicaCount int, bricks []brick.Brickinfo) error {
+	if replicaCount != 2 {
+		return errors.New("thin-arbiter can be used only with replica 2 volumes")
+	}
+	for _, b := range bricks {
+		if utils.IsPeerAddressSame(ta.Host, b.Hostname) {
+			return fmt.Errorf("thin-arbiter must be on a node other than the data bricks, %s has brick %s", ta.Host, b.Path)
+		}
+	}
+	return nil
+}
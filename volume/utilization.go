@@ -0,0 +1,327 @@
+package volume
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gluster/glusterd2/events"
+	"github.com/gluster/glusterd2/gdctx"
+	"github.com/gluster/glusterd2/pkg/api"
+	"github.com/gluster/glusterd2/store"
+	"github.com/gluster/glusterd2/utils"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pborman/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	config "github.com/spf13/viper"
+)
+
+const (
+	utilizationPrefix = store.GlusterPrefix + "utilization/"
+
+	// UtilizationThresholdsOption is the volume option overriding the
+	// utilization-thresholds setting for a volume
+	UtilizationThresholdsOption = "glusterd.utilization-thresholds"
+
+	// InodeUtilizationThresholdsOption is the volume option overriding the
+	// inode-utilization-thresholds setting for a volume
+	InodeUtilizationThresholdsOption = "glusterd.inode-utilization-thresholds"
+
+	defaultUtilizationInterval = time.Minute
+)
+
+// The utilization gauges of the volumes, over their bricks on this node,
+// exported if metrics-utilization is set, so that alerts on utilization can
+// be raised by Prometheus instead of by events
+var (
+	utilizationRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "glusterd2_volume_utilization_ratio",
+		Help: "Ratio of the space used to the total space of the bricks of the volume on this node.",
+	}, []string{"volume"})
+	inodeUtilizationRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "glusterd2_volume_inode_utilization_ratio",
+		Help: "Ratio of the inodes used to the total inodes of the bricks of the volume on this node.",
+	}, []string{"volume"})
+	utilizationOverThreshold = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "glusterd2_volume_utilization_over_threshold",
+		Help: "1 if a brick of the volume on this node is over a space or inode utilization threshold of the volume, 0 otherwise.",
+	}, []string{"volume"})
+)
+
+func init() {
+	prometheus.MustRegister(utilizationRatio, inodeUtilizationRatio, utilizationOverThreshold)
+}
+
+// BrickUtilization is the last sampled space and inode utilization of a
+// brick. Threshold is the highest utilization threshold crossed, 0 if none,
+// and InodeThreshold the highest inode utilization threshold crossed. The
+// inode utilization is missing for filesystems which don't report it.
+type BrickUtilization struct {
+	BrickID        string     `json:"brick-id"`
+	Hostname       string     `json:"hostname"`
+	Path           string     `json:"path"`
+	Total          api.Uint64 `json:"total"`
+	Used           api.Uint64 `json:"used"`
+	Percent        int        `json:"percent"`
+	Threshold      int        `json:"threshold,omitempty"`
+	InodesTotal    api.Uint64 `json:"inodes-total,omitempty"`
+	InodesUsed     api.Uint64 `json:"inodes-used,omitempty"`
+	InodesPercent  int        `json:"inodes-percent,omitempty"`
+	InodeThreshold int        `json:"inode-threshold,omitempty"`
+	Sampled        time.Time  `json:"sampled"`
+}
+
+// ParseThresholds parses a comma separated list of utilization percentages
+func ParseThresholds(s string) ([]int, error) {
+	var thresholds []int
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		t, err := strconv.Atoi(f)
+		if err != nil || t <= 0 || t > 100 {
+			return nil, fmt.Errorf("invalid utilization threshold %q", f)
+		}
+		thresholds = append(thresholds, t)
+	}
+	sort.Ints(thresholds)
+	return thresholds, nil
+}
+
+// UtilizationThresholds returns the utilization thresholds of the volume,
+// which are its option if set, or the utilization-thresholds setting
+func (v *Volinfo) UtilizationThresholds() ([]int, error) {
+	if s, ok := v.Options[UtilizationThresholdsOption]; ok {
+		return ParseThresholds(s)
+	}
+	return ParseThresholds(config.GetString("utilization-thresholds"))
+}
+
+// InodeUtilizationThresholds returns the inode utilization thresholds of the
+// volume, which are its option if set, or the inode-utilization-thresholds
+// setting
+func (v *Volinfo) InodeUtilizationThresholds() ([]int, error) {
+	if s, ok := v.Options[InodeUtilizationThresholdsOption]; ok {
+		return ParseThresholds(s)
+	}
+	return ParseThresholds(config.GetString("inode-utilization-thresholds"))
+}
+
+func brickUtilizationKey(volID uuid.UUID, brickID string) string {
+	return utilizationPrefix + volID.String() + "/" + brickID
+}
+
+// GetBrickUtilizations returns the last sampled utilization of the bricks of
+// the volume. Bricks not sampled yet, or on nodes which are down, are missing.
+func GetBrickUtilizations(v *Volinfo) ([]BrickUtilization, error) {
+	resp, err := store.Store.Get(context.TODO(), utilizationPrefix+v.ID.String()+"/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	sampled := make(map[string]BrickUtilization)
+	for _, kv := range resp.Kvs {
+		var u BrickUtilization
+		if err := json.Unmarshal(kv.Value, &u); err != nil {
+			return nil, err
+		}
+		sampled[u.BrickID] = u
+	}
+
+	var utilizations []BrickUtilization
+	for _, b := range v.Bricks {
+		if u, ok := sampled[b.ID()]; ok {
+			utilizations = append(utilizations, u)
+		}
+	}
+	return utilizations, nil
+}
+
+// UtilizationWatcher periodically samples the space and inode utilization of
+// the bricks on this node. Crossing a utilization threshold of the volume, in
+// either direction, raises an event. It is a suture.Service.
+type UtilizationWatcher struct {
+	stop chan struct{}
+
+	// crossed and inodesCrossed have the highest space and inode
+	// thresholds crossed by the bricks, by the store key of the brick
+	crossed       map[string]int
+	inodesCrossed map[string]int
+}
+
+var webhookOnce sync.Once
+
+// NewUtilizationWatcher returns a new UtilizationWatcher
+func NewUtilizationWatcher() *UtilizationWatcher {
+	return &UtilizationWatcher{
+		stop:          make(chan struct{}),
+		crossed:       make(map[string]int),
+		inodesCrossed: make(map[string]int),
+	}
+}
+
+// Serve samples the brick utilizations till the watcher is stopped
+func (w *UtilizationWatcher) Serve() {
+	if url := config.GetString("utilization-webhook"); url != "" {
+		webhookOnce.Do(func() {
+			events.Register(events.NewWebhookHandler(url, "volume-utilization-high", "volume-utilization-normal",
+				"volume-inode-utilization-high", "volume-inode-utilization-normal"))
+		})
+	}
+
+	interval := config.GetDuration("utilization-interval")
+	if interval <= 0 {
+		interval = defaultUtilizationInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.sample()
+		}
+	}
+}
+
+// Stop stops the UtilizationWatcher
+func (w *UtilizationWatcher) Stop() {
+	close(w.stop)
+}
+
+// highestCrossed returns the highest of the thresholds crossed by the
+// utilization, 0 if none
+func highestCrossed(thresholds []int, percent int) int {
+	crossed := 0
+	for _, t := range thresholds {
+		if percent >= t {
+			crossed = t
+		}
+	}
+	return crossed
+}
+
+func (w *UtilizationWatcher) sample() {
+	volumes, err := GetVolumes()
+	if err != nil {
+		log.WithError(err).Debug("failed to get volumes for utilization sampling")
+		return
+	}
+
+	exportGauges := config.GetBool("metrics-utilization")
+	// The gauges are reset so that volumes which are gone, or have no
+	// bricks here anymore, aren't reported
+	utilizationRatio.Reset()
+	inodeUtilizationRatio.Reset()
+	utilizationOverThreshold.Reset()
+
+	for _, v := range volumes {
+		var used, total, inodesUsed, inodesTotal uint64
+		over := 0.0
+
+		thresholds, err := v.UtilizationThresholds()
+		if err != nil {
+			log.WithError(err).WithField("volume", v.Name).Warn("invalid utilization thresholds")
+		}
+		inodeThresholds, err := v.InodeUtilizationThresholds()
+		if err != nil {
+			log.WithError(err).WithField("volume", v.Name).Warn("invalid inode utilization thresholds")
+		}
+
+		for _, b := range v.Bricks {
+			if !uuid.Equal(b.NodeID, gdctx.MyUUID) {
+				continue
+			}
+
+			btotal, bavail, err := utils.GetBrickAvailableSpace(b.Path)
+			if err != nil || btotal == 0 {
+				continue
+			}
+
+			u := BrickUtilization{
+				BrickID:  b.ID(),
+				Hostname: b.Hostname,
+				Path:     b.Path,
+				Total:    api.Uint64(btotal),
+				Used:     api.Uint64(btotal - bavail),
+				Percent:  int((btotal - bavail) * 100 / btotal),
+				Sampled:  time.Now(),
+			}
+			u.Threshold = highestCrossed(thresholds, u.Percent)
+
+			// Filesystems allocating inodes dynamically, like btrfs,
+			// report no inodes
+			if itotal, ifree, err := utils.GetBrickInodes(b.Path); err == nil && itotal > 0 {
+				u.InodesTotal = api.Uint64(itotal)
+				u.InodesUsed = api.Uint64(itotal - ifree)
+				u.InodesPercent = int((itotal - ifree) * 100 / itotal)
+				u.InodeThreshold = highestCrossed(inodeThresholds, u.InodesPercent)
+			}
+
+			used += uint64(u.Used)
+			total += uint64(u.Total)
+			inodesUsed += uint64(u.InodesUsed)
+			inodesTotal += uint64(u.InodesTotal)
+			if u.Threshold > 0 || u.InodeThreshold > 0 {
+				over = 1
+			}
+
+			key := brickUtilizationKey(v.ID, u.BrickID)
+			w.checkThreshold(w.crossed, "volume-utilization", key, &v, &u, u.Percent, u.Threshold)
+			if u.InodesTotal > 0 {
+				w.checkThreshold(w.inodesCrossed, "volume-inode-utilization", key, &v, &u, u.InodesPercent, u.InodeThreshold)
+			}
+
+			bytes, err := json.Marshal(u)
+			if err != nil {
+				continue
+			}
+			// Samples of a node go away along with its store session
+			_, err = store.Store.Put(context.TODO(), key, string(bytes), clientv3.WithLease(store.Store.Session.Lease()))
+			if err != nil {
+				log.WithError(err).WithField("brick", b.Path).Debug("failed to store brick utilization")
+			}
+		}
+
+		if exportGauges && total > 0 {
+			utilizationRatio.WithLabelValues(v.Name).Set(float64(used) / float64(total))
+			utilizationOverThreshold.WithLabelValues(v.Name).Set(over)
+		}
+		if exportGauges && inodesTotal > 0 {
+			inodeUtilizationRatio.WithLabelValues(v.Name).Set(float64(inodesUsed) / float64(inodesTotal))
+		}
+	}
+}
+
+// checkThreshold raises an event if the brick has crossed a threshold since
+// it was last sampled, recording the thresholds crossed in crossed. The event
+// is named after kind, with a -high or -normal suffix.
+func (w *UtilizationWatcher) checkThreshold(crossed map[string]int, kind string, key string, v *Volinfo, u *BrickUtilization, percent int, threshold int) {
+	prev, seen := crossed[key]
+	crossed[key] = threshold
+	if threshold == prev || (!seen && threshold == 0) {
+		return
+	}
+
+	name := kind + "-high"
+	if threshold < prev {
+		name = kind + "-normal"
+	}
+	events.Broadcast(events.New(name, map[string]string{
+		"volume.name": v.Name,
+		"brick":       u.Hostname + ":" + u.Path,
+		"percent":     strconv.Itoa(percent),
+		"threshold":   strconv.Itoa(threshold),
+	}))
+}
@@ -1,18 +1,20 @@
 package volume
 
 import (
+	"fmt"
 	"os"
 	"strings"
 
 	"github.com/gluster/glusterd2/brick"
-	"github.com/gluster/glusterd2/errors"
 	"github.com/gluster/glusterd2/utils"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/pborman/uuid"
 )
 
 var (
-	getVolumesFunc = GetVolumes
+	getVolumesFunc            = GetVolumes
+	canonicalizeBrickPathFunc = utils.CanonicalizeBrickPath
 )
 
 // RemoveBrickPaths is to clean up the bricks in case commit fails for volume
@@ -35,9 +37,31 @@ func RemoveBrickPaths(bricks []brick.Brickinfo) error {
 	return e
 }
 
-// isBrickPathAvailable validates whether the brick is consumed by other
-// volume
-func isBrickPathAvailable(hostname string, brickPath string) error {
+// BrickPathInUseError is returned when a brick path overlaps with a brick of
+// an existing volume on the same node
+type BrickPathInUseError struct {
+	Path         string
+	Volume       string
+	ExistingPath string
+}
+
+func (e *BrickPathInUseError) Error() string {
+	return fmt.Sprintf("brick path %s overlaps with brick %s of volume %s",
+		e.Path, e.ExistingPath, e.Volume)
+}
+
+// brickPathsOverlap returns true if both the paths are the same or if one of
+// them is a parent directory of the other
+func brickPathsOverlap(p1 string, p2 string) bool {
+	if p1 == p2 {
+		return true
+	}
+	return strings.HasPrefix(p1, p2+"/") || strings.HasPrefix(p2, p1+"/")
+}
+
+// isBrickPathAvailable validates whether the brick path, or any of its parent
+// or child directories, is consumed by other volume on the same node
+func isBrickPathAvailable(nodeID uuid.UUID, brickPath string) error {
 	volumes, e := getVolumesFunc()
 	if e != nil || volumes == nil {
 		// In case cluster doesn't have any volumes configured yet,
@@ -45,11 +69,28 @@ func isBrickPathAvailable(hostname string, brickPath string) error {
 		log.Debug("Failed to retrieve volumes")
 		return nil
 	}
+
+	p, e := canonicalizeBrickPathFunc(brickPath)
+	if e != nil {
+		return e
+	}
+
 	for _, v := range volumes {
 		for _, b := range v.Bricks {
-			if b.Hostname == hostname && b.Path == brickPath {
-				log.Error("Brick is already used by ", v.Name)
-				return errors.ErrBrickPathAlreadyInUse
+			if !uuid.Equal(b.NodeID, nodeID) {
+				continue
+			}
+			existing, e := canonicalizeBrickPathFunc(b.Path)
+			if e != nil {
+				existing = b.Path
+			}
+			if brickPathsOverlap(p, existing) {
+				log.WithFields(log.Fields{
+					"brick":    brickPath,
+					"existing": b.Path,
+					"volume":   v.Name,
+				}).Error("Brick path overlaps with brick of existing volume")
+				return &BrickPathInUseError{brickPath, v.Name, b.Path}
 			}
 		}
 	}
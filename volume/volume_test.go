@@ -3,13 +3,17 @@ package volume
 import (
 	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gluster/glusterd2/brick"
 	"github.com/gluster/glusterd2/errors"
 	"github.com/gluster/glusterd2/peer"
 	"github.com/gluster/glusterd2/tests"
 
 	heketitests "github.com/heketi/tests"
+	"github.com/pborman/uuid"
 )
 
 func find(haystack []string, needle string) bool {
@@ -66,3 +70,551 @@ func TestNewBrickEntry(t *testing.T) {
 	tests.Assert(t, err == errors.ErrBrickPathConvertFail)
 
 }
+
+// TestIsBrickPathAvailable validates isBrickPathAvailable()
+func TestIsBrickPathAvailable(t *testing.T) {
+	nodeID := uuid.NewRandom()
+	defer heketitests.Patch(&getVolumesFunc, func() ([]Volinfo, error) {
+		return []Volinfo{
+			{
+				Name: "vol1",
+				Bricks: []brick.Brickinfo{
+					{NodeID: nodeID, Path: "/bricks/vol1/b1"},
+				},
+			},
+		}, nil
+	}).Restore()
+
+	tests.Assert(t, isBrickPathAvailable(nodeID, "/bricks/vol2/b1") == nil)
+	tests.Assert(t, isBrickPathAvailable(nodeID, "/bricks/vol1/b10") == nil)
+	tests.Assert(t, isBrickPathAvailable(uuid.NewRandom(), "/bricks/vol1/b1") == nil)
+
+	// Same path, parent path and child path must all be rejected
+	for _, p := range []string{"/bricks/vol1/b1", "/bricks/vol1/b1/", "/bricks/vol1", "/bricks/vol1/b1/sub"} {
+		err := isBrickPathAvailable(nodeID, p)
+		e, ok := err.(*BrickPathInUseError)
+		tests.Assert(t, ok)
+		tests.Assert(t, e.Volume == "vol1")
+	}
+}
+
+// TestBrickByID validates that brick IDs round trip to the bricks of a volume
+func TestBrickByID(t *testing.T) {
+	v := &Volinfo{Bricks: []brick.Brickinfo{
+		{Hostname: "host1", Path: "/bricks/b1"},
+		{Hostname: "host2", Path: "/bricks/b1"},
+	}}
+
+	for i := range v.Bricks {
+		b, ok := v.BrickByID(brick.BrickID(v.Bricks[i].Hostname, v.Bricks[i].Path))
+		tests.Assert(t, ok)
+		tests.Assert(t, b.Hostname == v.Bricks[i].Hostname)
+	}
+
+	// The ID doesn't depend on the case of the host or trailing slashes
+	_, ok := v.BrickByID(brick.BrickID("HOST1", "/bricks/b1/"))
+	tests.Assert(t, ok)
+
+	_, ok = v.BrickByID(brick.BrickID("host3", "/bricks/b1"))
+	tests.Assert(t, !ok)
+}
+
+// TestParseThresholds validates parsing of utilization thresholds and the
+// threshold crossed by a utilization
+func TestParseThresholds(t *testing.T) {
+	th, err := ParseThresholds("90, 80")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, len(th) == 2 && th[0] == 80 && th[1] == 90)
+
+	tests.Assert(t, highestCrossed(th, 79) == 0)
+	tests.Assert(t, highestCrossed(th, 85) == 80)
+	tests.Assert(t, highestCrossed(th, 100) == 90)
+
+	for _, s := range []string{"abc", "0", "101", "80,-1"} {
+		_, err := ParseThresholds(s)
+		tests.Assert(t, err != nil)
+	}
+}
+
+// TestInodeUtilizationThresholds validates that the inode utilization
+// thresholds of a volume are set independently of the space ones
+func TestInodeUtilizationThresholds(t *testing.T) {
+	v := &Volinfo{Options: map[string]string{
+		UtilizationThresholdsOption:      "90",
+		InodeUtilizationThresholdsOption: "70,95",
+	}}
+	th, err := v.InodeUtilizationThresholds()
+	tests.Assert(t, err == nil)
+	tests.Assert(t, len(th) == 2 && th[0] == 70 && th[1] == 95)
+
+	th, err = v.UtilizationThresholds()
+	tests.Assert(t, err == nil)
+	tests.Assert(t, len(th) == 1 && th[0] == 90)
+
+	v.Options[InodeUtilizationThresholdsOption] = "200"
+	_, err = v.InodeUtilizationThresholds()
+	tests.Assert(t, err != nil)
+}
+
+// TestOrderBricks validates that hashed ordering keeps replica sets together
+// and keeps the relative order of existing sets on expansion
+func TestOrderBricks(t *testing.T) {
+	var bricks []brick.Brickinfo
+	for i := 0; i < 8; i++ {
+		bricks = append(bricks, brick.Brickinfo{
+			Hostname: fmt.Sprintf("host%d", i%2),
+			Path:     fmt.Sprintf("/bricks/b%d", i/2),
+		})
+	}
+
+	asGiven := OrderBricks(bricks, 2, BrickOrderAsGiven)
+	tests.Assert(t, asGiven[0].ID() == bricks[0].ID())
+
+	ordered := OrderBricks(bricks[:6], 2, BrickOrderHashed)
+	tests.Assert(t, len(ordered) == 6)
+	for i := 0; i < len(ordered); i += 2 {
+		// The sets are those of the given order
+		tests.Assert(t, ordered[i].Path == ordered[i+1].Path)
+		tests.Assert(t, ordered[i].Hostname == "host0")
+	}
+
+	expanded := ExpandBricks(ordered, bricks[6:], 2, BrickOrderHashed)
+	tests.Assert(t, len(expanded) == 8)
+	var old []string
+	for _, b := range expanded {
+		if b.Path != bricks[6].Path {
+			old = append(old, b.ID())
+		}
+	}
+	for i := range ordered {
+		tests.Assert(t, old[i] == ordered[i].ID())
+	}
+
+	// Sets placed in another order aren't reordered by a hashed expand
+	reversed := append(append(append([]brick.Brickinfo{}, ordered[4:6]...), ordered[2:4]...), ordered[0:2]...)
+	expanded = ExpandBricks(reversed, bricks[6:], 2, BrickOrderHashed)
+	old = nil
+	for _, b := range expanded {
+		if b.Path != bricks[6].Path {
+			old = append(old, b.ID())
+		}
+	}
+	for i := range reversed {
+		tests.Assert(t, old[i] == reversed[i].ID())
+	}
+
+	tests.Assert(t, ValidateBrickOrder("random") != nil)
+}
+
+// TestFindSplitBrain validates that only files whose every copy is blamed
+// are reported in split-brain
+func TestFindSplitBrain(t *testing.T) {
+	v := &Volinfo{ReplicaCount: 2, Bricks: []brick.Brickinfo{
+		{Hostname: "host1", Path: "/bricks/b1"},
+		{Hostname: "host2", Path: "/bricks/b1"},
+		{Hostname: "host1", Path: "/bricks/b2"},
+		{Hostname: "host2", Path: "/bricks/b2"},
+	}}
+
+	tests.Assert(t, len(FindSplitBrain(v, nil)) == 0)
+
+	now := time.Now()
+	heals := map[string][]PendingHeal{
+		v.Bricks[0].ID(): {
+			{GFID: "g1", Path: "/f1", Size: 10, Mtime: now, Pending: map[int][3]uint32{1: {1, 0, 0}}},
+			{GFID: "g2", Path: "/f2", Pending: map[int][3]uint32{1: {0, 1, 0}}},
+		},
+		v.Bricks[1].ID(): {
+			{GFID: "g1", Path: "/f1", Size: 20, Mtime: now, Pending: map[int][3]uint32{0: {2, 0, 0}}},
+		},
+		// Blames on bricks of another replica set don't count
+		v.Bricks[2].ID(): {
+			{GFID: "g3", Path: "/f3", Pending: map[int][3]uint32{1: {1, 0, 0}, 3: {1, 0, 0}}},
+		},
+	}
+
+	files := FindSplitBrain(v, heals)
+	tests.Assert(t, len(files) == 1)
+	tests.Assert(t, files[0].GFID == "g1" && files[0].Path == "/f1")
+	tests.Assert(t, len(files[0].Types) == 1 && files[0].Types[0] == SplitBrainData)
+	tests.Assert(t, len(files[0].Copies) == 2)
+	tests.Assert(t, find(files[0].Policies, SplitBrainPolicyBiggerFile))
+	tests.Assert(t, !find(files[0].Policies, SplitBrainPolicyLatestMtime))
+	tests.Assert(t, find(files[0].Policies, SplitBrainPolicySourceBrick))
+
+	tests.Assert(t, len(FindSplitBrain(&Volinfo{ReplicaCount: 1, Bricks: v.Bricks}, heals)) == 0)
+}
+
+// TestParsePendingXattrs validates decoding of the AFR changelog xattrs
+func TestParsePendingXattrs(t *testing.T) {
+	pending := parsePendingXattrs("vol1", map[string]string{
+		"trusted.afr.vol1-client-1": "0x000000020000000100000000",
+		"trusted.afr.vol1-client-2": "0x000000000000000000000000",
+		"trusted.afr.dirty":         "0x000000010000000000000000",
+		"trusted.afr.vol2-client-3": "0x000000010000000000000000",
+	})
+	tests.Assert(t, len(pending) == 1)
+	tests.Assert(t, pending[1] == [3]uint32{2, 1, 0})
+}
+
+// TestSplitBrainSource validates the choice of the copy a split-brain is
+// resolved with by each policy
+func TestSplitBrainSource(t *testing.T) {
+	now := time.Now()
+	f := &SplitBrainFile{
+		GFID:  "g1",
+		Types: []string{SplitBrainData, SplitBrainMetadata},
+		Copies: []SplitBrainCopy{
+			{BrickID: "b1", Size: 20, Mtime: now},
+			{BrickID: "b2", Size: 10, Mtime: now.Add(time.Second)},
+		},
+	}
+	f.Policies = splitBrainPolicies(f.Types, f.Copies)
+
+	c, types, err := SplitBrainSource(f, SplitBrainPolicyBiggerFile, "")
+	tests.Assert(t, err == nil && c.BrickID == "b1")
+	tests.Assert(t, len(types) == 1 && types[0] == SplitBrainData)
+
+	c, _, err = SplitBrainSource(f, SplitBrainPolicyLatestMtime, "")
+	tests.Assert(t, err == nil && c.BrickID == "b2")
+
+	c, types, err = SplitBrainSource(f, SplitBrainPolicySourceBrick, "b2")
+	tests.Assert(t, err == nil && c.BrickID == "b2" && len(types) == 2)
+
+	_, _, err = SplitBrainSource(f, SplitBrainPolicySourceBrick, "b3")
+	tests.Assert(t, err != nil)
+
+	// Copies of the same size leave no bigger file to choose
+	f.Copies[1].Size = 20
+	_, _, err = SplitBrainSource(f, SplitBrainPolicyBiggerFile, "")
+	tests.Assert(t, err != nil)
+}
+
+func TestCheckBrickDevice(t *testing.T) {
+	b := brick.Brickinfo{Hostname: "node1", Path: os.TempDir()}
+
+	// Not recorded
+	change, err := CheckBrickDevice(b)
+	tests.Assert(t, err == nil && change == nil)
+
+	b.DeviceID, err = BrickDeviceID(b)
+	tests.Assert(t, err == nil)
+	change, err = CheckBrickDevice(b)
+	tests.Assert(t, err == nil && change == nil)
+
+	recorded := b.DeviceID + 1
+	b.DeviceID = recorded
+	change, err = CheckBrickDevice(b)
+	tests.Assert(t, err == nil && change != nil)
+	tests.Assert(t, change.Recorded == recorded && change.Current == recorded-1)
+
+	b.Path = "/gd2-nonexistent"
+	_, err = CheckBrickDevice(b)
+	tests.Assert(t, err != nil)
+}
+
+func TestCompareGFIDCopies(t *testing.T) {
+	v := &Volinfo{ReplicaCount: 2, Bricks: []brick.Brickinfo{
+		{Hostname: "host1", Path: "/bricks/b1"},
+		{Hostname: "host2", Path: "/bricks/b1"},
+		{Hostname: "host1", Path: "/bricks/b2"},
+		{Hostname: "host2", Path: "/bricks/b2"},
+	}}
+
+	tests.Assert(t, CompareGFIDCopies(v, "g1", nil, nil) == nil)
+
+	lookup := CompareGFIDCopies(v, "g1", map[string]GFIDCopy{
+		v.Bricks[2].ID(): {Paths: []string{"/f1"}, Size: 10},
+		v.Bricks[3].ID(): {Paths: []string{"/f1"}, Size: 10},
+	}, nil)
+	tests.Assert(t, lookup.Consistent)
+	tests.Assert(t, len(lookup.ReplicaSets) == 1 && lookup.ReplicaSets[0].ReplicaSet == 1)
+	tests.Assert(t, len(lookup.Paths) == 1 && lookup.Paths[0] == "/f1")
+
+	lookup = CompareGFIDCopies(v, "g1", map[string]GFIDCopy{
+		v.Bricks[0].ID(): {Paths: []string{"/f1"}, Size: 10},
+		v.Bricks[1].ID(): {Paths: []string{"/f2"}, Size: 20},
+	}, nil)
+	tests.Assert(t, !lookup.Consistent)
+	tests.Assert(t, len(lookup.Paths) == 2)
+	mismatches := lookup.ReplicaSets[0].Mismatches
+	tests.Assert(t, len(mismatches) == 2 && find(mismatches, "path") && find(mismatches, "size"))
+
+	// Hard links are compared as sets of paths
+	lookup = CompareGFIDCopies(v, "g1", map[string]GFIDCopy{
+		v.Bricks[0].ID(): {Paths: []string{"/f1", "/l1"}, Size: 10},
+		v.Bricks[1].ID(): {Paths: []string{"/f1"}, Size: 10},
+	}, nil)
+	tests.Assert(t, !lookup.Consistent && len(lookup.Paths) == 2)
+	tests.Assert(t, len(lookup.ReplicaSets[0].Mismatches) == 1 && lookup.ReplicaSets[0].Mismatches[0] == "path")
+
+	// A missing copy is reported, unless its brick couldn't be looked up
+	lookup = CompareGFIDCopies(v, "g1", map[string]GFIDCopy{
+		v.Bricks[0].ID(): {Paths: []string{"/f1"}, Size: 10},
+	}, nil)
+	tests.Assert(t, !lookup.Consistent && len(lookup.ReplicaSets) == 1)
+	tests.Assert(t, len(lookup.ReplicaSets[0].Missing) == 1 && lookup.ReplicaSets[0].Missing[0] == v.Bricks[1].ID())
+	lookup = CompareGFIDCopies(v, "g1", map[string]GFIDCopy{
+		v.Bricks[0].ID(): {Paths: []string{"/f1"}, Size: 10},
+	}, []string{v.Bricks[1].ID()})
+	tests.Assert(t, lookup.Consistent && len(lookup.ReplicaSets[0].Missing) == 0)
+
+	// Directories are in every replica set, with sizes of their own
+	lookup = CompareGFIDCopies(v, "g1", map[string]GFIDCopy{
+		v.Bricks[0].ID(): {Paths: []string{"/d1"}, Dir: true, Size: 4096},
+		v.Bricks[1].ID(): {Paths: []string{"/d1"}, Dir: true, Size: 6},
+		v.Bricks[2].ID(): {Paths: []string{"/d1"}, Dir: true, Size: 4096},
+		v.Bricks[3].ID(): {Paths: []string{"/d1"}, Dir: true, Size: 4096},
+	}, nil)
+	tests.Assert(t, lookup.Consistent && len(lookup.ReplicaSets) == 2 && len(lookup.Paths) == 1)
+	lookup = CompareGFIDCopies(v, "g1", map[string]GFIDCopy{
+		v.Bricks[0].ID(): {Paths: []string{"/d1"}, Dir: true},
+		v.Bricks[1].ID(): {Paths: []string{"/d1"}, Dir: true},
+	}, nil)
+	tests.Assert(t, !lookup.Consistent && len(lookup.ReplicaSets) == 2)
+	tests.Assert(t, len(lookup.ReplicaSets[1].Copies) == 0 && len(lookup.ReplicaSets[1].Missing) == 2)
+}
+
+func TestThinArbiter(t *testing.T) {
+	ta, err := ParseThinArbiter("ta-node:/bricks/ta/")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, ta.Host == "ta-node" && ta.Port == DefaultThinArbiterPort && ta.Path == "/bricks/ta")
+
+	ta, err = ParseThinArbiter("ta-node:24010:/bricks/ta")
+	tests.Assert(t, err == nil && ta.Address() == "ta-node:24010")
+
+	for _, s := range []string{"ta-node", "/bricks/ta", ":/bricks/ta", "ta-node:port:/bricks/ta"} {
+		_, err = ParseThinArbiter(s)
+		tests.Assert(t, err != nil)
+	}
+
+	bricks := []brick.Brickinfo{
+		{Hostname: "10.0.0.1", Path: "/bricks/b1"},
+		{Hostname: "10.0.0.2", Path: "/bricks/b1"},
+	}
+	ta, _ = ParseThinArbiter("10.0.0.3:/bricks/ta")
+	tests.Assert(t, ValidateThinArbiter(ta, 2, bricks) == nil)
+	tests.Assert(t, ValidateThinArbiter(ta, 3, bricks) != nil)
+	ta, _ = ParseThinArbiter("10.0.0.2:/bricks/ta")
+	tests.Assert(t, ValidateThinArbiter(ta, 2, bricks) != nil)
+}
+
+func TestParseIOStatsDump(t *testing.T) {
+	dump := []byte(`{
+		"gluster.brick.aggr.read_bytes": "4096",
+		"gluster.brick.aggr.write_bytes": 8192,
+		"gluster.brick.aggr.fop.WRITE.count": "4",
+		"gluster.brick.aggr.fop.WRITE.latency_ave_usec": "10.5",
+		"gluster.brick.aggr.fop.WRITE.latency_min_usec": "2",
+		"gluster.brick.aggr.fop.WRITE.latency_max_usec": "30",
+		"gluster.brick.aggr.fop.READ.count": "0",
+		"gluster.brick.inter.read_bytes": "1024"
+	}`)
+	stats, err := parseIOStatsDump(dump)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, stats.ReadBytes == 4096 && stats.WriteBytes == 8192)
+	tests.Assert(t, len(stats.Fops) == 1 && stats.Fops["WRITE"].Count == 4)
+	tests.Assert(t, stats.Fops["WRITE"].TotalLatency == 42 && stats.Fops["WRITE"].AvgLatency() == 10.5)
+
+	baseline := IOStats{ReadBytes: 1024, WriteBytes: 8192, Fops: map[string]FopStats{"WRITE": {Count: 1, TotalLatency: 12}}}
+	since := stats.Since(baseline)
+	tests.Assert(t, since.ReadBytes == 3072 && since.WriteBytes == 0)
+	tests.Assert(t, since.Fops["WRITE"].Count == 3 && since.Fops["WRITE"].AvgLatency() == 10)
+
+	// A brick restarted since the baseline has lower counters
+	baseline.ReadBytes = 1 << 20
+	tests.Assert(t, stats.Since(baseline).ReadBytes == 4096)
+
+	_, err = parseIOStatsDump([]byte("not json"))
+	tests.Assert(t, err != nil)
+}
+
+func TestValidateNewReplicaSets(t *testing.T) {
+	n1, n2, n3 := uuid.NewRandom(), uuid.NewRandom(), uuid.NewRandom()
+	bricks := []brick.Brickinfo{
+		{NodeID: n1, Hostname: "n1", Path: "/b1"},
+		{NodeID: n1, Hostname: "n1", Path: "/b2"},
+		{NodeID: n1, Hostname: "n1", Path: "/b3"},
+		{NodeID: n2, Hostname: "n2", Path: "/b3"},
+		{NodeID: n2, Hostname: "n2", Path: "/b4"},
+		{NodeID: n3, Hostname: "n3", Path: "/b4"},
+	}
+
+	// The existing replica set on a single node isn't checked again
+	tests.Assert(t, ValidateNewReplicaSets(bricks, 2, 2) == nil)
+	tests.Assert(t, ValidateNewReplicaSets(bricks[:5], 2, 2) != nil)
+
+	err := ValidateNewReplicaSets(bricks, 3, 3)
+	rsErr, ok := err.(*ReplicaSetError)
+	tests.Assert(t, ok && rsErr.ReplicaSet == 1 && len(rsErr.Bricks) == 3)
+	tests.Assert(t, ValidateNewReplicaSets(bricks, 3, 6) == nil)
+	tests.Assert(t, ValidateNewReplicaSets(bricks, 1, 0) == nil)
+}
+
+func TestSubdirExports(t *testing.T) {
+	for _, p := range []string{"dir", "/a/../../b", "/a b", "/a,b"} {
+		_, err := ValidateSubdirExport(SubdirExport{Path: p, Clients: []string{"*"}})
+		tests.Assert(t, err != nil)
+	}
+	_, err := ValidateSubdirExport(SubdirExport{Path: "/a", Clients: []string{"10.0.0.1|10.0.0.2"}})
+	tests.Assert(t, err != nil)
+	_, err = ValidateSubdirExport(SubdirExport{Path: "/a"})
+	tests.Assert(t, err != nil)
+
+	e, err := ValidateSubdirExport(SubdirExport{Path: "/tenant1/", Clients: []string{"10.0.0.*", "client1"}})
+	tests.Assert(t, err == nil && e.Path == "/tenant1")
+
+	v := &Volinfo{Options: make(map[string]string)}
+	v.SetSubdirExports([]SubdirExport{e})
+	tests.Assert(t, v.Options[AuthAllowOption] == "/tenant1(10.0.0.*|client1)")
+
+	tests.Assert(t, v.CheckSubdirExportConflict(SubdirExport{Path: "/tenant1"}) != nil)
+	tests.Assert(t, v.CheckSubdirExportConflict(SubdirExport{Path: "/tenant1/a"}) != nil)
+	tests.Assert(t, v.CheckSubdirExportConflict(SubdirExport{Path: "/tenant2"}) == nil)
+	tests.Assert(t, v.CheckSubdirExportConflict(SubdirExport{Path: "/"}) == nil)
+
+	v.SetSubdirExports(append(v.SubdirExports, SubdirExport{Path: "/", Clients: []string{"admin"}}))
+	tests.Assert(t, v.Options[AuthAllowOption] == "/(admin),/tenant1(10.0.0.*|client1)")
+	tests.Assert(t, v.CheckSubdirExportConflict(SubdirExport{Path: "/tenant2"}) == nil)
+
+	v.SetSubdirExports(nil)
+	_, ok := v.Options[AuthAllowOption]
+	tests.Assert(t, !ok)
+}
+
+func TestApplyDefaultOptions(t *testing.T) {
+	v := &Volinfo{Options: map[string]string{"afr.eager-lock": "off"}}
+	v.ApplyDefaultOptions(map[string]string{
+		"afr.eager-lock":            "on",
+		"io-cache.cache-size":       "64MB",
+		"write-behind.flush-behind": "on",
+	})
+	tests.Assert(t, v.Options["afr.eager-lock"] == "off")
+	tests.Assert(t, v.Options["io-cache.cache-size"] == "64MB")
+	tests.Assert(t, len(v.DefaultOptions) == 2 && v.DefaultOptions[0] == "io-cache.cache-size")
+
+	v.ClearDefaultOption("io-cache.cache-size")
+	tests.Assert(t, len(v.DefaultOptions) == 1 && v.DefaultOptions[0] == "write-behind.flush-behind")
+}
+
+func TestOptionChanges(t *testing.T) {
+	v := &Volinfo{
+		Options: map[string]string{
+			"afr.eager-lock":      "off",
+			"io-cache.cache-size": "32MB",
+		},
+		DefaultOptions: []string{"io-cache.cache-size"},
+	}
+	v.ApplyRecommendedOptions(map[string]string{
+		"dht.lookup-optimize":      "off",
+		"io-cache.cache-size":      "16MB",
+		"write-behind.window-size": "1MB",
+	}, 30000)
+	tests.Assert(t, v.Options["io-cache.cache-size"] == "32MB" && v.OptionsOpVersion == 30000)
+	tests.Assert(t, len(v.RecommendedOptions) == 2 && v.RecommendedOptions[0] == "dht.lookup-optimize")
+	v.Options["write-behind.window-size"] = "4MB"
+	v.ClearDefaultOption("write-behind.window-size")
+
+	changes, skipped := v.OptionChanges(map[string]string{
+		"afr.eager-lock":            "on",
+		"io-cache.cache-size":       "64MB",
+		"dht.lookup-optimize":       "on",
+		"write-behind.flush-behind": "on",
+		"write-behind.window-size":  "2MB",
+	})
+	tests.Assert(t, len(changes) == 2)
+	tests.Assert(t, changes[0].Option == "dht.lookup-optimize" && changes[0].Current == "off")
+	tests.Assert(t, changes[1].Option == "write-behind.flush-behind" && changes[1].Current == "")
+	// Options set explicitly or from the cluster defaults are left alone
+	tests.Assert(t, len(skipped) == 3 && skipped[0].Option == "afr.eager-lock")
+	tests.Assert(t, skipped[1].Option == "io-cache.cache-size" && skipped[2].Option == "write-behind.window-size")
+
+	v.ApplyOptionChanges(changes, 40100)
+	tests.Assert(t, v.Options["dht.lookup-optimize"] == "on" && v.Options["io-cache.cache-size"] == "32MB")
+	tests.Assert(t, v.Options["afr.eager-lock"] == "off" && v.OptionsOpVersion == 40100)
+	tests.Assert(t, len(v.RecommendedOptions) == 2 && v.RecommendedOptions[1] == "write-behind.flush-behind")
+	tests.Assert(t, len(v.DefaultOptions) == 1)
+}
+
+func TestPlacementConstraints(t *testing.T) {
+	nodes := []uuid.UUID{uuid.NewRandom(), uuid.NewRandom(), uuid.NewRandom(), uuid.NewRandom()}
+	labels := map[string]map[string]string{
+		nodes[0].String(): {"rack": "r1", "disk": "ssd"},
+		nodes[1].String(): {"rack": "r1", "disk": "hdd"},
+		nodes[2].String(): {"rack": "r2", "disk": "ssd"},
+		nodes[3].String(): {"disk": "ssd"},
+	}
+	var bricks []brick.Brickinfo
+	for i, n := range nodes {
+		bricks = append(bricks, brick.Brickinfo{NodeID: n, Hostname: fmt.Sprintf("host%d", i), Path: "/b"})
+	}
+
+	var none *PlacementConstraints
+	_, err := none.Check(bricks, 2, 0, labels)
+	tests.Assert(t, err == nil)
+
+	c := &PlacementConstraints{Require: map[string]string{"disk": "ssd"}}
+	_, err = c.Check(bricks, 2, 0, labels)
+	tests.Assert(t, err != nil && err.(*PlacementError).Constraint == "require")
+	_, err = c.Check(bricks, 2, 2, labels)
+	tests.Assert(t, err == nil)
+
+	c = &PlacementConstraints{SpreadBy: "rack", Prefer: map[string]string{"disk": "ssd"}}
+	_, err = c.Check(bricks[:2], 2, 0, labels)
+	tests.Assert(t, err != nil && err.(*PlacementError).Constraint == "spread-by")
+	_, err = c.Check(bricks[2:], 2, 0, labels)
+	tests.Assert(t, err != nil && strings.Contains(err.Error(), "no rack label"))
+
+	unpreferred, err := c.Check([]brick.Brickinfo{bricks[0], bricks[2]}, 2, 0, labels)
+	tests.Assert(t, err == nil && len(unpreferred) == 0)
+	unpreferred, err = c.Check([]brick.Brickinfo{bricks[1], bricks[2]}, 2, 0, labels)
+	tests.Assert(t, err == nil && len(unpreferred) == 1 && unpreferred[0] == "host1:/b")
+}
+
+func TestBrickPathPolicy(t *testing.T) {
+	var policy BrickPathPolicy
+	tests.Assert(t, policy.Validate() == nil)
+	tests.Assert(t, policy.Check("/any/path/at/all") == nil)
+
+	policy = BrickPathPolicy{
+		MaxDepth:            3,
+		MaxLength:           24,
+		RequiredPrefix:      "/bricks/",
+		ForbiddenSubstrings: []string{"tmp"},
+	}
+	tests.Assert(t, policy.Validate() == nil)
+	tests.Assert(t, policy.Check("/bricks/vol1/b1") == nil)
+	for _, p := range []string{"/data/vol1/b1", "/bricksx/b1", "/bricks/vol1/b1/sub", "/bricks/a-very-long-name/b1", "/bricks/tmp1"} {
+		_, ok := policy.Check(p).(*BrickPathPolicyError)
+		tests.Assert(t, ok)
+	}
+
+	for _, bad := range []BrickPathPolicy{{MaxDepth: -1}, {MaxLength: -1}, {RequiredPrefix: "bricks"}, {ForbiddenSubstrings: []string{""}}} {
+		tests.Assert(t, bad.Validate() != nil)
+	}
+}
+
+func TestBrickIndexOps(t *testing.T) {
+	node1, node2 := uuid.NewRandom(), uuid.NewRandom()
+	old := &Volinfo{ID: uuid.NewRandom(), Name: "vol", Bricks: []brick.Brickinfo{
+		{NodeID: node1, Hostname: "host1", Path: "/b1"},
+		{NodeID: node2, Hostname: "host2", Path: "/b2"},
+	}}
+	new := *old
+	new.Bricks = []brick.Brickinfo{old.Bricks[0], {NodeID: node2, Hostname: "host2", Path: "/b3"}}
+
+	ops, err := brickIndexOps(old, &new)
+	tests.Assert(t, err == nil && len(ops) == 2)
+	tests.Assert(t, ops[0].IsDelete() && string(ops[0].KeyBytes()) == brickIndexKey(node2, old.Bricks[1].ID()))
+	// The unchanged brick isn't written again
+	tests.Assert(t, ops[1].IsPut() && string(ops[1].KeyBytes()) == brickIndexKey(node2, new.Bricks[1].ID()))
+
+	ops, err = brickIndexOps(old, old)
+	tests.Assert(t, err == nil && len(ops) == 0)
+
+	ops, err = brickIndexOps(old, nil)
+	tests.Assert(t, err == nil && len(ops) == 2)
+	for _, op := range ops {
+		tests.Assert(t, op.IsDelete())
+	}
+}